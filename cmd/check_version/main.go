@@ -4,6 +4,8 @@ import (
 	"context"
 	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/hexiosec/asm-cloud-connector/internal/config"
 	"github.com/hexiosec/asm-cloud-connector/internal/http"
@@ -16,6 +18,7 @@ import (
 
 var (
 	debugMode = flag.Bool("debug", false, "Enable debug output")
+	channel   = flag.String("channel", version.ChannelStable, "Release channel: stable, or beta to include pre-releases")
 )
 
 func main() {
@@ -49,12 +52,16 @@ func main() {
 
 	// Main
 	logger.GetGlobalLogger().Info().Msg("Starting version check")
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	cfg := &config.Config{}
 
-	http := http.NewHttpService(cfg, "hexiosec-cloud-connector")
-	checker, err := version.NewChecker(http)
+	http, err := http.NewHttpService(cfg, version.UserAgent())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not init HTTP service")
+	}
+	checker, err := version.NewChecker(http, version.NewFileCache(version.DefaultCachePath()), *channel)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Could not init version checker")
 	}
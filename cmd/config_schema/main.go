@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+var (
+	outputPath = flag.String("output", "", "Path to write the schema to, defaults to stdout")
+)
+
+func main() {
+	flag.Parse()
+
+	schema, err := config.Schema()
+	if err != nil {
+		logger.GetGlobalLogger().Fatal().Err(err).Msg("failed to generate schema")
+	}
+
+	if *outputPath == "" {
+		if _, err := os.Stdout.Write(schema); err != nil {
+			logger.GetGlobalLogger().Fatal().Err(err).Msg("failed to write schema")
+		}
+		return
+	}
+
+	if err := os.WriteFile(*outputPath, schema, 0644); err != nil {
+		logger.GetGlobalLogger().Fatal().Err(err).Msg("failed to write schema")
+	}
+}
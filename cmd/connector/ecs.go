@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/connector"
+	"github.com/hexiosec/asm-cloud-connector/internal/ecs"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/pkg/core"
+)
+
+// ecsStopMargin is kept from the ECS stop timeout for notifying and logging the report once the
+// seeds in flight finish
+const ecsStopMargin = 5 * time.Second
+
+// ecsCmd runs the ecs subcommand, a single sync tuned for ECS scheduled tasks, and returns the exit
+// code. Logs are tagged with the task, seeds in flight finish within the stop timeout of the task,
+// and the report is logged as a single event.
+func ecsCmd(args []string) int {
+	flags := flag.NewFlagSet("ecs", flag.ExitOnError)
+	debugMode := flags.Bool("debug", false, "Enable debug output")
+	quietMode := flags.Bool("quiet", false, "Only log errors and the sync report")
+	cfgFilePath := flags.String("config", "./config.yml", "Path to config YAML")
+	_ = flags.Parse(args)
+
+	core.SetCfgFilePath(*cfgFilePath)
+	core.SetDebugMode(*debugMode)
+	core.SetQuietMode(*quietMode)
+
+	if err := core.Setup(); err != nil {
+		logger.GetGlobalLogger().Error().Err(err).Msg("failed to setup")
+		return core.ExitCode(err)
+	}
+
+	// ECS sends SIGTERM when the task is stopped, and SIGKILL once the stop timeout passes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	metadata, ok, err := ecs.ReadTaskMetadata(ctx)
+	switch {
+	case !ok:
+		logger.GetGlobalLogger().Warn().Msg("ECS_CONTAINER_METADATA_URI_V4 not set, not running in an ECS task")
+	case err != nil:
+		logger.GetGlobalLogger().Warn().Err(err).Msg("Could not read ECS task metadata, logs aren't tagged with the task")
+	default:
+		ctx = core.WithLogFields(ctx, metadata.LogFields())
+	}
+
+	grace := max(ecs.StopTimeout()-ecsStopMargin, time.Second)
+	ctx = connector.WithInFlightGrace(ctx, grace)
+
+	start := time.Now()
+	result, err := core.RunWithResult(ctx)
+	code := core.ExitCode(err)
+
+	// One event, so the outcome of a task can be found and alarmed on in CloudWatch Logs
+	event := logger.Summary(ctx).Info()
+	if err != nil {
+		event = logger.Summary(ctx).Error().Err(err)
+	}
+	if metadata != nil {
+		for key, value := range metadata.LogFields() {
+			event = event.Str(key, value)
+		}
+	}
+	event.
+		Bool("success", err == nil).
+		Int("exit_code", code).
+		Dur("duration", time.Since(start)).
+		Interface("report", result).
+		Msg("Sync report")
+	return code
+}
@@ -2,27 +2,618 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/config/source"
+	connector_http "github.com/hexiosec/asm-cloud-connector/internal/http"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/progress"
+	"github.com/hexiosec/asm-cloud-connector/internal/version"
 	"github.com/hexiosec/asm-cloud-connector/pkg/core"
 )
 
 var (
 	debugMode   = flag.Bool("debug", false, "Enable debug output")
+	quietMode   = flag.Bool("quiet", false, "Only log errors and the summary of each run")
 	cfgFilePath = flag.String("config", "./config.yml", "Path to config YAML")
+	daemonMode  = flag.Bool("daemon", false, "Sync on the schedule of the config until stopped")
+	cfgDir      = flag.String("config-dir", "", "Directory of configs to sync each of, rather than --config")
+	parallel    = flag.Int("parallel", 1, "Configs of --config-dir to sync at a time")
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(validate(os.Args[2:]))
+		case "config":
+			os.Exit(configCmd(os.Args[2:]))
+		case "init":
+			os.Exit(initCmd(os.Args[2:]))
+		case "discover":
+			os.Exit(discoverCmd(os.Args[2:]))
+		case "preflight":
+			os.Exit(preflightCmd(os.Args[2:]))
+		case "policy":
+			os.Exit(policyCmd(os.Args[2:]))
+		case "version":
+			os.Exit(versionCmd(os.Args[2:]))
+		case "self-update":
+			os.Exit(selfUpdateCmd(os.Args[2:]))
+		case "ecs":
+			os.Exit(ecsCmd(os.Args[2:]))
+		case "service":
+			os.Exit(serviceCmd(os.Args[2:]))
+		default:
+			// Not a subcommand, the arguments are the flags of a sync run
+		}
+	}
+
 	flag.Parse()
+	if *cfgDir != "" && *daemonMode {
+		fmt.Fprintln(os.Stderr, "--config-dir can't be used with --daemon")
+		flag.Usage()
+		os.Exit(core.ExitUsage)
+	}
+	if *quietMode && *debugMode {
+		fmt.Fprintln(os.Stderr, "--quiet can't be used with --debug")
+		flag.Usage()
+		os.Exit(core.ExitUsage)
+	}
+	core.SetCfgFilePath(*cfgFilePath)
+	core.SetDebugMode(*debugMode)
+	core.SetQuietMode(*quietMode)
+
+	// Debugging a run in a terminal shows its progress below the logs. A config directory's
+	// configs log elsewhere, so the display couldn't keep its lines below them.
+	var display *progress.Display
+	if *debugMode && *cfgDir == "" && progress.IsTerminal(os.Stdout) {
+		display = progress.NewDisplay(os.Stdout)
+		core.SetLogOutput(display)
+	}
+
+	if err := core.Setup(); err != nil {
+		logger.GetGlobalLogger().Error().Err(err).Msg("failed to setup")
+		os.Exit(core.ExitCode(err))
+	}
+
+	// SIGTERM stops the daemon, or a run, between seeds
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if display != nil {
+		ctx = progress.WithReporter(ctx, display)
+	}
+
+	// Run as a Windows service if started by the service control manager, which stops it as
+	// SIGTERM does
+	code, ok := runWindowsService(ctx, run)
+	if !ok {
+		code = run(ctx)
+	}
+	if display != nil {
+		display.Close()
+	}
+	stop()
+	os.Exit(code)
+}
+
+// run syncs the configs of --config-dir, or starts a sync or daemon with --config, and returns the
+// exit code
+func run(ctx context.Context) int {
+	if *cfgDir != "" {
+		if _, err := core.RunConfigDir(ctx, *cfgDir, *parallel); err != nil {
+			logger.GetGlobalLogger().Error().Err(err).Msg("failed to run")
+			return core.ExitCode(err)
+		}
+		return core.ExitOK
+	}
+
+	if err := core.Start(ctx, *daemonMode); err != nil {
+		logger.GetGlobalLogger().Error().Err(err).Msg("failed to run")
+		return core.ExitCode(err)
+	}
+	return core.ExitOK
+}
+
+// validate runs the validate subcommand, which checks the config without calling any cloud
+// provider or ASM API, and returns the exit code
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	cfgFilePath := flags.String("config", "./config.yml", "Path to config YAML")
+	_ = flags.Parse(args)
+
+	core.SetCfgFilePath(*cfgFilePath)
+	// Keep stdout for the result
+	core.SetLogOutput(os.Stderr)
+
+	if err := core.Setup(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to setup: %v\n", err)
+		return core.ExitCode(err)
+	}
+
+	err := core.Validate()
+
+	var validationErrs config.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		fmt.Fprintln(os.Stderr, "Config is invalid:")
+		for _, fieldErr := range validationErrs {
+			fmt.Fprintf(os.Stderr, "  %s\n", fieldErr)
+		}
+		return core.ExitCode(err)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Config is invalid: %v\n", err)
+		return core.ExitCode(err)
+	}
+
+	fmt.Fprintln(os.Stdout, "Config is valid")
+	return core.ExitOK
+}
+
+// configCmd runs the config subcommands and returns the exit code. config print writes the
+// effective config, merged and defaulted with credentials redacted, to stdout.
+func configCmd(args []string) int {
+	if len(args) > 0 && args[0] == "encrypt" {
+		return configEncryptCmd(args[1:])
+	}
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: connector config print [--config <path>]")
+		fmt.Fprintln(os.Stderr, "       connector config encrypt --key <kms key> [--config <path>]")
+		return core.ExitUsage
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ExitOnError)
+	cfgFilePath := flags.String("config", "./config.yml", "Path to config YAML")
+	_ = flags.Parse(args[1:])
+
+	core.SetCfgFilePath(*cfgFilePath)
+	// Keep stdout for the config
+	core.SetLogOutput(os.Stderr)
+
+	if err := core.Setup(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to setup: %v\n", err)
+		return core.ExitCode(err)
+	}
+
+	out, err := core.EffectiveConfig()
+
+	var validationErrs config.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		fmt.Fprintln(os.Stderr, "Config is invalid:")
+		for _, fieldErr := range validationErrs {
+			fmt.Fprintf(os.Stderr, "  %s\n", fieldErr)
+		}
+		return core.ExitCode(err)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Config is invalid: %v\n", err)
+		return core.ExitCode(err)
+	}
+
+	_, _ = os.Stdout.Write(out)
+	return core.ExitOK
+}
+
+// configEncryptCmd runs config encrypt, which writes the config file encrypted with a KMS key to
+// stdout, as the value of CONNECTOR_CONFIG
+func configEncryptCmd(args []string) int {
+	flags := flag.NewFlagSet("config encrypt", flag.ExitOnError)
+	cfgFilePath := flags.String("config", "./config.yml", "Path to config YAML")
+	key := flags.String("key", "", "KMS key: kms://aws-kms/<key ID, ARN or alias>, kms://gcp-kms/<key name> or kms://azure-kv/<vault>/<key>")
+	_ = flags.Parse(args)
+
+	if *key == "" {
+		fmt.Fprintln(os.Stderr, "--key is required")
+		flags.Usage()
+		return core.ExitUsage
+	}
+
+	plaintext, err := os.ReadFile(*cfgFilePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read config: %v\n", err)
+		return core.ExitConfig
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	value, err := source.EncryptKMS(ctx, *key, plaintext)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encrypt config: %v\n", err)
+		return core.ExitFailure
+	}
+
+	fmt.Println(value)
+	return core.ExitOK
+}
+
+// initCmd runs the init subcommand, which writes a commented starter config for the chosen
+// providers, and returns the exit code
+func initCmd(args []string) int {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	providers := flags.String("provider", "", "Comma separated providers to configure: "+strings.Join(config.SampleProviders, ", "))
+	outputPath := flags.String("output", "", "Path to write the config to, defaults to stdout")
+	_ = flags.Parse(args)
+
+	var selected []string
+	for _, provider := range strings.Split(*providers, ",") {
+		if provider = strings.TrimSpace(provider); provider != "" {
+			selected = append(selected, provider)
+		}
+	}
+
+	sample, err := config.Sample(selected)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		flags.Usage()
+		return core.ExitUsage
+	}
+
+	if *outputPath == "" {
+		_, _ = os.Stdout.Write(sample)
+		return core.ExitOK
+	}
+
+	// Don't overwrite an existing config
+	f, err := os.OpenFile(*outputPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create config: %v\n", err)
+		return core.ExitFailure
+	}
+	defer f.Close()
+
+	if _, err := f.Write(sample); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write config: %v\n", err)
+		return core.ExitFailure
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *outputPath)
+	return core.ExitOK
+}
+
+// discoverCmd runs the discover subcommand, which prints the resources of every enabled profile,
+// normalised as they would be synced, without calling the ASM API, and returns the exit code
+func discoverCmd(args []string) int {
+	flags := flag.NewFlagSet("discover", flag.ExitOnError)
+	cfgFilePath := flags.String("config", "./config.yml", "Path to config YAML")
+	debugMode := flags.Bool("debug", false, "Enable debug output")
+	format := flags.String("format", "text", "Output format: text, json or csv")
+	_ = flags.Parse(args)
+
+	if !slices.Contains([]string{"text", "json", "csv"}, *format) {
+		fmt.Fprintf(os.Stderr, "unknown format %q, expected text, json or csv\n", *format)
+		flags.Usage()
+		return core.ExitUsage
+	}
+
+	core.SetCfgFilePath(*cfgFilePath)
+	core.SetDebugMode(*debugMode)
+	// Keep stdout for the resources
+	core.SetLogOutput(os.Stderr)
+
+	if err := core.Setup(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to setup: %v\n", err)
+		return core.ExitCode(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	discovered, err := core.Discover(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to discover resources: %v\n", err)
+		return core.ExitCode(err)
+	}
+
+	if err := printResources(os.Stdout, discovered, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print resources: %v\n", err)
+		return core.ExitFailure
+	}
+	return core.ExitOK
+}
+
+// printResources writes the discovered resources in format. Text is one resource per line,
+// deduplicated across profiles, so it can be piped into other tools.
+func printResources(w io.Writer, discovered []core.ProfileResources, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(discovered)
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"provider", "profile", "resource"})
+		for _, profile := range discovered {
+			for _, resource := range profile.Resources {
+				_ = cw.Write([]string{profile.Provider, profile.Profile, resource})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		var resources []string
+		for _, profile := range discovered {
+			resources = append(resources, profile.Resources...)
+		}
+		slices.Sort(resources)
+		for _, resource := range slices.Compact(resources) {
+			if _, err := fmt.Fprintln(w, resource); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// preflightCmd runs the preflight subcommand, which checks the access a run needs with the
+// smallest call of each check, and returns the exit code, 1 if any check failed
+func preflightCmd(args []string) int {
+	flags := flag.NewFlagSet("preflight", flag.ExitOnError)
+	cfgFilePath := flags.String("config", "./config.yml", "Path to config YAML")
+	debugMode := flags.Bool("debug", false, "Enable debug output")
+	format := flags.String("format", "text", "Output format: text or json")
+	_ = flags.Parse(args)
+
+	if !slices.Contains([]string{"text", "json"}, *format) {
+		fmt.Fprintf(os.Stderr, "unknown format %q, expected text or json\n", *format)
+		flags.Usage()
+		return core.ExitUsage
+	}
+
 	core.SetCfgFilePath(*cfgFilePath)
 	core.SetDebugMode(*debugMode)
+	// Keep stdout for the checks
+	core.SetLogOutput(os.Stderr)
+
+	if err := core.Setup(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to setup: %v\n", err)
+		return core.ExitCode(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	checks, err := core.Preflight(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to run preflight checks: %v\n", err)
+		return core.ExitCode(err)
+	}
+
+	if err := printChecks(os.Stdout, checks, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print checks: %v\n", err)
+		return core.ExitFailure
+	}
+
+	if slices.ContainsFunc(checks, func(check core.PreflightCheck) bool { return !check.Passed }) {
+		return core.ExitFailure
+	}
+	return core.ExitOK
+}
+
+// printChecks writes the preflight checks in format. Text is a pass/fail matrix, followed by the
+// error and remediation hint of each failed check.
+func printChecks(w io.Writer, checks []core.PreflightCheck, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(checks)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESULT\tPROVIDER\tPROFILE\tCHECK")
+	var failed []core.PreflightCheck
+	for _, check := range checks {
+		result := "PASS"
+		if !check.Passed {
+			result = "FAIL"
+			failed = append(failed, check)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result, check.Provider, check.Profile, check.Check)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, check := range failed {
+		name := strings.Join(slices.DeleteFunc([]string{check.Provider, check.Profile, check.Check}, func(s string) bool { return s == "" }), "/")
+		if _, err := fmt.Fprintf(w, "\n%s\n  error: %s\n  hint: %s\n", name, check.Error, check.Hint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// policyCmd runs the policy subcommand, which prints the least privilege policies the enabled
+// service checks of each profile need, without calling any cloud provider or ASM API, and returns
+// the exit code
+func policyCmd(args []string) int {
+	flags := flag.NewFlagSet("policy", flag.ExitOnError)
+	cfgFilePath := flags.String("config", "./config.yml", "Path to config YAML")
+	format := flags.String("format", "text", "Output format: text or json")
+	_ = flags.Parse(args)
+
+	if !slices.Contains([]string{"text", "json"}, *format) {
+		fmt.Fprintf(os.Stderr, "unknown format %q, expected text or json\n", *format)
+		flags.Usage()
+		return core.ExitUsage
+	}
+
+	core.SetCfgFilePath(*cfgFilePath)
+	// Keep stdout for the policies
+	core.SetLogOutput(os.Stderr)
+
+	if err := core.Setup(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to setup: %v\n", err)
+		return core.ExitCode(err)
+	}
+
+	profiles, err := core.Policies()
+	var validationErrs config.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		fmt.Fprintln(os.Stderr, "Config is invalid:")
+		for _, fieldErr := range validationErrs {
+			fmt.Fprintf(os.Stderr, "  %s\n", fieldErr)
+		}
+		return core.ExitCode(err)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "failed to generate policies: %v\n", err)
+		return core.ExitCode(err)
+	}
+
+	if err := printPolicies(os.Stdout, profiles, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print policies: %v\n", err)
+		return core.ExitFailure
+	}
+	return core.ExitOK
+}
+
+// printPolicies writes the policies of each profile in format. Text is each policy document,
+// headed by a comment naming its profile, so a document can be copied as is.
+func printPolicies(w io.Writer, profiles []core.ProfilePolicies, format string) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	// Keep placeholders such as <subscription-id> readable
+	enc.SetEscapeHTML(false)
+	if format == "json" {
+		return enc.Encode(profiles)
+	}
+
+	for _, profile := range profiles {
+		for _, policy := range profile.Policies {
+			if _, err := fmt.Fprintf(w, "# %s/%s %s\n", profile.Provider, profile.Profile, policy.Name); err != nil {
+				return err
+			}
+			if err := enc.Encode(policy.Document); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// versionCmd runs the version subcommand, which prints the build information of the binary, and
+// returns the exit code
+func versionCmd(args []string) int {
+	flags := flag.NewFlagSet("version", flag.ExitOnError)
+	format := flags.String("format", "text", "Output format: text or json")
+	_ = flags.Parse(args)
+
+	if !slices.Contains([]string{"text", "json"}, *format) {
+		fmt.Fprintf(os.Stderr, "unknown format %q, expected text or json\n", *format)
+		flags.Usage()
+		return core.ExitUsage
+	}
+
+	if err := printVersion(os.Stdout, version.BuildInfo(), *format); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print version: %v\n", err)
+		return core.ExitFailure
+	}
+	return core.ExitOK
+}
+
+// selfUpdateCmd runs the self-update subcommand, which replaces the binary with the latest release
+// for the current OS and architecture once its checksum and signature are verified, and returns the
+// exit code
+func selfUpdateCmd(args []string) int {
+	flags := flag.NewFlagSet("self-update", flag.ExitOnError)
+	debugMode := flags.Bool("debug", false, "Enable debug output")
+	channel := flags.String("channel", version.ChannelStable, "Release channel: stable, or beta to include pre-releases")
+	_ = flags.Parse(args)
+
+	if !slices.Contains([]string{version.ChannelStable, version.ChannelBeta}, *channel) {
+		fmt.Fprintf(os.Stderr, "unknown channel %q, expected stable or beta\n", *channel)
+		flags.Usage()
+		return core.ExitUsage
+	}
+
+	core.SetDebugMode(*debugMode)
+	// Keep stdout for the outcome
+	core.SetLogOutput(os.Stderr)
 
 	if err := core.Setup(); err != nil {
-		logger.GetGlobalLogger().Fatal().Err(err).Msg("failed to setup")
+		fmt.Fprintf(os.Stderr, "failed to setup: %v\n", err)
+		return core.ExitCode(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	executable, err := os.Executable()
+	if err == nil {
+		executable, err = filepath.EvalSymlinks(executable)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to find the binary to update: %v\n", err)
+		return core.ExitFailure
 	}
 
-	if err := core.Run(context.Background()); err != nil {
-		logger.GetGlobalLogger().Fatal().Err(err).Msg("failed to run")
+	client, err := connector_http.NewHttpService(&config.Config{}, version.UserAgent())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create http service: %v\n", err)
+		return core.ExitFailure
+	}
+	// Not cached, so a release published since the last run is found
+	checker, err := version.NewChecker(client, nil, *channel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create version checker: %v\n", err)
+		return core.ExitFailure
+	}
+
+	update, err := checker.SelfUpdate(ctx, version.UpdateOptions{Executable: executable})
+	if errors.Is(err, version.ErrNoReleaseKey) {
+		fmt.Fprintf(os.Stderr, "failed to update: %v, download the release from GitHub instead\n", err)
+		return core.ExitFailure
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to update: %v\n", err)
+		return core.ExitFailure
 	}
+
+	if !update.Updated {
+		fmt.Printf("Already running the latest version, %s\n", update.Current)
+		return core.ExitOK
+	}
+	fmt.Printf("Updated %s from %s to %s\n", executable, update.Current, update.Latest)
+	return core.ExitOK
+}
+
+// printVersion writes the build information in format, text or json
+func printVersion(w io.Writer, info version.Info, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+
+	commit := info.Commit
+	if commit == "" {
+		commit = "unknown"
+	} else if info.Modified {
+		commit += " (modified)"
+	}
+	date := info.Date
+	if date == "" {
+		date = "unknown"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Version:\t%s\n", info.Version)
+	fmt.Fprintf(tw, "Commit:\t%s\n", commit)
+	fmt.Fprintf(tw, "Built:\t%s\n", date)
+	fmt.Fprintf(tw, "Go version:\t%s\n", info.GoVersion)
+	return tw.Flush()
 }
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/hexiosec/asm-cloud-connector/pkg/core"
+)
+
+// runWindowsService only runs as a service on Windows
+func runWindowsService(_ context.Context, _ func(ctx context.Context) int) (code int, ok bool) {
+	return 0, false
+}
+
+// serviceCmd only installs a service on Windows, systemd services are installed with a unit file
+func serviceCmd(_ []string) int {
+	fmt.Fprintln(os.Stderr, "service install and uninstall are only supported on Windows, install a systemd unit instead, see docs/deploy-service.md")
+	return core.ExitUsage
+}
@@ -0,0 +1,159 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/pkg/core"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// defaultServiceName is the name the service is installed as
+const defaultServiceName = "asm-cloud-connector"
+
+// windowsService runs the connector as a Windows service, stopping it when the service is stopped
+type windowsService struct {
+	ctx  context.Context
+	run  func(ctx context.Context) int
+	code int
+}
+
+// runWindowsService runs run as a Windows service if the process was started by the service
+// control manager, returning its exit code. ok is false if it wasn't started as a service.
+func runWindowsService(ctx context.Context, run func(ctx context.Context) int) (code int, ok bool) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return 0, false
+	}
+
+	s := &windowsService{ctx: ctx, run: run}
+	// The name is ignored for services that run in their own process
+	if err := svc.Run(defaultServiceName, s); err != nil {
+		return core.ExitFailure, true
+	}
+	return s.code, true
+}
+
+// Execute runs the connector until it stops or the service is stopped
+func (s *windowsService) Execute(_ []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	done := make(chan int, 1)
+	go func() {
+		done <- s.run(ctx)
+	}()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case code := <-done:
+			s.code = code
+			changes <- svc.Status{State: svc.Stopped}
+			// A non-zero code is reported as a service specific exit code
+			return code != core.ExitOK, uint32(code)
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				// Stopped between seeds, as by SIGTERM
+				changes <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
+
+// serviceCmd runs the service subcommand, which installs or uninstalls the connector as a Windows
+// service, and returns the exit code
+func serviceCmd(args []string) int {
+	if len(args) == 0 || (args[0] != "install" && args[0] != "uninstall") {
+		fmt.Fprintln(os.Stderr, "usage: connector service install|uninstall [flags]")
+		return core.ExitUsage
+	}
+
+	flags := flag.NewFlagSet("service "+args[0], flag.ExitOnError)
+	name := flags.String("name", defaultServiceName, "Name of the service")
+	cfgFilePath := flags.String("config", "./config.yml", "Path to config YAML, made absolute as services run in the system directory")
+	_ = flags.Parse(args[1:])
+
+	var err error
+	if args[0] == "install" {
+		err = installService(*name, *cfgFilePath)
+	} else {
+		err = uninstallService(*name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to %s service: %v\n", args[0], err)
+		return core.ExitFailure
+	}
+	fmt.Printf("Service %s %sed\n", *name, args[0])
+	return core.ExitOK
+}
+
+// installService installs the connector as an automatically started service, running as a daemon
+// with the config at cfgFilePath
+func installService(name string, cfgFilePath string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not find executable, %w", err)
+	}
+	cfgFilePath, err = filepath.Abs(cfgFilePath)
+	if err != nil {
+		return fmt.Errorf("could not resolve config path, %w", err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("could not connect to service control manager, %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return errors.New("service already exists")
+	}
+
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: "Hexiosec ASM Cloud Connector",
+		Description: "Syncs cloud resources to Hexiosec ASM scans",
+		StartType:   mgr.StartAutomatic,
+	}, "--config", cfgFilePath, "--daemon")
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// Restart the connector if it exits with an error
+	return s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Minute},
+	}, uint32((24 * time.Hour).Seconds()))
+}
+
+// uninstallService removes the service, once it has stopped
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("could not connect to service control manager, %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s not installed, %w", name, err)
+	}
+	defer s.Close()
+	return s.Delete()
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
 	"github.com/hexiosec/asm-cloud-connector/pkg/core"
@@ -11,5 +13,18 @@ func main() {
 		logger.GetGlobalLogger().Fatal().Err(err).Msg("failed to setup")
 	}
 
-	lambda.Start(core.Run)
+	// SIGTERM stops a sync between seeds. Lambda only sends it before shutting the function down
+	// once an extension is registered, which WithEnableSIGTERM does.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The event can override the scan, providers and accounts of the config, or request a dry run.
+	// SQS events run the worker events of a fanned out sync.
+	lambda.StartWithOptions(core.HandleLambda,
+		lambda.WithContext(ctx),
+		lambda.WithEnableSIGTERM(func() {
+			logger.GetGlobalLogger().Warn().Msg("Received SIGTERM, stopping")
+			cancel()
+		}),
+	)
 }
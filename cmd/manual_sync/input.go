@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/connector"
+)
+
+// inputRecord is a resource of a CSV or JSON input, with its optional extra tags and seed type
+type inputRecord struct {
+	Resource string   `json:"resource"`
+	Tags     []string `json:"tags,omitempty"`
+	Type     string   `json:"type,omitempty"`
+}
+
+// readInput reads the resources of the input file at path, "-" for stdin, with the labels of those
+// that have tags or a type. The format is "text", "csv" or "json", from the file extension if empty.
+func readInput(path string, format string) ([]string, map[string]connector.Label, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not open input, %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var records []inputRecord
+	var err error
+	switch format {
+	case "csv":
+		records, err = readCSV(r)
+	case "json":
+		records, err = readJSON(r)
+	default:
+		records, err = readText(r)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resources := make([]string, 0, len(records))
+	labels := map[string]connector.Label{}
+	for _, record := range records {
+		resources = append(resources, record.Resource)
+		if len(record.Tags) > 0 || record.Type != "" {
+			labels[record.Resource] = connector.Label{Tags: record.Tags, Type: record.Type}
+		}
+	}
+	return resources, labels, nil
+}
+
+// readText reads a resource from each non-empty line
+func readText(r io.Reader) ([]inputRecord, error) {
+	var records []inputRecord
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			records = append(records, inputRecord{Resource: line})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read input, %w", err)
+	}
+	return records, nil
+}
+
+// readCSV reads a resource from each row after the header. The header names the resource column,
+// and the optional tags column, of tags separated by ";", and type column.
+func readCSV(r io.Reader) ([]inputRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read CSV header, %w", err)
+	}
+	for idx := range header {
+		header[idx] = strings.ToLower(strings.TrimSpace(header[idx]))
+	}
+	resourceCol := slices.Index(header, "resource")
+	if resourceCol < 0 {
+		return nil, fmt.Errorf("CSV header has no resource column")
+	}
+	tagsCol := slices.Index(header, "tags")
+	typeCol := slices.Index(header, "type")
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not read CSV, %w", err)
+	}
+
+	records := make([]inputRecord, 0, len(rows))
+	for _, row := range rows {
+		record := inputRecord{Resource: strings.TrimSpace(row[resourceCol])}
+		if record.Resource == "" {
+			continue
+		}
+		if tagsCol >= 0 {
+			for _, tag := range strings.Split(row[tagsCol], ";") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					record.Tags = append(record.Tags, tag)
+				}
+			}
+		}
+		if typeCol >= 0 {
+			record.Type = strings.TrimSpace(row[typeCol])
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// readJSON reads an array of resources, each an object with the resource and optional tags and
+// type
+func readJSON(r io.Reader) ([]inputRecord, error) {
+	var records []inputRecord
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("could not parse JSON input, %w", err)
+	}
+	for idx, record := range records {
+		if strings.TrimSpace(record.Resource) == "" {
+			return nil, fmt.Errorf("resource %d of JSON input has no resource", idx)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/connector"
+)
+
+func writeInput(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestReadInput_CSV(t *testing.T) {
+	path := writeInput(t, "acquisitions.csv", "Resource,Tags,Type\nacme.example.com,acquisition; acme,\n192.0.2.0/28,,IPRange\nplain.example.com,,\n")
+
+	resources, labels, err := readInput(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"acme.example.com", "192.0.2.0/28", "plain.example.com"}, resources)
+	assert.Equal(t, map[string]connector.Label{
+		"acme.example.com": {Tags: []string{"acquisition", "acme"}},
+		"192.0.2.0/28":     {Type: "IPRange"},
+	}, labels)
+}
+
+func TestReadInput_CSVWithoutResourceColumn_Err(t *testing.T) {
+	_, _, err := readInput(writeInput(t, "input.csv", "name,tags\nexample.com,a\n"), "")
+	assert.ErrorContains(t, err, "no resource column")
+}
+
+func TestReadInput_JSON(t *testing.T) {
+	path := writeInput(t, "input.txt", `[{"resource":"acme.example.com","tags":["acquisition"]},{"resource":"192.0.2.1"}]`)
+
+	resources, labels, err := readInput(path, "json")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"acme.example.com", "192.0.2.1"}, resources)
+	assert.Equal(t, map[string]connector.Label{
+		"acme.example.com": {Tags: []string{"acquisition"}},
+	}, labels)
+}
+
+func TestReadInput_Text(t *testing.T) {
+	resources, labels, err := readInput(writeInput(t, "input.txt", "example.com\n\n 192.0.2.1 \n"), "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "192.0.2.1"}, resources)
+	assert.Empty(t, labels)
+}
@@ -4,11 +4,16 @@ import (
 	"context"
 	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/hexiosec/asm-cloud-connector/internal/api"
 	"github.com/hexiosec/asm-cloud-connector/internal/config"
 	"github.com/hexiosec/asm-cloud-connector/internal/connector"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/state"
+	"github.com/hexiosec/asm-cloud-connector/internal/version"
+	"github.com/hexiosec/asm-cloud-connector/pkg/core"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -19,6 +24,8 @@ var (
 	scanID           = flag.String("scan-id", "", "Scan ID")
 	seedLabel        = flag.String("seed-label", "", "Seed Label")
 	deleteStaleSeeds = flag.Bool("delete-stale-seeds", true, "Delete seeds not in resource list")
+	input            = flag.String("input", "", "File of resources to sync, - for stdin")
+	inputFormat      = flag.String("format", "", "Format of the input file, text, csv or json, from its extension if not set")
 )
 
 func main() {
@@ -52,21 +59,33 @@ func main() {
 
 	// Main
 	logger.GetGlobalLogger().Info().Msg("Starting manual sync")
-	ctx := context.Background()
+	// SIGTERM or SIGINT stops the sync between seeds, without deleting stale seeds
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	if *scanID == "" {
-		log.Fatal().Msg("Scan ID not set, use --scan-id")
+		exit(core.ExitUsage, nil, "Scan ID not set, use --scan-id")
 	}
 
 	if *seedLabel == "" {
-		log.Fatal().Msg("Seed Label not set, use --seed-label")
+		exit(core.ExitUsage, nil, "Seed Label not set, use --seed-label")
 	}
 
-	if flag.NArg() == 0 {
-		log.Fatal().Msg("No resources have been provided")
+	resources := flag.Args()
+	var labels map[string]connector.Label
+	if *input != "" {
+		var inputResources []string
+		inputResources, labels, err = readInput(*input, *inputFormat)
+		if err != nil {
+			exit(core.ExitUsage, err, "Could not read input")
+		}
+		resources = append(resources, inputResources...)
+	}
+
+	if len(resources) == 0 {
+		exit(core.ExitUsage, nil, "No resources have been provided")
 	}
 
-	resources := flag.Args()
 	log.Info().Interface("resources", resources).Msgf("%d resources", len(resources))
 
 	cfg := &config.Config{
@@ -77,26 +96,67 @@ func main() {
 
 	apiKey, ok := os.LookupEnv("API_KEY")
 	if !ok {
-		log.Fatal().Msg("API_KEY environment variable not set")
+		exit(core.ExitASMAuth, nil, "API_KEY environment variable not set")
 	}
 
-	sdk, err := api.NewAPI(cfg, "hexiosec-cloud-connector", apiKey)
+	sdk, sdkCloser, err := api.NewAPI(cfg, version.UserAgent(), apiKey, nil)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Could not init ASM SDK")
 	}
+	// Also closed by exit, as os.Exit skips deferred calls
+	atExit = func() {
+		if err := sdkCloser.Close(); err != nil {
+			log.Error().Err(err).Msg("Could not close ASM SDK")
+		}
+	}
+	defer atExit()
 
-	conn, err := connector.NewConnector(cfg, sdk)
+	store, err := state.NewStore(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not init state store")
+	}
+
+	conn, err := connector.NewConnector(cfg, sdk, store)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Could not init Hexiosec ASM connector")
 	}
 
+	if err := conn.SetLabels(labels); err != nil {
+		exit(core.ExitUsage, err, "Invalid tags or type in input")
+	}
+
 	if err := conn.Authenticate(ctx); err != nil {
-		log.Fatal().Err(err).Msg("Could not authenticate with Hexiosec ASM connector")
+		exit(core.ExitASMAuth, err, "Could not authenticate with Hexiosec ASM connector")
+	}
+
+	report, err := conn.SyncResources(ctx, resources)
+	if err != nil {
+		// The report holds the changes made before the sync stopped
+		if report != nil {
+			log.Warn().
+				Strs("added", report.Added).
+				Strs("removed", report.Removed).
+				Interface("rejected", report.Rejected).
+				Msg("Sync is incomplete")
+		}
+		exit(core.ExitPartialSync, err, "Could not sync resources with Hexiosec ASM connector")
 	}
 
-	if err := conn.SyncResources(ctx, resources); err != nil {
-		log.Fatal().Err(err).Msg("Could not sync resources with Hexiosec ASM connector")
+	if len(report.Rejected) > 0 {
+		log.Warn().Interface("rejected", report.Rejected).Msgf("%d resources were rejected", len(report.Rejected))
 	}
 
 	logger.GetGlobalLogger().Info().Msg("Done")
 }
+
+// atExit, if set, is run by exit before exiting, e.g. to write out the recording of asm.record
+var atExit func()
+
+// exit logs msg and err, if any, runs atExit and exits with code. log.Fatal always exits with 1.
+func exit(code int, err error, msg string) {
+	log.Error().Err(err).Msg(msg)
+	if atExit != nil {
+		atExit()
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/connector"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/pkg/core"
+)
+
+const (
+	colourReset  = "\033[0m"
+	colourGreen  = "\033[32m"
+	colourRed    = "\033[31m"
+	colourYellow = "\033[33m"
+)
+
+var (
+	debugMode   = flag.Bool("debug", false, "Enable debug output")
+	cfgFilePath = flag.String("config", "./config.yml", "Path to config YAML")
+	jsonOutput  = flag.Bool("json", false, "Print the plan as JSON")
+)
+
+func main() {
+	flag.Parse()
+	core.SetCfgFilePath(*cfgFilePath)
+	core.SetDebugMode(*debugMode)
+	// Keep stdout for the plan
+	core.SetLogOutput(os.Stderr)
+
+	if err := core.Setup(); err != nil {
+		logger.GetGlobalLogger().Error().Err(err).Msg("failed to setup")
+		os.Exit(core.ExitCode(err))
+	}
+
+	// SIGTERM or SIGINT stops the plan, nothing is changed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	plans, err := core.Plan(ctx)
+	if err != nil {
+		logger.GetGlobalLogger().Error().Err(err).Msg("failed to plan")
+		stop()
+		os.Exit(core.ExitCode(err))
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plans); err != nil {
+			logger.GetGlobalLogger().Fatal().Err(err).Msg("failed to encode plan")
+		}
+		return
+	}
+
+	for idx, plan := range plans {
+		// Only label the plans when profiles feed more than one scan or seed tag
+		if len(plans) > 1 {
+			if idx > 0 {
+				fmt.Fprintln(os.Stdout)
+			}
+			fmt.Fprintf(os.Stdout, "Scan %s, seed tag %s:\n", plan.ScanID, plan.SeedTag)
+		}
+		printPlan(os.Stdout, plan.Plan, *debugMode)
+	}
+}
+
+// printPlan writes a terraform style diff of the plan, colourised if colour is true
+func printPlan(w io.Writer, plan *connector.SyncPlan, colour bool) {
+	paint := func(c string, s string) string {
+		if !colour {
+			return s
+		}
+		return c + s + colourReset
+	}
+
+	for _, seed := range plan.Add {
+		fmt.Fprintln(w, paint(colourGreen, fmt.Sprintf("  + %s (%s)", seed.Name, seed.Type)))
+	}
+	for _, seed := range plan.Remove {
+		fmt.Fprintln(w, paint(colourRed, fmt.Sprintf("  - %s", seed)))
+	}
+	for _, seed := range plan.Rejected {
+		fmt.Fprintln(w, paint(colourYellow, fmt.Sprintf("  ! %s (%s)", seed.Name, seed.Reason)))
+	}
+
+	fmt.Fprintf(w, "\nPlan: %d to add, %d to remove, %d unchanged, %d rejected.\n", len(plan.Add), len(plan.Remove), len(plan.Unchanged), len(plan.Rejected))
+}
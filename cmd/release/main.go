@@ -0,0 +1,136 @@
+// Command release builds the assets of a GitHub release that connector self-update installs: a
+// connector_<os>_<arch> binary for each platform, checksums.txt and its Ed25519 signature,
+// checksums.txt.sig. The signature covers the version too, so --version must be the tag the
+// release is published under. The binaries are built with the public half of the signing key, so
+// they verify the releases signed after them.
+//
+// The signing key is the base64 encoded Ed25519 private key in RELEASE_SIGNING_KEY, generated once
+// with --keygen and kept as a secret of the release pipeline.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/version"
+)
+
+const versionPkg = "github.com/hexiosec/asm-cloud-connector/internal/version"
+
+var (
+	keygen         = flag.Bool("keygen", false, "Print a new signing key and its public key, then exit")
+	releaseVersion = flag.String("version", "", "Version of the release, the tag it's published under, e.g. v1.4.0")
+	commit         = flag.String("commit", "", "Commit the release is built from")
+	date           = flag.String("date", "", "Build date of the release, RFC 3339")
+	outputDir      = flag.String("output", "dist", "Directory to write the assets to")
+	platforms      = flag.String("platforms", "linux/amd64,linux/arm64,darwin/amd64,darwin/arm64,windows/amd64", "Comma separated os/arch to build")
+)
+
+func main() {
+	flag.Parse()
+	log := logger.GetGlobalLogger()
+
+	if *keygen {
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to generate signing key")
+		}
+		fmt.Printf("RELEASE_SIGNING_KEY=%s\n", base64.StdEncoding.EncodeToString(private))
+		fmt.Printf("release key=%s\n", base64.StdEncoding.EncodeToString(public))
+		return
+	}
+
+	if *releaseVersion == "" {
+		log.Fatal().Msg("--version is required")
+	}
+	key, err := signingKey(os.Getenv("RELEASE_SIGNING_KEY"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to read signing key")
+	}
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		log.Fatal().Err(err).Msg("failed to create output directory")
+	}
+
+	ldflags := strings.Join([]string{
+		"-X " + versionPkg + ".version=" + *releaseVersion,
+		"-X " + versionPkg + ".commit=" + *commit,
+		"-X " + versionPkg + ".date=" + *date,
+		"-X " + versionPkg + ".releaseKey=" + base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+	}, " ")
+
+	var names []string
+	for _, platform := range strings.Split(*platforms, ",") {
+		goos, goarch, ok := strings.Cut(strings.TrimSpace(platform), "/")
+		if !ok {
+			log.Fatal().Str("platform", platform).Msg("platform must be os/arch")
+		}
+		name := version.BinaryAsset(goos, goarch)
+		log.Info().Str("asset", name).Msg("Building")
+
+		cmd := exec.Command("go", "build", "-trimpath", "-ldflags", ldflags, "-o", filepath.Join(*outputDir, name), "./cmd/connector")
+		cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS="+goos, "GOARCH="+goarch)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Fatal().Err(err).Str("asset", name).Msg("failed to build")
+		}
+		names = append(names, name)
+	}
+
+	checksums, err := writeChecksums(*outputDir, names)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to write checksums")
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, version.SignedPayload(*releaseVersion, checksums))) + "\n"
+	if err := os.WriteFile(filepath.Join(*outputDir, version.SignatureAsset), []byte(signature), 0644); err != nil {
+		log.Fatal().Err(err).Msg("failed to write signature")
+	}
+	log.Info().Str("output", *outputDir).Msg("Release assets written")
+}
+
+// signingKey decodes the base64 encoded Ed25519 private key, or its seed
+func signingKey(encoded string) (ed25519.PrivateKey, error) {
+	if encoded == "" {
+		return nil, fmt.Errorf("RELEASE_SIGNING_KEY is not set, generate one with --keygen")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("RELEASE_SIGNING_KEY is not base64, %w", err)
+	}
+	switch len(key) {
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	default:
+		return nil, fmt.Errorf("RELEASE_SIGNING_KEY is not an Ed25519 private key")
+	}
+}
+
+// writeChecksums writes the checksums asset in dir, the SHA-256 checksum of each of names in the format
+// of sha256sum, and returns its content
+func writeChecksums(dir string, names []string) ([]byte, error) {
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(&b, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	checksums := []byte(b.String())
+	return checksums, os.WriteFile(filepath.Join(dir, version.ChecksumsAsset), checksums, 0644)
+}
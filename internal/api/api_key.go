@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+const apiKeyHeader = "X-Hexiosec-API-Key"
+
+// RefreshKeyFunc fetches the API key again from its source, e.g. after it was rotated
+type RefreshKeyFunc func(ctx context.Context) (string, error)
+
+// apiKeyTransport sets the API key on each request. If a request is unauthorised and refresh
+// returns a different key, the request is retried once with the new key, so a key rotated during
+// a long sync doesn't abort it.
+type apiKeyTransport struct {
+	mu      sync.Mutex
+	key     string
+	refresh RefreshKeyFunc
+	next    http.RoundTripper
+}
+
+func newAPIKeyTransport(key string, refresh RefreshKeyFunc, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	// The key may come from a cloud provider rather than the config
+	logger.AddSecrets(key)
+
+	return &apiKeyTransport{
+		key:     key,
+		refresh: refresh,
+		next:    next,
+	}
+}
+
+func (t *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := t.currentKey()
+	resp, err := t.next.RoundTrip(withKey(req, key))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || t.refresh == nil {
+		return resp, err
+	}
+
+	// The body has been sent, so the request can only be retried if it can be read again
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	newKey, ok := t.refreshKey(req.Context(), key)
+	if !ok {
+		return resp, nil
+	}
+
+	retry := withKey(req, newKey)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+
+	_ = resp.Body.Close()
+	return t.next.RoundTrip(retry)
+}
+
+func (t *apiKeyTransport) currentKey() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.key
+}
+
+// refreshKey returns the key to retry with, false if it is unchanged. Requests failing at the same
+// time only refresh the key once, later ones retry with the key already refreshed.
+func (t *apiKeyTransport) refreshKey(ctx context.Context, used string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.key != used {
+		return t.key, true
+	}
+
+	logger.GetLogger(ctx).Info().Msg("ASM API key unauthorised, fetching it again")
+	key, err := t.refresh(ctx)
+	if err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Failed to fetch the ASM API key again")
+		return "", false
+	}
+	if key == "" || key == used {
+		logger.GetLogger(ctx).Warn().Msg("ASM API key is unchanged, not retrying")
+		return "", false
+	}
+
+	logger.AddSecrets(key)
+	t.key = key
+	return key, true
+}
+
+// withKey returns a copy of req with the API key header set
+func withKey(req *http.Request, key string) *http.Request {
+	req = req.Clone(req.Context())
+	req.Header.Set(apiKeyHeader, key)
+	return req
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		refresh    RefreshKeyFunc
+		wantStatus int
+		wantKeys   []string
+	}{
+		{
+			name:       "Unauthorised_KeyRefreshed_Retried",
+			refresh:    func(ctx context.Context) (string, error) { return "new", nil },
+			wantStatus: http.StatusOK,
+			wantKeys:   []string{"old", "new"},
+		},
+		{
+			name:       "Unauthorised_KeyUnchanged_NotRetried",
+			refresh:    func(ctx context.Context) (string, error) { return "old", nil },
+			wantStatus: http.StatusUnauthorized,
+			wantKeys:   []string{"old"},
+		},
+		{
+			name:       "Unauthorised_RefreshErr_NotRetried",
+			refresh:    func(ctx context.Context) (string, error) { return "", assert.AnError },
+			wantStatus: http.StatusUnauthorized,
+			wantKeys:   []string{"old"},
+		},
+		{
+			name:       "Unauthorised_NoRefresh_NotRetried",
+			wantStatus: http.StatusUnauthorized,
+			wantKeys:   []string{"old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys, bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key := r.Header.Get(apiKeyHeader)
+				body, _ := io.ReadAll(r.Body)
+				keys = append(keys, key)
+				bodies = append(bodies, string(body))
+				if key != "new" {
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			client := &http.Client{Transport: newAPIKeyTransport("old", tt.refresh, nil)}
+			resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"name":"seed"}`))
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantKeys, keys)
+			for _, body := range bodies {
+				assert.Equal(t, `{"name":"seed"}`, body)
+			}
+		})
+	}
+}
+
+func TestAPIKeyTransport_KeyRefreshed_UsedByLaterRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(apiKeyHeader) != "new" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	refreshes := 0
+	client := &http.Client{Transport: newAPIKeyTransport("old", func(ctx context.Context) (string, error) {
+		refreshes++
+		return "new", nil
+	}, nil)}
+
+	for range 2 {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.Equal(t, 1, refreshes)
+}
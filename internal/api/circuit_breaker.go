@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable is returned for requests that the circuit breaker fails fast, as ASM is failing
+var ErrUnavailable = errors.New("ASM unavailable")
+
+// circuitBreakerTransport fails requests fast once threshold requests in a row have failed with a
+// server error, rather than sending thousands of requests that will also fail. After cooldown the
+// circuit is half open: one request is let through as a probe, closing the circuit if it succeeds,
+// while the others still fail fast until its outcome is recorded.
+type circuitBreakerTransport struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	// probing is whether the probe of the half open circuit is in flight
+	probing bool
+	next    http.RoundTripper
+}
+
+// newCircuitBreakerTransport returns a transport failing requests to next fast after threshold
+// failures in a row, for cooldown. Requests are never failed fast if threshold is 0.
+func newCircuitBreakerTransport(threshold int, cooldown time.Duration, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if threshold == 0 {
+		return next
+	}
+
+	return &circuitBreakerTransport{
+		threshold: threshold,
+		cooldown:  cooldown,
+		next:      next,
+	}
+}
+
+func (t *circuitBreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	probe, err := t.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	// A cancelled request says nothing about the health of ASM, a cancelled probe lets another through
+	if req.Context().Err() != nil {
+		if probe {
+			t.mu.Lock()
+			t.probing = false
+			t.mu.Unlock()
+		}
+		return resp, err
+	}
+	t.record(probe, err != nil || resp.StatusCode >= http.StatusInternalServerError)
+
+	return resp, err
+}
+
+// allow returns an error if the request is failed fast, and whether it's the probe of the half open
+// circuit
+func (t *circuitBreakerTransport) allow() (probe bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.failures < t.threshold {
+		return false, nil
+	}
+	if t.probing || time.Since(t.openedAt) < t.cooldown {
+		return false, fmt.Errorf("api: %w, %d requests in a row failed", ErrUnavailable, t.failures)
+	}
+	t.probing = true
+	return true, nil
+}
+
+func (t *circuitBreakerTransport) record(probe bool, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if probe {
+		t.probing = false
+	}
+	if !failed {
+		t.failures = 0
+		return
+	}
+
+	t.failures++
+	if t.failures >= t.threshold {
+		t.openedAt = time.Now()
+	}
+}
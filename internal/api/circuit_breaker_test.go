@@ -0,0 +1,163 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerTransport_ServerErrors_FailsFast(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: newCircuitBreakerTransport(2, time.Minute, nil)}
+
+	for range 2 {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	_, err := client.Get(server.URL)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, 2, requests)
+}
+
+func TestCircuitBreakerTransport_Cooldown_Closes(t *testing.T) {
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: newCircuitBreakerTransport(1, 50*time.Millisecond, nil)}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	_, err = client.Get(server.URL)
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	// ASM recovers, the first request after the cooldown closes the circuit
+	status = http.StatusOK
+	time.Sleep(60 * time.Millisecond)
+	for range 2 {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestCircuitBreakerTransport_HalfOpen_OneProbe(t *testing.T) {
+	var requests atomic.Int32
+	failing := atomic.Bool{}
+	failing.Store(true)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// Hold the probe until the other requests have been failed fast
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: newCircuitBreakerTransport(1, 50*time.Millisecond, nil)}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	failing.Store(false)
+	time.Sleep(60 * time.Millisecond)
+
+	// Of the requests after the cooldown, only the probe reaches ASM
+	const concurrent = 10
+	var wg sync.WaitGroup
+	var failedFast atomic.Int32
+	for range concurrent {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				assert.ErrorIs(t, err, ErrUnavailable)
+				failedFast.Add(1)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	require.Eventually(t, func() bool { return failedFast.Load() == concurrent-1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), requests.Load())
+
+	// The probe succeeded, so the circuit is closed
+	resp, err = client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestCircuitBreakerTransport_HalfOpen_ProbeFails_Reopens(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: newCircuitBreakerTransport(1, 50*time.Millisecond, nil)}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	time.Sleep(60 * time.Millisecond)
+
+	// The probe fails, opening the circuit for another cooldown
+	resp, err = client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	_, err = client.Get(server.URL)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, 2, requests)
+}
+
+func TestCircuitBreakerTransport_Success_ResetsFailures(t *testing.T) {
+	statuses := []int{http.StatusBadGateway, http.StatusOK, http.StatusBadGateway, http.StatusOK}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[requests])
+		requests++
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: newCircuitBreakerTransport(2, time.Minute, nil)}
+
+	for range statuses {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, len(statuses), requests)
+}
+
+func TestCircuitBreakerTransport_Zero_Off(t *testing.T) {
+	next := http.DefaultTransport
+
+	assert.Same(t, next, newCircuitBreakerTransport(0, time.Minute, next))
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/metrics"
+	"github.com/hexiosec/asm-sdk-go"
+)
+
+var (
+	requestsTotal = metrics.Default.Counter(
+		"cloud_connector_asm_requests_total",
+		"Requests to the ASM API by endpoint and status code, \"error\" if there was no response",
+		"endpoint", "status",
+	)
+	requestDuration = metrics.Default.Histogram(
+		"cloud_connector_asm_request_duration_seconds",
+		"Latency of requests to the ASM API by endpoint, including retries",
+		metrics.DefaultBuckets,
+		"endpoint",
+	)
+)
+
+// instrumentedAPI records the count, status and latency of the requests of each endpoint, so slow
+// syncs can be put down to ASM or the cloud providers
+type instrumentedAPI struct {
+	next API
+}
+
+func newInstrumentedAPI(next API) API {
+	return &instrumentedAPI{next: next}
+}
+
+// observe records a request to endpoint that started at start
+func observe(endpoint string, start time.Time, resp *http.Response) {
+	status := "error"
+	if resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	requestsTotal.Inc(endpoint, status)
+	requestDuration.Observe(time.Since(start).Seconds(), endpoint)
+}
+
+func (a *instrumentedAPI) GetState(ctx context.Context) (*asm.AuthResponse, *http.Response, error) {
+	start := time.Now()
+	auth, resp, err := a.next.GetState(ctx)
+	observe("GetState", start, resp)
+	return auth, resp, err
+}
+
+func (a *instrumentedAPI) GetScanByID(ctx context.Context, scanID string) (*asm.ScanResponse, *http.Response, error) {
+	start := time.Now()
+	scan, resp, err := a.next.GetScanByID(ctx, scanID)
+	observe("GetScanByID", start, resp)
+	return scan, resp, err
+}
+
+func (a *instrumentedAPI) GetScans(ctx context.Context, search string) ([]asm.ScanResponse, *http.Response, error) {
+	start := time.Now()
+	scans, resp, err := a.next.GetScans(ctx, search)
+	observe("GetScans", start, resp)
+	return scans, resp, err
+}
+
+func (a *instrumentedAPI) CreateScan(ctx context.Context, request asm.CreateScanRequest) (*asm.ScanResponse, *http.Response, error) {
+	start := time.Now()
+	scan, resp, err := a.next.CreateScan(ctx, request)
+	observe("CreateScan", start, resp)
+	return scan, resp, err
+}
+
+func (a *instrumentedAPI) GetScanSeedsById(ctx context.Context, scanID string) ([]asm.SeedsResponseInner, *http.Response, error) {
+	start := time.Now()
+	seeds, resp, err := a.next.GetScanSeedsById(ctx, scanID)
+	observe("GetScanSeedsById", start, resp)
+	return seeds, resp, err
+}
+
+func (a *instrumentedAPI) AddScanSeedById(ctx context.Context, scanID string, request asm.CreateScanSeedRequest) (*asm.NodeResponse, *http.Response, error) {
+	start := time.Now()
+	node, resp, err := a.next.AddScanSeedById(ctx, scanID, request)
+	observe("AddScanSeedById", start, resp)
+	return node, resp, err
+}
+
+func (a *instrumentedAPI) RemoveScanSeedById(ctx context.Context, scanID string, seedID string) (*http.Response, error) {
+	start := time.Now()
+	resp, err := a.next.RemoveScanSeedById(ctx, scanID, seedID)
+	observe("RemoveScanSeedById", start, resp)
+	return resp, err
+}
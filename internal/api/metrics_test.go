@@ -0,0 +1,31 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/hexiosec/asm-sdk-go"
+)
+
+func TestInstrumentedAPI_RecordsRequests(t *testing.T) {
+	mockAPI := NewMockAPI(t).(*MockAPI)
+	mockAPI.On("GetScanSeedsById", "scan-123").Return([]asm.SeedsResponseInner{}, &http.Response{StatusCode: http.StatusOK}, nil)
+	mockAPI.On("AddScanSeedById", "scan-123", mock.Anything).Return(nil, nil, assert.AnError)
+
+	ok := requestsTotal.Value("GetScanSeedsById", "200")
+	failed := requestsTotal.Value("AddScanSeedById", "error")
+	count := requestDuration.Count("GetScanSeedsById")
+
+	api := newInstrumentedAPI(mockAPI)
+	_, _, _ = api.GetScanSeedsById(context.Background(), "scan-123")
+	_, _, err := api.AddScanSeedById(context.Background(), "scan-123", asm.CreateScanSeedRequest{})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, ok+1, requestsTotal.Value("GetScanSeedsById", "200"))
+	assert.Equal(t, failed+1, requestsTotal.Value("AddScanSeedById", "error"))
+	assert.Equal(t, count+1, requestDuration.Count("GetScanSeedsById"))
+}
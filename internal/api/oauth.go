@@ -0,0 +1,28 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// newOAuthTransport returns a transport authenticating requests with an OAuth access token, fetched
+// with the client credentials of the config and fetched again once it expires. Tokens are fetched
+// with tokenTransport, so the token URL is reached with the same network settings as ASM.
+func newOAuthTransport(cfg *config.Config, tokenTransport http.RoundTripper, next http.RoundTripper) http.RoundTripper {
+	credentials := &clientcredentials.Config{
+		ClientID:     cfg.ASM.OAuth.ClientID,
+		ClientSecret: cfg.ASM.OAuth.ClientSecret,
+		TokenURL:     cfg.ASM.OAuth.TokenURL,
+		Scopes:       cfg.ASM.OAuth.Scopes,
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: tokenTransport})
+
+	return &oauth2.Transport{
+		Source: credentials.TokenSource(ctx),
+		Base:   next,
+	}
+}
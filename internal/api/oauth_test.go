@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPI_OAuth(t *testing.T) {
+	tokens := 0
+	var authorization, apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/oauth/token" {
+			tokens++
+			id, secret, _ := r.BasicAuth()
+			assert.Equal(t, "client-id", id)
+			assert.Equal(t, "client-secret", secret)
+			_, _ = w.Write([]byte(`{"access_token":"token-123","token_type":"Bearer","expires_in":3600}`))
+			return
+		}
+		authorization = r.Header.Get("Authorization")
+		apiKey = r.Header.Get(apiKeyHeader)
+		_, _ = w.Write([]byte(`{"authenticated":true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.ASM.BaseURL = server.URL
+	cfg.ASM.OAuth.ClientID = "client-id"
+	cfg.ASM.OAuth.ClientSecret = "client-secret"
+	cfg.ASM.OAuth.TokenURL = server.URL + "/oauth/token"
+
+	sdk, _, err := NewAPI(cfg, "test", "", nil)
+	require.NoError(t, err)
+
+	for range 2 {
+		auth, _, err := sdk.GetState(context.Background())
+		require.NoError(t, err)
+		assert.True(t, auth.Authenticated)
+	}
+	assert.Equal(t, "Bearer token-123", authorization)
+	assert.Empty(t, apiKey)
+	// The token is reused until it expires
+	assert.Equal(t, 1, tokens)
+}
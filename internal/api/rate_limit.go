@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitedTransport waits for the limiter before each request, including retries,
+// so that large syncs stay under the ASM API rate limits
+type rateLimitedTransport struct {
+	limiter *rate.Limiter
+	next    http.RoundTripper
+}
+
+// newRateLimitedTransport returns a transport throttling requests to next to limit a second, with
+// bursts of burst. Requests aren't throttled if limit is 0.
+func newRateLimitedTransport(limit float64, burst int, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if limit == 0 {
+		return next
+	}
+
+	return &rateLimitedTransport{
+		limiter: rate.NewLimiter(rate.Limit(limit), burst),
+		next:    next,
+	}
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	return t.next.RoundTrip(req)
+}
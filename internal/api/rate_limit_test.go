@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedTransport_Burst_Throttled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: newRateLimitedTransport(20, 2, nil)}
+
+	start := time.Now()
+	for range 4 {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// 2 requests use the burst, the other 2 wait 50ms each
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestRateLimitedTransport_ContextCancelled_Err(t *testing.T) {
+	client := &http.Client{Transport: newRateLimitedTransport(0.001, 1, nil)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.invalid", nil)
+	require.NoError(t, err)
+
+	_, err = client.Do(req)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRateLimitedTransport_Zero_NotThrottled(t *testing.T) {
+	next := http.DefaultTransport
+
+	assert.Same(t, next, newRateLimitedTransport(0, 0, next))
+}
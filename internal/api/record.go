@@ -0,0 +1,226 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// interaction is a request to the ASM API and its response, recorded as a line of JSON. Request
+// headers aren't recorded, so the file never holds the API key.
+type interaction struct {
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	Query        string `json:"query,omitempty"`
+	RequestBody  string `json:"request_body,omitempty"`
+	Status       int    `json:"status"`
+	ContentType  string `json:"content_type,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"`
+}
+
+// key identifies the requests that a recorded response is replayed for
+func (i *interaction) key() string {
+	return i.Method + " " + i.Path + "?" + i.Query + "\n" + i.RequestBody
+}
+
+// recordTransport writes every request to the ASM API and its response to a file, which
+// replayTransport can serve them from
+type recordTransport struct {
+	mu   sync.Mutex
+	file *os.File
+	next http.RoundTripper
+}
+
+func newRecordTransport(path string, next http.RoundTripper) (*recordTransport, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("api: could not create recording %s, %w", path, err)
+	}
+
+	return &recordTransport{
+		file: file,
+		next: next,
+	}, nil
+}
+
+// Close flushes the recording to disk and closes it. Requests made after it fail.
+func (t *recordTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.file.Sync(); err != nil {
+		t.file.Close()
+		return fmt.Errorf("api: could not write recording, %w", err)
+	}
+	if err := t.file.Close(); err != nil {
+		return fmt.Errorf("api: could not close recording, %w", err)
+	}
+	return nil
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("api: could not record request, %w", err)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("api: could not record response, %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	line, err := json.Marshal(interaction{
+		Method:       req.Method,
+		Path:         req.URL.Path,
+		Query:        req.URL.RawQuery,
+		RequestBody:  string(reqBody),
+		Status:       resp.StatusCode,
+		ContentType:  resp.Header.Get("Content-Type"),
+		ResponseBody: string(respBody),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("api: could not record response, %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.file.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("api: could not write recording, %w", err)
+	}
+
+	return resp, nil
+}
+
+// replayTransport serves the responses of a recording instead of calling the ASM API. Identical
+// requests are served their recorded responses in order, repeating the last one. A request that
+// wasn't recorded, such as adding a seed for a newly discovered resource, is served the last
+// response recorded for the same method and path.
+type replayTransport struct {
+	mu       sync.Mutex
+	requests map[string][]interaction
+	served   map[string]int
+	paths    map[string]interaction
+}
+
+func newReplayTransport(path string) (http.RoundTripper, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("api: could not open recording %s, %w", path, err)
+	}
+	defer file.Close()
+
+	t := &replayTransport{
+		requests: map[string][]interaction{},
+		served:   map[string]int{},
+		paths:    map[string]interaction{},
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var i interaction
+		if err := json.Unmarshal(scanner.Bytes(), &i); err != nil {
+			return nil, fmt.Errorf("api: could not decode line %d of recording %s, %w", line, path, err)
+		}
+		t.requests[i.key()] = append(t.requests[i.key()], i)
+		t.paths[i.Method+" "+i.Path] = i
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("api: could not read recording %s, %w", path, err)
+	}
+
+	return t, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("api: could not replay request, %w", err)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	i, ok := t.next(&interaction{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Query:       req.URL.RawQuery,
+		RequestBody: string(reqBody),
+	})
+	if !ok {
+		return nil, fmt.Errorf("api: no recorded response for %s %s", req.Method, req.URL.Path)
+	}
+
+	header := http.Header{}
+	if i.ContentType != "" {
+		header.Set("Content-Type", i.ContentType)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(i.ResponseBody))),
+		ContentLength: int64(len(i.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+// next returns the recorded response to serve for req
+func (t *replayTransport) next(req *interaction) (interaction, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := req.key()
+	if recorded := t.requests[key]; len(recorded) > 0 {
+		n := min(t.served[key], len(recorded)-1)
+		t.served[key]++
+		return recorded[n], true
+	}
+
+	i, ok := t.paths[req.Method+" "+req.Path]
+	return i, ok
+}
+
+// readRequestBody returns the body of req, leaving it readable for the next transport
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReplay(t *testing.T) {
+	seeds := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			seeds++
+			_, _ = io.WriteString(w, `{"seeds":`+strings.Repeat("1", seeds)+`}`)
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		}
+	}))
+
+	recording := filepath.Join(t.TempDir(), "asm.jsonl")
+	record, err := newRecordTransport(recording, nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: record}
+
+	get := func(client *http.Client) string {
+		resp, err := client.Get(server.URL + "/scans/scan-123/seeds?expand=tags")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	post := func(client *http.Client, seed string) (int, string) {
+		resp, err := client.Post(server.URL+"/scans/scan-123/seeds", "application/json", strings.NewReader(seed))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	assert.Equal(t, `{"seeds":1}`, get(client))
+	assert.Equal(t, `{"seeds":11}`, get(client))
+	status, body := post(client, `{"name":"example.com"}`)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, `{"name":"example.com"}`, body)
+	server.Close()
+	require.NoError(t, record.Close())
+
+	replay, err := newReplayTransport(recording)
+	require.NoError(t, err)
+	client = &http.Client{Transport: replay}
+
+	// Identical requests are served in order, repeating the last
+	assert.Equal(t, `{"seeds":1}`, get(client))
+	assert.Equal(t, `{"seeds":11}`, get(client))
+	assert.Equal(t, `{"seeds":11}`, get(client))
+
+	// An unrecorded request is served the last response for its method and path
+	status, body = post(client, `{"name":"new.example.com"}`)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, `{"name":"example.com"}`, body)
+
+	_, err = client.Get(server.URL + "/auth")
+	assert.ErrorContains(t, err, "no recorded response for GET /auth")
+}
+
+func TestRecordTransport_APIKey_NotRecorded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	recording := filepath.Join(t.TempDir(), "asm.jsonl")
+	record, err := newRecordTransport(recording, nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: newAPIKeyTransport("secret-key", nil, record)}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	data, err := os.ReadFile(recording)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret-key")
+}
+
+func TestReplayTransport_InvalidRecording_Err(t *testing.T) {
+	recording := filepath.Join(t.TempDir(), "asm.jsonl")
+	require.NoError(t, os.WriteFile(recording, []byte("not json\n"), 0o600))
+
+	_, err := newReplayTransport(recording)
+	assert.ErrorContains(t, err, "could not decode line 1")
+}
+
+func TestNewAPI_Record_ClosedByCloser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.ASM.BaseURL = server.URL
+	cfg.ASM.Record = filepath.Join(t.TempDir(), "asm.jsonl")
+
+	sdk, closer, err := NewAPI(cfg, "test", "key", nil)
+	require.NoError(t, err)
+	_, _, _ = sdk.GetState(context.Background())
+	require.NoError(t, closer.Close())
+
+	content, err := os.ReadFile(cfg.ASM.Record)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"path":"/auth"`)
+
+	// The recording is closed, so no more requests are recorded
+	_, _, err = sdk.GetState(context.Background())
+	assert.ErrorContains(t, err, "could not write recording")
+}
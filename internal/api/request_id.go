@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+// requestIDHeader identifies a request in the ASM logs
+const requestIDHeader = "X-Request-Id"
+
+// requestIDTransport logs the request ID ASM gives each response, so support can trace a
+// request from the connector logs. Server errors are logged as warnings. Client errors are
+// expected, e.g. a seed rejected or a scan not found, and handled by the caller, so are only
+// logged at debug.
+type requestIDTransport struct {
+	next http.RoundTripper
+}
+
+func newRequestIDTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &requestIDTransport{next: next}
+}
+
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	event := logger.GetLogger(req.Context()).Trace()
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		event = logger.GetLogger(req.Context()).Warn()
+	case resp.StatusCode >= http.StatusBadRequest:
+		event = logger.GetLogger(req.Context()).Debug()
+	}
+	event.
+		Str("method", req.Method).
+		Str("path", req.URL.Path).
+		Int("status_code", resp.StatusCode).
+		Str("request_id", resp.Header.Get(requestIDHeader)).
+		Msg("ASM API response")
+
+	return resp, nil
+}
@@ -2,39 +2,89 @@ package api
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
-	"github.com/hashicorp/go-retryablehttp"
 	"github.com/hexiosec/asm-cloud-connector/internal/config"
-	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	connector_http "github.com/hexiosec/asm-cloud-connector/internal/http"
 	"github.com/hexiosec/asm-sdk-go"
 )
 
 type API interface {
 	GetState(ctx context.Context) (*asm.AuthResponse, *http.Response, error)
 	GetScanByID(ctx context.Context, scanID string) (*asm.ScanResponse, *http.Response, error)
+	GetScans(ctx context.Context, search string) ([]asm.ScanResponse, *http.Response, error)
+	CreateScan(ctx context.Context, request asm.CreateScanRequest) (*asm.ScanResponse, *http.Response, error)
 	GetScanSeedsById(ctx context.Context, scanID string) ([]asm.SeedsResponseInner, *http.Response, error)
 	AddScanSeedById(ctx context.Context, scanID string, request asm.CreateScanSeedRequest) (*asm.NodeResponse, *http.Response, error)
 	RemoveScanSeedById(ctx context.Context, scanID string, seedID string) (*http.Response, error)
 }
 
+// nopCloser is the closer of a client with nothing to close
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
 type sdk struct {
 	client *asm.APIClient
 }
 
-func NewAPI(cfg *config.Config, userAgent string, apiKey string) (API, error) {
-	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = cfg.Http.RetryCount
-	retryClient.RetryWaitMax = cfg.Http.RetryMaxDelay
-	retryClient.RetryWaitMin = cfg.Http.RetryBaseDelay
-	retryClient.Logger = &logger.RetryableLogger{}
+// NewAPI returns a client for the ASM API. refreshKey, if set, fetches the API key again when a
+// request is unauthorised, e.g. after the key was rotated. With OAuth client credentials in the
+// config, requests are authenticated with an access token instead of the API key. Close the
+// returned closer once the client is no longer used, to write out the recording of asm.record.
+func NewAPI(cfg *config.Config, userAgent string, apiKey string, refreshKey RefreshKeyFunc) (API, io.Closer, error) {
+	asmTransport, err := connector_http.NewASMTransport(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("api: failed to create transport, %w", err)
+	}
+	transport := connector_http.NewTraceTransport(cfg, asmTransport)
+	// Retried with the same policy and backoff as the HTTP service. Each attempt is sent with the
+	// run ID, and logged with the ASM request ID
+	retryTransport := connector_http.NewRetryTransport(cfg, connector_http.NewRunIDTransport(newRequestIDTransport(newRateLimitedTransport(valueOrZero(cfg.Http.RateLimit), cfg.Http.RateBurst, transport))))
 
 	sdkCfg := asm.NewConfiguration()
-	sdkCfg.HTTPClient = retryClient.StandardClient()
+	// The key is set by the transport rather than the SDK, so it can be replaced mid-run
+	// The circuit breaker sees each request once its retries are exhausted
+	breaker := newCircuitBreakerTransport(valueOrZero(cfg.Http.CircuitBreakerThreshold), cfg.Http.CircuitBreakerCooldown, retryTransport)
+	var sdkTransport http.RoundTripper
+	if cfg.ASM.OAuth.ClientID != "" {
+		sdkTransport = newOAuthTransport(cfg, transport, breaker)
+	} else {
+		sdkTransport = newAPIKeyTransport(apiKey, refreshKey, breaker)
+	}
+
+	// Record the interactions with ASM to a file, or replay them from one instead of calling ASM
+	var closer io.Closer = nopCloser{}
+	switch {
+	case cfg.ASM.Replay != "":
+		sdkTransport, err = newReplayTransport(cfg.ASM.Replay)
+	case cfg.ASM.Record != "":
+		var record *recordTransport
+		record, err = newRecordTransport(cfg.ASM.Record, sdkTransport)
+		sdkTransport, closer = record, record
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sdkCfg.HTTPClient = &http.Client{Transport: sdkTransport}
 	sdkCfg.UserAgent = userAgent
-	sdkCfg.APIKey = apiKey
+	if cfg.ASM.BaseURL != "" {
+		sdkCfg.Servers = asm.ServerConfigurations{{URL: strings.TrimSuffix(cfg.ASM.BaseURL, "/")}}
+	}
+
+	return newInstrumentedAPI(&sdk{client: asm.NewAPIClient(sdkCfg)}), closer, nil
+}
 
-	return &sdk{client: asm.NewAPIClient(sdkCfg)}, nil
+// valueOrZero returns the value of p, or 0 if it's not set
+func valueOrZero[T int | float64](p *T) T {
+	if p == nil {
+		return 0
+	}
+	return *p
 }
 
 func (s *sdk) GetState(ctx context.Context) (*asm.AuthResponse, *http.Response, error) {
@@ -45,6 +95,14 @@ func (s *sdk) GetScanByID(ctx context.Context, scanID string) (*asm.ScanResponse
 	return s.client.ScansAPI.GetScanByID(ctx, scanID).Execute()
 }
 
+func (s *sdk) GetScans(ctx context.Context, search string) ([]asm.ScanResponse, *http.Response, error) {
+	return s.client.ScansAPI.GetScans(ctx).Search(search).Execute()
+}
+
+func (s *sdk) CreateScan(ctx context.Context, request asm.CreateScanRequest) (*asm.ScanResponse, *http.Response, error) {
+	return s.client.ScansAPI.CreateScan(ctx).CreateScanRequest(request).Execute()
+}
+
 func (s *sdk) GetScanSeedsById(ctx context.Context, scanID string) ([]asm.SeedsResponseInner, *http.Response, error) {
 	return s.client.ScansAPI.GetScanSeedsById(ctx, scanID).Expand([]string{"tags"}).Execute()
 }
@@ -56,6 +56,38 @@ func (m *MockAPI) GetScanByID(ctx context.Context, scanID string) (*asm.ScanResp
 	return scan, resp, args.Error(2)
 }
 
+func (m *MockAPI) GetScans(ctx context.Context, search string) ([]asm.ScanResponse, *http.Response, error) {
+	args := m.Called(search)
+
+	var scans []asm.ScanResponse
+	if v := args.Get(0); v != nil {
+		scans = v.([]asm.ScanResponse)
+	}
+
+	var resp *http.Response
+	if v := args.Get(1); v != nil {
+		resp = v.(*http.Response)
+	}
+
+	return scans, resp, args.Error(2)
+}
+
+func (m *MockAPI) CreateScan(ctx context.Context, request asm.CreateScanRequest) (*asm.ScanResponse, *http.Response, error) {
+	args := m.Called(request)
+
+	var scan *asm.ScanResponse
+	if v := args.Get(0); v != nil {
+		scan = v.(*asm.ScanResponse)
+	}
+
+	var resp *http.Response
+	if v := args.Get(1); v != nil {
+		resp = v.(*http.Response)
+	}
+
+	return scan, resp, args.Error(2)
+}
+
 func (m *MockAPI) GetScanSeedsById(ctx context.Context, scanID string) ([]asm.SeedsResponseInner, *http.Response, error) {
 	args := m.Called(scanID)
 
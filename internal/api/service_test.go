@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	connector_http "github.com/hexiosec/asm-cloud-connector/internal/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPI_BaseURL(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.ASM.BaseURL = server.URL + "/asm/api/"
+
+	sdk, _, err := NewAPI(cfg, "test", "key", nil)
+	require.NoError(t, err)
+
+	_, _, _ = sdk.GetState(context.Background())
+	assert.Equal(t, "/asm/api/auth", path)
+}
+
+func TestNewAPI_UserAgentAndRunID(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.ASM.BaseURL = server.URL
+
+	sdk, _, err := NewAPI(cfg, "hexiosec-cloud-connector/1.2.3", "key", nil)
+	require.NoError(t, err)
+
+	_, _, _ = sdk.GetState(connector_http.WithRunID(context.Background(), "run-123"))
+	assert.Equal(t, "hexiosec-cloud-connector/1.2.3", header.Get("User-Agent"))
+	assert.Equal(t, "run-123", header.Get(connector_http.RunIDHeader))
+}
@@ -0,0 +1,200 @@
+// Package audit keeps an append-only record of the seeds each run adds and removes, for customers
+// who have to audit changes to their attack surface
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+// Actions of a record
+const (
+	ActionAdd    = "add"
+	ActionRemove = "remove"
+)
+
+// Record is a seed added to or removed from a scan
+type Record struct {
+	Time   time.Time `json:"time"`
+	RunID  string    `json:"run_id"`
+	ScanID string    `json:"scan_id"`
+	Seed   string    `json:"seed"`
+	Action string    `json:"action"`
+	// Origin is the discovered resource the seed was normalised from, empty for a removed seed
+	Origin string `json:"origin,omitempty"`
+}
+
+// IAuditor records the seed changes of a run. Its methods may be called concurrently.
+type IAuditor interface {
+	// Record records a seed change, stamping it with the time and run ID if unset
+	Record(ctx context.Context, r Record) error
+	// Close writes out any buffered records, no more can be recorded once it's closed
+	Close(ctx context.Context) error
+}
+
+// NewAuditor returns an auditor writing the records of run runID to the configured audit path. A
+// local path is a file appended to, one JSON record per line. An s3://bucket/prefix,
+// gs://bucket/prefix or Azure Blob container URL is a prefix a new object is written under for each
+// run with changes, as objects can't be appended to. Nothing is recorded if no path is configured.
+func NewAuditor(cfg *config.Config, runID string) (IAuditor, error) {
+	path := cfg.Audit.Path
+	if path == "" {
+		return &nopAuditor{}, nil
+	}
+
+	object, err := isObjectPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if object {
+		return &objectAuditor{prefix: strings.TrimSuffix(path, "/"), runID: runID, upload: upload}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("audit: could not create directory of %s, %w", path, err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: could not open %s, %w", path, err)
+	}
+
+	return &fileAuditor{f: f, runID: runID}, nil
+}
+
+// stamp sets the time and run ID of r, if unset
+func stamp(r Record, runID string) Record {
+	if r.Time.IsZero() {
+		r.Time = time.Now().UTC()
+	}
+	if r.RunID == "" {
+		r.RunID = runID
+	}
+	return r
+}
+
+// encode returns r as a line of JSON
+func encode(r Record) ([]byte, error) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("audit: could not encode record of %s, %w", r.Seed, err)
+	}
+	return append(line, '\n'), nil
+}
+
+type nopAuditor struct{}
+
+func (a *nopAuditor) Record(_ context.Context, _ Record) error {
+	return nil
+}
+
+func (a *nopAuditor) Close(_ context.Context) error {
+	return nil
+}
+
+// fileAuditor appends each record to a file as it's recorded, so the records of a run that crashes
+// are kept
+type fileAuditor struct {
+	mu    sync.Mutex
+	f     *os.File
+	runID string
+}
+
+func (a *fileAuditor) Record(_ context.Context, r Record) error {
+	line, err := encode(stamp(r, a.runID))
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Write the line in one call, so it isn't interleaved with the records of another process
+	if _, err := a.f.Write(line); err != nil {
+		return fmt.Errorf("audit: could not write record of %s, %w", r.Seed, err)
+	}
+	return nil
+}
+
+func (a *fileAuditor) Close(_ context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.f.Close(); err != nil {
+		return fmt.Errorf("audit: could not close %s, %w", a.f.Name(), err)
+	}
+	return nil
+}
+
+// objectAuditor buffers the records of a run and writes them to a new object under prefix when
+// closed
+type objectAuditor struct {
+	mu      sync.Mutex
+	prefix  string
+	runID   string
+	records bytes.Buffer
+	started time.Time
+	upload  func(ctx context.Context, ref string, data []byte) error
+}
+
+func (a *objectAuditor) Record(_ context.Context, r Record) error {
+	r = stamp(r, a.runID)
+	line, err := encode(r)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.started.IsZero() {
+		a.started = r.Time
+	}
+	a.records.Write(line)
+	return nil
+}
+
+func (a *objectAuditor) Close(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.records.Len() == 0 {
+		return nil
+	}
+
+	// Name the object by when the run's first change was made, so the objects sort in order
+	ref := a.objectRef()
+	if err := a.upload(ctx, ref, a.records.Bytes()); err != nil {
+		return err
+	}
+	a.records.Reset()
+	return nil
+}
+
+// objectRef returns the reference of the object of the run's records, the query of an Azure Blob
+// URL, e.g. a SAS token, is kept after the object's name
+func (a *objectAuditor) objectRef() string {
+	name := fmt.Sprintf("%s-%s.jsonl", a.started.UTC().Format("20060102T150405Z"), a.runID)
+
+	u, err := url.Parse(a.prefix)
+	if err != nil || u.RawQuery == "" {
+		return a.prefix + "/" + name
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + name
+	return u.String()
+}
+
+var (
+	_ IAuditor = (*nopAuditor)(nil)
+	_ IAuditor = (*fileAuditor)(nil)
+	_ IAuditor = (*objectAuditor)(nil)
+)
@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+func newConfig(path string) *config.Config {
+	cfg := &config.Config{}
+	cfg.Audit.Path = path
+	return cfg
+}
+
+// readRecords returns the records of a JSON lines audit log
+func readRecords(t *testing.T, data []byte) []Record {
+	t.Helper()
+	var records []Record
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var r Record
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestNewAuditor_NoPath_Nop(t *testing.T) {
+	a, err := NewAuditor(newConfig(""), "run-1")
+	require.NoError(t, err)
+	assert.IsType(t, &nopAuditor{}, a)
+	assert.NoError(t, a.Record(context.Background(), Record{Seed: "example.com"}))
+	assert.NoError(t, a.Close(context.Background()))
+}
+
+func TestNewAuditor_UnsupportedURL_Err(t *testing.T) {
+	_, err := NewAuditor(newConfig("https://example.com/audit"), "run-1")
+	assert.Error(t, err)
+}
+
+func TestFileAuditor_AppendsAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "seeds.jsonl")
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, run := range []string{"run-1", "run-2"} {
+		a, err := NewAuditor(newConfig(path), run)
+		require.NoError(t, err)
+		require.NoError(t, a.Record(context.Background(), Record{Time: at, ScanID: "scan-1", Seed: "example.com", Action: ActionAdd, Origin: "https://example.com"}))
+		require.NoError(t, a.Close(context.Background()))
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []Record{
+		{Time: at, RunID: "run-1", ScanID: "scan-1", Seed: "example.com", Action: ActionAdd, Origin: "https://example.com"},
+		{Time: at, RunID: "run-2", ScanID: "scan-1", Seed: "example.com", Action: ActionAdd, Origin: "https://example.com"},
+	}, readRecords(t, data))
+}
+
+func TestFileAuditor_StampsTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seeds.jsonl")
+	a, err := NewAuditor(newConfig(path), "run-1")
+	require.NoError(t, err)
+
+	before := time.Now()
+	require.NoError(t, a.Record(context.Background(), Record{Seed: "stale.com", Action: ActionRemove}))
+	require.NoError(t, a.Close(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	records := readRecords(t, data)
+	require.Len(t, records, 1)
+	assert.False(t, records[0].Time.Before(before.Truncate(time.Second)))
+	assert.Equal(t, "run-1", records[0].RunID)
+	assert.NotContains(t, string(data), "origin")
+}
+
+func TestObjectAuditor_UploadsRunOnClose(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "S3",
+			path:     "s3://bucket/audit/",
+			expected: "s3://bucket/audit/20260102T030405Z-run-1.jsonl",
+		},
+		{
+			name:     "GCS",
+			path:     "gs://bucket/audit",
+			expected: "gs://bucket/audit/20260102T030405Z-run-1.jsonl",
+		},
+		{
+			name:     "AzureBlobSAS",
+			path:     "https://account.blob.core.windows.net/container/audit?sv=1&sig=secret",
+			expected: "https://account.blob.core.windows.net/container/audit/20260102T030405Z-run-1.jsonl?sv=1&sig=secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAuditor(newConfig(tt.path), "run-1")
+			require.NoError(t, err)
+			objects := map[string][]byte{}
+			a.(*objectAuditor).upload = func(_ context.Context, ref string, data []byte) error {
+				objects[ref] = append([]byte(nil), data...)
+				return nil
+			}
+
+			at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			require.NoError(t, a.Record(context.Background(), Record{Time: at, Seed: "a.com", Action: ActionAdd}))
+			require.NoError(t, a.Record(context.Background(), Record{Time: at.Add(time.Second), Seed: "b.com", Action: ActionRemove}))
+			assert.Empty(t, objects)
+
+			require.NoError(t, a.Close(context.Background()))
+			require.Contains(t, objects, tt.expected)
+			assert.Equal(t, []string{"a.com", "b.com"}, []string{
+				readRecords(t, objects[tt.expected])[0].Seed,
+				readRecords(t, objects[tt.expected])[1].Seed,
+			})
+		})
+	}
+}
+
+func TestObjectAuditor_NoChanges_NothingUploaded(t *testing.T) {
+	a, err := NewAuditor(newConfig("s3://bucket/audit"), "run-1")
+	require.NoError(t, err)
+	a.(*objectAuditor).upload = func(_ context.Context, _ string, _ []byte) error {
+		t.Fatal("unexpected upload")
+		return nil
+	}
+
+	assert.NoError(t, a.Close(context.Background()))
+}
+
+func TestObjectAuditor_UploadFails_Err(t *testing.T) {
+	a, err := NewAuditor(newConfig("gs://bucket/audit"), "run-1")
+	require.NoError(t, err)
+	a.(*objectAuditor).upload = func(_ context.Context, _ string, _ []byte) error {
+		return assert.AnError
+	}
+
+	require.NoError(t, a.Record(context.Background(), Record{Seed: "a.com", Action: ActionAdd}))
+	assert.ErrorIs(t, a.Close(context.Background()), assert.AnError)
+}
@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
+)
+
+const azureBlobHostSuffix = ".blob.core.windows.net"
+
+// isObjectPath reports whether path is an object storage prefix rather than a local file
+func isObjectPath(path string) (bool, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return false, nil
+	}
+
+	switch u.Scheme {
+	case "s3", "gs":
+		return true, nil
+	case "http", "https":
+		if !strings.HasSuffix(u.Hostname(), azureBlobHostSuffix) {
+			return false, fmt.Errorf("audit: unsupported audit path, only Azure Blob URLs are supported")
+		}
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// upload writes data to a new object at ref, failing rather than overwriting an existing object,
+// so earlier records can't be lost
+func upload(ctx context.Context, ref string, data []byte) error {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return fmt.Errorf("audit: invalid audit path, %w", err)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		return uploadS3(ctx, u.Host, strings.TrimPrefix(u.Path, "/"), data)
+	case "gs":
+		return uploadGCS(ctx, u.Host, strings.TrimPrefix(u.Path, "/"), data)
+	default:
+		return uploadAzureBlob(ctx, u, data)
+	}
+}
+
+func uploadS3(ctx context.Context, bucket string, key string, data []byte) error {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, tlsmode.AWS)
+	if err != nil {
+		return fmt.Errorf("audit: unable to load AWS SDK config, %w", err)
+	}
+
+	_, err = s3.NewFromConfig(cfg).PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/jsonl"),
+		IfNoneMatch: aws.String("*"),
+	})
+	if err != nil {
+		return fmt.Errorf("audit: failed to put S3 object, %w", err)
+	}
+	return nil
+}
+
+func uploadGCS(ctx context.Context, bucket string, object string, data []byte) error {
+	client, err := storage.NewClient(ctx, tlsmode.GCP()...)
+	if err != nil {
+		return fmt.Errorf("audit: failed to create GCS client, %w", err)
+	}
+	defer client.Close()
+
+	w := client.Bucket(bucket).Object(object).If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	w.ContentType = "application/jsonl"
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("audit: failed to write GCS object, %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("audit: failed to write GCS object, %w", err)
+	}
+	return nil
+}
+
+// uploadAzureBlob writes data to the blob at u, with the SAS token of its query if it has one, or
+// the default Azure credentials
+func uploadAzureBlob(ctx context.Context, u *url.URL, data []byte) error {
+	container, name, ok := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if !ok {
+		return fmt.Errorf("audit: Azure Blob URL must include a container")
+	}
+
+	serviceURL := u.Scheme + "://" + u.Host + "/"
+	var client *azblob.Client
+	var err error
+	if u.Query().Has("sig") {
+		client, err = azblob.NewClientWithNoCredential(serviceURL+"?"+u.RawQuery, &azblob.ClientOptions{ClientOptions: tlsmode.Azure()})
+	} else {
+		var cred *azidentity.DefaultAzureCredential
+		cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: tlsmode.Azure()})
+		if err != nil {
+			return fmt.Errorf("audit: failed to get default Azure credentials, %w", err)
+		}
+		client, err = azblob.NewClient(serviceURL, cred, &azblob.ClientOptions{ClientOptions: tlsmode.Azure()})
+	}
+	if err != nil {
+		return fmt.Errorf("audit: failed to create Azure Blob client, %w", err)
+	}
+
+	etag := azcore.ETagAny
+	_, err = client.UploadBuffer(ctx, container, name, data, &azblob.UploadBufferOptions{
+		AccessConditions: &azblob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: &etag},
+		},
+	})
+	if err != nil {
+		// The error includes the URL, which may hold a SAS token
+		return fmt.Errorf("audit: failed to upload Azure Blob %s/%s, %w", container, name, unwrapURLError(err))
+	}
+	return nil
+}
+
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -29,12 +29,13 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/smithy-go"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
 )
 
 type IAWSWrapper interface {
 	AssumeRole(ctx context.Context, role string) (IAWSWrapper, error)
-	ChangeRegion(region string)
-	ResetRegion()
+	// InRegion returns a wrapper calling region, so regions can be checked concurrently
+	InRegion(region string) IAWSWrapper
 	CheckConnection(ctx context.Context) error
 	GetSecretString(ctx context.Context, secret string) (string, error)
 	ListAllAccounts(ctx context.Context) ([]string, error)
@@ -42,16 +43,18 @@ type IAWSWrapper interface {
 	GetEC2Resources(ctx context.Context, resources []string) ([]string, error)
 	GetEIPResources(ctx context.Context, resources []string) ([]string, error)
 	GetELBResources(ctx context.Context, resources []string) ([]string, error)
-	GetS3Resources(ctx context.Context, resources []string) ([]string, error)
+	GetS3Resources(ctx context.Context, resources []string, includePrivate bool) ([]string, error)
 	GetACMResources(ctx context.Context, resources []string) ([]string, error)
 	GetRoute53Resources(ctx context.Context, resources []string) ([]string, error)
 	GetCloudFrontResources(ctx context.Context, resources []string) ([]string, error)
 	GetAPIGatewayResources(ctx context.Context, resources []string) ([]string, error)
 	GetAPIGatewayV2Resources(ctx context.Context, resources []string) ([]string, error)
 	GetEKSResources(ctx context.Context, resources []string) ([]string, error)
-	GetRDSResources(ctx context.Context, resources []string) ([]string, error)
+	GetRDSResources(ctx context.Context, resources []string, publicOnly bool) ([]string, error)
 	GetOpenSearchResources(ctx context.Context, resources []string) ([]string, error)
 	GetLambdaResources(ctx context.Context, resources []string) ([]string, error)
+	GetBYOIPResources(ctx context.Context, resources []string) ([]string, error)
+	ProbeService(ctx context.Context, service string) error
 }
 
 type AWSWrapper struct {
@@ -59,11 +62,46 @@ type AWSWrapper struct {
 	defaultRegion string
 }
 
-func NewWrapper(ctx context.Context, region string) (IAWSWrapper, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+// Credentials selects how NewWrapper authenticates, with the default credential chain if empty
+type Credentials struct {
+	// Profile is a named profile of the shared AWS config, e.g. an SSO session
+	Profile string
+	// RoleARN is a role assumed with the OIDC token in TokenFile, e.g. of EKS IRSA or GitHub Actions
+	RoleARN     string
+	TokenFile   string
+	SessionName string
+}
+
+// NewWrapper authenticates with the default credential chain, the named profile of the shared AWS
+// config, e.g. an SSO session, or a role assumed with a web identity token, as selected by creds
+func NewWrapper(ctx context.Context, region string, creds Credentials) (IAWSWrapper, error) {
+	opts := []func(*config.LoadOptions) error{config.WithRegion(region), tlsmode.AWS}
+	if creds.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(creds.Profile))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
+		if creds.Profile != "" {
+			return nil, fmt.Errorf("aws: unable to load SDK config for profile %s, %w", creds.Profile, err)
+		}
 		return nil, fmt.Errorf("aws: unable to load SDK config, %w", err)
 	}
+
+	if creds.RoleARN != "" {
+		// AssumeRoleWithWebIdentity is authenticated by the token rather than signed, so the STS client
+		// doesn't need credentials of its own. The token file is read on every renewal.
+		provider := stscreds.NewWebIdentityRoleProvider(
+			sts.NewFromConfig(cfg),
+			creds.RoleARN,
+			stscreds.IdentityTokenFile(creds.TokenFile),
+			func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = creds.SessionName
+			},
+		)
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
 	return &AWSWrapper{cfg: &cfg, defaultRegion: region}, nil
 }
 
@@ -76,6 +114,7 @@ func (w *AWSWrapper) AssumeRole(ctx context.Context, role string) (IAWSWrapper,
 		ctx,
 		config.WithRegion(w.defaultRegion),
 		config.WithCredentialsProvider(provider),
+		tlsmode.AWS,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("aws: unable to load SDK config with role %s, %w", role, err)
@@ -84,12 +123,10 @@ func (w *AWSWrapper) AssumeRole(ctx context.Context, role string) (IAWSWrapper,
 	return &AWSWrapper{cfg: &cfg, defaultRegion: w.defaultRegion}, nil
 }
 
-func (w *AWSWrapper) ChangeRegion(region string) {
-	w.cfg.Region = region
-}
-
-func (w *AWSWrapper) ResetRegion() {
-	w.cfg.Region = w.defaultRegion
+func (w *AWSWrapper) InRegion(region string) IAWSWrapper {
+	cfg := w.cfg.Copy()
+	cfg.Region = region
+	return &AWSWrapper{cfg: &cfg, defaultRegion: w.defaultRegion}
 }
 
 // Return nil if able to get the caller identity and the account is set
@@ -186,7 +223,7 @@ func (w *AWSWrapper) GetEC2Resources(ctx context.Context, resources []string) ([
 
 		for _, reservation := range resp.Reservations {
 			for _, instance := range reservation.Instances {
-				logger.GetLogger(ctx).Trace().Msgf("found instance %s", *instance.InstanceId)
+				logger.PerResource(ctx, "found instance").Trace().Msgf("found instance %s", *instance.InstanceId)
 				if instance.PublicDnsName != nil {
 					resources = append(resources, *instance.PublicDnsName)
 				}
@@ -213,7 +250,7 @@ func (w *AWSWrapper) GetEIPResources(ctx context.Context, resources []string) ([
 		}
 
 		for _, address := range resp.Addresses {
-			logger.GetLogger(ctx).Trace().Msgf("found address %s", *address.AllocationId)
+			logger.PerResource(ctx, "found address").Trace().Msgf("found address %s", *address.AllocationId)
 			if address.PublicIp != nil {
 				resources = append(resources, *address.PublicIp)
 			}
@@ -240,7 +277,7 @@ func (w *AWSWrapper) GetELBResources(ctx context.Context, resources []string) ([
 		}
 
 		for _, loadBalancer := range resp.LoadBalancers {
-			logger.GetLogger(ctx).Trace().Msgf("found load balancer %s", *loadBalancer.LoadBalancerArn)
+			logger.PerResource(ctx, "found load balancer").Trace().Msgf("found load balancer %s", *loadBalancer.LoadBalancerArn)
 			if loadBalancer.DNSName != nil {
 				resources = append(resources, *loadBalancer.DNSName)
 			}
@@ -255,7 +292,7 @@ func (w *AWSWrapper) GetELBResources(ctx context.Context, resources []string) ([
 	return resources, nil
 }
 
-func (w *AWSWrapper) GetS3Resources(ctx context.Context, resources []string) ([]string, error) {
+func (w *AWSWrapper) GetS3Resources(ctx context.Context, resources []string, includePrivate bool) ([]string, error) {
 	client := s3.NewFromConfig(*w.cfg)
 	logger.GetLogger(ctx).Trace().Msgf("getting S3 bucket resources")
 
@@ -273,17 +310,19 @@ func (w *AWSWrapper) GetS3Resources(ctx context.Context, resources []string) ([]
 		}
 
 		for _, bucket := range resp.Buckets {
-			logger.GetLogger(ctx).Trace().Msgf("found bucket %s", *bucket.Name)
+			logger.PerResource(ctx, "found bucket").Trace().Msgf("found bucket %s", *bucket.Name)
 
-			isPublic, err := w.isS3Public(ctx, client, bucket.Name)
-			if err != nil {
-				logger.GetLogger(ctx).Warn().Err(err).Msgf("failed to determine if %s bucket is public, assuming public", *bucket.Name)
-				isPublic = true
-			}
+			if !includePrivate {
+				isPublic, err := w.isS3Public(ctx, client, bucket.Name)
+				if err != nil {
+					logger.GetLogger(ctx).Warn().Err(err).Msgf("failed to determine if %s bucket is public, assuming public", *bucket.Name)
+					isPublic = true
+				}
 
-			if !isPublic {
-				logger.GetLogger(ctx).Trace().Msgf("%s bucket is private, skipping", *bucket.Name)
-				continue
+				if !isPublic {
+					logger.PerResource(ctx, "private bucket").Trace().Msgf("%s bucket is private, skipping", *bucket.Name)
+					continue
+				}
 			}
 
 			isWebsite, err := w.isS3Website(ctx, client, bucket.Name)
@@ -430,7 +469,7 @@ func (w *AWSWrapper) GetACMResources(ctx context.Context, resources []string) ([
 		}
 
 		for _, certificate := range resp.CertificateSummaryList {
-			logger.GetLogger(ctx).Trace().Msgf("found certificate %s", *certificate.CertificateArn)
+			logger.PerResource(ctx, "found certificate").Trace().Msgf("found certificate %s", *certificate.CertificateArn)
 			if certificate.DomainName != nil {
 				resources = append(resources, *certificate.DomainName)
 			}
@@ -476,7 +515,7 @@ func (w *AWSWrapper) GetRoute53Resources(ctx context.Context, resources []string
 		}
 
 		for _, zone := range resp.HostedZones {
-			logger.GetLogger(ctx).Trace().Msgf("found hosted zone %s", *zone.Id)
+			logger.PerResource(ctx, "found hosted zone").Trace().Msgf("found hosted zone %s", *zone.Id)
 
 			resources = append(resources, *zone.Name)
 
@@ -541,7 +580,7 @@ func (w *AWSWrapper) GetCloudFrontResources(ctx context.Context, resources []str
 		}
 
 		for _, distribution := range resp.DistributionList.Items {
-			logger.GetLogger(ctx).Trace().Msgf("found distribution %s", *distribution.Id)
+			logger.PerResource(ctx, "found distribution").Trace().Msgf("found distribution %s", *distribution.Id)
 			resources = append(resources, *distribution.DomainName)
 
 			for _, origin := range distribution.Origins.Items {
@@ -572,7 +611,7 @@ func (w *AWSWrapper) GetAPIGatewayResources(ctx context.Context, resources []str
 		}
 
 		for _, api := range resp.Items {
-			logger.GetLogger(ctx).Trace().Msgf("found api %s", *api.Id)
+			logger.PerResource(ctx, "found api").Trace().Msgf("found api %s", *api.Id)
 			resources = append(resources, fmt.Sprintf("%s.execute-api.%s.amazonaws.com", *api.Id, w.cfg.Region))
 		}
 	}
@@ -587,7 +626,7 @@ func (w *AWSWrapper) GetAPIGatewayResources(ctx context.Context, resources []str
 		}
 
 		for _, domain := range resp.Items {
-			logger.GetLogger(ctx).Trace().Msgf("found domain %s", *domain.DomainNameId)
+			logger.PerResource(ctx, "found domain").Trace().Msgf("found domain %s", *domain.DomainNameId)
 			resources = append(resources, *domain.DomainName)
 		}
 	}
@@ -612,7 +651,7 @@ func (w *AWSWrapper) GetAPIGatewayV2Resources(ctx context.Context, resources []s
 		}
 
 		for _, api := range resp.Items {
-			logger.GetLogger(ctx).Trace().Msgf("found api %s", *api.ApiId)
+			logger.PerResource(ctx, "found api").Trace().Msgf("found api %s", *api.ApiId)
 			if api.ApiEndpoint != nil {
 				resources = append(resources, *api.ApiEndpoint)
 			}
@@ -644,7 +683,7 @@ func (w *AWSWrapper) GetEKSResources(ctx context.Context, resources []string) ([
 		}
 
 		for _, cluster := range resp.Clusters {
-			logger.GetLogger(ctx).Trace().Msgf("found cluster %s", cluster)
+			logger.PerResource(ctx, "found cluster").Trace().Msgf("found cluster %s", cluster)
 
 			detail, err := client.DescribeCluster(
 				ctx,
@@ -670,10 +709,13 @@ func (w *AWSWrapper) GetEKSResources(ctx context.Context, resources []string) ([
 	return resources, nil
 }
 
-func (w *AWSWrapper) GetRDSResources(ctx context.Context, resources []string) ([]string, error) {
+func (w *AWSWrapper) GetRDSResources(ctx context.Context, resources []string, publicOnly bool) ([]string, error) {
 	client := rds.NewFromConfig(*w.cfg)
 	logger.GetLogger(ctx).Trace().Msgf("getting RDS Database resources")
 
+	// Clusters with a publicly accessible member, for publicOnly
+	publicClusters := map[string]bool{}
+
 	instancePager := rds.NewDescribeDBInstancesPaginator(client, &rds.DescribeDBInstancesInput{})
 	for instancePager.HasMorePages() {
 		resp, err := instancePager.NextPage(ctx)
@@ -682,7 +724,17 @@ func (w *AWSWrapper) GetRDSResources(ctx context.Context, resources []string) ([
 		}
 
 		for _, db := range resp.DBInstances {
-			logger.GetLogger(ctx).Trace().Msgf("found db %s", *db.DBInstanceIdentifier)
+			logger.PerResource(ctx, "found db").Trace().Msgf("found db %s", *db.DBInstanceIdentifier)
+
+			isPublic := db.PubliclyAccessible != nil && *db.PubliclyAccessible
+			if isPublic && db.DBClusterIdentifier != nil {
+				publicClusters[*db.DBClusterIdentifier] = true
+			}
+
+			if publicOnly && !isPublic {
+				logger.PerResource(ctx, "private db").Trace().Msgf("%s db is not publicly accessible, skipping", *db.DBInstanceIdentifier)
+				continue
+			}
 
 			if db.Endpoint != nil && db.Endpoint.Address != nil {
 				resources = append(resources, *db.Endpoint.Address)
@@ -698,7 +750,13 @@ func (w *AWSWrapper) GetRDSResources(ctx context.Context, resources []string) ([
 		}
 
 		for _, db := range resp.DBClusters {
-			logger.GetLogger(ctx).Trace().Msgf("found db %s", *db.DBClusterIdentifier)
+			logger.PerResource(ctx, "found db").Trace().Msgf("found db %s", *db.DBClusterIdentifier)
+
+			isPublic := publicClusters[*db.DBClusterIdentifier] || (db.PubliclyAccessible != nil && *db.PubliclyAccessible)
+			if publicOnly && !isPublic {
+				logger.PerResource(ctx, "private db cluster").Trace().Msgf("%s db cluster is not publicly accessible, skipping", *db.DBClusterIdentifier)
+				continue
+			}
 
 			if db.Endpoint != nil {
 				resources = append(resources, *db.Endpoint)
@@ -725,7 +783,7 @@ func (w *AWSWrapper) GetOpenSearchResources(ctx context.Context, resources []str
 		}
 
 		for _, app := range resp.ApplicationSummaries {
-			logger.GetLogger(ctx).Trace().Msgf("found app %s", *app.Id)
+			logger.PerResource(ctx, "found app").Trace().Msgf("found app %s", *app.Id)
 
 			if app.Endpoint != nil {
 				resources = append(resources, *app.Endpoint)
@@ -748,7 +806,7 @@ func (w *AWSWrapper) GetLambdaResources(ctx context.Context, resources []string)
 		}
 
 		for _, function := range resp.Functions {
-			logger.GetLogger(ctx).Trace().Msgf("found function %s", *function.FunctionName)
+			logger.PerResource(ctx, "found function").Trace().Msgf("found function %s", *function.FunctionName)
 
 			urlConfig, err := client.GetFunctionUrlConfig(
 				ctx,
@@ -772,3 +830,71 @@ func (w *AWSWrapper) GetLambdaResources(ctx context.Context, resources []string)
 
 	return resources, nil
 }
+
+func (w *AWSWrapper) GetBYOIPResources(ctx context.Context, resources []string) ([]string, error) {
+	client := ec2.NewFromConfig(*w.cfg)
+	logger.GetLogger(ctx).Trace().Msgf("getting Bring Your Own IP (BYOIP) CIDR resources")
+
+	pager := ec2.NewDescribeByoipCidrsPaginator(client, &ec2.DescribeByoipCidrsInput{
+		MaxResults: aws.Int32(100),
+	})
+
+	for pager.HasMorePages() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return resources, fmt.Errorf("aws: getting BYOIP resources, %w", err)
+		}
+
+		for _, cidr := range resp.ByoipCidrs {
+			logger.PerResource(ctx, "found BYOIP CIDR").Trace().Str("state", string(cidr.State)).Msgf("found BYOIP CIDR %s", aws.ToString(cidr.Cidr))
+			// Only advertised ranges are reachable from the internet
+			if cidr.Cidr != nil && cidr.State == ec2_t.ByoipCidrStateAdvertised {
+				resources = append(resources, *cidr.Cidr)
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+// ProbeService makes the smallest list call of a service, to check the service can be discovered
+// without discovering it
+func (w *AWSWrapper) ProbeService(ctx context.Context, service string) error {
+	var err error
+	switch service {
+	case serviceEC2:
+		_, err = ec2.NewFromConfig(*w.cfg).DescribeInstances(ctx, &ec2.DescribeInstancesInput{MaxResults: aws.Int32(5)})
+	case serviceEIP:
+		_, err = ec2.NewFromConfig(*w.cfg).DescribeAddressesAttribute(ctx, &ec2.DescribeAddressesAttributeInput{MaxResults: aws.Int32(1)})
+	case serviceELB:
+		_, err = elb.NewFromConfig(*w.cfg).DescribeLoadBalancers(ctx, &elb.DescribeLoadBalancersInput{PageSize: aws.Int32(1)})
+	case serviceS3:
+		_, err = s3.NewFromConfig(*w.cfg).ListBuckets(ctx, &s3.ListBucketsInput{MaxBuckets: aws.Int32(1)})
+	case serviceACM:
+		_, err = acm.NewFromConfig(*w.cfg).ListCertificates(ctx, &acm.ListCertificatesInput{MaxItems: aws.Int32(1)})
+	case serviceRoute53:
+		_, err = route53.NewFromConfig(*w.cfg).ListHostedZones(ctx, &route53.ListHostedZonesInput{MaxItems: aws.Int32(1)})
+	case serviceCloudFront:
+		_, err = cloudfront.NewFromConfig(*w.cfg).ListDistributions(ctx, &cloudfront.ListDistributionsInput{MaxItems: aws.Int32(1)})
+	case serviceAPIGateway:
+		_, err = apigateway.NewFromConfig(*w.cfg).GetRestApis(ctx, &apigateway.GetRestApisInput{Limit: aws.Int32(1)})
+	case serviceAPIGatewayV2:
+		_, err = apigatewayv2.NewFromConfig(*w.cfg).GetApis(ctx, &apigatewayv2.GetApisInput{MaxResults: aws.String("1")})
+	case serviceEKS:
+		_, err = eks.NewFromConfig(*w.cfg).ListClusters(ctx, &eks.ListClustersInput{MaxResults: aws.Int32(1)})
+	case serviceRDS:
+		_, err = rds.NewFromConfig(*w.cfg).DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{MaxRecords: aws.Int32(20)})
+	case serviceOpenSearch:
+		_, err = opensearch.NewFromConfig(*w.cfg).ListApplications(ctx, &opensearch.ListApplicationsInput{MaxResults: 1})
+	case serviceLambda:
+		_, err = lambda.NewFromConfig(*w.cfg).ListFunctions(ctx, &lambda.ListFunctionsInput{MaxItems: aws.Int32(1)})
+	case serviceBYOIP:
+		_, err = ec2.NewFromConfig(*w.cfg).DescribeByoipCidrs(ctx, &ec2.DescribeByoipCidrsInput{MaxResults: aws.Int32(5)})
+	default:
+		return fmt.Errorf("aws: unknown service %s", service)
+	}
+	if err != nil {
+		return fmt.Errorf("aws: probing %s, %w", service, err)
+	}
+	return nil
+}
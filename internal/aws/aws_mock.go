@@ -30,12 +30,9 @@ func (m *MockWrapper) AssumeRole(_ context.Context, role string) (IAWSWrapper, e
 	return nil, args.Error(1)
 }
 
-func (m *MockWrapper) ChangeRegion(region string) {
-	m.Called(region)
-}
-
-func (m *MockWrapper) ResetRegion() {
-	m.Called()
+func (m *MockWrapper) InRegion(region string) IAWSWrapper {
+	args := m.Called(region)
+	return args.Get(0).(IAWSWrapper)
 }
 
 func (m *MockWrapper) CheckConnection(_ context.Context) error {
@@ -76,8 +73,8 @@ func (m *MockWrapper) GetELBResources(_ context.Context, resources []string) ([]
 	return getStringSlice(args.Get(0)), args.Error(1)
 }
 
-func (m *MockWrapper) GetS3Resources(_ context.Context, resources []string) ([]string, error) {
-	args := m.Called(resources)
+func (m *MockWrapper) GetS3Resources(_ context.Context, resources []string, includePrivate bool) ([]string, error) {
+	args := m.Called(resources, includePrivate)
 	return getStringSlice(args.Get(0)), args.Error(1)
 }
 
@@ -111,8 +108,8 @@ func (m *MockWrapper) GetEKSResources(_ context.Context, resources []string) ([]
 	return getStringSlice(args.Get(0)), args.Error(1)
 }
 
-func (m *MockWrapper) GetRDSResources(_ context.Context, resources []string) ([]string, error) {
-	args := m.Called(resources)
+func (m *MockWrapper) GetRDSResources(_ context.Context, resources []string, publicOnly bool) ([]string, error) {
+	args := m.Called(resources, publicOnly)
 	return getStringSlice(args.Get(0)), args.Error(1)
 }
 
@@ -126,6 +123,16 @@ func (m *MockWrapper) GetLambdaResources(_ context.Context, resources []string)
 	return getStringSlice(args.Get(0)), args.Error(1)
 }
 
+func (m *MockWrapper) GetBYOIPResources(_ context.Context, resources []string) ([]string, error) {
+	args := m.Called(resources)
+	return getStringSlice(args.Get(0)), args.Error(1)
+}
+
+func (m *MockWrapper) ProbeService(_ context.Context, service string) error {
+	args := m.Called(service)
+	return args.Error(0)
+}
+
 func getStringSlice(value interface{}) []string {
 	if value == nil {
 		return nil
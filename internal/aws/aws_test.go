@@ -0,0 +1,99 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+)
+
+func setSharedConfig(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	t.Setenv("AWS_CONFIG_FILE", path)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+}
+
+func TestNewWrapper_Profile_UsesProfileCredentials(t *testing.T) {
+	setSharedConfig(t, "[profile org-b]\naws_access_key_id = AKIAORGB\naws_secret_access_key = secret\n")
+
+	wrapper, err := NewWrapper(context.Background(), "eu-west-2", Credentials{Profile: "org-b"})
+	require.NoError(t, err)
+
+	creds, err := wrapper.(*AWSWrapper).cfg.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIAORGB", creds.AccessKeyID)
+	assert.Equal(t, "eu-west-2", wrapper.(*AWSWrapper).cfg.Region)
+}
+
+func TestNewWrapper_UnknownProfile_Err(t *testing.T) {
+	setSharedConfig(t, "[profile org-b]\naws_access_key_id = AKIAORGB\naws_secret_access_key = secret\n")
+
+	_, err := NewWrapper(context.Background(), "eu-west-2", Credentials{Profile: "org-c"})
+
+	assert.ErrorContains(t, err, "unable to load SDK config for profile org-c")
+}
+
+func TestNewWrapper_WebIdentity_AssumesRole(t *testing.T) {
+	setSharedConfig(t, "")
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIAWEBIDENTITY</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("AWS_ENDPOINT_URL_STS", server.URL)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("oidc-token"), 0o600))
+
+	wrapper, err := NewWrapper(context.Background(), "eu-west-2", Credentials{
+		RoleARN:     "arn:aws:iam::123456789012:role/connector",
+		TokenFile:   tokenFile,
+		SessionName: "asm-cloud-connector",
+	})
+	require.NoError(t, err)
+
+	creds, err := wrapper.(*AWSWrapper).cfg.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ASIAWEBIDENTITY", creds.AccessKeyID)
+	assert.Equal(t, "oidc-token", form.Get("WebIdentityToken"))
+	assert.Equal(t, "arn:aws:iam::123456789012:role/connector", form.Get("RoleArn"))
+	assert.Equal(t, "asm-cloud-connector", form.Get("RoleSessionName"))
+}
+
+func TestNewWrapper_WebIdentity_MissingToken_Err(t *testing.T) {
+	setSharedConfig(t, "")
+	creds := Credentials{RoleARN: "arn:aws:iam::123456789012:role/connector", TokenFile: filepath.Join(t.TempDir(), "token")}
+
+	wrapper, err := NewWrapper(context.Background(), "eu-west-2", creds)
+	require.NoError(t, err)
+	_, err = wrapper.(*AWSWrapper).cfg.Credentials.Retrieve(context.Background())
+
+	var authErr *cloud_provider_t.AuthError
+	require.ErrorAs(t, diagnose(err, creds), &authErr)
+	assert.Equal(t, "the web identity token file "+creds.TokenFile+" could not be read", authErr.Cause)
+}
@@ -3,10 +3,15 @@ package aws
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
 	"github.com/hexiosec/asm-cloud-connector/internal/config"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/progress"
+	"github.com/hexiosec/asm-cloud-connector/internal/reporting"
+	"github.com/hexiosec/asm-cloud-connector/internal/warnings"
 )
 
 type AWSProvider struct {
@@ -14,9 +19,9 @@ type AWSProvider struct {
 	wrapper IAWSWrapper
 }
 
-func NewAWSProvider(cfg *config.Config) (cloud_provider_t.CloudProvider, error) {
+func NewAWSProvider(cfg *config.AWSCloudProvider) (cloud_provider_t.CloudProvider, error) {
 	return &AWSProvider{
-		cfg: cfg.AWS,
+		cfg: cfg,
 	}, nil
 }
 
@@ -24,14 +29,19 @@ func (c *AWSProvider) GetName() string {
 	return "AWS"
 }
 
+func (c *AWSProvider) GetProfile() *config.CloudProvider {
+	return &c.cfg.CloudProvider
+}
+
 func (c *AWSProvider) Authenticate(ctx context.Context) error {
-	wrapper, err := NewWrapper(ctx, c.cfg.DefaultRegion)
+	creds := profileCredentials(c.cfg)
+	wrapper, err := NewWrapper(ctx, c.cfg.DefaultRegion, creds)
 	if err != nil {
-		return err
+		return diagnose(err, creds)
 	}
 
 	if err := wrapper.CheckConnection(ctx); err != nil {
-		return err
+		return diagnose(err, creds)
 	}
 
 	c.wrapper = wrapper
@@ -47,10 +57,22 @@ func (c *AWSProvider) GetAPIKey(ctx context.Context) (string, error) {
 	return c.wrapper.GetSecretString(ctx, *c.cfg.APIKeySecret)
 }
 
+// ListAccounts returns the accounts a role is assumed in, listing the accounts of the organisation
+// if list_all_accounts is set
+func (c *AWSProvider) ListAccounts(ctx context.Context) ([]string, error) {
+	if c.cfg.AssumeRole == nil {
+		return nil, nil
+	}
+	if c.cfg.ListAllAccounts {
+		return c.wrapper.ListAllAccounts(ctx)
+	}
+	return c.cfg.Accounts, nil
+}
+
 func (c *AWSProvider) GetResources(ctx context.Context) ([]string, error) {
 	// Use the default config
 	if !c.cfg.ListAllAccounts && len(c.cfg.Accounts) == 0 {
-		return getResources(ctx, c.wrapper, c.cfg.Services, []string{})
+		return getResources(ctx, c.wrapper, c.cfg.Services, c.cfg.ServiceTimeout, c.cfg.Concurrency, []string{})
 	}
 
 	var err error
@@ -64,71 +86,177 @@ func (c *AWSProvider) GetResources(ctx context.Context) ([]string, error) {
 
 	resources := []string{}
 	for _, account := range accounts {
+		start := time.Now()
 		ctx = logger.WithLogger(ctx, logger.GetLogger(ctx).With().Str("account", account).Logger())
+		ctx = reporting.WithTags(ctx, "account", account)
 		role := fmt.Sprintf("arn:aws:iam::%s:role/%s", account, *c.cfg.AssumeRole)
 		logger.GetLogger(ctx).Trace().Msgf("assuming role %s", role)
 
 		assumeWrapper, err := c.wrapper.AssumeRole(ctx, role)
 		if err != nil {
 			logger.GetLogger(ctx).Warn().Err(err).Msgf("unable to load config with role %s, skipping account %s", role, account)
+			warnings.Record(ctx, warnings.SkippedAccount, "account %s skipped, %s", account, err)
 			continue
 		}
 
-		resources, err = getResources(ctx, assumeWrapper, c.cfg.Services, resources)
+		before := len(resources)
+		resources, err = getResources(ctx, assumeWrapper, c.cfg.Services, c.cfg.ServiceTimeout, c.cfg.Concurrency, resources)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get resources for account %s %w", account, err)
 		}
+		logger.GetLogger(ctx).Info().
+			Int("resource_count", len(resources)-before).
+			Dur("duration", time.Since(start)).
+			Msgf("got resources of account %s", account)
 	}
 
 	return resources, nil
 }
 
-func getResources(ctx context.Context, wrapper IAWSWrapper, services *config.AWSServices, resources []string) ([]string, error) {
-	var err error
+// Preflight checks the accounts can be listed and their role assumed, and probes each enabled
+// service. Services are probed in the first account whose role could be assumed, or the account of
+// the credentials if no role is assumed.
+func (c *AWSProvider) Preflight(ctx context.Context) []cloud_provider_t.Check {
+	var checks []cloud_provider_t.Check
+	wrapper := c.wrapper
 
+	if c.cfg.AssumeRole != nil && (c.cfg.ListAllAccounts || len(c.cfg.Accounts) > 0) {
+		accounts := c.cfg.Accounts
+		if c.cfg.ListAllAccounts {
+			var err error
+			accounts, err = c.wrapper.ListAllAccounts(ctx)
+			checks = append(checks, cloud_provider_t.Check{
+				Name: "ListAccounts",
+				Err:  err,
+				Hint: "Allow organizations:ListAccounts for the connector in the management account",
+			})
+			if err != nil {
+				return checks
+			}
+		}
+
+		wrapper = nil
+		for _, account := range accounts {
+			role := fmt.Sprintf("arn:aws:iam::%s:role/%s", account, *c.cfg.AssumeRole)
+			assumed, err := c.wrapper.AssumeRole(ctx, role)
+			if err == nil {
+				// Credentials are only fetched on the first call
+				err = assumed.CheckConnection(ctx)
+			}
+			checks = append(checks, cloud_provider_t.Check{
+				Name: "AssumeRole " + account,
+				Err:  err,
+				Hint: fmt.Sprintf("Create %s trusting the connector, and allow the connector sts:AssumeRole on it", role),
+			})
+			if err == nil && wrapper == nil {
+				wrapper = assumed
+			}
+		}
+		if wrapper == nil {
+			return checks
+		}
+	}
+
+	_, err := wrapper.GetRegions(ctx)
+	checks = append(checks, cloud_provider_t.Check{
+		Name: "Regions",
+		Err:  err,
+		Hint: "Allow " + strings.Join(regionPermissions, ", "),
+	})
+
+	for _, check := range serviceChecks(c.cfg.Services) {
+		if !check.enabled {
+			continue
+		}
+		checks = append(checks, cloud_provider_t.Check{
+			Name: check.name,
+			Err:  wrapper.ProbeService(ctx, check.name),
+			Hint: "Allow " + strings.Join(servicePermissions[check.name], ", "),
+		})
+	}
+
+	return checks
+}
+
+// serviceCheck is the discovery of the resources of a service in a region
+type serviceCheck struct {
+	name    string
+	enabled bool
+	f       func(wrapper IAWSWrapper, ctx context.Context, resources []string) ([]string, error)
+}
+
+// serviceChecks returns the discovery of each service, enabled as in services
+func serviceChecks(services *config.AWSServices) []serviceCheck {
+	return []serviceCheck{
+		{serviceEC2, services.CheckEC2.Enabled, IAWSWrapper.GetEC2Resources},
+		{serviceEIP, services.CheckEIP.Enabled, IAWSWrapper.GetEIPResources},
+		{serviceELB, services.CheckELB.Enabled, IAWSWrapper.GetELBResources},
+		{serviceS3, services.CheckS3.Enabled, func(wrapper IAWSWrapper, ctx context.Context, resources []string) ([]string, error) {
+			return wrapper.GetS3Resources(ctx, resources, services.CheckS3.IncludePrivate)
+		}},
+		{serviceACM, services.CheckACM.Enabled, IAWSWrapper.GetACMResources},
+		{serviceRoute53, services.CheckRoute53.Enabled, IAWSWrapper.GetRoute53Resources},
+		{serviceCloudFront, services.CheckCloudFront.Enabled, IAWSWrapper.GetCloudFrontResources},
+		{serviceAPIGateway, services.CheckAPIGateway.Enabled, IAWSWrapper.GetAPIGatewayResources},
+		{serviceAPIGatewayV2, services.CheckAPIGatewayV2.Enabled, IAWSWrapper.GetAPIGatewayV2Resources},
+		{serviceEKS, services.CheckEKS.Enabled, IAWSWrapper.GetEKSResources},
+		{serviceRDS, services.CheckRDS.Enabled, func(wrapper IAWSWrapper, ctx context.Context, resources []string) ([]string, error) {
+			return wrapper.GetRDSResources(ctx, resources, services.CheckRDS.PublicOnly)
+		}},
+		{serviceOpenSearch, services.CheckOpenSearch.Enabled, IAWSWrapper.GetOpenSearchResources},
+		{serviceLambda, services.CheckLambda.Enabled, IAWSWrapper.GetLambdaResources},
+		{serviceBYOIP, services.CheckBYOIP.Enabled, IAWSWrapper.GetBYOIPResources},
+	}
+}
+
+// regionCheck is the discovery of the resources of a service in a region
+type regionCheck struct {
+	serviceCheck
+	region string
+}
+
+// getResources appends the resources of each enabled service in each region to resources, checking
+// up to concurrency services and regions at a time
+func getResources(ctx context.Context, wrapper IAWSWrapper, services *config.AWSServices, serviceTimeout time.Duration, concurrency int, resources []string) ([]string, error) {
 	regions, err := wrapper.GetRegions(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("could not determine active regions, %w", err)
 	}
 
-	defs := []struct {
-		name    string
-		enabled bool
-		f       func(ctx context.Context, resources []string) ([]string, error)
-	}{
-		{"EC2", services.CheckEC2, wrapper.GetEC2Resources},
-		{"EIP", services.CheckEIP, wrapper.GetEIPResources},
-		{"ELB", services.CheckELB, wrapper.GetELBResources},
-		{"S3", services.CheckS3, wrapper.GetS3Resources},
-		{"ACM", services.CheckACM, wrapper.GetACMResources},
-		{"Route53", services.CheckRoute53, wrapper.GetRoute53Resources},
-		{"CloudFront", services.CheckCloudFront, wrapper.GetCloudFrontResources},
-		{"APIGateway", services.CheckAPIGateway, wrapper.GetAPIGatewayResources},
-		{"APIGatewayV2", services.CheckAPIGatewayV2, wrapper.GetAPIGatewayV2Resources},
-		{"EKS", services.CheckEKS, wrapper.GetEKSResources},
-		{"RDS", services.CheckRDS, wrapper.GetRDSResources},
-		{"OpenSearch", services.CheckOpenSearch, wrapper.GetOpenSearchResources},
-		{"Lambda", services.CheckLambda, wrapper.GetLambdaResources},
-	}
-
-	for _, def := range defs {
+	var checks []regionCheck
+	for _, def := range serviceChecks(services) {
 		if !def.enabled {
 			logger.GetLogger(ctx).Trace().Msgf("skipping %s discovery; check disabled", def.name)
 			continue
 		}
-
 		for _, region := range regions {
-			ctx = logger.WithLogger(ctx, logger.GetLogger(ctx).With().Str("region", region).Logger())
-			logger.GetLogger(ctx).Trace().Msgf("checking region %s", region)
-			wrapper.ChangeRegion(region)
+			checks = append(checks, regionCheck{def, region})
+		}
+	}
 
-			resources, err = def.f(ctx, resources)
-			if err != nil {
-				logger.GetLogger(ctx).Warn().Err(err).Msgf("failed to get %s resources", def.name)
-			}
+	found := make([][]string, len(checks))
+	cloud_provider_t.RunAll(len(checks), concurrency, func(idx int) {
+		check := checks[idx]
+		rCtx := logger.WithLogger(ctx, logger.GetLogger(ctx).With().Str("region", check.region).Logger())
+		defer reporting.Repanic(reporting.WithTags(rCtx, "service", check.name, "region", check.region))
+		logger.GetLogger(rCtx).Trace().Msgf("checking %s in region %s", check.name, check.region)
+
+		start := time.Now()
+		svcCtx, cancel := cloud_provider_t.WithTimeout(rCtx, serviceTimeout)
+		res, err := check.f(wrapper.InRegion(check.region), svcCtx, nil)
+		cancel()
+		elapsed := time.Since(start)
+		if err != nil {
+			logger.GetLogger(rCtx).Warn().Err(err).Msgf("failed to get %s resources", check.name)
+			warnings.Record(ctx, warnings.ServiceCheck, "%s in %s failed, %s", check.name, check.region, err)
 		}
+		logger.GetLogger(rCtx).Debug().Int("resource_count", len(res)).Dur("duration", elapsed).Msgf("checked %s in region %s", check.name, check.region)
+		progress.Service(rCtx, check.name, check.region, len(res), elapsed, err)
+		found[idx] = res
+	})
 
-		wrapper.ResetRegion()
+	for _, res := range found {
+		resources = append(resources, res...)
 	}
 	return resources, nil
 }
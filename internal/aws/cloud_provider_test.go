@@ -36,14 +36,13 @@ func TestAWSProvider_GetAPIKey_ReturnsSecret(t *testing.T) {
 
 func TestAWSProvider_GetResources_DefaultAccountConfig(t *testing.T) {
 	cfg := &config.AWSCloudProvider{
-		Services: &config.AWSServices{CheckEC2: true},
+		Services: &config.AWSServices{CheckEC2: config.Check{Enabled: true}},
 	}
 	provider, mockWrapper := newProviderWithMock(t, cfg)
 
 	mockWrapper.On("GetRegions").Return([]string{"us-east-1"}, nil)
-	mockWrapper.On("ChangeRegion", "us-east-1").Return()
+	mockWrapper.On("InRegion", "us-east-1").Return(mockWrapper)
 	mockWrapper.On("GetEC2Resources", mock.Anything).Return([]string{"i-1"}, nil).Once()
-	mockWrapper.On("ResetRegion").Return()
 
 	resources, err := provider.GetResources(context.Background())
 	assert.NoError(t, err)
@@ -55,7 +54,7 @@ func TestAWSProvider_GetResources_ListAllAccountsError(t *testing.T) {
 	cfg := &config.AWSCloudProvider{
 		ListAllAccounts: true,
 		AssumeRole:      &role,
-		Services:        &config.AWSServices{CheckEC2: true},
+		Services:        &config.AWSServices{CheckEC2: config.Check{Enabled: true}},
 	}
 	provider, mockWrapper := newProviderWithMock(t, cfg)
 
@@ -71,7 +70,7 @@ func TestAWSProvider_GetResources_AssumeRolePerAccount(t *testing.T) {
 	cfg := &config.AWSCloudProvider{
 		Accounts:   []string{account},
 		AssumeRole: &role,
-		Services:   &config.AWSServices{CheckEC2: true},
+		Services:   &config.AWSServices{CheckEC2: config.Check{Enabled: true}},
 	}
 
 	parent := NewMockWrapper(t).(*MockWrapper)
@@ -86,9 +85,8 @@ func TestAWSProvider_GetResources_AssumeRolePerAccount(t *testing.T) {
 		Return(child, nil)
 
 	child.On("GetRegions").Return([]string{"us-east-1"}, nil)
-	child.On("ChangeRegion", "us-east-1").Return()
+	child.On("InRegion", "us-east-1").Return(child)
 	child.On("GetEC2Resources", mock.Anything).Return([]string{"acct-res"}, nil).Once()
-	child.On("ResetRegion").Return()
 
 	resources, err := provider.GetResources(context.Background())
 	assert.NoError(t, err)
@@ -101,7 +99,7 @@ func TestAWSProvider_GetResources_AssumeRoleErr_Continue(t *testing.T) {
 	cfg := &config.AWSCloudProvider{
 		Accounts:   []string{account},
 		AssumeRole: &role,
-		Services:   &config.AWSServices{CheckEC2: true},
+		Services:   &config.AWSServices{CheckEC2: config.Check{Enabled: true}},
 	}
 
 	parent := NewMockWrapper(t).(*MockWrapper)
@@ -120,31 +118,126 @@ func TestAWSProvider_GetResources_AssumeRoleErr_Continue(t *testing.T) {
 
 func Test_getResources_GetRegionsError(t *testing.T) {
 	mockWrapper := NewMockWrapper(t).(*MockWrapper)
-	services := &config.AWSServices{CheckEC2: true}
+	services := &config.AWSServices{CheckEC2: config.Check{Enabled: true}}
 
 	mockWrapper.On("GetRegions").Return(nil, assert.AnError)
 
-	_, err := getResources(context.Background(), mockWrapper, services, nil)
+	_, err := getResources(context.Background(), mockWrapper, services, 0, 1, nil)
 	assert.ErrorContains(t, err, "could not determine active regions")
 	assert.ErrorIs(t, err, assert.AnError)
 }
 
 func Test_getResources_AggregatesResourcesAcrossRegions(t *testing.T) {
 	mockWrapper := NewMockWrapper(t).(*MockWrapper)
-	services := &config.AWSServices{CheckEC2: true}
+	services := &config.AWSServices{CheckEC2: config.Check{Enabled: true}}
 
 	mockWrapper.On("GetRegions").Return([]string{"us-east-1", "us-west-2"}, nil)
-	mockWrapper.On("ChangeRegion", "us-east-1").Return()
-	mockWrapper.On("ChangeRegion", "us-west-2").Return()
-	mockWrapper.On("GetEC2Resources", mock.Anything).Return([]string{"res-east"}, nil).Once()
-	mockWrapper.On("GetEC2Resources", mock.Anything).Return([]string{"res-east", "res-west"}, nil).Once()
-	mockWrapper.On("ResetRegion").Return()
-
-	resources, err := getResources(context.Background(), mockWrapper, services, []string{})
+	east := NewMockWrapper(t).(*MockWrapper)
+	west := NewMockWrapper(t).(*MockWrapper)
+	mockWrapper.On("InRegion", "us-east-1").Return(east)
+	mockWrapper.On("InRegion", "us-west-2").Return(west)
+	east.On("GetEC2Resources", mock.Anything).Return([]string{"res-east"}, nil).Once()
+	west.On("GetEC2Resources", mock.Anything).Return([]string{"res-west"}, nil).Once()
+
+	resources, err := getResources(context.Background(), mockWrapper, services, 0, 1, []string{})
 	assert.NoError(t, err)
 	assert.Equal(t, []string{"res-east", "res-west"}, resources)
 }
 
+func Test_getResources_Concurrency_KeepsOrder(t *testing.T) {
+	mockWrapper := NewMockWrapper(t).(*MockWrapper)
+	services := &config.AWSServices{
+		CheckEC2: config.Check{Enabled: true},
+		CheckEIP: config.Check{Enabled: true},
+	}
+
+	east := NewMockWrapper(t).(*MockWrapper)
+	west := NewMockWrapper(t).(*MockWrapper)
+	mockWrapper.On("GetRegions").Return([]string{"us-east-1", "us-west-2"}, nil)
+	mockWrapper.On("InRegion", "us-east-1").Return(east)
+	mockWrapper.On("InRegion", "us-west-2").Return(west)
+	east.On("GetEC2Resources", mock.Anything).Return([]string{"ec2-east"}, nil).Once()
+	west.On("GetEC2Resources", mock.Anything).Return([]string{"ec2-west"}, nil).Once()
+	east.On("GetEIPResources", mock.Anything).Return(nil, assert.AnError).Once()
+	west.On("GetEIPResources", mock.Anything).Return([]string{"eip-west"}, nil).Once()
+
+	resources, err := getResources(context.Background(), mockWrapper, services, 0, 4, []string{"existing"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"existing", "ec2-east", "ec2-west", "eip-west"}, resources)
+}
+
+func Test_getResources_PassesCheckOptions(t *testing.T) {
+	mockWrapper := NewMockWrapper(t).(*MockWrapper)
+	services := &config.AWSServices{
+		CheckS3:  config.S3Check{Enabled: true, IncludePrivate: true},
+		CheckRDS: config.RDSCheck{Enabled: true, PublicOnly: true},
+	}
+
+	mockWrapper.On("GetRegions").Return([]string{"us-east-1"}, nil)
+	mockWrapper.On("InRegion", "us-east-1").Return(mockWrapper)
+	mockWrapper.On("GetS3Resources", mock.Anything, true).Return([]string{"bucket"}, nil).Once()
+	mockWrapper.On("GetRDSResources", mock.Anything, true).Return([]string{"db"}, nil).Once()
+
+	resources, err := getResources(context.Background(), mockWrapper, services, 0, 1, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bucket", "db"}, resources)
+}
+
+func TestAWSProvider_ListAccounts(t *testing.T) {
+	role := "MyRole"
+
+	provider, _ := newProviderWithMock(t, &config.AWSCloudProvider{})
+	accounts, err := provider.ListAccounts(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, accounts)
+
+	provider, _ = newProviderWithMock(t, &config.AWSCloudProvider{Accounts: []string{"111111111111"}, AssumeRole: &role})
+	accounts, err = provider.ListAccounts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"111111111111"}, accounts)
+
+	provider, mockWrapper := newProviderWithMock(t, &config.AWSCloudProvider{ListAllAccounts: true, AssumeRole: &role})
+	mockWrapper.On("ListAllAccounts").Return([]string{"111111111111", "222222222222"}, nil)
+	accounts, err = provider.ListAccounts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"111111111111", "222222222222"}, accounts)
+}
+
+func TestAWSProvider_Preflight(t *testing.T) {
+	role := "MyRole"
+	cfg := &config.AWSCloudProvider{
+		Accounts:   []string{"111111111111", "222222222222"},
+		AssumeRole: &role,
+		Services: &config.AWSServices{
+			CheckEC2: config.Check{Enabled: true},
+			CheckS3:  config.S3Check{Enabled: true},
+		},
+	}
+	provider, parent := newProviderWithMock(t, cfg)
+	child := NewMockWrapper(t).(*MockWrapper)
+
+	parent.On("AssumeRole", "arn:aws:iam::111111111111:role/MyRole").Return(nil, assert.AnError)
+	parent.On("AssumeRole", "arn:aws:iam::222222222222:role/MyRole").Return(child, nil)
+	child.On("CheckConnection").Return(nil)
+	child.On("GetRegions").Return([]string{"us-east-1"}, nil)
+	child.On("ProbeService", "EC2").Return(nil)
+	child.On("ProbeService", "S3").Return(assert.AnError)
+
+	checks := provider.Preflight(context.Background())
+
+	names := []string{}
+	failed := []string{}
+	for _, check := range checks {
+		names = append(names, check.Name)
+		if check.Err != nil {
+			failed = append(failed, check.Name)
+		}
+	}
+	assert.Equal(t, []string{"AssumeRole 111111111111", "AssumeRole 222222222222", "Regions", "EC2", "S3"}, names)
+	assert.Equal(t, []string{"AssumeRole 111111111111", "S3"}, failed)
+	assert.Contains(t, checks[4].Hint, "s3:ListAllMyBuckets")
+}
+
 func newProviderWithMock(t *testing.T, cfg *config.AWSCloudProvider) (*AWSProvider, *MockWrapper) {
 	t.Helper()
 	wrapper := NewMockWrapper(t).(*MockWrapper)
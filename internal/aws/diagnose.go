@@ -0,0 +1,121 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/smithy-go"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+)
+
+// diagnose returns err as a cloud_provider_t.AuthError if its cause is recognised, e.g. an expired
+// SSO session, or err unchanged otherwise. creds are the credentials the wrapper was created with.
+func diagnose(err error, creds Credentials) error {
+	if err == nil {
+		return nil
+	}
+	if creds.RoleARN != "" {
+		if authErr := diagnoseWebIdentity(err, creds); authErr != nil {
+			return authErr
+		}
+	}
+	profile := creds.Profile
+
+	var profileErr config.SharedConfigProfileNotExistError
+	if errors.As(err, &profileErr) {
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("AWS profile %s is not in the shared AWS config", profileErr.Profile),
+			Remediation: "check aws.profile, or add the profile to ~/.aws/config or AWS_CONFIG_FILE",
+			Err:         err,
+		}
+	}
+
+	var tokenErr *ssocreds.InvalidTokenError
+	if errors.As(err, &tokenErr) {
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("the SSO session of AWS profile %s has expired or was never started", profile),
+			Remediation: fmt.Sprintf("run aws sso login --profile %s", profile),
+			Err:         err,
+		}
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ExpiredToken", "ExpiredTokenException", "RequestExpired":
+			return &cloud_provider_t.AuthError{
+				Cause:       "the AWS session credentials have expired",
+				Remediation: "refresh AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, or use aws.profile or an IAM role that renews them",
+				Err:         err,
+			}
+		case "InvalidClientTokenId":
+			return &cloud_provider_t.AuthError{
+				Cause:       "the AWS access key ID is not valid",
+				Remediation: "check AWS_ACCESS_KEY_ID or the access key of the profile, it may have been deleted or deactivated",
+				Err:         err,
+			}
+		case "SignatureDoesNotMatch":
+			return &cloud_provider_t.AuthError{
+				Cause:       "the AWS secret access key doesn't match the access key ID",
+				Remediation: "check AWS_SECRET_ACCESS_KEY or the secret key of the profile",
+				Err:         err,
+			}
+		}
+	}
+
+	// The last provider of the default credential chain is the role of an EC2 instance, so no
+	// credentials were found anywhere if it has none
+	if strings.Contains(err.Error(), "no EC2 IMDS role found") {
+		return &cloud_provider_t.AuthError{
+			Cause:       "no AWS credentials were found",
+			Remediation: "set aws.profile or AWS_PROFILE, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or run the connector with an IAM role",
+			Err:         err,
+		}
+	}
+
+	return err
+}
+
+// diagnoseWebIdentity returns err as a cloud_provider_t.AuthError if it is a recognised failure to
+// assume a role with a web identity token, or nil otherwise
+func diagnoseWebIdentity(err error, creds Credentials) error {
+	// The token file error isn't wrapped, so only its message can be matched
+	if strings.Contains(err.Error(), "failed to retrieve jwt from provide source") {
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("the web identity token file %s could not be read", creds.TokenFile),
+			Remediation: "check aws.web_identity.token_file, for EKS IRSA the service account must be annotated with the role so the token is mounted",
+			Err:         err,
+		}
+	}
+
+	var opErr *smithy.OperationError
+	var apiErr smithy.APIError
+	if !errors.As(err, &opErr) || opErr.OperationName != "AssumeRoleWithWebIdentity" || !errors.As(err, &apiErr) {
+		return nil
+	}
+	switch apiErr.ErrorCode() {
+	case "ExpiredTokenException":
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("the web identity token in %s has expired", creds.TokenFile),
+			Remediation: "check the token is renewed before it expires, e.g. by the kubelet for EKS IRSA, or fetch a new GitHub Actions OIDC token for each run",
+			Err:         err,
+		}
+	case "InvalidIdentityToken":
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("the web identity token in %s was rejected by AWS STS", creds.TokenFile),
+			Remediation: "check the issuer of the token is an IAM OIDC identity provider of the account and its audience is sts.amazonaws.com",
+			Err:         err,
+		}
+	case "AccessDenied":
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("the web identity token is not authorised to assume role %s", creds.RoleARN),
+			Remediation: "check aws.web_identity.role_arn, and that the trust policy of the role allows sts:AssumeRoleWithWebIdentity for the issuer and subject of the token",
+			Err:         err,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+)
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		remediation string
+	}{
+		{"SSOExpired", fmt.Errorf("aws: get-caller-identity failed, %w", &ssocreds.InvalidTokenError{Err: assert.AnError}), "run aws sso login --profile org-a"},
+		{"UnknownProfile", config.SharedConfigProfileNotExistError{Profile: "org-c"}, "check aws.profile, or add the profile to ~/.aws/config or AWS_CONFIG_FILE"},
+		{"ExpiredToken", &smithy.GenericAPIError{Code: "ExpiredToken"}, "refresh AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, or use aws.profile or an IAM role that renews them"},
+		{"InvalidKey", &smithy.GenericAPIError{Code: "InvalidClientTokenId"}, "check AWS_ACCESS_KEY_ID or the access key of the profile, it may have been deleted or deactivated"},
+		{"NoCredentials", fmt.Errorf("get credentials: failed to refresh cached credentials, no EC2 IMDS role found, %w", assert.AnError), "set aws.profile or AWS_PROFILE, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or run the connector with an IAM role"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var authErr *cloud_provider_t.AuthError
+			err := diagnose(tc.err, Credentials{Profile: "org-a"})
+			assert.ErrorAs(t, err, &authErr)
+			assert.Equal(t, tc.remediation, authErr.Remediation)
+			assert.Equal(t, tc.err, authErr.Err)
+		})
+	}
+}
+
+func TestDiagnose_Unrecognised_Unchanged(t *testing.T) {
+	assert.Equal(t, assert.AnError, diagnose(assert.AnError, Credentials{}))
+	assert.NoError(t, diagnose(nil, Credentials{}))
+}
+
+func TestDiagnose_WebIdentity(t *testing.T) {
+	creds := Credentials{RoleARN: "arn:aws:iam::123456789012:role/connector", TokenFile: "/var/run/secrets/token"}
+	stsErr := func(code string) error {
+		return fmt.Errorf("failed to retrieve credentials, %w", &smithy.OperationError{
+			ServiceID:     "STS",
+			OperationName: "AssumeRoleWithWebIdentity",
+			Err:           &smithy.GenericAPIError{Code: code},
+		})
+	}
+
+	tests := []struct {
+		name  string
+		err   error
+		cause string
+	}{
+		{"Expired", stsErr("ExpiredTokenException"), "the web identity token in /var/run/secrets/token has expired"},
+		{"Invalid", stsErr("InvalidIdentityToken"), "the web identity token in /var/run/secrets/token was rejected by AWS STS"},
+		{"AccessDenied", stsErr("AccessDenied"), "the web identity token is not authorised to assume role arn:aws:iam::123456789012:role/connector"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var authErr *cloud_provider_t.AuthError
+			assert.ErrorAs(t, diagnose(tc.err, creds), &authErr)
+			assert.Equal(t, tc.cause, authErr.Cause)
+		})
+	}
+
+	// Session credentials expiring after the role was assumed aren't a token failure
+	var authErr *cloud_provider_t.AuthError
+	assert.ErrorAs(t, diagnose(&smithy.GenericAPIError{Code: "ExpiredToken"}, creds), &authErr)
+	assert.Equal(t, "the AWS session credentials have expired", authErr.Cause)
+}
@@ -0,0 +1,40 @@
+package aws
+
+// Services checked by the AWS provider, named as in the logs
+const (
+	serviceEC2          = "EC2"
+	serviceEIP          = "EIP"
+	serviceELB          = "ELB"
+	serviceS3           = "S3"
+	serviceACM          = "ACM"
+	serviceRoute53      = "Route53"
+	serviceCloudFront   = "CloudFront"
+	serviceAPIGateway   = "APIGateway"
+	serviceAPIGatewayV2 = "APIGatewayV2"
+	serviceEKS          = "EKS"
+	serviceRDS          = "RDS"
+	serviceOpenSearch   = "OpenSearch"
+	serviceLambda       = "Lambda"
+	serviceBYOIP        = "BYOIP"
+)
+
+// regionPermissions are the IAM actions needed to find the regions every service is checked in
+var regionPermissions = []string{"ec2:DescribeRegions"}
+
+// servicePermissions are the IAM actions the wrapper method of each service calls, by service
+var servicePermissions = map[string][]string{
+	serviceEC2:          {"ec2:DescribeInstances"},
+	serviceEIP:          {"ec2:DescribeAddressesAttribute"},
+	serviceELB:          {"elasticloadbalancing:DescribeLoadBalancers"},
+	serviceS3:           {"s3:ListAllMyBuckets", "s3:GetBucketPublicAccessBlock", "s3:GetBucketAcl", "s3:GetBucketPolicy", "s3:GetBucketWebsite"},
+	serviceACM:          {"acm:ListCertificates", "acm:DescribeCertificate"},
+	serviceRoute53:      {"route53:ListHostedZones", "route53:ListResourceRecordSets"},
+	serviceCloudFront:   {"cloudfront:ListDistributions"},
+	serviceAPIGateway:   {"apigateway:GET"},
+	serviceAPIGatewayV2: {"apigateway:GET"},
+	serviceEKS:          {"eks:ListClusters", "eks:DescribeCluster"},
+	serviceRDS:          {"rds:DescribeDBInstances", "rds:DescribeDBClusters"},
+	serviceOpenSearch:   {"es:ListApplications"},
+	serviceLambda:       {"lambda:ListFunctions", "lambda:GetFunctionUrlConfig"},
+	serviceBYOIP:        {"ec2:DescribeByoipCidrs"},
+}
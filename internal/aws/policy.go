@@ -0,0 +1,89 @@
+package aws
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+)
+
+// iamPolicy is an IAM policy document
+type iamPolicy struct {
+	Version   string         `json:"Version"`
+	Statement []iamStatement `json:"Statement"`
+}
+
+type iamStatement struct {
+	Sid      string   `json:"Sid"`
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource []string `json:"Resource"`
+}
+
+func newIAMPolicy(statements ...iamStatement) iamPolicy {
+	return iamPolicy{Version: "2012-10-17", Statement: statements}
+}
+
+func allow(sid string, actions []string, resources ...string) iamStatement {
+	return iamStatement{Sid: sid, Effect: "Allow", Action: actions, Resource: resources}
+}
+
+// Policies returns the IAM policies the enabled service checks need. If a role is assumed in each
+// account, the connector's policy only lists the accounts and assumes the role, and the role's
+// policy allows discovery.
+func (c *AWSProvider) Policies() []cloud_provider_t.Policy {
+	discovery := allow("Discovery", c.discoveryActions(), "*")
+
+	var connector []iamStatement
+	var policies []cloud_provider_t.Policy
+	if c.cfg.AssumeRole != nil && (c.cfg.ListAllAccounts || len(c.cfg.Accounts) > 0) {
+		if c.cfg.ListAllAccounts {
+			connector = append(connector, allow("ListAccounts", []string{"organizations:ListAccounts"}, "*"))
+		}
+
+		accounts := c.cfg.Accounts
+		if c.cfg.ListAllAccounts {
+			accounts = []string{"*"}
+		}
+		roles := make([]string, 0, len(accounts))
+		for _, account := range accounts {
+			roles = append(roles, fmt.Sprintf("arn:aws:iam::%s:role/%s", account, *c.cfg.AssumeRole))
+		}
+		connector = append(connector, allow("AssumeRole", []string{"sts:AssumeRole"}, roles...))
+
+		policies = append(policies, cloud_provider_t.Policy{
+			Name:     "role " + *c.cfg.AssumeRole,
+			Document: newIAMPolicy(discovery),
+		})
+	} else {
+		connector = append(connector, discovery)
+	}
+
+	if c.cfg.APIKeySecret != nil {
+		connector = append(connector, allow("APIKeySecret", []string{"secretsmanager:GetSecretValue"}, secretARN(*c.cfg.APIKeySecret)))
+	}
+
+	return append([]cloud_provider_t.Policy{{Name: "connector", Document: newIAMPolicy(connector...)}}, policies...)
+}
+
+// discoveryActions returns the IAM actions of the enabled service checks, sorted and deduplicated
+func (c *AWSProvider) discoveryActions() []string {
+	actions := slices.Clone(regionPermissions)
+	for _, check := range serviceChecks(c.cfg.Services) {
+		if check.enabled {
+			actions = append(actions, servicePermissions[check.name]...)
+		}
+	}
+	slices.Sort(actions)
+	return slices.Compact(actions)
+}
+
+// secretARN returns the ARN of a secret given by ARN or name. Secrets Manager appends a random
+// suffix to the ARN of a secret, so a name matches any suffix.
+func secretARN(secret string) string {
+	if strings.HasPrefix(secret, "arn:") {
+		return secret
+	}
+	return fmt.Sprintf("arn:aws:secretsmanager:*:*:secret:%s-??????", secret)
+}
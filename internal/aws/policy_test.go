@@ -0,0 +1,50 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+func TestAWSProvider_Policies_NoAssumeRole(t *testing.T) {
+	provider := &AWSProvider{cfg: &config.AWSCloudProvider{
+		Services: &config.AWSServices{CheckEC2: config.Check{Enabled: true}},
+	}}
+
+	policies := provider.Policies()
+
+	assert.Len(t, policies, 1)
+	assert.Equal(t, newIAMPolicy(
+		allow("Discovery", []string{"ec2:DescribeInstances", "ec2:DescribeRegions"}, "*"),
+	), policies[0].Document)
+}
+
+func TestAWSProvider_Policies_AssumeRole(t *testing.T) {
+	role := "connector"
+	secret := "asm-api-key"
+	provider := &AWSProvider{cfg: &config.AWSCloudProvider{
+		ListAllAccounts: true,
+		AssumeRole:      &role,
+		APIKeySecret:    &secret,
+		Services: &config.AWSServices{
+			CheckAPIGateway:   config.Check{Enabled: true},
+			CheckAPIGatewayV2: config.Check{Enabled: true},
+		},
+	}}
+
+	policies := provider.Policies()
+
+	assert.Len(t, policies, 2)
+	assert.Equal(t, "connector", policies[0].Name)
+	assert.Equal(t, newIAMPolicy(
+		allow("ListAccounts", []string{"organizations:ListAccounts"}, "*"),
+		allow("AssumeRole", []string{"sts:AssumeRole"}, "arn:aws:iam::*:role/connector"),
+		allow("APIKeySecret", []string{"secretsmanager:GetSecretValue"}, "arn:aws:secretsmanager:*:*:secret:asm-api-key-??????"),
+	), policies[0].Document)
+	assert.Equal(t, "role connector", policies[1].Name)
+	assert.Equal(t, newIAMPolicy(
+		allow("Discovery", []string{"apigateway:GET", "ec2:DescribeRegions"}, "*"),
+	), policies[1].Document)
+}
@@ -0,0 +1,38 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+// profileCredentials returns the credentials a profile authenticates with
+func profileCredentials(cfg *config.AWSCloudProvider) Credentials {
+	creds := Credentials{Profile: cfg.Profile}
+	if cfg.WebIdentity != nil {
+		creds.RoleARN = cfg.WebIdentity.RoleARN
+		creds.TokenFile = cfg.WebIdentity.TokenFile
+		creds.SessionName = cfg.WebIdentity.SessionName
+	}
+	return creds
+}
+
+// ReadSecret returns the value of a Secrets Manager secret, the secret of a secret://aws-sm/
+// reference in the config, read with the credentials of profile, or the default credentials if
+// profile is nil. Like the API key secret of a profile, it's read before any role is assumed.
+func ReadSecret(ctx context.Context, profile *config.AWSCloudProvider, secret string) (string, error) {
+	var (
+		region string
+		creds  Credentials
+	)
+	if profile != nil {
+		region = profile.DefaultRegion
+		creds = profileCredentials(profile)
+	}
+
+	wrapper, err := NewWrapper(ctx, region, creds)
+	if err != nil {
+		return "", err
+	}
+	return wrapper.GetSecretString(ctx, secret)
+}
@@ -1,16 +1,22 @@
 package azure
 
 import (
+	"cmp"
 	"context"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"os"
 	"strings"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
 	"github.com/hexiosec/asm-cloud-connector/internal/util"
 )
 
@@ -19,6 +25,7 @@ type IAzureWrapper interface {
 	InitResourceGraph(ctx context.Context) error
 	GetPublicIPs(ctx context.Context) ([]string, error)
 	GetPublicIPDNSNames(ctx context.Context) ([]string, error)
+	GetPublicIPPrefixes(ctx context.Context) ([]string, error)
 	GetApplicationGatewayHostnames(ctx context.Context) ([]string, error)
 	GetApplicationGatewayCertificateDomains(ctx context.Context) ([]string, error)
 	GetFrontDoorClassicHostnames(ctx context.Context) ([]string, error)
@@ -32,19 +39,60 @@ type IAzureWrapper interface {
 	GetSQLServerFQDNs(ctx context.Context) ([]string, error)
 	GetCosmosDocumentEndpoints(ctx context.Context) ([]string, error)
 	GetRedisHostnames(ctx context.Context) ([]string, error)
+	CountSubscriptions(ctx context.Context) (int64, error)
+	Identity(ctx context.Context) (string, error)
 }
 
 type AzureWrapper struct {
-	cred      *azidentity.DefaultAzureCredential
+	cred azcore.TokenCredential
+	// tokenFile is the federated token file of an explicit workload identity
+	tokenFile string
 	argClient *armresourcegraph.Client
 }
 
-func NewWrapper() (IAzureWrapper, error) {
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
+// Credentials selects how NewWrapper authenticates, with DefaultAzureCredential unless ClientID is set
+type Credentials struct {
+	// TenantID is the tenant to authenticate with, if not empty
+	TenantID string
+	// ClientID and TokenFile are an app registration or managed identity authenticated with a
+	// federated token, e.g. of AKS workload identity
+	ClientID  string
+	TokenFile string
+}
+
+// NewWrapper authenticates with the default credentials, or a workload identity if creds has a
+// client ID
+func NewWrapper(creds Credentials) (IAzureWrapper, error) {
+	cred, err := newCredential(creds)
+	if err != nil {
+		return nil, err
+	}
+	return &AzureWrapper{cred: cred, tokenFile: creds.TokenFile}, nil
+}
+
+// newCredential returns the token credential of creds
+func newCredential(creds Credentials) (azcore.TokenCredential, error) {
+	if creds.ClientID != "" {
+		cred, err := azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			ClientOptions: tlsmode.Azure(),
+			TenantID:      creds.TenantID,
+			ClientID:      creds.ClientID,
+			TokenFilePath: creds.TokenFile,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("azure: failed to create workload identity credentials, %w", err)
+		}
+		return cred, nil
+	}
+
+	cred, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+		ClientOptions: tlsmode.Azure(),
+		TenantID:      creds.TenantID,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("azure: failed to get default credentials, %w", err)
 	}
-	return &AzureWrapper{cred: cred}, nil
+	return cred, nil
 }
 
 const azureScopeARM = "https://management.azure.com/.default"
@@ -52,6 +100,14 @@ const azureScopeARM = "https://management.azure.com/.default"
 // Return nil if able to get a token and therefore can authenticate
 // doesn't check that the required permissions are set
 func (w *AzureWrapper) CheckConnection(ctx context.Context) error {
+	// The token file is only read once the tenant's endpoints are resolved, so a missing one is
+	// checked first to be reported as such
+	if w.tokenFile != "" {
+		if _, err := os.Stat(w.tokenFile); err != nil {
+			return fmt.Errorf("azure: failed to read workload identity token file, %w", err)
+		}
+	}
+
 	// Try to get a token for ARM (Azure Resource Manager)
 	_, err := w.cred.GetToken(ctx, policy.TokenRequestOptions{
 		Scopes: []string{azureScopeARM},
@@ -63,8 +119,68 @@ func (w *AzureWrapper) CheckConnection(ctx context.Context) error {
 	return nil
 }
 
+// Identity describes the identity the credentials authenticated as, from the claims of its token
+// for ARM, e.g. "managed identity 00000000-0000-0000-0000-000000000000"
+func (w *AzureWrapper) Identity(ctx context.Context) (string, error) {
+	token, err := w.cred.GetToken(ctx, policy.TokenRequestOptions{
+		Scopes: []string{azureScopeARM},
+	})
+	if err != nil {
+		return "", fmt.Errorf("azure: failed to get token, %w", err)
+	}
+
+	identity, err := describeToken(token.Token)
+	if err != nil {
+		return "", err
+	}
+	if w.tokenFile != "" {
+		identity = strings.Replace(identity, "service principal", "workload identity", 1)
+	}
+	return identity, nil
+}
+
+// tokenClaims are the claims of a Microsoft Entra ID access token identifying who it was issued to
+type tokenClaims struct {
+	// IdentityType is "app" for service principals and managed identities, or "user"
+	IdentityType string `json:"idtyp"`
+	AppID        string `json:"appid"`
+	// ManagedIdentity is the resource ID of a managed identity
+	ManagedIdentity string `json:"xms_mirid"`
+	UPN             string `json:"upn"`
+	UniqueName      string `json:"unique_name"`
+	TenantID        string `json:"tid"`
+}
+
+// describeToken describes the identity an access token was issued to, its signature isn't verified
+// as it was just issued to the connector
+func describeToken(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("azure: access token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("azure: failed to decode access token, %w", err)
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("azure: failed to decode access token, %w", err)
+	}
+
+	var identity string
+	switch {
+	case claims.ManagedIdentity != "":
+		identity = "managed identity " + claims.AppID
+	case claims.IdentityType == "user" || claims.UPN != "":
+		identity = "user " + cmp.Or(claims.UPN, claims.UniqueName)
+	default:
+		identity = "service principal " + claims.AppID
+	}
+	return fmt.Sprintf("%s in tenant %s", identity, claims.TenantID), nil
+}
+
 func (w *AzureWrapper) InitResourceGraph(ctx context.Context) error {
-	client, err := armresourcegraph.NewClient(w.cred, nil)
+	client, err := armresourcegraph.NewClient(w.cred, &arm.ClientOptions{ClientOptions: tlsmode.Azure()})
 	if err != nil {
 		return fmt.Errorf("azure: failed to create resource graph client, %w", err)
 	}
@@ -97,6 +213,18 @@ func (w *AzureWrapper) GetPublicIPDNSNames(ctx context.Context) ([]string, error
 	return w.queryResourceGraph(ctx, query)
 }
 
+func (w *AzureWrapper) GetPublicIPPrefixes(ctx context.Context) ([]string, error) {
+	query := `
+		Resources
+		| where type =~ 'microsoft.network/publicipprefixes'
+		| extend resource = tostring(properties.ipPrefix)
+		| where isnotempty(resource)
+		| distinct resource
+	`
+
+	return w.queryResourceGraph(ctx, query)
+}
+
 func (w *AzureWrapper) GetApplicationGatewayHostnames(ctx context.Context) ([]string, error) {
 	query := `
 		Resources
@@ -267,6 +395,24 @@ func (w *AzureWrapper) GetRedisHostnames(ctx context.Context) ([]string, error)
 	return w.queryResourceGraph(ctx, query)
 }
 
+// CountSubscriptions returns the number of subscriptions Resource Graph queries can read, every
+// service is discovered with Resource Graph
+func (w *AzureWrapper) CountSubscriptions(ctx context.Context) (int64, error) {
+	query := `
+		ResourceContainers
+		| where type =~ 'microsoft.resources/subscriptions'
+		| project subscriptionId
+	`
+	resp, err := w.argClient.Resources(ctx, armresourcegraph.QueryRequest{Query: &query}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("azure: resource graph query failed, %w", err)
+	}
+	if resp.TotalRecords == nil {
+		return 0, nil
+	}
+	return *resp.TotalRecords, nil
+}
+
 func (w *AzureWrapper) queryResourceGraph(ctx context.Context, query string) ([]string, error) {
 	resources := []string{}
 	req := armresourcegraph.QueryRequest{Query: &query, Options: &armresourcegraph.QueryRequestOptions{}}
@@ -32,6 +32,16 @@ func (m *MockWrapper) InitResourceGraph(_ context.Context) error {
 	return args.Error(0)
 }
 
+func (m *MockWrapper) CountSubscriptions(_ context.Context) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWrapper) Identity(_ context.Context) (string, error) {
+	args := m.Called()
+	return args.String(0), args.Error(1)
+}
+
 func (m *MockWrapper) GetPublicIPs(_ context.Context) ([]string, error) {
 	args := m.Called()
 	return getStringSlice(args.Get(0)), args.Error(1)
@@ -42,6 +52,11 @@ func (m *MockWrapper) GetPublicIPDNSNames(_ context.Context) ([]string, error) {
 	return getStringSlice(args.Get(0)), args.Error(1)
 }
 
+func (m *MockWrapper) GetPublicIPPrefixes(_ context.Context) ([]string, error) {
+	args := m.Called()
+	return getStringSlice(args.Get(0)), args.Error(1)
+}
+
 func (m *MockWrapper) GetApplicationGatewayHostnames(_ context.Context) ([]string, error) {
 	args := m.Called()
 	return getStringSlice(args.Get(0)), args.Error(1)
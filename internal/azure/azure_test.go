@@ -0,0 +1,51 @@
+package azure
+
+import (
+	"context"
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+)
+
+func TestDescribeToken(t *testing.T) {
+	token := func(claims string) string {
+		return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".signature"
+	}
+
+	tests := []struct {
+		name     string
+		claims   string
+		identity string
+	}{
+		{"ManagedIdentity", `{"idtyp":"app","appid":"1111","xms_mirid":"/subscriptions/s/resourcegroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/connector","tid":"contoso"}`, "managed identity 1111 in tenant contoso"},
+		{"ServicePrincipal", `{"idtyp":"app","appid":"2222","tid":"contoso"}`, "service principal 2222 in tenant contoso"},
+		{"User", `{"idtyp":"user","upn":"admin@contoso.com","tid":"contoso"}`, "user admin@contoso.com in tenant contoso"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			identity, err := describeToken(token(tc.claims))
+			require.NoError(t, err)
+			assert.Equal(t, tc.identity, identity)
+		})
+	}
+
+	_, err := describeToken("opaque")
+	assert.ErrorContains(t, err, "access token is not a JWT")
+}
+
+func TestAzureWrapper_WorkloadIdentity_MissingTokenFile(t *testing.T) {
+	wrapper, err := NewWrapper(Credentials{TenantID: "contoso.onmicrosoft.com", ClientID: "1111", TokenFile: filepath.Join(t.TempDir(), "token")})
+	require.NoError(t, err)
+
+	err = wrapper.CheckConnection(context.Background())
+
+	var authErr *cloud_provider_t.AuthError
+	require.ErrorAs(t, diagnose(err, "contoso.onmicrosoft.com"), &authErr)
+	assert.Equal(t, "the workload identity token file doesn't exist", authErr.Cause)
+}
@@ -2,10 +2,15 @@ package azure
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
 	"github.com/hexiosec/asm-cloud-connector/internal/config"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/progress"
+	"github.com/hexiosec/asm-cloud-connector/internal/reporting"
+	"github.com/hexiosec/asm-cloud-connector/internal/warnings"
 )
 
 type AzureProvider struct {
@@ -13,14 +18,14 @@ type AzureProvider struct {
 	wrapper IAzureWrapper
 }
 
-func NewAzureProvider(cfg *config.Config) (cloud_provider_t.CloudProvider, error) {
-	wrapper, err := NewWrapper()
+func NewAzureProvider(cfg *config.AzureCloudProvider) (cloud_provider_t.CloudProvider, error) {
+	wrapper, err := NewWrapper(profileCredentials(cfg))
 	if err != nil {
 		return nil, err
 	}
 
 	return &AzureProvider{
-		cfg:     cfg.Azure,
+		cfg:     cfg,
 		wrapper: wrapper,
 	}, nil
 }
@@ -29,9 +34,13 @@ func (c *AzureProvider) GetName() string {
 	return "Azure"
 }
 
+func (c *AzureProvider) GetProfile() *config.CloudProvider {
+	return &c.cfg.CloudProvider
+}
+
 func (c *AzureProvider) Authenticate(ctx context.Context) error {
 	if err := c.wrapper.CheckConnection(ctx); err != nil {
-		return err
+		return diagnose(err, c.cfg.TenantID)
 	}
 
 	logger.GetLogger(ctx).Debug().Msg("authentication successful")
@@ -42,47 +51,127 @@ func (c *AzureProvider) GetAPIKey(ctx context.Context) (string, error) {
 	return "", cloud_provider_t.ErrNoAPIKey
 }
 
+// Preflight checks which identity the connector authenticated as, and that Resource Graph can read
+// at least one subscription. Every service is discovered with Resource Graph, which only returns
+// the resources of subscriptions it can read, so services aren't checked separately.
+func (c *AzureProvider) Preflight(ctx context.Context) []cloud_provider_t.Check {
+	return []cloud_provider_t.Check{c.identityCheck(ctx), c.resourceGraphCheck(ctx)}
+}
+
+// identityCheck names the identity DefaultAzureCredential, or the configured workload identity,
+// authenticated as, so a credential found before the intended one is noticed
+func (c *AzureProvider) identityCheck(ctx context.Context) cloud_provider_t.Check {
+	check := cloud_provider_t.Check{
+		Name: "Credential",
+		Hint: "Check the credentials of the connector's identity",
+	}
+
+	identity, err := c.wrapper.Identity(ctx)
+	if err != nil {
+		check.Err = diagnose(err, c.cfg.TenantID)
+		return check
+	}
+	check.Name = "Credential: " + identity
+	logger.GetLogger(ctx).Debug().Str("identity", identity).Msg("authenticated identity")
+	return check
+}
+
+// resourceGraphCheck checks Resource Graph can read at least one subscription
+func (c *AzureProvider) resourceGraphCheck(ctx context.Context) cloud_provider_t.Check {
+	check := cloud_provider_t.Check{
+		Name: "Resource Graph",
+		Hint: "Assign the Reader role to the connector's identity on the subscriptions or management group to discover",
+	}
+
+	if err := c.wrapper.InitResourceGraph(ctx); err != nil {
+		check.Err = diagnose(err, c.cfg.TenantID)
+		return check
+	}
+
+	subscriptions, err := c.wrapper.CountSubscriptions(ctx)
+	switch {
+	case err != nil:
+		check.Err = diagnose(err, c.cfg.TenantID)
+	case subscriptions == 0:
+		// Resource Graph only returns the subscriptions the identity can read, rather than failing
+		check.Err = &cloud_provider_t.AuthError{
+			Cause:       "no subscriptions are readable by the connector's identity",
+			Remediation: readerRemediation,
+			Err:         fmt.Errorf("azure: resource graph returned no subscriptions"),
+		}
+	default:
+		logger.GetLogger(ctx).Debug().Int64("subscriptions", subscriptions).Msg("resource graph can read subscriptions")
+	}
+	return check
+}
+
+// serviceCheck is the discovery of the resources of a service with Resource Graph
+type serviceCheck struct {
+	name    string
+	enabled bool
+	f       func(ctx context.Context) ([]string, error)
+}
+
+// serviceChecks returns the discovery of each service, enabled as in the config
+func (c *AzureProvider) serviceChecks() []serviceCheck {
+	return []serviceCheck{
+		{servicePublicIPs, c.cfg.Services.CheckPublicIPAddresses.Enabled, c.wrapper.GetPublicIPs},
+		{servicePublicIPDNS, c.cfg.Services.CheckPublicIPAddresses.Enabled, c.wrapper.GetPublicIPDNSNames},
+		{servicePublicIPPrefixes, c.cfg.Services.CheckPublicIPPrefixes.Enabled, c.wrapper.GetPublicIPPrefixes},
+		{serviceApplicationGateways, c.cfg.Services.CheckApplicationGateways.Enabled, c.wrapper.GetApplicationGatewayHostnames},
+		{serviceApplicationGatewayTLS, c.cfg.Services.CheckApplicationGatewayCertificates.Enabled, c.wrapper.GetApplicationGatewayCertificateDomains},
+		{serviceFrontDoorClassic, c.cfg.Services.CheckFrontDoorClassic.Enabled, c.wrapper.GetFrontDoorClassicHostnames},
+		{serviceFrontDoorAfd, c.cfg.Services.CheckFrontDoorAfd.Enabled, c.wrapper.GetFrontDoorAfdHostnames},
+		{serviceTrafficManager, c.cfg.Services.CheckTrafficManager.Enabled, c.wrapper.GetTrafficManagerFQDNs},
+		{serviceDNSZones, c.cfg.Services.CheckDNSZones.Enabled, c.wrapper.GetDNSZones},
+		{serviceDNSRecords, c.cfg.Services.CheckDNSRecords.Enabled, c.wrapper.GetDNSRecordFQDNs},
+		{serviceStorageWeb, c.cfg.Services.CheckStorageStaticWebsites.Enabled, c.wrapper.GetStorageWebEndpoints},
+		{serviceCDNEndpoints, c.cfg.Services.CheckCDNEndpoints.Enabled, c.wrapper.GetCDNEndpointHostnames},
+		{serviceAppServices, c.cfg.Services.CheckAppServices.Enabled, c.wrapper.GetAppServiceHostnames},
+		{serviceSQL, c.cfg.Services.CheckSQLServers.Enabled, c.wrapper.GetSQLServerFQDNs},
+		{serviceCosmosDB, c.cfg.Services.CheckCosmosDB.Enabled, c.wrapper.GetCosmosDocumentEndpoints},
+		{serviceRedis, c.cfg.Services.CheckRedisCache.Enabled, c.wrapper.GetRedisHostnames},
+	}
+}
+
 func (c *AzureProvider) GetResources(ctx context.Context) ([]string, error) {
 	if err := c.wrapper.InitResourceGraph(ctx); err != nil {
+		err = diagnose(err, c.cfg.TenantID)
 		logger.GetLogger(ctx).Warn().Err(err).Msgf("failed to create azure resource graph client, unable to check for any resources")
+		warnings.Record(ctx, warnings.ServiceCheck, "Resource Graph failed, no services checked, %s", err)
 		return []string{}, nil
 	}
 
-	defs := []struct {
-		name    string
-		enabled bool
-		f       func(ctx context.Context) ([]string, error)
-	}{
-		{"Public IPs", c.cfg.Services.CheckPublicIPAddresses, c.wrapper.GetPublicIPs},
-		{"Public IP DNS", c.cfg.Services.CheckPublicIPAddresses, c.wrapper.GetPublicIPDNSNames},
-		{"Application Gateways", c.cfg.Services.CheckApplicationGateways, c.wrapper.GetApplicationGatewayHostnames},
-		{"Application Gateway Certificates", c.cfg.Services.CheckApplicationGatewayCertificates, c.wrapper.GetApplicationGatewayCertificateDomains},
-		{"Front Door (Classic)", c.cfg.Services.CheckFrontDoorClassic, c.wrapper.GetFrontDoorClassicHostnames},
-		{"Front Door (AFD)", c.cfg.Services.CheckFrontDoorAfd, c.wrapper.GetFrontDoorAfdHostnames},
-		{"Traffic Manager", c.cfg.Services.CheckTrafficManager, c.wrapper.GetTrafficManagerFQDNs},
-		{"DNS Zones", c.cfg.Services.CheckDNSZones, c.wrapper.GetDNSZones},
-		{"DNS Records", c.cfg.Services.CheckDNSRecords, c.wrapper.GetDNSRecordFQDNs},
-		{"Storage (Web)", c.cfg.Services.CheckStorageStaticWebsites, c.wrapper.GetStorageWebEndpoints},
-		{"CDN Endpoints", c.cfg.Services.CheckCDNEndpoints, c.wrapper.GetCDNEndpointHostnames},
-		{"App Services", c.cfg.Services.CheckAppServices, c.wrapper.GetAppServiceHostnames},
-		{"Azure SQL", c.cfg.Services.CheckSQLServers, c.wrapper.GetSQLServerFQDNs},
-		{"Cosmos DB", c.cfg.Services.CheckCosmosDB, c.wrapper.GetCosmosDocumentEndpoints},
-		{"Redis", c.cfg.Services.CheckRedisCache, c.wrapper.GetRedisHostnames},
-	}
-
-	resources := []string{}
-	for _, def := range defs {
+	var defs []serviceCheck
+	for _, def := range c.serviceChecks() {
 		if !def.enabled {
 			logger.GetLogger(ctx).Trace().Msgf("skipping %s discovery; check disabled", def.name)
 			continue
 		}
+		defs = append(defs, def)
+	}
 
-		res, err := def.f(ctx)
+	// Each service is a separate Resource Graph query, so up to Concurrency run at a time
+	found := make([][]string, len(defs))
+	cloud_provider_t.RunAll(len(defs), c.cfg.Concurrency, func(idx int) {
+		def := defs[idx]
+		defer reporting.Repanic(reporting.WithTags(ctx, "service", def.name))
+		start := time.Now()
+		svcCtx, cancel := cloud_provider_t.WithTimeout(ctx, c.cfg.ServiceTimeout)
+		res, err := def.f(svcCtx)
+		cancel()
+		elapsed := time.Since(start)
 		if err != nil {
 			logger.GetLogger(ctx).Warn().Err(err).Msgf("failed to get %s resources", def.name)
-			continue
+			warnings.Record(ctx, warnings.ServiceCheck, "%s failed, %s", def.name, err)
 		}
+		logger.GetLogger(ctx).Debug().Int("resource_count", len(res)).Dur("duration", elapsed).Msgf("checked %s", def.name)
+		progress.Service(ctx, def.name, "", len(res), elapsed, err)
+		found[idx] = res
+	})
 
+	resources := []string{}
+	for _, res := range found {
 		resources = append(resources, res...)
 	}
 
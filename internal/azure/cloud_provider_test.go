@@ -2,6 +2,7 @@ package azure
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -44,7 +45,7 @@ func TestAzureProvider_GetResources_UsesEnabledServices(t *testing.T) {
 	provider, wrapper := newProviderWithWrapper(t, &config.AzureCloudProvider{
 		CloudProvider: config.CloudProvider{Enabled: true},
 		Services: &config.AzureServices{
-			CheckAppServices: true,
+			CheckAppServices: config.Check{Enabled: true},
 		},
 	})
 
@@ -62,7 +63,7 @@ func TestAzureProvider_GetResources_ContinuesOnError(t *testing.T) {
 	provider, wrapper := newProviderWithWrapper(t, &config.AzureCloudProvider{
 		CloudProvider: config.CloudProvider{Enabled: true},
 		Services: &config.AzureServices{
-			CheckPublicIPAddresses: true,
+			CheckPublicIPAddresses: config.Check{Enabled: true},
 		},
 	})
 
@@ -76,11 +77,33 @@ func TestAzureProvider_GetResources_ContinuesOnError(t *testing.T) {
 	assert.Equal(t, []string{"example.azure.com"}, resources)
 }
 
+func TestAzureProvider_GetResources_Concurrency_KeepsOrder(t *testing.T) {
+	provider, wrapper := newProviderWithWrapper(t, &config.AzureCloudProvider{
+		CloudProvider: config.CloudProvider{Enabled: true, Concurrency: 4},
+		Services: &config.AzureServices{
+			CheckPublicIPAddresses: config.Check{Enabled: true},
+			CheckDNSZones:          config.Check{Enabled: true},
+			CheckRedisCache:        config.Check{Enabled: true},
+		},
+	})
+
+	wrapper.On("InitResourceGraph").Return(nil)
+	wrapper.On("GetPublicIPs").Return([]string{"1.2.3.4"}, nil)
+	wrapper.On("GetPublicIPDNSNames").Return(nil, assert.AnError)
+	wrapper.On("GetDNSZones").Return([]string{"example.com"}, nil)
+	wrapper.On("GetRedisHostnames").Return([]string{"cache.redis.cache.windows.net"}, nil)
+
+	resources, err := provider.GetResources(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3.4", "example.com", "cache.redis.cache.windows.net"}, resources)
+}
+
 func TestAzureProvider_GetResources_ApplicationGatewayCertificates(t *testing.T) {
 	provider, wrapper := newProviderWithWrapper(t, &config.AzureCloudProvider{
 		CloudProvider: config.CloudProvider{Enabled: true},
 		Services: &config.AzureServices{
-			CheckApplicationGatewayCertificates: true,
+			CheckApplicationGatewayCertificates: config.Check{Enabled: true},
 		},
 	})
 
@@ -93,11 +116,28 @@ func TestAzureProvider_GetResources_ApplicationGatewayCertificates(t *testing.T)
 	assert.Equal(t, []string{"cert.example.com"}, resources)
 }
 
+func TestAzureProvider_GetResources_PublicIPPrefixes(t *testing.T) {
+	provider, wrapper := newProviderWithWrapper(t, &config.AzureCloudProvider{
+		CloudProvider: config.CloudProvider{Enabled: true},
+		Services: &config.AzureServices{
+			CheckPublicIPPrefixes: config.Check{Enabled: true},
+		},
+	})
+
+	wrapper.On("InitResourceGraph").Return(nil)
+	wrapper.On("GetPublicIPPrefixes").Return([]string{"20.0.0.0/28"}, nil)
+
+	resources, err := provider.GetResources(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"20.0.0.0/28"}, resources)
+}
+
 func TestAzureProvider_GetResources_InitResourceGraphError(t *testing.T) {
 	provider, wrapper := newProviderWithWrapper(t, &config.AzureCloudProvider{
 		CloudProvider: config.CloudProvider{Enabled: true},
 		Services: &config.AzureServices{
-			CheckAppServices: true,
+			CheckAppServices: config.Check{Enabled: true},
 		},
 	})
 
@@ -110,6 +150,41 @@ func TestAzureProvider_GetResources_InitResourceGraphError(t *testing.T) {
 	wrapper.AssertNotCalled(t, "GetAppServiceHostnames")
 }
 
+func TestAzureProvider_Preflight(t *testing.T) {
+	provider, wrapper := newProviderWithWrapper(t, &config.AzureCloudProvider{
+		CloudProvider: config.CloudProvider{Enabled: true},
+	})
+
+	wrapper.On("Identity").Return("managed identity 11111111-1111-1111-1111-111111111111 in tenant contoso", nil)
+	wrapper.On("InitResourceGraph").Return(nil)
+	wrapper.On("CountSubscriptions").Return(int64(0), nil)
+
+	checks := provider.Preflight(context.Background())
+
+	assert.Len(t, checks, 2)
+	assert.Equal(t, "Credential: managed identity 11111111-1111-1111-1111-111111111111 in tenant contoso", checks[0].Name)
+	assert.NoError(t, checks[0].Err)
+	assert.Equal(t, "Resource Graph", checks[1].Name)
+	assert.ErrorContains(t, checks[1].Err, "no subscriptions are readable")
+	assert.NotEmpty(t, checks[1].Hint)
+}
+
+func TestAzureProvider_Preflight_IdentityErr(t *testing.T) {
+	provider, wrapper := newProviderWithWrapper(t, &config.AzureCloudProvider{
+		CloudProvider: config.CloudProvider{Enabled: true},
+	})
+
+	wrapper.On("Identity").Return("", fmt.Errorf("azure: failed to get token, AADSTS700024: Client assertion is not within its valid time range"))
+	wrapper.On("InitResourceGraph").Return(nil)
+	wrapper.On("CountSubscriptions").Return(int64(3), nil)
+
+	checks := provider.Preflight(context.Background())
+
+	assert.Equal(t, "Credential", checks[0].Name)
+	assert.ErrorContains(t, checks[0].Err, "the workload identity token has expired")
+	assert.NoError(t, checks[1].Err)
+}
+
 func newProviderWithWrapper(t *testing.T, cfg *config.AzureCloudProvider) (*AzureProvider, *MockWrapper) {
 	t.Helper()
 	wrapper := NewMockWrapper(t).(*MockWrapper)
@@ -0,0 +1,91 @@
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+)
+
+// aadstsPattern matches the Microsoft Entra ID error code in a token error, e.g. AADSTS7000215
+var aadstsPattern = regexp.MustCompile(`AADSTS(\d+)`)
+
+// readerRemediation is how to fix an identity that can't read the subscriptions to discover
+const readerRemediation = "assign the Reader role to the connector's identity on the subscriptions or management group to discover"
+
+// diagnose returns err as a cloud_provider_t.AuthError if its cause is recognised, e.g. a tenant
+// that doesn't exist, or err unchanged otherwise. tenantID is the tenant_id of the config, if set.
+func diagnose(err error, tenantID string) error {
+	if err == nil {
+		return nil
+	}
+
+	tenant := "the tenant"
+	if tenantID != "" {
+		tenant = "tenant " + tenantID
+	}
+
+	if match := aadstsPattern.FindStringSubmatch(err.Error()); match != nil {
+		var cause, remediation string
+		switch match[1] {
+		case "90002", "900023":
+			cause = fmt.Sprintf("Azure %s was not found", tenant)
+			remediation = "check tenant_id or AZURE_TENANT_ID is the ID or primary domain of the tenant"
+		case "700016":
+			cause = fmt.Sprintf("the app registration of AZURE_CLIENT_ID is not in %s", tenant)
+			remediation = "check tenant_id or AZURE_TENANT_ID is the tenant the app is registered in"
+		case "7000215":
+			cause = "the Azure client secret is not valid"
+			remediation = "check AZURE_CLIENT_SECRET is the value of the secret rather than its ID"
+		case "7000222":
+			cause = "the Azure client secret has expired"
+			remediation = "create a new secret for the app registration and update AZURE_CLIENT_SECRET"
+		case "700082", "50173":
+			cause = "the Azure CLI login has expired"
+			remediation = "run az login, with --tenant if tenant_id is set"
+		case "700024":
+			cause = "the workload identity token has expired"
+			remediation = "check the token file is renewed before the token expires, e.g. by the AKS workload identity webhook"
+		case "70021", "700211", "700213":
+			cause = "the workload identity has no federated credential matching the issuer and subject of the token"
+			remediation = "add a federated credential for the issuer and subject of the token to the app registration or managed identity of workload_identity.client_id"
+		}
+		if cause != "" {
+			return &cloud_provider_t.AuthError{Cause: cause, Remediation: remediation, Err: err}
+		}
+	}
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return &cloud_provider_t.AuthError{
+			Cause:       "the workload identity token file doesn't exist",
+			Remediation: "check workload_identity.token_file, for AKS the pod's service account must have the azure.workload.identity/client-id annotation and the pod the azure.workload.identity/use label",
+			Err:         err,
+		}
+	}
+
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) && (respErr.StatusCode == http.StatusForbidden || respErr.ErrorCode == "AuthorizationFailed") {
+		return &cloud_provider_t.AuthError{
+			Cause:       "the connector's identity is not authorised to query Resource Graph",
+			Remediation: readerRemediation,
+			Err:         err,
+		}
+	}
+
+	// DefaultAzureCredential lists every credential it tried when none of them were available
+	if strings.Contains(err.Error(), "DefaultAzureCredential") && strings.Contains(err.Error(), "Attempted credentials") {
+		return &cloud_provider_t.AuthError{
+			Cause:       "no Azure credentials were found",
+			Remediation: "set AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET, use a managed or workload identity, or run az login",
+			Err:         err,
+		}
+	}
+
+	return err
+}
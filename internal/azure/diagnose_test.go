@@ -0,0 +1,41 @@
+package azure
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/stretchr/testify/assert"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+)
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		cause string
+	}{
+		{"TenantNotFound", fmt.Errorf("azure: failed to get token, AADSTS90002: Tenant 'contoso' not found"), "Azure tenant contoso.onmicrosoft.com was not found"},
+		{"WrongTenant", fmt.Errorf("azure: failed to get token, AADSTS700016: Application with identifier 'abc' was not found in the directory"), "the app registration of AZURE_CLIENT_ID is not in tenant contoso.onmicrosoft.com"},
+		{"InvalidSecret", fmt.Errorf("azure: failed to get token, AADSTS7000215: Invalid client secret provided"), "the Azure client secret is not valid"},
+		{"ReaderRoleMissing", &azcore.ResponseError{StatusCode: 403, ErrorCode: "AuthorizationFailed"}, "the connector's identity is not authorised to query Resource Graph"},
+		{"ExpiredFederatedToken", fmt.Errorf("azure: failed to get token, AADSTS700024: Client assertion is not within its valid time range"), "the workload identity token has expired"},
+		{"NoFederatedCredential", fmt.Errorf("azure: failed to get token, AADSTS700213: No matching federated identity record found for presented assertion subject"), "the workload identity has no federated credential matching the issuer and subject of the token"},
+		{"NoCredentials", fmt.Errorf("azure: failed to get token, DefaultAzureCredential: failed to acquire a token.\nAttempted credentials:\n\tEnvironmentCredential: missing environment variable"), "no Azure credentials were found"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var authErr *cloud_provider_t.AuthError
+			assert.ErrorAs(t, diagnose(tc.err, "contoso.onmicrosoft.com"), &authErr)
+			assert.Equal(t, tc.cause, authErr.Cause)
+		})
+	}
+}
+
+func TestDiagnose_Unrecognised_Unchanged(t *testing.T) {
+	err := fmt.Errorf("azure: failed to get token, AADSTS50058: A silent sign-in request was sent")
+
+	assert.Equal(t, err, diagnose(err, ""))
+}
@@ -0,0 +1,49 @@
+package azure
+
+// Services checked by the Azure provider, named as in the logs
+const (
+	servicePublicIPs             = "Public IPs"
+	servicePublicIPDNS           = "Public IP DNS"
+	servicePublicIPPrefixes      = "Public IP Prefixes"
+	serviceApplicationGateways   = "Application Gateways"
+	serviceApplicationGatewayTLS = "Application Gateway Certificates"
+	serviceFrontDoorClassic      = "Front Door (Classic)"
+	serviceFrontDoorAfd          = "Front Door (AFD)"
+	serviceTrafficManager        = "Traffic Manager"
+	serviceDNSZones              = "DNS Zones"
+	serviceDNSRecords            = "DNS Records"
+	serviceStorageWeb            = "Storage (Web)"
+	serviceCDNEndpoints          = "CDN Endpoints"
+	serviceAppServices           = "App Services"
+	serviceSQL                   = "Azure SQL"
+	serviceCosmosDB              = "Cosmos DB"
+	serviceRedis                 = "Redis"
+)
+
+// resourceGraphPermissions are the actions needed to query Resource Graph and find the
+// subscriptions it can read
+var resourceGraphPermissions = []string{
+	"Microsoft.ResourceGraph/resources/read",
+	"Microsoft.Resources/subscriptions/read",
+}
+
+// servicePermissions are the actions needed to read the resources the Resource Graph query of each
+// service returns, by service
+var servicePermissions = map[string][]string{
+	servicePublicIPs:             {"Microsoft.Network/publicIPAddresses/read"},
+	servicePublicIPDNS:           {"Microsoft.Network/publicIPAddresses/read"},
+	servicePublicIPPrefixes:      {"Microsoft.Network/publicIPPrefixes/read"},
+	serviceApplicationGateways:   {"Microsoft.Network/applicationGateways/read"},
+	serviceApplicationGatewayTLS: {"Microsoft.Network/applicationGateways/read"},
+	serviceFrontDoorClassic:      {"Microsoft.Network/frontDoors/read"},
+	serviceFrontDoorAfd:          {"Microsoft.Cdn/profiles/afdEndpoints/read"},
+	serviceTrafficManager:        {"Microsoft.Network/trafficManagerProfiles/read"},
+	serviceDNSZones:              {"Microsoft.Network/dnsZones/read"},
+	serviceDNSRecords:            {"Microsoft.Network/dnsZones/A/read", "Microsoft.Network/dnsZones/CNAME/read"},
+	serviceStorageWeb:            {"Microsoft.Storage/storageAccounts/read"},
+	serviceCDNEndpoints:          {"Microsoft.Cdn/profiles/endpoints/read"},
+	serviceAppServices:           {"Microsoft.Web/sites/read"},
+	serviceSQL:                   {"Microsoft.Sql/servers/read"},
+	serviceCosmosDB:              {"Microsoft.DocumentDB/databaseAccounts/read"},
+	serviceRedis:                 {"Microsoft.Cache/redis/read"},
+}
@@ -0,0 +1,41 @@
+package azure
+
+import (
+	"slices"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+)
+
+// roleDefinition is an Azure custom role definition, as created by az role definition create
+type roleDefinition struct {
+	Name             string   `json:"Name"`
+	IsCustom         bool     `json:"IsCustom"`
+	Description      string   `json:"Description"`
+	Actions          []string `json:"Actions"`
+	NotActions       []string `json:"NotActions"`
+	AssignableScopes []string `json:"AssignableScopes"`
+}
+
+// Policies returns the custom role the enabled service checks need. Profiles don't configure their
+// subscriptions, so the assignable scope is a placeholder to replace.
+func (c *AzureProvider) Policies() []cloud_provider_t.Policy {
+	actions := slices.Clone(resourceGraphPermissions)
+	for _, check := range c.serviceChecks() {
+		if check.enabled {
+			actions = append(actions, servicePermissions[check.name]...)
+		}
+	}
+	slices.Sort(actions)
+
+	return []cloud_provider_t.Policy{{
+		Name: "connector",
+		Document: roleDefinition{
+			Name:             "Hexiosec ASM Cloud Connector",
+			IsCustom:         true,
+			Description:      "Reads the public resources of the enabled service checks with Resource Graph",
+			Actions:          slices.Compact(actions),
+			NotActions:       []string{},
+			AssignableScopes: []string{"/subscriptions/<subscription-id>"},
+		},
+	}}
+}
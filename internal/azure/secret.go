@@ -0,0 +1,38 @@
+package azure
+
+import (
+	"context"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/config/source"
+)
+
+// profileCredentials returns the credentials a profile authenticates with
+func profileCredentials(cfg *config.AzureCloudProvider) Credentials {
+	creds := Credentials{TenantID: cfg.TenantID}
+	if cfg.WorkloadIdentity != nil {
+		creds.ClientID = cfg.WorkloadIdentity.ClientID
+		creds.TokenFile = cfg.WorkloadIdentity.TokenFile
+	}
+	return creds
+}
+
+// ReadSecret returns the value of a Key Vault secret named <vault>/<secret>, the secret of a
+// secret://azure-kv/ reference in the config, read with the credentials of profile, or the
+// default credentials if profile is nil
+func ReadSecret(ctx context.Context, profile *config.AzureCloudProvider, name string) (string, error) {
+	var creds Credentials
+	if profile != nil {
+		creds = profileCredentials(profile)
+	}
+
+	cred, err := newCredential(creds)
+	if err != nil {
+		return "", err
+	}
+	value, err := source.ReadKeyVaultSecret(ctx, name, cred)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
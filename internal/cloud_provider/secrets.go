@@ -0,0 +1,35 @@
+package cloud_provider
+
+import (
+	"context"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/aws"
+	"github.com/hexiosec/asm-cloud-connector/internal/azure"
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/gcp"
+)
+
+// The secret references of the config are read with the credentials of the first enabled profile
+// of their cloud, e.g. its assumed role or workload identity, or the default credentials if the
+// cloud has none
+func init() {
+	config.RegisterSecretStore("aws-sm", func(ctx context.Context, cfg *config.Config, name string) (string, error) {
+		return aws.ReadSecret(ctx, firstEnabled(cfg.AWS, func(p *config.AWSCloudProvider) bool { return p.Enabled }), name)
+	})
+	config.RegisterSecretStore("gcp-sm", func(ctx context.Context, cfg *config.Config, name string) (string, error) {
+		return gcp.ReadSecret(ctx, firstEnabled(cfg.GCP, func(p *config.GCPCloudProvider) bool { return p.Enabled }), name)
+	})
+	config.RegisterSecretStore("azure-kv", func(ctx context.Context, cfg *config.Config, name string) (string, error) {
+		return azure.ReadSecret(ctx, firstEnabled(cfg.Azure, func(p *config.AzureCloudProvider) bool { return p.Enabled }), name)
+	})
+}
+
+// firstEnabled returns the first enabled profile, nil if there is none
+func firstEnabled[T any](profiles []*T, enabled func(*T) bool) *T {
+	for _, profile := range profiles {
+		if profile != nil && enabled(profile) {
+			return profile
+		}
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package cloud_provider
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/hexiosec/asm-cloud-connector/internal/aws"
@@ -8,17 +10,57 @@ import (
 	t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
 	"github.com/hexiosec/asm-cloud-connector/internal/config"
 	"github.com/hexiosec/asm-cloud-connector/internal/gcp"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/warnings"
 )
 
-func NewCloudProvider(cfg *config.Config) (t.CloudProvider, error) {
-	switch {
-	case cfg.AWS != nil && cfg.AWS.Enabled:
-		return aws.NewAWSProvider(cfg)
-	case cfg.Azure != nil && cfg.Azure.Enabled:
-		return azure.NewAzureProvider(cfg)
-	case cfg.GCP != nil && cfg.GCP.Enabled:
-		return gcp.NewGCPProvider(cfg)
-	default:
+// NewCloudProviders returns a cloud provider for each enabled profile. A profile that fails to
+// initialise is skipped, logged and recorded as a warning of the run, so it doesn't stop the others
+// being discovered. An error is only returned if no profile could be initialised.
+func NewCloudProviders(ctx context.Context, cfg *config.Config) ([]t.CloudProvider, error) {
+	providers := []t.CloudProvider{}
+	var errs []error
+
+	add := func(provider string, profile *config.CloudProvider, cp t.CloudProvider, err error) {
+		if err != nil {
+			logger.GetLogger(ctx).Warn().Err(err).Str("cloud_provider", provider).Str("profile", profile.Name).Msg("Could not init cloud provider profile, skipping it")
+			warnings.Record(ctx, warnings.SkippedProfile, "could not init %s profile %s, %v", provider, profile.Name, err)
+			errs = append(errs, fmt.Errorf("%s profile %s, %w", provider, profile.Name, err))
+			return
+		}
+		providers = append(providers, cp)
+	}
+
+	for _, profile := range cfg.AWS {
+		if profile == nil || !profile.Enabled {
+			continue
+		}
+		cp, err := aws.NewAWSProvider(profile)
+		add("AWS", &profile.CloudProvider, cp, err)
+	}
+
+	for _, profile := range cfg.Azure {
+		if profile == nil || !profile.Enabled {
+			continue
+		}
+		cp, err := azure.NewAzureProvider(profile)
+		add("Azure", &profile.CloudProvider, cp, err)
+	}
+
+	for _, profile := range cfg.GCP {
+		if profile == nil || !profile.Enabled {
+			continue
+		}
+		cp, err := gcp.NewGCPProvider(profile)
+		add("GCP", &profile.CloudProvider, cp, err)
+	}
+
+	if len(providers) == 0 {
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("no cloud provider profile could be initialised, %w", errors.Join(errs...))
+		}
 		return nil, fmt.Errorf("no cloud provider enabled")
 	}
+
+	return providers, nil
 }
@@ -1,60 +1,128 @@
 package cloud_provider
 
 import (
+	"context"
 	"testing"
 
 	"github.com/hexiosec/asm-cloud-connector/internal/aws"
 	"github.com/hexiosec/asm-cloud-connector/internal/azure"
 	"github.com/hexiosec/asm-cloud-connector/internal/config"
 	"github.com/hexiosec/asm-cloud-connector/internal/gcp"
+	"github.com/hexiosec/asm-cloud-connector/internal/warnings"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestNewCloudProvider_AWSEnabled_Success(t *testing.T) {
+func TestNewCloudProviders_AWSEnabled_Success(t *testing.T) {
 	cfg := &config.Config{
-		AWS: &config.AWSCloudProvider{
+		AWS: config.Profiles[config.AWSCloudProvider]{{
 			CloudProvider: config.CloudProvider{Enabled: true},
-		},
+		}},
 	}
 
-	provider, err := NewCloudProvider(cfg)
+	providers, err := NewCloudProviders(context.Background(), cfg)
 
 	assert.NoError(t, err)
-	assert.IsType(t, &aws.AWSProvider{}, provider)
+	assert.Len(t, providers, 1)
+	assert.IsType(t, &aws.AWSProvider{}, providers[0])
 }
 
-func TestNewCloudProvider_AzureEnabled_Success(t *testing.T) {
+func TestNewCloudProviders_AzureEnabled_Success(t *testing.T) {
 	cfg := &config.Config{
-		Azure: &config.AzureCloudProvider{
+		Azure: config.Profiles[config.AzureCloudProvider]{{
 			CloudProvider: config.CloudProvider{Enabled: true},
-		},
+		}},
 	}
 
-	provider, err := NewCloudProvider(cfg)
+	providers, err := NewCloudProviders(context.Background(), cfg)
 
 	assert.NoError(t, err)
-	assert.IsType(t, &azure.AzureProvider{}, provider)
+	assert.Len(t, providers, 1)
+	assert.IsType(t, &azure.AzureProvider{}, providers[0])
 }
 
-func TestNewCloudProvider_GCPEnabled_Success(t *testing.T) {
+func TestNewCloudProviders_GCPEnabled_Success(t *testing.T) {
 	cfg := &config.Config{
-		GCP: &config.GCPCloudProvider{
+		GCP: config.Profiles[config.GCPCloudProvider]{{
 			CloudProvider: config.CloudProvider{Enabled: true},
+		}},
+	}
+
+	providers, err := NewCloudProviders(context.Background(), cfg)
+
+	assert.NoError(t, err)
+	assert.Len(t, providers, 1)
+	assert.IsType(t, &gcp.GCPProvider{}, providers[0])
+}
+
+func TestNewCloudProviders_MultipleProfiles_Success(t *testing.T) {
+	cfg := &config.Config{
+		AWS: config.Profiles[config.AWSCloudProvider]{
+			{CloudProvider: config.CloudProvider{Enabled: true, Name: "org-a"}},
+			{CloudProvider: config.CloudProvider{Enabled: false, Name: "org-b"}},
+			{CloudProvider: config.CloudProvider{Enabled: true, Name: "org-c"}},
+		},
+		GCP: config.Profiles[config.GCPCloudProvider]{
+			{CloudProvider: config.CloudProvider{Enabled: true, Name: "gcp-org"}},
 		},
 	}
 
-	provider, err := NewCloudProvider(cfg)
+	providers, err := NewCloudProviders(context.Background(), cfg)
 
 	assert.NoError(t, err)
-	assert.IsType(t, &gcp.GCPProvider{}, provider)
+	if assert.Len(t, providers, 3) {
+		assert.Equal(t, "org-a", providers[0].GetProfile().Name)
+		assert.Equal(t, "org-c", providers[1].GetProfile().Name)
+		assert.Equal(t, "gcp-org", providers[2].GetProfile().Name)
+		assert.IsType(t, &gcp.GCPProvider{}, providers[2])
+	}
 }
 
-func TestNewCloudProvider_NoneEnabled_Err(t *testing.T) {
+func TestNewCloudProviders_NoneEnabled_Err(t *testing.T) {
 	cfg := &config.Config{}
 
-	provider, err := NewCloudProvider(cfg)
+	providers, err := NewCloudProviders(context.Background(), cfg)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "no cloud provider enabled")
-	assert.Nil(t, provider)
+	assert.Nil(t, providers)
+}
+
+func TestNewCloudProviders_ProfileFails_Skipped(t *testing.T) {
+	cfg := &config.Config{
+		AWS: config.Profiles[config.AWSCloudProvider]{
+			{CloudProvider: config.CloudProvider{Enabled: true, Name: "org-a"}},
+		},
+		GCP: config.Profiles[config.GCPCloudProvider]{{
+			CloudProvider:    config.CloudProvider{Enabled: true, Name: "gcp-org"},
+			WorkloadIdentity: &config.GCPWorkloadIdentity{Source: "unknown"},
+		}},
+	}
+	collector := &warnings.Collector{}
+
+	providers, err := NewCloudProviders(warnings.WithCollector(context.Background(), collector), cfg)
+
+	assert.NoError(t, err)
+	if assert.Len(t, providers, 1) {
+		assert.Equal(t, "org-a", providers[0].GetProfile().Name)
+	}
+	got := collector.Warnings()
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, warnings.SkippedProfile, got[0].Category)
+		assert.Contains(t, got[0].Message, "gcp-org")
+	}
+}
+
+func TestNewCloudProviders_AllProfilesFail_Err(t *testing.T) {
+	cfg := &config.Config{
+		GCP: config.Profiles[config.GCPCloudProvider]{{
+			CloudProvider:    config.CloudProvider{Enabled: true, Name: "gcp-org"},
+			WorkloadIdentity: &config.GCPWorkloadIdentity{Source: "unknown"},
+		}},
+	}
+
+	providers, err := NewCloudProviders(context.Background(), cfg)
+
+	assert.ErrorContains(t, err, "GCP profile gcp-org")
+	assert.ErrorContains(t, err, "unknown workload identity source")
+	assert.Nil(t, providers)
 }
@@ -0,0 +1,23 @@
+package cloud_provider_t
+
+import "fmt"
+
+// AuthError is a failure to authenticate with a cloud provider, or to read what discovery needs,
+// whose cause was recognised. It reads as the cause and how to fix it rather than the error chain
+// of the SDK, which Err keeps for debugging.
+type AuthError struct {
+	// Cause is what went wrong, e.g. "the SSO session of AWS profile org-a has expired"
+	Cause string
+	// Remediation is how to fix it, e.g. "run aws sso login --profile org-a"
+	Remediation string
+	// Err is the error of the SDK
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("%s, %s", e.Cause, e.Remediation)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
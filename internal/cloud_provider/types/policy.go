@@ -0,0 +1,16 @@
+package cloud_provider_t
+
+// Policy is the least privilege access the discovery of a profile needs, in the format of its
+// cloud provider, e.g. an IAM policy
+type Policy struct {
+	// Name is who or what the policy is for, e.g. "connector" or the role assumed in each account
+	Name string `json:"name"`
+	// Document is the policy, custom role or role definition, marshalled to JSON as is
+	Document any `json:"document"`
+}
+
+// PolicyGenerator is a provider that can generate the policies its enabled service checks need,
+// from its config alone, without calling the cloud provider
+type PolicyGenerator interface {
+	Policies() []Policy
+}
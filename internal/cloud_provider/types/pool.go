@@ -0,0 +1,42 @@
+package cloud_provider_t
+
+import "sync"
+
+// RunAll calls f with each index below n, up to concurrency calls at a time, and waits for them
+// to return. The calls are made in order, one at a time, if concurrency is 1 or less. f writes
+// its result to its index of a slice, so the results keep the order of the calls. A panic in f
+// is raised again in the caller, once all the calls have returned.
+func RunAll(n int, concurrency int, f func(idx int)) {
+	if concurrency <= 1 {
+		for idx := range n {
+			f(idx)
+		}
+		return
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var panicked any
+	for idx := range n {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// Recovered so it can be reported with the context of the caller, rather than
+			// crashing the process from a goroutine
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { panicked = r })
+				}
+			}()
+			f(idx)
+		}()
+	}
+	wg.Wait()
+
+	if panicked != nil {
+		panic(panicked)
+	}
+}
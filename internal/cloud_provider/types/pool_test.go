@@ -0,0 +1,52 @@
+package cloud_provider_t
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunAll_Sequential(t *testing.T) {
+	var order []int
+	RunAll(3, 1, func(idx int) {
+		order = append(order, idx)
+	})
+	assert.Equal(t, []int{0, 1, 2}, order)
+}
+
+func TestRunAll_BoundsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	results := make([]int, 10)
+
+	RunAll(len(results), 3, func(idx int) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		results[idx] = idx * 2
+	})
+
+	assert.LessOrEqual(t, peak.Load(), int32(3))
+	assert.Greater(t, peak.Load(), int32(1))
+	assert.Equal(t, []int{0, 2, 4, 6, 8, 10, 12, 14, 16, 18}, results)
+}
+
+func TestRunAll_Panic_RaisedInCaller(t *testing.T) {
+	var calls atomic.Int32
+	assert.PanicsWithValue(t, "boom", func() {
+		RunAll(5, 2, func(idx int) {
+			calls.Add(1)
+			if idx == 3 {
+				panic("boom")
+			}
+		})
+	})
+	assert.Equal(t, int32(5), calls.Load())
+}
@@ -0,0 +1,19 @@
+package cloud_provider_t
+
+import "context"
+
+// Check is the outcome of a preflight check of access the discovery of a profile needs
+type Check struct {
+	// Name is what was checked, e.g. a service or "AssumeRole 111111111111"
+	Name string
+	// Err is why the check failed, nil if it passed
+	Err error
+	// Hint is how to fix the check if it failed, e.g. the permissions to grant
+	Hint string
+}
+
+// Preflighter is a provider that can check the access its discovery needs, with the smallest call
+// of each check rather than discovering resources. Preflight is called after Authenticate.
+type Preflighter interface {
+	Preflight(ctx context.Context) []Check
+}
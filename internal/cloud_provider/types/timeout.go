@@ -0,0 +1,14 @@
+package cloud_provider_t
+
+import (
+	"context"
+	"time"
+)
+
+// WithTimeout bounds ctx by timeout, or leaves it unbounded if timeout is 0
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -3,6 +3,8 @@ package cloud_provider_t
 import (
 	"context"
 	"fmt"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
 )
 
 var ErrNoAPIKey = fmt.Errorf("no API key")
@@ -12,4 +14,14 @@ type CloudProvider interface {
 	GetResources(ctx context.Context) ([]string, error)
 	GetAPIKey(ctx context.Context) (string, error)
 	GetName() string
+	// GetProfile returns the config profile the provider was created from
+	GetProfile() *config.CloudProvider
+}
+
+// AccountLister is a provider that discovers the resources of several accounts, e.g. the AWS
+// accounts of an organisation, so a sync can be split into one per account
+type AccountLister interface {
+	// ListAccounts returns the accounts the resources are discovered from, as selected by the
+	// accounts of an Event, or none if the provider only discovers its own account
+	ListAccounts(ctx context.Context) ([]string, error)
 }
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Check turns a service check on or off. It is set with a bool, or a mapping of the check's
+// options, which turns the check on unless enabled: false is set.
+type Check struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// S3Check is the S3 service check
+type S3Check struct {
+	Enabled bool `yaml:"enabled"`
+	// IncludePrivate also discovers buckets that aren't publicly accessible
+	IncludePrivate bool `yaml:"include_private,omitempty"`
+}
+
+// RDSCheck is the RDS service check
+type RDSCheck struct {
+	Enabled bool `yaml:"enabled"`
+	// PublicOnly skips instances that aren't publicly accessible, and clusters without one
+	PublicOnly bool `yaml:"public_only,omitempty"`
+}
+
+func (c *Check) UnmarshalYAML(node *yaml.Node) error {
+	type plain Check
+	return decodeCheck(node, (*plain)(c), &c.Enabled)
+}
+
+func (c *S3Check) UnmarshalYAML(node *yaml.Node) error {
+	type plain S3Check
+	return decodeCheck(node, (*plain)(c), &c.Enabled)
+}
+
+func (c *RDSCheck) UnmarshalYAML(node *yaml.Node) error {
+	type plain RDSCheck
+	return decodeCheck(node, (*plain)(c), &c.Enabled)
+}
+
+func (c *Check) scalarShorthand() *schema    { return &schema{Type: "boolean"} }
+func (c *S3Check) scalarShorthand() *schema  { return &schema{Type: "boolean"} }
+func (c *RDSCheck) scalarShorthand() *schema { return &schema{Type: "boolean"} }
+
+// decodeCheck decodes a bool into enabled, or a mapping into check, a pointer to a check struct
+// without its UnmarshalYAML method
+func decodeCheck(node *yaml.Node, check any, enabled *bool) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(enabled)
+	}
+
+	// Setting options implies the check is on
+	*enabled = true
+	return node.Decode(check)
+}
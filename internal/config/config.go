@@ -1,7 +1,10 @@
 package config
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"regexp"
@@ -9,65 +12,85 @@ import (
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/hexiosec/asm-cloud-connector/internal/config/source"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
+	"github.com/robfig/cron/v3"
 	"github.com/sethvargo/go-envconfig"
 	"gopkg.in/yaml.v3"
 )
 
 type CloudProvider struct {
 	Enabled bool `yaml:"enabled"`
+	// Name identifies the profile in logs, defaults to the provider and its position in the list
+	Name string `yaml:"name"`
+	// ScanID, or ScanName, and SeedTag override the global values for the resources of this profile
+	ScanID   string `yaml:"scan_id,omitempty"`
+	ScanName string `yaml:"scan_name,omitempty"`
+	SeedTag  string `yaml:"seed_tag,omitempty"`
+	// Timeout bounds the discovery of this profile, and ServiceTimeout each service check call of it,
+	// e.g. one AWS service in one region, or the GCP asset search of one project. Unbounded if 0.
+	Timeout        time.Duration `yaml:"timeout,omitempty" validate:"min=0"`
+	ServiceTimeout time.Duration `yaml:"service_timeout,omitempty" validate:"min=0"`
+	// Concurrency is how many service check calls of this profile run at a time, one if 0
+	Concurrency int `yaml:"concurrency,omitempty" validate:"min=0"`
 }
 
 type AWSServices struct {
-	CheckEC2          bool `yaml:"check_ec2"`
-	CheckEIP          bool `yaml:"check_eip"`
-	CheckELB          bool `yaml:"check_elb"`
-	CheckS3           bool `yaml:"check_s3"`
-	CheckACM          bool `yaml:"check_acm"`
-	CheckRoute53      bool `yaml:"check_route53"`
-	CheckCloudFront   bool `yaml:"check_cloudfront"`
-	CheckAPIGateway   bool `yaml:"check_api_gateway"`
-	CheckAPIGatewayV2 bool `yaml:"check_api_gateway_v2"`
-	CheckEKS          bool `yaml:"check_eks"`
-	CheckRDS          bool `yaml:"check_rds"`
-	CheckOpenSearch   bool `yaml:"check_opensearch"`
-	CheckLambda       bool `yaml:"check_lambda"`
+	ServiceSelection  `yaml:",inline"`
+	CheckEC2          Check    `yaml:"check_ec2"`
+	CheckEIP          Check    `yaml:"check_eip"`
+	CheckELB          Check    `yaml:"check_elb"`
+	CheckS3           S3Check  `yaml:"check_s3"`
+	CheckACM          Check    `yaml:"check_acm"`
+	CheckRoute53      Check    `yaml:"check_route53"`
+	CheckCloudFront   Check    `yaml:"check_cloudfront"`
+	CheckAPIGateway   Check    `yaml:"check_api_gateway"`
+	CheckAPIGatewayV2 Check    `yaml:"check_api_gateway_v2"`
+	CheckEKS          Check    `yaml:"check_eks"`
+	CheckRDS          RDSCheck `yaml:"check_rds"`
+	CheckOpenSearch   Check    `yaml:"check_opensearch"`
+	CheckLambda       Check    `yaml:"check_lambda"`
+	CheckBYOIP        Check    `yaml:"check_byoip"`
 }
 
 type GCPServices struct {
-	CheckDNSResourceRecordSet    bool `yaml:"check_dns_resource_record_set"`
-	CheckDNSManagedZone          bool `yaml:"check_dns_managed_zone"`
-	CheckComputeInstance         bool `yaml:"check_compute_instance"`
-	CheckComputeAddress          bool `yaml:"check_compute_address"`
-	CheckStorageBucket           bool `yaml:"check_storage_bucket"`
-	CheckCloudFunction           bool `yaml:"check_cloud_function"`
-	CheckRunService              bool `yaml:"check_run_service"`
-	CheckRunDomainMapping        bool `yaml:"check_run_domain_mapping"`
-	CheckAPIGateway              bool `yaml:"check_api_gateway"`
-	CheckSQLInstance             bool `yaml:"check_sql_instance"`
-	CheckComputeForwardingRule   bool `yaml:"check_compute_forwarding_rule"`
-	CheckComputeGlobalForwarding bool `yaml:"check_compute_global_forwarding_rule"`
-	CheckComputeURLMap           bool `yaml:"check_compute_url_map"`
-	CheckAppEngineService        bool `yaml:"check_app_engine_service"`
-	CheckGKECluster              bool `yaml:"check_gke_cluster"`
-	CheckCertificates            bool `yaml:"check_certificates"`
+	ServiceSelection             `yaml:",inline"`
+	CheckDNSResourceRecordSet    Check `yaml:"check_dns_resource_record_set"`
+	CheckDNSManagedZone          Check `yaml:"check_dns_managed_zone"`
+	CheckComputeInstance         Check `yaml:"check_compute_instance"`
+	CheckComputeAddress          Check `yaml:"check_compute_address"`
+	CheckStorageBucket           Check `yaml:"check_storage_bucket"`
+	CheckCloudFunction           Check `yaml:"check_cloud_function"`
+	CheckRunService              Check `yaml:"check_run_service"`
+	CheckRunDomainMapping        Check `yaml:"check_run_domain_mapping"`
+	CheckAPIGateway              Check `yaml:"check_api_gateway"`
+	CheckSQLInstance             Check `yaml:"check_sql_instance"`
+	CheckComputeForwardingRule   Check `yaml:"check_compute_forwarding_rule"`
+	CheckComputeGlobalForwarding Check `yaml:"check_compute_global_forwarding_rule"`
+	CheckComputeURLMap           Check `yaml:"check_compute_url_map"`
+	CheckAppEngineService        Check `yaml:"check_app_engine_service"`
+	CheckGKECluster              Check `yaml:"check_gke_cluster"`
+	CheckCertificates            Check `yaml:"check_certificates"`
 }
 
 type AzureServices struct {
-	CheckPublicIPAddresses              bool `yaml:"check_public_ip_addresses"`
-	CheckApplicationGateways            bool `yaml:"check_application_gateways"`
-	CheckApplicationGatewayCertificates bool `yaml:"check_application_gateway_certificates"`
-	CheckFrontDoorClassic               bool `yaml:"check_front_door_classic"`
-	CheckFrontDoorAfd                   bool `yaml:"check_front_door_afd"`
-	CheckTrafficManager                 bool `yaml:"check_traffic_manager"`
-	CheckDNSZones                       bool `yaml:"check_dns_zones"`
-	CheckDNSRecords                     bool `yaml:"check_dns_records"`
-	CheckStorageStaticWebsites          bool `yaml:"check_storage_static_websites"`
-	CheckCDNEndpoints                   bool `yaml:"check_cdn_endpoints"`
-	CheckAppServices                    bool `yaml:"check_app_services"`
-	CheckSQLServers                     bool `yaml:"check_sql_servers"`
-	CheckCosmosDB                       bool `yaml:"check_cosmos_db"`
-	CheckRedisCache                     bool `yaml:"check_redis_cache"`
+	ServiceSelection                    `yaml:",inline"`
+	CheckPublicIPAddresses              Check `yaml:"check_public_ip_addresses"`
+	CheckPublicIPPrefixes               Check `yaml:"check_public_ip_prefixes"`
+	CheckApplicationGateways            Check `yaml:"check_application_gateways"`
+	CheckApplicationGatewayCertificates Check `yaml:"check_application_gateway_certificates"`
+	CheckFrontDoorClassic               Check `yaml:"check_front_door_classic"`
+	CheckFrontDoorAfd                   Check `yaml:"check_front_door_afd"`
+	CheckTrafficManager                 Check `yaml:"check_traffic_manager"`
+	CheckDNSZones                       Check `yaml:"check_dns_zones"`
+	CheckDNSRecords                     Check `yaml:"check_dns_records"`
+	CheckStorageStaticWebsites          Check `yaml:"check_storage_static_websites"`
+	CheckCDNEndpoints                   Check `yaml:"check_cdn_endpoints"`
+	CheckAppServices                    Check `yaml:"check_app_services"`
+	CheckSQLServers                     Check `yaml:"check_sql_servers"`
+	CheckCosmosDB                       Check `yaml:"check_cosmos_db"`
+	CheckRedisCache                     Check `yaml:"check_redis_cache"`
 }
 
 type AWSCloudProvider struct {
@@ -78,44 +101,378 @@ type AWSCloudProvider struct {
 	Services        *AWSServices `yaml:"services,omitempty" validate:"required_with=Enabled"`
 	APIKeySecret    *string      `yaml:"api_key_secret,omitempty"`
 	DefaultRegion   string       `yaml:"default_region" validate:"required"`
+	// Profile is a named profile of the shared AWS config, e.g. an SSO session, to authenticate
+	// with instead of the default credential chain
+	Profile string `yaml:"profile,omitempty"`
+	// WebIdentity assumes a role with an OIDC token, e.g. of EKS IRSA or GitHub Actions, instead of
+	// the default credential chain
+	WebIdentity *AWSWebIdentity `yaml:"web_identity,omitempty" validate:"excluded_with=Profile"`
+}
+
+// AWSWebIdentity is a role assumed with the OIDC token in a file, which is read again whenever the
+// credentials are renewed so tokens rotated in place keep working
+type AWSWebIdentity struct {
+	RoleARN   string `yaml:"role_arn" validate:"required"`
+	TokenFile string `yaml:"token_file" validate:"required"`
+	// SessionName is the role session name, logged in CloudTrail. Defaults to asm-cloud-connector.
+	SessionName string `yaml:"session_name,omitempty"`
 }
 
 type GCPCloudProvider struct {
 	CloudProvider `yaml:",inline"`
 	Services      *GCPServices `yaml:"services,omitempty" validate:"required_with=Enabled"`
 	Projects      []string     `yaml:"projects" validate:"required_with=Enabled,omitempty,min=1,dive,gcp_project"`
+	// WorkloadIdentity authenticates with workload identity federation, exchanging a token of another
+	// cloud or identity provider, instead of Application Default Credentials
+	WorkloadIdentity *GCPWorkloadIdentity `yaml:"workload_identity,omitempty"`
+}
+
+// GCPWorkloadIdentity is an external account credential of workload identity federation, so the
+// connector can read GCP from AWS, Azure or on-prem without a service account key
+type GCPWorkloadIdentity struct {
+	// Audience is the full resource name of the workload identity pool provider, e.g.
+	// //iam.googleapis.com/projects/<number>/locations/global/workloadIdentityPools/<pool>/providers/<provider>
+	Audience string `yaml:"audience" validate:"required,startswith=//iam.googleapis.com/"`
+	// Source is where the exchanged token comes from: the AWS credentials of the environment, the
+	// managed identity of an Azure VM, or a file holding an OIDC token
+	Source string `yaml:"source" validate:"required,oneof=aws azure file"`
+	// TokenFile holds the OIDC token for source file, read again on every exchange
+	TokenFile string `yaml:"token_file,omitempty" validate:"required_if=Source file"`
+	// AppIDURI is the application ID URI the Azure managed identity requests a token for, for source azure
+	AppIDURI string `yaml:"app_id_uri,omitempty" validate:"required_if=Source azure"`
+	// ServiceAccount is the email of a service account to impersonate, if the pool isn't granted
+	// access directly
+	ServiceAccount string `yaml:"service_account,omitempty"`
 }
 
 type AzureCloudProvider struct {
 	CloudProvider `yaml:",inline"`
 	Services      *AzureServices `yaml:"services,omitempty" validate:"required_with=Enabled"`
+	// TenantID selects the tenant to authenticate with, needed when profiles cover several tenants
+	TenantID string `yaml:"tenant_id,omitempty" validate:"required_with=WorkloadIdentity"`
+	// WorkloadIdentity authenticates with a federated token, e.g. of AKS workload identity, instead of
+	// the credentials DefaultAzureCredential finds first
+	WorkloadIdentity *AzureWorkloadIdentity `yaml:"workload_identity,omitempty"`
+}
+
+// AzureWorkloadIdentity is an app registration or user-assigned managed identity authenticated with
+// the federated token in a file, which is read again as it is rotated
+type AzureWorkloadIdentity struct {
+	ClientID  string `yaml:"client_id" validate:"required"`
+	TokenFile string `yaml:"token_file" validate:"required"`
+}
+
+// NormalisationRule transforms resources before they are normalised. Rules apply to resources
+// matching Match, or to all resources if it is empty, in the order they are configured.
+type NormalisationRule struct {
+	Match       string  `yaml:"match" validate:"required_with=Replace,omitempty,regexp"`
+	Replace     *string `yaml:"replace"`
+	StripPrefix string  `yaml:"strip_prefix"`
+	KeepCase    bool    `yaml:"keep_case"`
+}
+
+// NewScan is the scan created when the configured scan doesn't exist
+type NewScan struct {
+	Name        string `yaml:"name" validate:"required"`
+	ScanGroupID string `yaml:"scan_group_id" validate:"required"`
+	Type        string `yaml:"type" validate:"omitempty,oneof=adhoc continuous_own continuous_vendor"`
+}
+
+// VersionCheck checks GitHub, or Docker Hub in a container, for a new version each run. It is set
+// with "enabled" or "disabled", or a mapping of its options.
+type VersionCheck struct {
+	// Mode is "disabled" to stop each run checking for a new version, e.g. on a network that blocks
+	// api.github.com
+	Mode string `yaml:"mode,omitempty" env:"VERSION_CHECK,overwrite" validate:"omitempty,oneof=enabled disabled"`
+	// Channel is "stable", or "beta" to be compared against pre-releases as well
+	Channel string `yaml:"channel,omitempty" env:"VERSION_CHECK_CHANNEL,overwrite" validate:"omitempty,oneof=stable beta"`
+}
+
+func (v *VersionCheck) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&v.Mode)
+	}
+	type plain VersionCheck
+	return node.Decode((*plain)(v))
+}
+
+func (v *VersionCheck) scalarShorthand() *schema {
+	return &schema{Type: "string", Enum: []string{"enabled", "disabled"}}
 }
 
 type Config struct {
-	ScanID           string              `yaml:"scan_id" env:"SCAN_ID,overwrite" validate:"required"`
-	SeedTag          string              `yaml:"seed_tag" env:"SEED_TAG,overwrite" validate:"required"`
-	DeleteStaleSeeds bool                `yaml:"delete_stale_seeds" env:"DELETE_STALE_SEEDS,overwrite"`
-	AWS              *AWSCloudProvider   `yaml:"aws,omitempty" env:",noinit" validate:"required_without_all=Azure GCP"`
-	Azure            *AzureCloudProvider `yaml:"azure,omitempty" env:",noinit" validate:"required_without_all=AWS GCP"`
-	GCP              *GCPCloudProvider   `yaml:"gcp,omitempty" env:",noinit" validate:"required_without_all=AWS Azure"`
+	// Version is the layout of the config, older layouts are migrated to ConfigVersion when loaded
+	Version int    `yaml:"version,omitempty" validate:"omitempty,min=1"`
+	ScanID  string `yaml:"scan_id" env:"SCAN_ID,overwrite" validate:"required_without_all=ScanName CreateScanIfMissing"`
+	// ScanName selects the scan by name when ScanID isn't set, resolved to its ID at startup
+	ScanName string `yaml:"scan_name,omitempty" env:"SCAN_NAME,overwrite"`
+	SeedTag  string `yaml:"seed_tag" env:"SEED_TAG,overwrite" validate:"required"`
+	// CreateScanIfMissing creates NewScan when the scan isn't found, or reuses a scan with its name
+	CreateScanIfMissing bool     `yaml:"create_scan_if_missing" env:"CREATE_SCAN_IF_MISSING,overwrite"`
+	NewScan             *NewScan `yaml:"new_scan,omitempty" env:",noinit" validate:"required_with=CreateScanIfMissing"`
+	// APIKey is the ASM API key, used if no cloud provider profile provides one
+	APIKey           string                       `yaml:"api_key,omitempty" env:"API_KEY,overwrite"`
+	DeleteStaleSeeds bool                         `yaml:"delete_stale_seeds" env:"DELETE_STALE_SEEDS,overwrite"`
+	SyncTimeout      time.Duration                `yaml:"sync_timeout" env:"SYNC_TIMEOUT,overwrite" validate:"min=0"`
+	AWS              Profiles[AWSCloudProvider]   `yaml:"aws,omitempty" validate:"required_without_all=Azure GCP,dive"`
+	Azure            Profiles[AzureCloudProvider] `yaml:"azure,omitempty" validate:"required_without_all=AWS GCP,dive"`
+	GCP              Profiles[GCPCloudProvider]   `yaml:"gcp,omitempty" validate:"required_without_all=AWS Azure,dive"`
+	VersionCheck     VersionCheck                 `yaml:"version_check,omitempty"`
 
 	Http struct {
 		RetryCount     int           `yaml:"retry_count"  validate:"required"`
 		RetryBaseDelay time.Duration `yaml:"retry_base_delay"  validate:"required"`
 		RetryMaxDelay  time.Duration `yaml:"retry_max_delay"  validate:"required"`
+		// Retry delays grow by RetryMultiplier each attempt, randomised by RetryJitter so that
+		// concurrent instances don't retry in step. "full" waits between the base delay and the
+		// backoff, "equal" between half the backoff and the backoff, "none" waits the backoff
+		RetryMultiplier float64 `yaml:"retry_multiplier" validate:"omitempty,min=1"`
+		RetryJitter     string  `yaml:"retry_jitter" validate:"omitempty,oneof=none full equal"`
+		// Timeout bounds each call of the HTTP service, including its retries, unless the call sets
+		// its own timeout
+		Timeout time.Duration `yaml:"timeout" validate:"min=0"`
+		// RateLimit and RateBurst throttle requests to the ASM API, in requests per second. RateLimit
+		// is a pointer so 0, no throttling, can be told apart from not set.
+		RateLimit *float64 `yaml:"rate_limit" validate:"omitempty,min=0"`
+		RateBurst int      `yaml:"rate_burst" validate:"min=0"`
+		// After CircuitBreakerThreshold failed requests in a row, ASM API requests fail fast for
+		// CircuitBreakerCooldown. CircuitBreakerThreshold is a pointer so 0, no circuit breaker, can
+		// be told apart from not set.
+		CircuitBreakerThreshold *int          `yaml:"circuit_breaker_threshold" validate:"omitempty,min=0"`
+		CircuitBreakerCooldown  time.Duration `yaml:"circuit_breaker_cooldown" validate:"min=0"`
 	} `yaml:"http" validate:"required"`
+
+	Sync struct {
+		// SeedRetryCount and FailureBudget are pointers so 0, no retries or no failures tolerated,
+		// can be told apart from not set
+		SeedRetryCount *int          `yaml:"seed_retry_count" validate:"omitempty,min=0"`
+		SeedRetryDelay time.Duration `yaml:"seed_retry_delay" validate:"min=0"`
+		FailureBudget  *int          `yaml:"failure_budget" validate:"omitempty,min=0"`
+		// IPRangeMode controls how CIDR ranges are submitted, "range" sends them as IPRange
+		// seeds and "expand" sends each address of ranges up to IPRangeExpandLimit addresses
+		IPRangeMode        string `yaml:"ip_range_mode" validate:"omitempty,oneof=range expand"`
+		IPRangeExpandLimit int    `yaml:"ip_range_expand_limit" validate:"min=0"`
+		// PortMode controls resources discovered as host:port, "strip" seeds the host, "tag" seeds the
+		// host tagged with its ports and "drop_non_standard" skips resources on ports other than 80 and 443
+		PortMode string `yaml:"port_mode" validate:"omitempty,oneof=strip tag drop_non_standard"`
+		// IDNFormat converts internationalised domain names to "ascii" (punycode) or "unicode",
+		// or leaves them as discovered if empty
+		IDNFormat string `yaml:"idn_format" validate:"omitempty,oneof=ascii unicode"`
+		// WildcardMode controls wildcard names (*.example.com), which ASM can't seed directly. Both
+		// modes seed the parent domain, "tag" also tags the seed as coming from a wildcard
+		WildcardMode string `yaml:"wildcard_mode" validate:"omitempty,oneof=strip tag"`
+		// CollapseSubdomains drops domains whose parent domain is seeded in the same sync, e.g. from
+		// a wildcard, as ASM discovers the subdomains of a seed
+		CollapseSubdomains bool                `yaml:"collapse_subdomains"`
+		NormalisationRules []NormalisationRule `yaml:"normalisation_rules" validate:"dive"`
+		// SeedLimit caps the seeds of a scan, the only cap the connector enforces as ASM has no seed
+		// limit field, 0 for no cap
+		SeedLimit int `yaml:"seed_limit" validate:"min=0"`
+		// QuotaMode controls new seeds that would exceed the seed limit, "abort" adds none of them and
+		// "trim" adds those that fit, in QuotaPriority order
+		QuotaMode string `yaml:"quota_mode" validate:"omitempty,oneof=abort trim"`
+		// QuotaPriority lists regexes of the seeds kept first when trimming, in order, seeds matching
+		// none are kept last
+		QuotaPriority []string `yaml:"quota_priority" validate:"dive,regexp"`
+	} `yaml:"sync"`
+
+	Notify struct {
+		// WebhookURL receives a summary at the end of each run, disabled if empty
+		WebhookURL    string `yaml:"webhook_url" env:"WEBHOOK_URL,overwrite" validate:"omitempty,url"`
+		WebhookFormat string `yaml:"webhook_format" validate:"omitempty,oneof=json slack teams"`
+		// On is when to notify, "always", "change" when seeds were added or removed or the run failed, or "failure"
+		On string `yaml:"on" validate:"omitempty,oneof=always change failure"`
+	} `yaml:"notify"`
+
+	ErrorReporting struct {
+		// SentryDSN is the DSN of a Sentry project panics and failed runs are reported to
+		SentryDSN string `yaml:"sentry_dsn,omitempty" env:"SENTRY_DSN,overwrite" validate:"omitempty,url"`
+		// WebhookURL receives panics and failed runs as JSON
+		WebhookURL string `yaml:"webhook_url,omitempty" env:"ERROR_WEBHOOK_URL,overwrite" validate:"omitempty,url"`
+		// Environment is the environment of Sentry events, e.g. "production"
+		Environment string `yaml:"environment,omitempty"`
+	} `yaml:"error_reporting,omitempty"`
+
+	Heartbeat struct {
+		// URL receives the status of the connector after each run, its version, config hash, last
+		// run and next scheduled run, so its health can be shown elsewhere. Disabled if empty.
+		URL string `yaml:"url,omitempty" env:"HEARTBEAT_URL,overwrite" validate:"omitempty,url"`
+	} `yaml:"heartbeat,omitempty"`
+
+	Log struct {
+		// Level is the minimum level logged, e.g. "debug"
+		Level string `yaml:"level" env:"LOG_LEVEL,overwrite" validate:"omitempty,oneof=trace debug info warn error fatal panic disabled"`
+		// Format is "json", "console" for human readable logs, or "gcp" or "azure" for JSON with the
+		// severity and trace fields of Cloud Logging or Azure Monitor
+		Format string `yaml:"format" validate:"omitempty,oneof=json console gcp azure"`
+		// GCPProject is the Google Cloud project the traces of "gcp" logs are in, no trace is logged
+		// if empty
+		GCPProject string `yaml:"gcp_project,omitempty" env:"GOOGLE_CLOUD_PROJECT,overwrite"`
+		// File is appended to instead of logging to stdout
+		File string `yaml:"file"`
+		// MaxSizeMB and MaxAge rotate the log file once it's larger, or has been written to for
+		// longer, the file is never rotated if both are zero
+		MaxSizeMB int           `yaml:"max_size_mb,omitempty" validate:"min=0"`
+		MaxAge    time.Duration `yaml:"max_age,omitempty" validate:"min=0"`
+		// MaxBackups is the number of rotated log files kept
+		MaxBackups int `yaml:"max_backups,omitempty" validate:"min=0"`
+		// Stdout logs to stdout as well as the log file
+		Stdout bool `yaml:"stdout,omitempty"`
+		// SampleEvery logs only the first and then every nth per-resource debug and trace message of
+		// each kind, e.g. each EC2 instance found, all are logged if 0 or 1
+		SampleEvery int `yaml:"sample_every,omitempty" env:"LOG_SAMPLE_EVERY,overwrite" validate:"min=0"`
+		// HTTPTrace logs each outbound request and response, with credentials redacted
+		HTTPTrace bool `yaml:"http_trace" env:"LOG_HTTP_TRACE,overwrite"`
+		// Privacy replaces the hostnames and IP addresses in logs, e.g. of the resources discovered,
+		// "hash" with a hash that's the same for the same value, "redact" with a placeholder, or "off"
+		Privacy string `yaml:"privacy,omitempty" env:"LOG_PRIVACY,overwrite" validate:"omitempty,oneof=off hash redact"`
+		// PrivacyKey keys the hashes of Privacy "hash", so they match across runs but can't be
+		// reversed by hashing known hostnames. A random key is used for each run if empty.
+		PrivacyKey string `yaml:"privacy_key,omitempty" env:"LOG_PRIVACY_KEY,overwrite"`
+	} `yaml:"log"`
+
+	Network struct {
+		// ProxyURL is the proxy for requests to ASM and webhooks, the HTTPS_PROXY env vars are used if empty
+		ProxyURL string `yaml:"proxy_url" validate:"omitempty,url"`
+		// NoProxy lists hosts, domains and CIDR ranges requested without ProxyURL, or without the
+		// proxy of the environment if ProxyURL isn't set
+		NoProxy []string `yaml:"no_proxy"`
+		// CABundle is a PEM file of CA certificates trusted in addition to the system ones, e.g. the
+		// CA of a TLS inspecting proxy
+		CABundle           string `yaml:"ca_bundle" validate:"omitempty,file"`
+		InsecureSkipVerify bool   `yaml:"insecure_skip_verify" validate:"excluded_unless=TLSMode default"`
+		// MinTLSVersion is the lowest TLS version negotiated for outbound requests
+		MinTLSVersion string `yaml:"min_tls_version" validate:"omitempty,oneof=1.2 1.3"`
+		// TLSMode is "default", "restricted" for TLS 1.2 or later with only ECDHE and AES-GCM cipher
+		// suites and NIST curves, or "fips" for restricted and Go's FIPS 140-3 mode, which is
+		// required to be on. It applies to every outbound client of the process, see tlsmode.Set.
+		TLSMode string `yaml:"tls_mode,omitempty" env:"TLS_MODE,overwrite" validate:"omitempty,oneof=default restricted fips"`
+	} `yaml:"network"`
+
+	ASM struct {
+		// BaseURL is the ASM API that seeds are synced to, for self-hosted or regional deployments
+		BaseURL string `yaml:"base_url" env:"ASM_BASE_URL,overwrite" validate:"omitempty,url"`
+		// APIKeyFile is a file the API key is read from instead of api_key, e.g. a Docker or
+		// Kubernetes secret mounted in the container. It's read again if the key is rejected, to pick
+		// up a rotated secret.
+		APIKeyFile string `yaml:"api_key_file,omitempty" env:"API_KEY_FILE,overwrite"`
+		// Record writes the requests to the ASM API and their responses to a file, which Replay
+		// serves them from instead of calling the ASM API, e.g. to test config changes offline
+		Record string `yaml:"record,omitempty" env:"ASM_RECORD,overwrite" validate:"excluded_with=Replay"`
+		Replay string `yaml:"replay,omitempty" env:"ASM_REPLAY,overwrite" validate:"omitempty,file"`
+		// CABundle is a PEM file of CA certificates trusted for the ASM API, in addition to the system
+		// ones and network.ca_bundle
+		CABundle string `yaml:"ca_bundle,omitempty" validate:"omitempty,file"`
+		// ClientCert and ClientKey are PEM files of the certificate and key presented to the ASM API,
+		// e.g. to an egress gateway enforcing mTLS
+		ClientCert string `yaml:"client_cert,omitempty" validate:"required_with=ClientKey,omitempty,file"`
+		ClientKey  string `yaml:"client_key,omitempty" validate:"required_with=ClientCert,omitempty,file"`
+		// OAuth authenticates to the ASM API with OAuth client credentials rather than an API key,
+		// when ClientID is set
+		OAuth struct {
+			ClientID     string   `yaml:"client_id" env:"ASM_OAUTH_CLIENT_ID,overwrite"`
+			ClientSecret string   `yaml:"client_secret" env:"ASM_OAUTH_CLIENT_SECRET,overwrite" validate:"required_with=ClientID"`
+			TokenURL     string   `yaml:"token_url" validate:"required_with=ClientID,omitempty,url"`
+			Scopes       []string `yaml:"scopes,omitempty"`
+		} `yaml:"oauth,omitempty"`
+	} `yaml:"asm"`
+
+	State struct {
+		// Dir is where progress is checkpointed so interrupted syncs can resume, disabled if empty
+		Dir string `yaml:"dir" env:"STATE_DIR,overwrite"`
+	} `yaml:"state"`
+
+	// Audit keeps an append-only record of every seed added and removed
+	Audit struct {
+		// Path is a file appended to, or an s3://, gs:// or Azure Blob URL prefix a new object is
+		// written under for each run. Disabled if empty.
+		Path string `yaml:"path,omitempty" env:"AUDIT_PATH,overwrite"`
+	} `yaml:"audit,omitempty"`
+
+	// Schedule runs syncs in daemon mode, every Interval or on the Cron expression
+	Schedule struct {
+		Interval time.Duration `yaml:"interval,omitempty" validate:"min=0"`
+		// Cron is a standard 5 field cron expression, e.g. "0 */6 * * *", evaluated in local time
+		Cron string `yaml:"cron,omitempty" validate:"excluded_with=Interval,omitempty,cron"`
+	} `yaml:"schedule,omitempty"`
+
+	// Server serves health checks, metrics and a sync trigger over HTTP, keeping the connector
+	// running as in daemon mode
+	Server struct {
+		// Listen is the address to listen on, e.g. ":8080", the server is disabled if empty
+		Listen string `yaml:"listen,omitempty" env:"SERVER_LISTEN,overwrite" validate:"omitempty,hostname_port"`
+		// Token authenticates requests to POST /sync as a bearer token
+		Token string `yaml:"token,omitempty" env:"SERVER_TOKEN,overwrite" validate:"required_with=Listen"`
+	} `yaml:"server,omitempty"`
+
+	// StatsD sends the metrics served at GET /metrics to a StatsD server or Datadog agent as well
+	StatsD struct {
+		// Address is the StatsD server over UDP, e.g. "localhost:8125", disabled if empty
+		Address string `yaml:"address,omitempty" env:"STATSD_ADDRESS,overwrite" validate:"omitempty,hostname_port"`
+		// Format is "dogstatsd", sending labels as tags, or "statsd", appending them to the name
+		Format string `yaml:"format,omitempty" validate:"omitempty,oneof=dogstatsd statsd"`
+		// Tags are added to every metric sent to DogStatsD, e.g. "env:prod"
+		Tags []string `yaml:"tags,omitempty"`
+	} `yaml:"statsd,omitempty"`
+
+	// Health reports the liveness of a daemon or server, so a wedged connector can be restarted
+	Health struct {
+		// File is kept while the connector is healthy and removed once it's unhealthy, for an exec
+		// liveness probe. No file is kept if empty.
+		File string `yaml:"file,omitempty" env:"HEALTH_FILE,overwrite"`
+		// MaxStaleness is how long without a successful sync before the connector is unhealthy, it's
+		// always healthy if zero
+		MaxStaleness time.Duration `yaml:"max_staleness,omitempty" env:"HEALTH_MAX_STALENESS,overwrite" validate:"min=0"`
+	} `yaml:"health,omitempty"`
+
+	// FanOut splits a Lambda sync into one invocation per AWS account and GCP project, so large
+	// organisations stay within the Lambda time limit
+	FanOut struct {
+		// QueueURL is the SQS queue the coordinator invocation sends an event for each account to,
+		// which triggers the worker invocations. Fan-out is disabled if empty.
+		QueueURL string `yaml:"queue_url,omitempty" env:"FAN_OUT_QUEUE_URL,overwrite" validate:"omitempty,url"`
+	} `yaml:"fan_out,omitempty"`
+
+	// CloudWatch sets the metrics a Lambda invocation emits in the CloudWatch Embedded Metric Format
+	CloudWatch struct {
+		// Namespace is the CloudWatch namespace of the metrics
+		Namespace string `yaml:"namespace,omitempty" env:"CLOUDWATCH_NAMESPACE,overwrite"`
+		// DisableMetrics stops a Lambda invocation emitting metrics
+		DisableMetrics bool `yaml:"disable_metrics,omitempty" env:"CLOUDWATCH_DISABLE_METRICS,overwrite"`
+	} `yaml:"cloudwatch,omitempty"`
+
+	// secrets maps the values of resolved secret references to the references, values are redacted
+	// when the config is printed
+	secrets map[string]string
 }
 
+// DefaultASMBaseURL is the ASM API of the Hexiosec hosted platform
+const DefaultASMBaseURL = "https://asm.hexiosec.com/api"
+
+// Time allowed to read the config from a remote source
+const remoteSourceTimeout = 1 * time.Minute
+
+// gcpProject matches a GCP project by number, or by project ID which is resolved to its number at
+// startup. Project IDs are 6 to 30 lowercase letters, digits or hyphens, starting with a letter.
+var gcpProject = regexp.MustCompile(`^projects/([0-9]+|[a-z][a-z0-9-]{4,28}[a-z0-9])$`)
+
 // Provider for Config
 func Provider(filePath string) *Config {
-	config, err := loadConfig(filePath)
+	config, err := Load(filePath)
 	if err != nil {
 		logger.GetGlobalLogger().Fatal().Err(err).Msg("Config failed to load")
 	}
 	return config
 }
 
-func loadConfig(filePath string) (*Config, error) {
+// Load reads, defaults and validates the config from the CONNECTOR_CONFIG env var, or filePath if
+// it's not set. Validation failures are returned as ValidationErrors.
+func Load(filePath string) (*Config, error) {
+	// network.tls_mode isn't known until the config is read, so reading it follows TLS_MODE
+	if err := tlsmode.Set(os.Getenv("TLS_MODE")); err != nil {
+		return nil, fmt.Errorf("config: failed to apply TLS_MODE, %w", err)
+	}
+
 	if raw, ok := os.LookupEnv("CONNECTOR_CONFIG"); ok {
 		logger.GetGlobalLogger().Info().Msg("Loading config from CONNECTOR_CONFIG env var")
 
@@ -123,10 +480,32 @@ func loadConfig(filePath string) (*Config, error) {
 			return nil, fmt.Errorf("config: CONNECTOR_CONFIG is set but empty")
 		}
 
-		config, err := unmarshalConfig([]byte(raw))
+		if source.IsKMS(raw) {
+			plaintext, err := decryptKMSConfig(raw)
+			if err != nil {
+				return nil, err
+			}
+			raw = string(plaintext)
+		}
+
+		decrypted, err := decryptConfig([]byte(raw), "CONNECTOR_CONFIG")
 		if err != nil {
-			return nil, fmt.Errorf("config: failed to parse CONNECTOR_CONFIG as YAML: %w", err)
+			return nil, err
+		}
+		config, err := unmarshalConfig(decrypted)
+		if err != nil {
+			return nil, fmt.Errorf("config: failed to parse CONNECTOR_CONFIG as YAML or JSON: %w", err)
+		}
+		if err := applyTLSMode(config); err != nil {
+			return nil, err
 		}
+		if err := resolveSecrets(config); err != nil {
+			return nil, err
+		}
+		if err := readAPIKeyFile(config); err != nil {
+			return nil, err
+		}
+		logger.AddSecrets(config.Secrets()...)
 		setDefaults(config)
 		if err := validate(config); err != nil {
 			return nil, fmt.Errorf("config: validation failed for CONNECTOR_CONFIG: %w", err)
@@ -135,21 +514,51 @@ func loadConfig(filePath string) (*Config, error) {
 		return config, nil
 	}
 
-	logger.GetGlobalLogger().Info().Str("path", filePath).Msg("Loading config from file")
-
-	cfgFile, err := os.ReadFile(filePath)
+	paths, err := configPaths(filePath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("config: no configuration found: CONNECTOR_CONFIG not set and file %s not found", filePath)
+		return nil, err
+	}
+
+	// Later files are overlaid on earlier ones
+	var merged *yaml.Node
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		cfgFile, err := readConfig(path)
+		if err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("config: failed to read %s: %w", filePath, err)
+
+		if source.IsRemote(path) {
+			// Keep credentials in the source, e.g. SAS tokens, out of errors
+			path = source.Redact(path)
+		}
+		names = append(names, path)
+
+		node, err := parseConfig(cfgFile)
+		if err != nil {
+			return nil, fmt.Errorf("config: failed to unmarshal %s: %w", path, err)
+		}
+		merged = mergeNodes(merged, node)
 	}
+	filePath = strings.Join(names, ", ")
 
-	config, err := unmarshalConfig(cfgFile)
+	config, err := decodeConfig(merged)
 	if err != nil {
 		return nil, fmt.Errorf("config: failed to unmarshal %s: %w", filePath, err)
 	}
 
+	if err := applyTLSMode(config); err != nil {
+		return nil, err
+	}
+	if err := resolveSecrets(config); err != nil {
+		return nil, err
+	}
+	if err := readAPIKeyFile(config); err != nil {
+		return nil, err
+	}
+	// Keep the credentials of the config out of the logs, e.g. trace logs of the requests using them
+	logger.AddSecrets(config.Secrets()...)
+
 	setDefaults(config)
 
 	if err := validate(config); err != nil {
@@ -159,11 +568,92 @@ func loadConfig(filePath string) (*Config, error) {
 	return config, nil
 }
 
+// readConfig reads the config file, or the config from a remote source if filePath is one,
+// decrypting it if it was encrypted with SOPS
+func readConfig(filePath string) ([]byte, error) {
+	if source.IsRemote(filePath) {
+		ref := source.Redact(filePath)
+		logger.GetGlobalLogger().Info().Str("source", ref).Msg("Loading config from remote source")
+
+		ctx, cancel := context.WithTimeout(context.Background(), remoteSourceTimeout)
+		defer cancel()
+
+		cfgFile, err := source.Read(ctx, filePath)
+		if err != nil {
+			return nil, fmt.Errorf("config: failed to read %s: %w", ref, err)
+		}
+		return decryptConfig(cfgFile, ref)
+	}
+
+	logger.GetGlobalLogger().Info().Str("path", filePath).Msg("Loading config from file")
+
+	cfgFile, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("config: no configuration found: CONNECTOR_CONFIG not set and file %s not found", filePath)
+		}
+		return nil, fmt.Errorf("config: failed to read %s: %w", filePath, err)
+	}
+	return decryptConfig(cfgFile, filePath)
+}
+
+// unmarshalConfig parses a YAML or JSON config, expanding ${VAR} references to env vars in its
+// values
+// applyTLSMode makes network.tls_mode the TLS mode of the process, before the secrets of the config
+// are read and the clients of the run created
+func applyTLSMode(config *Config) error {
+	if err := tlsmode.Set(config.Network.TLSMode); err != nil {
+		return fmt.Errorf("config: failed to apply network.tls_mode, %w", err)
+	}
+	return nil
+}
+
 func unmarshalConfig(configYaml []byte) (*Config, error) {
-	var config Config
-	if err := yaml.Unmarshal(configYaml, &config); err != nil {
+	node, err := parseConfig(configYaml)
+	if err != nil {
+		return nil, err
+	}
+	return decodeConfig(node)
+}
+
+// parseConfig parses a YAML or JSON config into a node, expanding ${VAR} references to env vars in
+// its values. JSON is a subset of YAML so is decoded the same way, but is checked as JSON first to
+// give JSON syntax errors rather than YAML ones.
+func parseConfig(configYaml []byte) (*yaml.Node, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(configYaml), []byte("{")) {
+		var raw any
+		if err := json.Unmarshal(configYaml, &raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON, %w", err)
+		}
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(configYaml, &node); err != nil {
 		return nil, err
 	}
+	if err := interpolate(&node); err != nil {
+		return nil, fmt.Errorf("failed to interpolate env vars, %w", err)
+	}
+	return &node, nil
+}
+
+// decodeConfig decodes a parsed config, then applies env var overrides
+func decodeConfig(node *yaml.Node) (*Config, error) {
+	var config Config
+	// An empty document leaves the node unset
+	if node != nil && node.Kind != 0 {
+		warnings, err := migrate(node)
+		if err != nil {
+			return nil, err
+		}
+		for _, warning := range warnings {
+			logger.GetGlobalLogger().Warn().Str("key", warning).Msgf("Deprecated config layout migrated, set version: %d and update the config to silence this", ConfigVersion)
+		}
+
+		if err := node.Decode(&config); err != nil {
+			return nil, err
+		}
+	}
 	if err := envconfig.Process(context.Background(), &config); err != nil {
 		return nil, err
 	}
@@ -181,9 +671,123 @@ func setDefaults(config *Config) {
 	if config.Http.RetryMaxDelay == 0 {
 		config.Http.RetryMaxDelay = 5 * time.Second
 	}
+	if config.Http.RetryMultiplier == 0 {
+		config.Http.RetryMultiplier = 2
+	}
+	if config.Http.RetryJitter == "" {
+		config.Http.RetryJitter = "full"
+	}
+	if config.Http.Timeout == 0 {
+		config.Http.Timeout = 30 * time.Second
+	}
+	if config.CloudWatch.Namespace == "" {
+		config.CloudWatch.Namespace = "HexiosecCloudConnector"
+	}
+	if config.Network.MinTLSVersion == "" {
+		config.Network.MinTLSVersion = "1.2"
+	}
+	if config.Network.TLSMode == "" {
+		config.Network.TLSMode = "default"
+	}
+	if config.Http.RateLimit == nil {
+		limit := 10.0
+		config.Http.RateLimit = &limit
+	}
+	if config.Http.RateBurst == 0 {
+		config.Http.RateBurst = 10
+	}
+	if config.Http.CircuitBreakerThreshold == nil {
+		threshold := 5
+		config.Http.CircuitBreakerThreshold = &threshold
+	}
+	if config.Http.CircuitBreakerCooldown == 0 {
+		config.Http.CircuitBreakerCooldown = 1 * time.Minute
+	}
+	if config.Sync.SeedRetryCount == nil {
+		retries := 2
+		config.Sync.SeedRetryCount = &retries
+	}
+	if config.Sync.SeedRetryDelay == 0 {
+		config.Sync.SeedRetryDelay = 2 * time.Second
+	}
+	if config.Sync.FailureBudget == nil {
+		budget := 10
+		config.Sync.FailureBudget = &budget
+	}
+	if config.Sync.IPRangeMode == "" {
+		config.Sync.IPRangeMode = "range"
+	}
+	if config.Sync.IPRangeExpandLimit == 0 {
+		config.Sync.IPRangeExpandLimit = 256
+	}
+	if config.Sync.PortMode == "" {
+		config.Sync.PortMode = "strip"
+	}
+	if config.Sync.WildcardMode == "" {
+		config.Sync.WildcardMode = "strip"
+	}
+	if config.Sync.QuotaMode == "" {
+		config.Sync.QuotaMode = "abort"
+	}
+	if config.Notify.WebhookFormat == "" {
+		config.Notify.WebhookFormat = "json"
+	}
+	if config.Notify.On == "" {
+		config.Notify.On = "always"
+	}
 	if config.SeedTag == "" {
 		config.SeedTag = "cloud-connector"
 	}
+	if config.VersionCheck.Mode == "" {
+		config.VersionCheck.Mode = "enabled"
+	}
+	if config.VersionCheck.Channel == "" {
+		config.VersionCheck.Channel = "stable"
+	}
+	if config.Log.Level == "" {
+		config.Log.Level = "info"
+	}
+	if config.Log.Format == "" {
+		config.Log.Format = "json"
+	}
+	if config.Log.Privacy == "" {
+		config.Log.Privacy = "off"
+	}
+	if config.Log.MaxBackups == 0 {
+		config.Log.MaxBackups = 5
+	}
+	if config.ASM.BaseURL == "" {
+		config.ASM.BaseURL = DefaultASMBaseURL
+	}
+	if config.NewScan != nil && config.NewScan.Type == "" {
+		config.NewScan.Type = "continuous_own"
+	}
+	for idx, profile := range config.AWS {
+		setProfileDefaults(config, &profile.CloudProvider, "aws", idx)
+		if profile.WebIdentity != nil && profile.WebIdentity.SessionName == "" {
+			profile.WebIdentity.SessionName = "asm-cloud-connector"
+		}
+	}
+	for idx, profile := range config.Azure {
+		setProfileDefaults(config, &profile.CloudProvider, "azure", idx)
+	}
+	for idx, profile := range config.GCP {
+		setProfileDefaults(config, &profile.CloudProvider, "gcp", idx)
+	}
+}
+
+func setProfileDefaults(config *Config, profile *CloudProvider, provider string, idx int) {
+	if profile.Name == "" {
+		profile.Name = fmt.Sprintf("%s-%d", provider, idx+1)
+	}
+	// A profile naming its scan doesn't inherit the global scan ID
+	if profile.ScanID == "" && profile.ScanName == "" {
+		profile.ScanID = config.ScanID
+		profile.ScanName = config.ScanName
+	}
+	if profile.SeedTag == "" {
+		profile.SeedTag = config.SeedTag
+	}
 }
 
 func validate(config *Config) error {
@@ -191,12 +795,34 @@ func validate(config *Config) error {
 
 	// Custom validator: gcp_project
 	if err := v.RegisterValidation("gcp_project", func(fl validator.FieldLevel) bool {
-		// We expect a string like "projects/641674919469"
+		// We expect a string like "projects/641674919469" or "projects/my-project"
 		value := fl.Field().String()
-		return regexp.MustCompile(`^projects/[0-9]+$`).MatchString(value)
+		return gcpProject.MatchString(value)
 	}); err != nil {
 		return fmt.Errorf("config: failed to register gcp_project validator: %w", err)
 	}
 
-	return v.Struct(config)
+	// Custom validator: regexp
+	if err := v.RegisterValidation("regexp", func(fl validator.FieldLevel) bool {
+		_, err := regexp.Compile(fl.Field().String())
+		return err == nil
+	}); err != nil {
+		return fmt.Errorf("config: failed to register regexp validator: %w", err)
+	}
+
+	// Custom validator: cron
+	if err := v.RegisterValidation("cron", func(fl validator.FieldLevel) bool {
+		_, err := cron.ParseStandard(fl.Field().String())
+		return err == nil
+	}); err != nil {
+		return fmt.Errorf("config: failed to register cron validator: %w", err)
+	}
+
+	err := v.Struct(config)
+
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		return newValidationErrors(fieldErrs)
+	}
+	return err
 }
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is a validation failure of the config field at Path, a YAML path such as
+// aws[0].assume_role
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors are the validation failures of a config
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		msgs = append(msgs, fieldErr.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func newValidationErrors(errs validator.ValidationErrors) ValidationErrors {
+	out := make(ValidationErrors, 0, len(errs))
+	for _, err := range errs {
+		path, parent := yamlPath(reflect.TypeFor[Config](), err.StructNamespace())
+		out = append(out, FieldError{
+			Path:    path,
+			Message: fieldMessage(err, parent),
+		})
+	}
+	return out
+}
+
+// fieldMessage describes a validation failure in terms of the YAML keys, parent is the struct
+// holding the field, so the fields named by rules such as required_with can be resolved
+func fieldMessage(err validator.FieldError, parent reflect.Type) string {
+	switch err.Tag() {
+	case "required":
+		return "is required"
+	case "required_with":
+		return fmt.Sprintf("is required when %s", joinOr(siblingConditions(parent, err.Param())))
+	case "required_without", "required_without_all":
+		return fmt.Sprintf("is required unless %s", joinOr(siblingConditions(parent, err.Param())))
+	case "excluded_with":
+		return fmt.Sprintf("must not be set when %s", joinOr(siblingConditions(parent, err.Param())))
+	case "required_if":
+		return fmt.Sprintf("is required when %s", valueConditions(parent, err.Param()))
+	case "excluded_unless":
+		return fmt.Sprintf("must not be set unless %s", valueConditions(parent, err.Param()))
+	case "min":
+		switch {
+		case err.Type() == durationType:
+			n, _ := strconv.Atoi(err.Param())
+			return fmt.Sprintf("must be at least %s", time.Duration(n))
+		case err.Kind() == reflect.Slice:
+			return fmt.Sprintf("must have at least %s item(s)", err.Param())
+		default:
+			return fmt.Sprintf("must be at least %s", err.Param())
+		}
+	case "oneof":
+		return fmt.Sprintf("must be one of %s, got %q", joinOr(strings.Fields(err.Param())), err.Value())
+	case "startswith":
+		return fmt.Sprintf("must start with %s, got %q", err.Param(), err.Value())
+	case "url":
+		return fmt.Sprintf("must be a URL, got %q", err.Value())
+	case "regexp":
+		return fmt.Sprintf("must be a valid regular expression, got %q", err.Value())
+	case "file":
+		return fmt.Sprintf("must be an existing file, got %q", err.Value())
+	case "hostname_port":
+		return fmt.Sprintf("must be an address in the form host:port or :port, got %q", err.Value())
+	case "cron":
+		return fmt.Sprintf("must be a cron expression, e.g. \"0 */6 * * *\", got %q", err.Value())
+	case "gcp_project":
+		return fmt.Sprintf("must be a project in the form projects/<number> or projects/<project ID>, got %q", err.Value())
+	default:
+		return fmt.Sprintf("failed the %s rule", err.Tag())
+	}
+}
+
+// siblingConditions describes the fields named by a rule's param as set, e.g. "accounts is set"
+func siblingConditions(parent reflect.Type, param string) []string {
+	var conditions []string
+	for _, name := range strings.Fields(param) {
+		field, ok := parent.FieldByName(name)
+		if !ok {
+			conditions = append(conditions, name+" is set")
+			continue
+		}
+
+		key := yamlKey(field)
+		if field.Type.Kind() == reflect.Bool {
+			conditions = append(conditions, key+" is true")
+		} else {
+			conditions = append(conditions, key+" is set")
+		}
+	}
+	return conditions
+}
+
+// valueConditions describes the field and value pairs of a rule's param, e.g. "tls_mode is default"
+func valueConditions(parent reflect.Type, param string) string {
+	var conditions []string
+	fields := strings.Fields(param)
+	for i := 0; i+1 < len(fields); i += 2 {
+		key := fields[i]
+		if field, ok := parent.FieldByName(fields[i]); ok {
+			key = yamlKey(field)
+		}
+		conditions = append(conditions, key+" is "+fields[i+1])
+	}
+	return strings.Join(conditions, " and ")
+}
+
+func joinOr(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
+
+func yamlKey(field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if key == "" {
+		key = strings.ToLower(field.Name)
+	}
+	return key
+}
+
+// yamlPath converts the Go namespace of a field, e.g. Config.AWS[0].CloudProvider.ScanID, to its
+// YAML path, e.g. aws[0].scan_id. t is the type of the root struct. The struct type holding the
+// field is also returned.
+func yamlPath(t reflect.Type, namespace string) (string, reflect.Type) {
+	var path []string
+	parent := t
+
+	// The first segment is the root struct
+	segments := strings.Split(namespace, ".")[1:]
+	for _, segment := range segments {
+		name, index, hasIndex := strings.Cut(segment, "[")
+
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		parent = t
+		field, ok := t.FieldByName(name)
+		if !ok {
+			path = append(path, segment)
+			continue
+		}
+
+		t = field.Type
+		if _, opts, _ := strings.Cut(field.Tag.Get("yaml"), ","); opts == "inline" {
+			continue
+		}
+
+		key := yamlKey(field)
+		if hasIndex {
+			key += "[" + index
+			for t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			t = t.Elem()
+		}
+		path = append(path, key)
+	}
+
+	return strings.Join(path, "."), parent
+}
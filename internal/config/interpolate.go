@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envRef matches ${VAR} and ${VAR:-default}, or an escaped $${. Only the braced form is expanded,
+// so regex replacements such as $1 in normalisation rules are left alone.
+var envRef = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// interpolate expands env var references in the scalar values of a YAML document. Keys are not
+// expanded. Unquoted values are re-resolved after expansion, so ${VAR} can set a bool or number.
+func interpolate(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := interpolate(child); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		// Content alternates keys and values
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolate(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+
+		value, err := expandEnv(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+
+		node.Value = value
+		if node.Style == 0 && node.Tag == "!!str" {
+			node.Tag = ""
+		}
+	}
+
+	return nil
+}
+
+// expandEnv replaces the env var references in s, failing if a variable without a default is unset
+func expandEnv(s string) (string, error) {
+	var missing []string
+	expanded := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+
+		match := envRef.FindStringSubmatch(ref)
+		if value, ok := os.LookupEnv(match[1]); ok {
+			return value
+		}
+		if match[2] != "" {
+			return match[3]
+		}
+
+		missing = append(missing, match[1])
+		return ref
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("env var %s is not set", strings.Join(missing, ", "))
+	}
+
+	return expanded, nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config/source"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+// decryptKMSConfig decrypts a CONNECTOR_CONFIG encrypted with a KMS key, e.g. kms://aws-kms/...,
+// with the default credentials of its cloud, so function environment variables don't hold the
+// config in plaintext
+func decryptKMSConfig(raw string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+
+	store, _, _ := strings.Cut(strings.TrimPrefix(raw, "kms://"), "/")
+	logger.GetGlobalLogger().Info().Str("kms", store).Msg("Decrypting KMS-encrypted CONNECTOR_CONFIG")
+
+	plaintext, err := source.DecryptKMS(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to decrypt CONNECTOR_CONFIG, %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config/source"
+	"gopkg.in/yaml.v3"
+)
+
+// configExtensions are the files read from a config directory
+var configExtensions = []string{".yml", ".yaml", ".json"}
+
+// configPaths splits a comma separated list of config files, directories and remote sources. The
+// config files in a directory are read in name order, e.g. 00-base.yml before 10-prod.yml.
+func configPaths(filePath string) ([]string, error) {
+	var paths []string
+	for _, path := range strings.Split(filePath, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if source.IsRemote(path) || err != nil || !info.IsDir() {
+			// Missing files are reported when they are read
+			paths = append(paths, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: failed to read directory %s: %w", path, err)
+		}
+
+		found := false
+		for _, entry := range entries {
+			if !entry.IsDir() && slices.Contains(configExtensions, filepath.Ext(entry.Name())) {
+				paths = append(paths, filepath.Join(path, entry.Name()))
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("config: no config files found in directory %s", path)
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("config: no config file given")
+	}
+	return paths, nil
+}
+
+// DirConfigs returns the configs of dir, each config file and subdirectory in name order, to be
+// loaded separately rather than merged. A subdirectory is merged as one config, see Load.
+func DirConfigs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to read directory %s: %w", dir, err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		// Skip hidden entries, e.g. .git
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if entry.IsDir() || slices.Contains(configExtensions, filepath.Ext(entry.Name())) {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("config: no config files found in directory %s", dir)
+	}
+	return paths, nil
+}
+
+// mergeNodes deep merges overlay into base and returns the result. Mappings are merged key by key,
+// any other value in overlay, including lists, replaces the value in base.
+func mergeNodes(base *yaml.Node, overlay *yaml.Node) *yaml.Node {
+	switch {
+	case base == nil || base.Kind == 0:
+		return overlay
+	case overlay == nil || overlay.Kind == 0:
+		return base
+	case base.Kind == yaml.DocumentNode && overlay.Kind == yaml.DocumentNode:
+		base.Content[0] = mergeNodes(base.Content[0], overlay.Content[0])
+		return base
+	case base.Kind != yaml.MappingNode || overlay.Kind != yaml.MappingNode:
+		return overlay
+	}
+
+	// Content alternates keys and values
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+
+		merged := false
+		for j := 0; j+1 < len(base.Content); j += 2 {
+			if base.Content[j].Value == key.Value {
+				base.Content[j+1] = mergeNodes(base.Content[j+1], value)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			base.Content = append(base.Content, key, value)
+		}
+	}
+
+	return base
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigVersion is the version of the config layout of this release. Configs without a version
+// are version 1, the layout before provider profiles and per-service options.
+const ConfigVersion = 2
+
+// migrations upgrade a config from the version at their index + 1 to the next version. Each
+// returns a warning for every deprecated key or layout it rewrote, by YAML path.
+var migrations = []func(root *yaml.Node) []string{
+	migrateV1,
+}
+
+// migrate upgrades a parsed config to ConfigVersion and sets its version, returning warnings for
+// the deprecated keys and layouts it rewrote
+func migrate(node *yaml.Node) ([]string, error) {
+	root := node
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	// Anything else fails to decode with a clearer error
+	if root.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	version := 1
+	versionNode := mappingValue(root, "version")
+	if versionNode != nil {
+		v, err := strconv.Atoi(versionNode.Value)
+		if err != nil || versionNode.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: version must be a number", versionNode.Line)
+		}
+		if v < 1 || v > ConfigVersion {
+			return nil, fmt.Errorf("line %d: config version %d is not supported, the latest is %d", versionNode.Line, v, ConfigVersion)
+		}
+		version = v
+	}
+
+	var warnings []string
+	for v := version; v < ConfigVersion; v++ {
+		warnings = append(warnings, migrations[v-1](root)...)
+	}
+
+	if versionNode == nil {
+		versionNode = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int"}
+		root.Content = append([]*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}, versionNode}, root.Content...)
+	}
+	versionNode.Value = strconv.Itoa(ConfigVersion)
+
+	return warnings, nil
+}
+
+// migrateV1 turns single provider profiles into lists, and boolean service checks into mappings.
+// Version 2 still accepts both as shorthands, so neither is deprecated and nothing is warned about.
+func migrateV1(root *yaml.Node) []string {
+	for _, provider := range []string{"aws", "azure", "gcp"} {
+		profiles := mappingValue(root, provider)
+		if profiles == nil {
+			continue
+		}
+
+		if profiles.Kind == yaml.MappingNode {
+			list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{{}}}
+			*list.Content[0] = *profiles
+			*profiles = *list
+		}
+		if profiles.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for _, profile := range profiles.Content {
+			services := mappingValue(profile, "services")
+			if services == nil || services.Kind != yaml.MappingNode {
+				continue
+			}
+
+			// Content alternates keys and values
+			for j := 1; j < len(services.Content); j += 2 {
+				key, check := services.Content[j-1], services.Content[j]
+				if !strings.HasPrefix(key.Value, "check_") || check.Kind != yaml.ScalarNode {
+					continue
+				}
+
+				enabled := *check
+				*check = yaml.Node{
+					Kind:    yaml.MappingNode,
+					Tag:     "!!map",
+					Line:    enabled.Line,
+					Content: []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: "enabled"}, &enabled},
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil if it is not set
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
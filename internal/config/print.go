@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces credentials in the printed config
+const redacted = "REDACTED"
+
+// redactedKeys are the YAML paths of fields that hold credentials, such as the token in a webhook
+// URL or the password in a proxy URL
+var redactedKeys = [][]string{
+	{"api_key"},
+	{"notify", "webhook_url"},
+	{"error_reporting", "sentry_dsn"},
+	{"error_reporting", "webhook_url"},
+	{"heartbeat", "url"},
+	{"network", "proxy_url"},
+	{"asm", "oauth", "client_secret"},
+	{"server", "token"},
+	{"log", "privacy_key"},
+}
+
+// MarshalRedacted returns the config as YAML, with credentials and the values of secret references
+// redacted, so it can be shared when debugging
+func (c *Config) MarshalRedacted() ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(c); err != nil {
+		return nil, fmt.Errorf("config: failed to encode config, %w", err)
+	}
+
+	redactNode(&node, nil, c.secrets)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, fmt.Errorf("config: failed to marshal config, %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("config: failed to marshal config, %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Hash returns a SHA-256 hash of the config, with credentials redacted, to tell which config a
+// connector runs without sharing it. Rotating a credential doesn't change the hash.
+func (c *Config) Hash() (string, error) {
+	b, err := c.MarshalRedacted()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// redactNode redacts the non-empty scalars of node at one of redactedKeys, or equal to the value
+// of one of secrets. path is the mapping keys leading to node.
+func redactNode(node *yaml.Node, path []string, secrets map[string]string) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			redactNode(child, path, secrets)
+		}
+	case yaml.MappingNode:
+		// Content alternates keys and values
+		for i := 1; i < len(node.Content); i += 2 {
+			redactNode(node.Content[i], append(slices.Clone(path), node.Content[i-1].Value), secrets)
+		}
+	case yaml.ScalarNode:
+		if node.Value == "" {
+			return
+		}
+
+		isKey := slices.ContainsFunc(redactedKeys, func(key []string) bool { return slices.Equal(key, path) })
+		_, isSecret := secrets[node.Value]
+		if isKey || isSecret {
+			node.Value = redacted
+			node.Tag = "!!str"
+			node.Style = 0
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Profiles is a list of cloud provider profiles. In YAML it is either a single profile,
+// as in earlier versions, or a list of them.
+type Profiles[T any] []*T
+
+func (p *Profiles[T]) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var profiles []*T
+		if err := node.Decode(&profiles); err != nil {
+			return err
+		}
+		*p = profiles
+		return nil
+	}
+
+	var profile T
+	if err := node.Decode(&profile); err != nil {
+		return err
+	}
+	*p = Profiles[T]{&profile}
+	return nil
+}
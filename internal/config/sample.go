@@ -0,0 +1,139 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// SampleProviders are the providers that Sample can write a profile for
+var SampleProviders = []string{"aws", "azure", "gcp"}
+
+var sampleTemplate = template.Must(template.New("sample").Funcs(template.FuncMap{
+	"services": sampleServices,
+}).Parse(`# yaml-language-server: $schema=https://raw.githubusercontent.com/hexiosec/asm-cloud-connector/main/config.schema.json
+# Hexiosec ASM Cloud Connector config, see the README for every option.
+# Check it with: connector validate --config <this file>
+version: {{ .Version }}
+
+# ASM scan that receives the discovered resources as seeds
+scan_id: 00000000-0000-0000-0000-000000000000
+# Label applied to the seeds created by the Cloud Connector
+seed_tag: cloud_connector
+# Delete seeds with the seed tag that are no longer discovered
+delete_stale_seeds: false
+# ASM API key, if no provider profile provides one. Prefer a secret reference or the API_KEY env var
+# api_key: secret://aws-sm/asm-api-key
+{{- if .AWS }}
+
+aws:
+  - enabled: true
+    # Region used for authentication and the initial API calls
+    default_region: eu-west-2
+    # Name or ARN of a Secrets Manager secret holding the ASM API key
+    # api_key_secret: asm-cloud-connector/api-key
+    # Discover every account in the organisation, or only the listed accounts, by assuming assume_role
+    list_all_accounts: false
+    # accounts: ["123456789012"]
+    # assume_role: asm-cloud-connector
+    services:
+{{ services .AWS }}
+{{- end }}
+{{- if .Azure }}
+
+azure:
+  - enabled: true
+    # Tenant to authenticate with, defaults to the tenant of the credentials
+    # tenant_id: 00000000-0000-0000-0000-000000000000
+    services:
+{{ services .Azure }}
+{{- end }}
+{{- if .GCP }}
+
+gcp:
+  - enabled: true
+    # Projects to discover, by project number or project ID
+    projects:
+      - projects/my-project
+    services:
+{{ services .GCP }}
+{{- end }}
+`))
+
+// Sample returns a commented starter config with a profile for each of providers, listing every
+// service check of the provider turned on
+func Sample(providers []string) ([]byte, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("config: at least one provider is required")
+	}
+
+	data := struct {
+		Version int
+		AWS     *AWSServices
+		Azure   *AzureServices
+		GCP     *GCPServices
+	}{Version: ConfigVersion}
+
+	for _, provider := range providers {
+		switch provider {
+		case "aws":
+			data.AWS = &AWSServices{}
+		case "azure":
+			data.Azure = &AzureServices{}
+		case "gcp":
+			data.GCP = &GCPServices{}
+		default:
+			return nil, fmt.Errorf("config: unknown provider %q, must be one of %s", provider, strings.Join(SampleProviders, ", "))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := sampleTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("config: failed to write sample config, %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// sampleServices lists every service check of a services struct turned on, with the options of
+// checks that have them
+func sampleServices(services any) string {
+	const indent = "      "
+
+	v := reflect.ValueOf(services).Elem()
+	checks := serviceChecks(v)
+
+	var lines []string
+	for i := range v.NumField() {
+		key, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		if _, ok := checks[key]; !ok {
+			continue
+		}
+
+		options := checkOptions(v.Field(i).Type())
+		if len(options) == 0 {
+			lines = append(lines, fmt.Sprintf("%s%s: true", indent, key))
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("%s%s:", indent, key), fmt.Sprintf("%s  enabled: true", indent))
+		for _, option := range options {
+			lines = append(lines, fmt.Sprintf("%s  %s: false", indent, option))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// checkOptions returns the YAML keys of the options of a check, other than enabled
+func checkOptions(t reflect.Type) []string {
+	var options []string
+	for i := range t.NumField() {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if key != "enabled" && t.Field(i).Type.Kind() == reflect.Bool {
+			options = append(options, key)
+		}
+	}
+	return options
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// schema is the subset of JSON Schema needed to describe Config
+type schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	OneOf                []*schema          `json:"oneOf,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Default              any                `json:"default,omitempty"`
+}
+
+// shorthand is implemented by types that also accept a scalar in place of a mapping, it returns
+// the schema of the scalar
+type shorthand interface {
+	scalarShorthand() *schema
+}
+
+var (
+	durationType    = reflect.TypeFor[time.Duration]()
+	unmarshalerType = reflect.TypeFor[yaml.Unmarshaler]()
+	shorthandType   = reflect.TypeFor[shorthand]()
+)
+
+// Schema returns a JSON Schema for the config file, generated from the Config struct and its
+// validation tags. Fields with a default or an env var override are not required.
+func Schema() ([]byte, error) {
+	defaults := &Config{}
+	setDefaults(defaults)
+
+	s := structSchema(reflect.TypeFor[Config](), reflect.ValueOf(defaults).Elem())
+	s.Schema = schemaDraft
+	s.Title = "Hexiosec ASM Cloud Connector config"
+
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to marshal schema, %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+// structSchema describes a struct, defaults holds the default value of each field
+func structSchema(t reflect.Type, defaults reflect.Value) *schema {
+	closed := false
+	s := &schema{
+		Type:                 "object",
+		Properties:           map[string]*schema{},
+		AdditionalProperties: &closed,
+	}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+
+		var fieldDefault reflect.Value
+		if defaults.IsValid() {
+			fieldDefault = defaults.Field(i)
+		}
+
+		// Inlined structs share the properties of their parent
+		if opts == "inline" {
+			inlined := structSchema(field.Type, fieldDefault)
+			for k, v := range inlined.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, inlined.Required...)
+			continue
+		}
+
+		validate := field.Tag.Get("validate")
+		prop := typeSchema(field.Type, validate, fieldDefault)
+		if fieldDefault.IsValid() && !fieldDefault.IsZero() && field.Type.Kind() != reflect.Struct {
+			prop.Default = defaultValue(fieldDefault)
+		}
+		s.Properties[name] = prop
+
+		hasDefault := fieldDefault.IsValid() && !fieldDefault.IsZero()
+		if hasTag(validate, "required") && field.Tag.Get("env") == "" && !hasDefault {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return s
+}
+
+// typeSchema describes a field of type t, with the rules of its validate tag
+func typeSchema(t reflect.Type, validate string, defaults reflect.Value) *schema {
+	// Rules after dive apply to the elements of a list
+	rules, elemRules, _ := strings.Cut(validate, "dive")
+
+	// Profiles accept a single value or a list of them
+	if t.Kind() == reflect.Slice && reflect.PointerTo(t).Implements(unmarshalerType) {
+		item := typeSchema(t.Elem(), strings.Trim(elemRules, ","), reflect.Value{})
+		return &schema{OneOf: []*schema{item, {Type: "array", Items: item}}}
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var s *schema
+	switch {
+	case t == durationType:
+		s = &schema{Type: "string", Pattern: `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`}
+	case t.Kind() == reflect.Struct:
+		if defaults.IsValid() && defaults.Kind() == reflect.Pointer {
+			defaults = reflect.Value{}
+		}
+		s = structSchema(t, defaults)
+		if reflect.PointerTo(t).Implements(shorthandType) {
+			scalar := reflect.New(t).Interface().(shorthand).scalarShorthand()
+			return &schema{OneOf: []*schema{s, scalar}}
+		}
+		return s
+	case t.Kind() == reflect.Slice:
+		s = &schema{Type: "array", Items: typeSchema(t.Elem(), strings.Trim(elemRules, ","), reflect.Value{})}
+	case t.Kind() == reflect.Bool:
+		s = &schema{Type: "boolean"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s = &schema{Type: "number"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = &schema{Type: "integer"}
+	default:
+		s = &schema{Type: "string"}
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "oneof":
+			s.Enum = strings.Fields(value)
+		case "min":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			switch s.Type {
+			case "array":
+				s.MinItems = &n
+			case "integer", "number":
+				minimum := float64(n)
+				s.Minimum = &minimum
+			}
+		case "url":
+			s.Format = "uri"
+		case "regexp":
+			s.Format = "regex"
+		case "gcp_project":
+			s.Pattern = gcpProject.String()
+		case "startswith":
+			s.Pattern = "^" + regexp.QuoteMeta(value)
+		}
+	}
+
+	return s
+}
+
+func defaultValue(v reflect.Value) any {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	return v.Interface()
+}
+
+func hasTag(validate string, tag string) bool {
+	for _, rule := range strings.Split(validate, ",") {
+		if rule == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config/source"
+)
+
+// Time allowed to resolve the secret references in the config
+const secretTimeout = 1 * time.Minute
+
+// SecretStore reads the secret name of a reference to its store, e.g. <name> of
+// secret://aws-sm/<name>, with the credentials of the cloud provider profiles of config
+type SecretStore func(ctx context.Context, config *Config, name string) (string, error)
+
+// secretStores read the references of each store, instead of the default credentials of its cloud
+var secretStores = map[string]SecretStore{}
+
+// RegisterSecretStore makes the secret references of store, e.g. aws-sm, read with read. The cloud
+// providers register their stores, so references are read with the credentials of a profile, e.g.
+// its workload identity, as config can't depend on the providers.
+func RegisterSecretStore(store string, read SecretStore) {
+	secretStores[store] = read
+}
+
+// readSecret returns the value of a secret reference, read by its registered store or else with
+// the default credentials of its cloud
+func (c *Config) readSecret(ctx context.Context, ref string) (string, error) {
+	store, name, err := source.ParseSecret(ref)
+	if err != nil {
+		return "", err
+	}
+	if read, ok := secretStores[store]; ok {
+		return read(ctx, c, name)
+	}
+	return source.ReadSecret(ctx, ref)
+}
+
+// resolveSecrets replaces the secret references, e.g. secret://aws-sm/asm-api-key, in the string
+// fields of config with the values of the secrets
+func resolveSecrets(config *Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+
+	return resolveSecretFields(ctx, reflect.ValueOf(config).Elem(), nil, func(ctx context.Context, ref string) (string, error) {
+		value, err := config.readSecret(ctx, ref)
+		if err == nil {
+			if config.secrets == nil {
+				config.secrets = map[string]string{}
+			}
+			config.secrets[value] = ref
+		}
+		return value, err
+	})
+}
+
+// fileRefPrefix marks the reference of a value read from a file, e.g. asm.api_key_file, in the
+// secrets of the config
+const fileRefPrefix = "file:"
+
+// readAPIKeyFile sets the API key of config to the content of asm.api_key_file, if set
+func readAPIKeyFile(config *Config) error {
+	path := config.ASM.APIKeyFile
+	if path == "" {
+		return nil
+	}
+	if config.APIKey != "" {
+		return fmt.Errorf("config: api_key and asm.api_key_file are both set, set only one")
+	}
+
+	value, err := readSecretFile(path)
+	if err != nil {
+		return err
+	}
+	config.APIKey = value
+	if config.secrets == nil {
+		config.secrets = map[string]string{}
+	}
+	config.secrets[value] = fileRefPrefix + path
+	return nil
+}
+
+// readSecretFile returns the content of the file at path, without the trailing newline secrets
+// are often written with
+func readSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("config: failed to read secret file %s, %w", path, err)
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "" {
+		return "", fmt.Errorf("config: secret file %s is empty", path)
+	}
+	return value, nil
+}
+
+// RefreshSecret reads the secret or file that value was resolved from again, to pick up a rotated
+// secret. ok is false if value wasn't resolved from a secret reference or file.
+func (c *Config) RefreshSecret(ctx context.Context, value string) (refreshed string, ok bool, err error) {
+	ref, ok := c.secrets[value]
+	if !ok {
+		return "", false, nil
+	}
+
+	if path, isFile := strings.CutPrefix(ref, fileRefPrefix); isFile {
+		refreshed, err = readSecretFile(path)
+	} else {
+		refreshed, err = c.readSecret(ctx, ref)
+	}
+	if err != nil {
+		return "", true, fmt.Errorf("config: failed to refresh secret, %w", err)
+	}
+	return refreshed, true, nil
+}
+
+// Secrets returns the credentials in the config and the values of its secret references, to redact
+// from logs
+func (c *Config) Secrets() []string {
+	values := []string{c.APIKey, c.Notify.WebhookURL, c.ASM.OAuth.ClientSecret, c.Server.Token, c.ErrorReporting.SentryDSN, c.ErrorReporting.WebhookURL, c.Heartbeat.URL, c.Log.PrivacyKey}
+	if u, err := url.Parse(c.Network.ProxyURL); err == nil && u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			values = append(values, password)
+		}
+	}
+	for value := range c.secrets {
+		values = append(values, value)
+	}
+	return values
+}
+
+// resolveSecretFields walks v, replacing secret references with the result of read. path is the
+// YAML path of v, used in errors.
+func resolveSecretFields(ctx context.Context, v reflect.Value, path []string, read func(ctx context.Context, ref string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return resolveSecretFields(ctx, v.Elem(), path, read)
+	case reflect.Struct:
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			fieldPath := path
+			if _, opts, _ := strings.Cut(field.Tag.Get("yaml"), ","); opts != "inline" {
+				fieldPath = append(slices.Clone(path), yamlKey(field))
+			}
+			if err := resolveSecretFields(ctx, v.Field(i), fieldPath, read); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			elemPath := slices.Clone(path)
+			if len(elemPath) > 0 {
+				elemPath[len(elemPath)-1] += fmt.Sprintf("[%d]", i)
+			}
+			if err := resolveSecretFields(ctx, v.Index(i), elemPath, read); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if !source.IsSecret(v.String()) || !v.CanSet() {
+			return nil
+		}
+
+		value, err := read(ctx, v.String())
+		if err != nil {
+			return fmt.Errorf("config: failed to resolve secret for %s, %w", strings.Join(path, "."), err)
+		}
+		v.SetString(value)
+	}
+
+	return nil
+}
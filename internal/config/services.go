@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ServiceSelection turns on service checks in bulk. Checks set explicitly alongside it override
+// the selection, e.g. check_s3: false with enable_all: true.
+type ServiceSelection struct {
+	// EnableAll turns on every service check, including ones added in later versions
+	EnableAll bool `yaml:"enable_all,omitempty"`
+	// Preset turns on a named group of service checks
+	Preset string `yaml:"preset,omitempty" validate:"omitempty,oneof=dns-only network certificates"`
+	// Except lists service checks, by YAML key, left off by EnableAll or Preset
+	Except []string `yaml:"except,omitempty"`
+}
+
+// servicesAll is the scalar shorthand for a services block with enable_all: true
+const servicesAll = "all"
+
+var (
+	awsPresets = map[string][]string{
+		"dns-only":     {"check_route53"},
+		"network":      {"check_ec2", "check_eip", "check_elb", "check_byoip"},
+		"certificates": {"check_acm"},
+	}
+	azurePresets = map[string][]string{
+		"dns-only":     {"check_dns_zones", "check_dns_records"},
+		"network":      {"check_public_ip_addresses", "check_public_ip_prefixes", "check_application_gateways", "check_traffic_manager"},
+		"certificates": {"check_application_gateway_certificates"},
+	}
+	gcpPresets = map[string][]string{
+		"dns-only":     {"check_dns_resource_record_set", "check_dns_managed_zone"},
+		"network":      {"check_compute_instance", "check_compute_address", "check_compute_forwarding_rule", "check_compute_global_forwarding_rule"},
+		"certificates": {"check_certificates"},
+	}
+)
+
+func (s *AWSServices) UnmarshalYAML(node *yaml.Node) error {
+	type plain AWSServices
+	return decodeServices(node, (*plain)(s), &s.ServiceSelection, awsPresets)
+}
+
+func (s *AzureServices) UnmarshalYAML(node *yaml.Node) error {
+	type plain AzureServices
+	return decodeServices(node, (*plain)(s), &s.ServiceSelection, azurePresets)
+}
+
+func (s *GCPServices) UnmarshalYAML(node *yaml.Node) error {
+	type plain GCPServices
+	return decodeServices(node, (*plain)(s), &s.ServiceSelection, gcpPresets)
+}
+
+func (s *AWSServices) scalarShorthand() *schema   { return servicesShorthand() }
+func (s *AzureServices) scalarShorthand() *schema { return servicesShorthand() }
+func (s *GCPServices) scalarShorthand() *schema   { return servicesShorthand() }
+
+func servicesShorthand() *schema {
+	return &schema{Type: "string", Enum: []string{servicesAll}}
+}
+
+// decodeServices decodes a services block into services, a pointer to a services struct without
+// its UnmarshalYAML method. The selection is applied first, then the checks set explicitly.
+func decodeServices(node *yaml.Node, services any, selection *ServiceSelection, presets map[string][]string) error {
+	checks := serviceChecks(reflect.ValueOf(services).Elem())
+
+	if node.Kind == yaml.ScalarNode {
+		if node.Value != servicesAll {
+			return fmt.Errorf("line %d: services must be a mapping or %q", node.Line, servicesAll)
+		}
+		selection.EnableAll = true
+		for _, check := range checks {
+			check.SetBool(true)
+		}
+		return nil
+	}
+
+	if err := node.Decode(selection); err != nil {
+		return err
+	}
+
+	var selected []string
+	switch {
+	case selection.EnableAll:
+		for key := range checks {
+			selected = append(selected, key)
+		}
+	case selection.Preset != "":
+		preset, ok := presets[selection.Preset]
+		if !ok {
+			return fmt.Errorf("line %d: unknown services preset %q", node.Line, selection.Preset)
+		}
+		selected = preset
+	}
+
+	for _, key := range selection.Except {
+		if _, ok := checks[key]; !ok {
+			return fmt.Errorf("line %d: unknown service %q in except", node.Line, key)
+		}
+	}
+
+	for _, key := range selected {
+		if !slices.Contains(selection.Except, key) {
+			checks[key].SetBool(true)
+		}
+	}
+
+	// Explicit checks override the selection
+	return node.Decode(services)
+}
+
+// serviceChecks returns the Enabled field of each check in a services struct, by YAML key
+func serviceChecks(v reflect.Value) map[string]reflect.Value {
+	checks := map[string]reflect.Value{}
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if field.Type.Kind() != reflect.Struct || !strings.HasPrefix(key, "check_") {
+			continue
+		}
+		if enabled := v.Field(i).FieldByName("Enabled"); enabled.Kind() == reflect.Bool {
+			checks[key] = enabled
+		}
+	}
+	return checks
+}
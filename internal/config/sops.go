@@ -0,0 +1,87 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/getsops/sops/v3/decrypt"
+	"gopkg.in/yaml.v3"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
+)
+
+// sopsMetadata is the metadata SOPS adds to the files it encrypts, under the sops key
+type sopsMetadata struct {
+	SOPS *struct {
+		MAC      string `yaml:"mac"`
+		sopsKeys `yaml:",inline"`
+		// KeyGroups are the keys of a config split with Shamir's secret sharing
+		KeyGroups []sopsKeys `yaml:"key_groups"`
+	} `yaml:"sops"`
+}
+
+// sopsKeys are the keys of cloud KMS and Vault services the data key of a config is encrypted with
+type sopsKeys struct {
+	KMS     []any `yaml:"kms"`
+	GCPKMS  []any `yaml:"gcp_kms"`
+	AzureKV []any `yaml:"azure_kv"`
+	HCVault []any `yaml:"hc_vault"`
+}
+
+// any returns whether there is a key
+func (k sopsKeys) any() bool {
+	return len(k.KMS)+len(k.GCPKMS)+len(k.AzureKV)+len(k.HCVault) > 0
+}
+
+// isSOPSEncrypted returns whether the config was encrypted with SOPS
+func isSOPSEncrypted(configYaml []byte) bool {
+	var metadata sopsMetadata
+	if err := yaml.Unmarshal(configYaml, &metadata); err != nil {
+		return false
+	}
+	return metadata.SOPS != nil && metadata.SOPS.MAC != ""
+}
+
+// hasServiceKeys returns whether a config encrypted with SOPS has a key of a cloud KMS or Vault
+func hasServiceKeys(configYaml []byte) bool {
+	var metadata sopsMetadata
+	if err := yaml.Unmarshal(configYaml, &metadata); err != nil || metadata.SOPS == nil {
+		return false
+	}
+	if metadata.SOPS.sopsKeys.any() {
+		return true
+	}
+	for _, group := range metadata.SOPS.KeyGroups {
+		if group.any() {
+			return true
+		}
+	}
+	return false
+}
+
+// decryptConfig decrypts a config encrypted with SOPS, with the keys SOPS finds in the environment,
+// e.g. SOPS_AGE_KEY_FILE or the AWS, GCP or Azure credentials of a KMS key. Other configs are
+// returned unchanged. name is the file or env var the config was read from, used in errors.
+func decryptConfig(configYaml []byte, name string) ([]byte, error) {
+	if !isSOPSEncrypted(configYaml) {
+		return configYaml, nil
+	}
+
+	// SOPS creates its own clients of the key services, which TLS_MODE can't restrict
+	if tlsmode.Restricted() && hasServiceKeys(configYaml) {
+		return nil, fmt.Errorf("config: %s is encrypted with SOPS for a cloud KMS or Vault key, which TLS_MODE can't restrict the TLS of, encrypt it for age or PGP keys only", name)
+	}
+
+	logger.GetGlobalLogger().Info().Str("config", name).Msg("Decrypting SOPS-encrypted config")
+
+	format := "yaml"
+	if bytes.HasPrefix(bytes.TrimSpace(configYaml), []byte("{")) {
+		format = "json"
+	}
+	decrypted, err := decrypt.Data(configYaml, format)
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to decrypt %s with SOPS, %w", name, err)
+	}
+	return decrypted, nil
+}
@@ -0,0 +1,306 @@
+package source
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	awskms "github.com/aws/aws-sdk-go-v2/service/kms"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
+)
+
+const kmsPrefix = "kms://"
+
+// IsKMS reports whether value is encrypted with a KMS key, decrypted with DecryptKMS. Encrypted
+// values are:
+//   - kms://aws-kms/<key ID, ARN or alias>:<base64 ciphertext of KMS Encrypt>
+//   - kms://gcp-kms/projects/<project>/locations/<location>/keyRings/<key ring>/cryptoKeys/<key>:<base64 ciphertext>
+//   - kms://azure-kv/<vault>/<key>/<version>:<base64 wrapped key>.<base64 ciphertext>
+//
+// Key Vault keys are RSA, which only encrypts a few hundred bytes, so the value is encrypted with an
+// AES-256-GCM key that is wrapped with the Key Vault key.
+func IsKMS(value string) bool {
+	return strings.HasPrefix(value, kmsPrefix)
+}
+
+// DecryptKMS returns the plaintext of a value encrypted with a KMS key, using the default
+// credentials of its cloud
+func DecryptKMS(ctx context.Context, value string) ([]byte, error) {
+	store, key, ciphertext, err := parseKMS(strings.TrimSpace(value))
+	if err != nil {
+		return nil, err
+	}
+
+	switch store {
+	case "aws-kms":
+		blob, err := base64.StdEncoding.DecodeString(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("source: AWS KMS ciphertext is not base64, %w", err)
+		}
+		return decryptAWSKMS(ctx, key, blob)
+	case "gcp-kms":
+		blob, err := base64.StdEncoding.DecodeString(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("source: GCP KMS ciphertext is not base64, %w", err)
+		}
+		return decryptGCPKMS(ctx, key, blob)
+	case "azure-kv":
+		return decryptKeyVault(ctx, key, ciphertext)
+	default:
+		return nil, fmt.Errorf("source: unsupported KMS %s", store)
+	}
+}
+
+// EncryptKMS encrypts plaintext with a KMS key given as kms://<store>/<key>, e.g.
+// kms://aws-kms/alias/connector, and returns the value DecryptKMS decrypts
+func EncryptKMS(ctx context.Context, key string, plaintext []byte) (string, error) {
+	store, name, ok := strings.Cut(strings.TrimPrefix(key, kmsPrefix), "/")
+	if !strings.HasPrefix(key, kmsPrefix) || !ok || name == "" {
+		return "", fmt.Errorf("source: KMS key must be kms://<aws-kms, gcp-kms or azure-kv>/<key>")
+	}
+
+	switch store {
+	case "aws-kms":
+		blob, err := encryptAWSKMS(ctx, name, plaintext)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s%s/%s:%s", kmsPrefix, store, name, base64.StdEncoding.EncodeToString(blob)), nil
+	case "gcp-kms":
+		blob, err := encryptGCPKMS(ctx, name, plaintext)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s%s/%s:%s", kmsPrefix, store, name, base64.StdEncoding.EncodeToString(blob)), nil
+	case "azure-kv":
+		versioned, ciphertext, err := encryptKeyVault(ctx, name, plaintext)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s%s/%s:%s", kmsPrefix, store, versioned, ciphertext), nil
+	default:
+		return "", fmt.Errorf("source: unsupported KMS %s", store)
+	}
+}
+
+// parseKMS splits an encrypted value into its store, key and ciphertext. The ciphertext follows the
+// last colon, as AWS key ARNs have colons and base64 doesn't.
+func parseKMS(value string) (store string, key string, ciphertext string, err error) {
+	rest := strings.TrimPrefix(value, kmsPrefix)
+	idx := strings.LastIndex(rest, ":")
+	if idx < 0 || idx == len(rest)-1 {
+		return "", "", "", fmt.Errorf("source: KMS-encrypted value has no ciphertext")
+	}
+	ciphertext = rest[idx+1:]
+	store, key, _ = strings.Cut(rest[:idx], "/")
+	if store != "aws-kms" && key == "" {
+		return "", "", "", fmt.Errorf("source: KMS-encrypted value has no key")
+	}
+	return store, key, ciphertext, nil
+}
+
+func decryptAWSKMS(ctx context.Context, key string, blob []byte) ([]byte, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, tlsmode.AWS)
+	if err != nil {
+		return nil, fmt.Errorf("source: unable to load AWS SDK config, %w", err)
+	}
+
+	input := &awskms.DecryptInput{CiphertextBlob: blob}
+	// The ciphertext names its key, which is checked against the key of the value if it has one
+	if key != "" {
+		input.KeyId = aws.String(key)
+	}
+	resp, err := awskms.NewFromConfig(cfg).Decrypt(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to decrypt with AWS KMS, %w", err)
+	}
+
+	return resp.Plaintext, nil
+}
+
+func encryptAWSKMS(ctx context.Context, key string, plaintext []byte) ([]byte, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, tlsmode.AWS)
+	if err != nil {
+		return nil, fmt.Errorf("source: unable to load AWS SDK config, %w", err)
+	}
+
+	resp, err := awskms.NewFromConfig(cfg).Encrypt(ctx, &awskms.EncryptInput{KeyId: aws.String(key), Plaintext: plaintext})
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to encrypt with AWS KMS, %w", err)
+	}
+
+	return resp.CiphertextBlob, nil
+}
+
+func decryptGCPKMS(ctx context.Context, key string, blob []byte) ([]byte, error) {
+	client, err := kms.NewKeyManagementClient(ctx, tlsmode.GCP()...)
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to create GCP KMS client, %w", err)
+	}
+	defer client.Close()
+
+	resp, err := client.Decrypt(ctx, &kmspb.DecryptRequest{Name: key, Ciphertext: blob})
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to decrypt with GCP KMS, %w", err)
+	}
+
+	return resp.Plaintext, nil
+}
+
+func encryptGCPKMS(ctx context.Context, key string, plaintext []byte) ([]byte, error) {
+	client, err := kms.NewKeyManagementClient(ctx, tlsmode.GCP()...)
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to create GCP KMS client, %w", err)
+	}
+	defer client.Close()
+
+	resp, err := client.Encrypt(ctx, &kmspb.EncryptRequest{Name: key, Plaintext: plaintext})
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to encrypt with GCP KMS, %w", err)
+	}
+
+	return resp.Ciphertext, nil
+}
+
+// keyVaultClient returns a client of the vault of key, given as <vault>/<key>[/<version>], and the
+// name and version of the key
+func keyVaultClient(key string) (client *azkeys.Client, name string, version string, err error) {
+	vault, rest, ok := strings.Cut(key, "/")
+	if !ok || rest == "" {
+		return nil, "", "", fmt.Errorf("source: Key Vault key must include a vault and key")
+	}
+	name, version, _ = strings.Cut(rest, "/")
+
+	cred, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: tlsmode.Azure()})
+	if err != nil {
+		return nil, "", "", fmt.Errorf("source: failed to get default Azure credentials, %w", err)
+	}
+
+	client, err = azkeys.NewClient(fmt.Sprintf("https://%s.vault.azure.net/", vault), cred, &azkeys.ClientOptions{ClientOptions: tlsmode.Azure()})
+	if err != nil {
+		return nil, "", "", fmt.Errorf("source: failed to create Key Vault client, %w", err)
+	}
+
+	return client, name, version, nil
+}
+
+func decryptKeyVault(ctx context.Context, key string, ciphertext string) ([]byte, error) {
+	wrapped, sealed, err := decodeEnvelope(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	client, name, version, err := keyVaultClient(key)
+	if err != nil {
+		return nil, err
+	}
+
+	alg := azkeys.EncryptionAlgorithmRSAOAEP256
+	resp, err := client.UnwrapKey(ctx, name, version, azkeys.KeyOperationParameters{Algorithm: &alg, Value: wrapped}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to unwrap key with Key Vault, %w", err)
+	}
+
+	return openEnvelope(resp.Result, sealed)
+}
+
+// encryptKeyVault encrypts plaintext with a new AES key wrapped with a Key Vault key, returning the
+// key with the version that wrapped it and the ciphertext
+func encryptKeyVault(ctx context.Context, key string, plaintext []byte) (string, string, error) {
+	client, name, version, err := keyVaultClient(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	dataKey, sealed, err := sealEnvelope(plaintext)
+	if err != nil {
+		return "", "", err
+	}
+
+	alg := azkeys.EncryptionAlgorithmRSAOAEP256
+	resp, err := client.WrapKey(ctx, name, version, azkeys.KeyOperationParameters{Algorithm: &alg, Value: dataKey}, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("source: failed to wrap key with Key Vault, %w", err)
+	}
+
+	vault, _, _ := strings.Cut(key, "/")
+	if resp.KID != nil {
+		version = resp.KID.Version()
+	}
+	versioned := vault + "/" + name
+	if version != "" {
+		versioned += "/" + version
+	}
+	return versioned, base64.StdEncoding.EncodeToString(resp.Result) + "." + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// sealEnvelope encrypts plaintext with a new AES-256-GCM key, returning the key and the nonce
+// followed by the ciphertext
+func sealEnvelope(plaintext []byte) (key []byte, sealed []byte, err error) {
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, fmt.Errorf("source: failed to generate key, %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("source: failed to generate nonce, %w", err)
+	}
+
+	return key, gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// openEnvelope decrypts the output of sealEnvelope with its key
+func openEnvelope(key []byte, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("source: ciphertext is too short")
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to decrypt, %w", err)
+	}
+	return plaintext, nil
+}
+
+// decodeEnvelope splits a Key Vault ciphertext into the wrapped key and the sealed value
+func decodeEnvelope(ciphertext string) (wrapped []byte, sealed []byte, err error) {
+	wrappedB64, sealedB64, ok := strings.Cut(ciphertext, ".")
+	if !ok {
+		return nil, nil, fmt.Errorf("source: Key Vault ciphertext must be <wrapped key>.<ciphertext>")
+	}
+	if wrapped, err = base64.StdEncoding.DecodeString(wrappedB64); err != nil {
+		return nil, nil, fmt.Errorf("source: Key Vault wrapped key is not base64, %w", err)
+	}
+	if sealed, err = base64.StdEncoding.DecodeString(sealedB64); err != nil {
+		return nil, nil, fmt.Errorf("source: Key Vault ciphertext is not base64, %w", err)
+	}
+	return wrapped, sealed, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("source: invalid key, %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,78 @@
+package source
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKMS(t *testing.T) {
+	tests := []struct {
+		value      string
+		store      string
+		key        string
+		ciphertext string
+	}{
+		{"kms://aws-kms:AQICAHg=", "aws-kms", "", "AQICAHg="},
+		{"kms://aws-kms/arn:aws:kms:eu-west-2:111111111111:key/1234abcd:AQICAHg=", "aws-kms", "arn:aws:kms:eu-west-2:111111111111:key/1234abcd", "AQICAHg="},
+		{"kms://gcp-kms/projects/p/locations/global/keyRings/r/cryptoKeys/k:CiQA", "gcp-kms", "projects/p/locations/global/keyRings/r/cryptoKeys/k", "CiQA"},
+		{"kms://azure-kv/vault/key/0123:d3JhcA==.c2VhbA==", "azure-kv", "vault/key/0123", "d3JhcA==.c2VhbA=="},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			store, key, ciphertext, err := parseKMS(tc.value)
+			require.NoError(t, err)
+			assert.Equal(t, tc.store, store)
+			assert.Equal(t, tc.key, key)
+			assert.Equal(t, tc.ciphertext, ciphertext)
+		})
+	}
+}
+
+func TestDecryptKMS_Invalid_Fails(t *testing.T) {
+	tests := []struct {
+		value   string
+		errText string
+	}{
+		{"kms://vault/key:YWJj", "unsupported KMS vault"},
+		{"kms://aws-kms/alias/connector", "has no ciphertext"},
+		{"kms://gcp-kms:YWJj", "has no key"},
+		{"kms://aws-kms:%%%", "AWS KMS ciphertext is not base64"},
+		{"kms://azure-kv/vault/key:YWJj", "must be <wrapped key>.<ciphertext>"},
+		{"kms://azure-kv/vault:YWJj.ZGVm", "must include a vault and key"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			_, err := DecryptKMS(context.Background(), tc.value)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.errText)
+		})
+	}
+}
+
+func TestEncryptKMS_InvalidKey_Fails(t *testing.T) {
+	_, err := EncryptKMS(context.Background(), "aws-kms/alias/connector", []byte("scan_id: scan"))
+	assert.ErrorContains(t, err, "KMS key must be kms://")
+
+	_, err = EncryptKMS(context.Background(), "kms://vault/key", []byte("scan_id: scan"))
+	assert.ErrorContains(t, err, "unsupported KMS vault")
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	key, sealed, err := sealEnvelope([]byte("scan_id: scan"))
+	require.NoError(t, err)
+	assert.Len(t, key, 32)
+
+	plaintext, err := openEnvelope(key, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "scan_id: scan", string(plaintext))
+
+	// Tampering is detected
+	sealed[len(sealed)-1] ^= 1
+	_, err = openEnvelope(key, sealed)
+	assert.ErrorContains(t, err, "failed to decrypt")
+}
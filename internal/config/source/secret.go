@@ -0,0 +1,122 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"google.golang.org/api/option"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
+)
+
+const secretPrefix = "secret://"
+
+// IsSecret reports whether value is a secret reference, resolved with ReadSecret. Secret
+// references are:
+//   - secret://aws-sm/<secret name or ARN>
+//   - secret://gcp-sm/projects/<project>/secrets/<secret>[/versions/<version>]
+//   - secret://azure-kv/<vault>/<secret>
+func IsSecret(value string) bool {
+	return strings.HasPrefix(value, secretPrefix)
+}
+
+// ParseSecret returns the store of a secret reference, e.g. aws-sm, and the name of the secret in it
+func ParseSecret(ref string) (store string, name string, err error) {
+	store, name, _ = strings.Cut(strings.TrimPrefix(ref, secretPrefix), "/")
+	if name == "" {
+		return "", "", fmt.Errorf("source: secret reference %s has no secret name", ref)
+	}
+
+	switch store {
+	case "aws-sm", "gcp-sm", "azure-kv":
+		return store, name, nil
+	default:
+		return "", "", fmt.Errorf("source: unsupported secret store %s", store)
+	}
+}
+
+// ReadSecret returns the value of a secret reference, using the default credentials of its cloud
+func ReadSecret(ctx context.Context, ref string) (string, error) {
+	store, name, err := ParseSecret(ref)
+	if err != nil {
+		return "", err
+	}
+
+	var value []byte
+	switch store {
+	case "aws-sm":
+		value, err = readSecretsManager(ctx, name)
+	case "gcp-sm":
+		value, err = ReadGCPSecret(ctx, name)
+	case "azure-kv":
+		value, err = ReadKeyVaultSecret(ctx, name, nil)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(value), nil
+}
+
+// ReadGCPSecret returns the latest version of a Secret Manager secret, or the version in its name,
+// read with the client options opts, e.g. the credentials of a workload identity
+func ReadGCPSecret(ctx context.Context, name string, opts ...option.ClientOption) ([]byte, error) {
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	client, err := secretmanager.NewClient(ctx, append(opts, tlsmode.GCP()...)...)
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to create GCP Secret Manager client, %w", err)
+	}
+	defer client.Close()
+
+	resp, err := client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to access GCP secret, %w", err)
+	}
+
+	if resp.Payload == nil {
+		return nil, fmt.Errorf("source: GCP secret has no payload")
+	}
+
+	return resp.Payload.Data, nil
+}
+
+// ReadKeyVaultSecret returns the secret of a vault, named <vault>/<secret>, read with cred, or the
+// default Azure credentials if cred is nil
+func ReadKeyVaultSecret(ctx context.Context, name string, cred azcore.TokenCredential) ([]byte, error) {
+	vault, secret, ok := strings.Cut(name, "/")
+	if !ok || secret == "" {
+		return nil, fmt.Errorf("source: Key Vault secret reference must include a vault and secret")
+	}
+
+	if cred == nil {
+		var err error
+		if cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: tlsmode.Azure()}); err != nil {
+			return nil, fmt.Errorf("source: failed to get default Azure credentials, %w", err)
+		}
+	}
+
+	client, err := azsecrets.NewClient(fmt.Sprintf("https://%s.vault.azure.net/", vault), cred, &azsecrets.ClientOptions{ClientOptions: tlsmode.Azure()})
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to create Key Vault client, %w", err)
+	}
+
+	resp, err := client.GetSecret(ctx, secret, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to get Key Vault secret, %w", err)
+	}
+
+	if resp.Value == nil {
+		return nil, fmt.Errorf("source: Key Vault secret has no value")
+	}
+
+	return []byte(*resp.Value), nil
+}
@@ -0,0 +1,245 @@
+// Package source reads the config from remote sources, so it doesn't need to be baked into images
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
+)
+
+const (
+	ssmPrefix            = "ssm:"
+	secretsManagerPrefix = "secretsmanager:"
+	azureBlobHostSuffix  = ".blob.core.windows.net"
+	// Upper bound on the size of a config, to fail fast if a source points at the wrong object
+	maxConfigSize = 1 << 20
+	httpTimeout   = 30 * time.Second
+)
+
+// IsRemote reports whether ref is a remote source rather than a file path. Remote sources are:
+//   - http:// and https:// URLs, Azure Blob URLs without a SAS token use the default Azure credentials
+//   - s3://bucket/key
+//   - gs://bucket/object
+//   - ssm:<parameter name>
+//   - secretsmanager:<secret name or ARN>
+func IsRemote(ref string) bool {
+	if strings.HasPrefix(ref, ssmPrefix) || strings.HasPrefix(ref, secretsManagerPrefix) {
+		return true
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "http", "https", "s3", "gs":
+		return true
+	default:
+		return false
+	}
+}
+
+// Redact removes the query from URL sources, which may hold credentials such as SAS tokens, so the
+// source can be logged
+func Redact(ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil || u.RawQuery == "" {
+		return ref
+	}
+
+	u.RawQuery = ""
+	return u.String() + "?REDACTED"
+}
+
+// Read returns the contents of a remote source
+func Read(ctx context.Context, ref string) ([]byte, error) {
+	if name, ok := strings.CutPrefix(ref, ssmPrefix); ok {
+		return readSSM(ctx, name)
+	}
+	if name, ok := strings.CutPrefix(ref, secretsManagerPrefix); ok {
+		return readSecretsManager(ctx, name)
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("source: invalid source, %w", err)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		return readS3(ctx, u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "gs":
+		return readGCS(ctx, u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "http", "https":
+		if strings.HasSuffix(u.Hostname(), azureBlobHostSuffix) && !u.Query().Has("sig") {
+			return readAzureBlob(ctx, ref)
+		}
+		return readHTTP(ctx, ref)
+	default:
+		return nil, fmt.Errorf("source: unsupported source scheme %s", u.Scheme)
+	}
+}
+
+func readHTTP(ctx context.Context, ref string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to create request, %w", err)
+	}
+
+	client := &http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The error includes the URL, which may hold credentials
+		return nil, fmt.Errorf("source: request failed, %w", unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("source: unexpected status %d", resp.StatusCode)
+	}
+
+	return readLimited(resp.Body)
+}
+
+func readS3(ctx context.Context, bucket string, key string) ([]byte, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, tlsmode.AWS)
+	if err != nil {
+		return nil, fmt.Errorf("source: unable to load AWS SDK config, %w", err)
+	}
+
+	resp, err := s3.NewFromConfig(cfg).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to get S3 object, %w", err)
+	}
+	defer resp.Body.Close()
+
+	return readLimited(resp.Body)
+}
+
+func readGCS(ctx context.Context, bucket string, object string) ([]byte, error) {
+	client, err := storage.NewClient(ctx, tlsmode.GCP()...)
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to create GCS client, %w", err)
+	}
+	defer client.Close()
+
+	r, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to get GCS object, %w", err)
+	}
+	defer r.Close()
+
+	return readLimited(r)
+}
+
+func readAzureBlob(ctx context.Context, ref string) ([]byte, error) {
+	cred, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: tlsmode.Azure()})
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to get default Azure credentials, %w", err)
+	}
+
+	blobURL, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("source: invalid Azure Blob URL, %w", err)
+	}
+	serviceURL := blobURL.Scheme + "://" + blobURL.Host
+	container, blob, ok := strings.Cut(strings.TrimPrefix(blobURL.Path, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("source: Azure Blob URL must include a container and blob")
+	}
+
+	client, err := azblob.NewClient(serviceURL, cred, &azblob.ClientOptions{ClientOptions: tlsmode.Azure()})
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to create Azure Blob client, %w", err)
+	}
+
+	resp, err := client.DownloadStream(ctx, container, blob, nil)
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to download Azure Blob, %w", err)
+	}
+	defer resp.Body.Close()
+
+	return readLimited(resp.Body)
+}
+
+func readSSM(ctx context.Context, name string) ([]byte, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, tlsmode.AWS)
+	if err != nil {
+		return nil, fmt.Errorf("source: unable to load AWS SDK config, %w", err)
+	}
+
+	resp, err := ssm.NewFromConfig(cfg).GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to get SSM parameter, %w", err)
+	}
+
+	if resp.Parameter == nil || resp.Parameter.Value == nil {
+		return nil, fmt.Errorf("source: SSM parameter has no value")
+	}
+
+	return []byte(*resp.Parameter.Value), nil
+}
+
+func readSecretsManager(ctx context.Context, secret string) ([]byte, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, tlsmode.AWS)
+	if err != nil {
+		return nil, fmt.Errorf("source: unable to load AWS SDK config, %w", err)
+	}
+
+	resp, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secret),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to get secret, %w", err)
+	}
+
+	if resp.SecretString == nil {
+		return nil, fmt.Errorf("source: secret string not set")
+	}
+
+	return []byte(*resp.SecretString), nil
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxConfigSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("source: failed to read config, %w", err)
+	}
+
+	if len(data) > maxConfigSize {
+		return nil, fmt.Errorf("source: config is larger than %d bytes", maxConfigSize)
+	}
+
+	return data, nil
+}
+
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
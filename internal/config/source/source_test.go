@@ -0,0 +1,115 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRemote(t *testing.T) {
+	tests := []struct {
+		ref    string
+		remote bool
+	}{
+		{"./config.yml", false},
+		{"/etc/connector/config.yml", false},
+		{"config.json", false},
+		{"https://example.com/config.yml", true},
+		{"http://example.com/config.yml", true},
+		{"s3://bucket/path/config.yml", true},
+		{"gs://bucket/config.yml", true},
+		{"ssm:/asm/connector/config", true},
+		{"secretsmanager:arn:aws:secretsmanager:eu-west-2:123456789012:secret:config", true},
+		{"ftp://example.com/config.yml", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.ref, func(t *testing.T) {
+			assert.Equal(t, tc.remote, IsRemote(tc.ref))
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	assert.Equal(t, "https://account.blob.core.windows.net/c/config.yml?REDACTED", Redact("https://account.blob.core.windows.net/c/config.yml?sv=2024&sig=secret"))
+	assert.Equal(t, "s3://bucket/config.yml", Redact("s3://bucket/config.yml"))
+	assert.Equal(t, "ssm:/asm/config", Redact("ssm:/asm/config"))
+}
+
+func TestRead_HTTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    string
+		errText string
+	}{
+		{
+			name:   "Success",
+			status: http.StatusOK,
+			body:   "scan_id: 00000000-0000-0000-0000-000000000000\n",
+			want:   "scan_id: 00000000-0000-0000-0000-000000000000\n",
+		},
+		{
+			name:    "NotFound_Fails",
+			status:  http.StatusNotFound,
+			errText: "unexpected status 404",
+		},
+		{
+			name:    "TooLarge_Fails",
+			status:  http.StatusOK,
+			body:    strings.Repeat("a", maxConfigSize+1),
+			errText: "larger than",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			data, err := Read(context.Background(), server.URL+"/config.yml")
+			if tc.errText != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errText)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(data))
+		})
+	}
+}
+
+func TestRead_UnsupportedScheme_Fails(t *testing.T) {
+	_, err := Read(context.Background(), "ftp://example.com/config.yml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported source scheme ftp")
+}
+
+func TestReadSecret_InvalidReference_Fails(t *testing.T) {
+	tests := []struct {
+		ref     string
+		errText string
+	}{
+		{"secret://vault/name", "unsupported secret store vault"},
+		{"secret://aws-sm/", "has no secret name"},
+		{"secret://azure-kv/vault", "must include a vault and secret"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.ref, func(t *testing.T) {
+			_, err := ReadSecret(context.Background(), tc.ref)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.errText)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package connector
+
+import (
+	"context"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/audit"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/warnings"
+	"github.com/rs/zerolog"
+)
+
+// SetAuditor records the seeds each sync adds and removes with auditor
+func (c *Connector) SetAuditor(auditor audit.IAuditor) {
+	c.auditor = auditor
+}
+
+// traceOrigins records the resource each seed was normalised, and expanded, from, for the audit
+// record of the seeds added. A seed several resources are normalised to has the first of them.
+func (c *Connector) traceOrigins(ctx context.Context, resources []string) {
+	c.origins = map[string]string{}
+	if c.auditor == nil {
+		return
+	}
+
+	// The resources were normalised already, don't log about them again
+	quiet := logger.WithLogger(ctx, zerolog.Nop())
+	for _, resource := range resources {
+		names, _ := c.normaliser.normalise(quiet, []string{resource})
+		if c.expandRanges {
+			names = c.expand(quiet, names, &SyncReport{})
+		}
+		for _, name := range names {
+			if _, ok := c.origins[name]; !ok {
+				c.origins[name] = resource
+			}
+		}
+	}
+}
+
+// audit records a seed added or removed. A failure to record it doesn't stop the sync, as the seed
+// has been changed already, but is a warning of the run.
+func (c *Connector) audit(ctx context.Context, seed string, action string) {
+	if c.auditor == nil {
+		return
+	}
+
+	record := audit.Record{ScanID: c.scanID, Seed: seed, Action: action, Origin: c.origins[seed]}
+	if err := c.auditor.Record(ctx, record); err != nil {
+		logger.GetLogger(ctx).Error().Err(err).Str("seed", seed).Str("action", action).Msg("Could not record seed change in the audit log")
+		warnings.Record(ctx, warnings.Audit, "could not record %s of seed %s in the audit log, %v", action, seed, err)
+	}
+}
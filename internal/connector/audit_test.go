@@ -0,0 +1,94 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/audit"
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/warnings"
+	asm "github.com/hexiosec/asm-sdk-go"
+)
+
+// recordingAuditor keeps the records it's given, failing to record the seed fail if set
+type recordingAuditor struct {
+	mu      sync.Mutex
+	records []audit.Record
+	fail    string
+}
+
+func (a *recordingAuditor) Record(_ context.Context, r audit.Record) error {
+	if r.Seed == a.fail {
+		return assert.AnError
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(a.records, r)
+	return nil
+}
+
+func (a *recordingAuditor) Close(_ context.Context) error {
+	return nil
+}
+
+func TestSyncResources_Auditor_RecordsChanges(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:           "scan-123",
+		SeedTag:          "seed-tag",
+		DeleteStaleSeeds: true,
+	}
+	cfg.Sync.IPRangeMode = "expand"
+	cfg.Sync.IPRangeExpandLimit = 4
+	conn, mockAPI := newTestConnector(t, cfg)
+	auditor := &recordingAuditor{}
+	conn.SetAuditor(auditor)
+
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).
+		Return([]asm.SeedsResponseInner{
+			{Name: "existing.com", Tags: []string{cfg.SeedTag}, Id: "existing-id"},
+			{Name: "stale.com", Tags: []string{cfg.SeedTag}, Id: "stale-id"},
+		}, nil, nil)
+	mockAPI.On("AddScanSeedById", cfg.ScanID, mock.Anything).Return(&asm.NodeResponse{}, nil, nil).Times(3)
+	mockAPI.On("RemoveScanSeedById", cfg.ScanID, "stale-id").Return(&http.Response{}, nil).Once()
+
+	_, err := conn.SyncResources(context.Background(), []string{
+		"https://Example.COM/path",
+		"existing.com",
+		"192.0.2.0/31",
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []audit.Record{
+		{ScanID: "scan-123", Seed: "example.com", Action: audit.ActionAdd, Origin: "https://Example.COM/path"},
+		{ScanID: "scan-123", Seed: "192.0.2.0", Action: audit.ActionAdd, Origin: "192.0.2.0/31"},
+		{ScanID: "scan-123", Seed: "192.0.2.1", Action: audit.ActionAdd, Origin: "192.0.2.0/31"},
+		{ScanID: "scan-123", Seed: "stale.com", Action: audit.ActionRemove},
+	}, auditor.records)
+}
+
+func TestSyncResources_AuditorFails_Warning(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:  "scan-123",
+		SeedTag: "seed-tag",
+	}
+	conn, mockAPI := newTestConnector(t, cfg)
+	conn.SetAuditor(&recordingAuditor{fail: "example.com"})
+
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).Return([]asm.SeedsResponseInner{}, nil, nil)
+	mockAPI.On("AddScanSeedById", cfg.ScanID, mock.Anything).Return(&asm.NodeResponse{}, nil, nil).Once()
+
+	collector := &warnings.Collector{}
+	report, err := conn.SyncResources(warnings.WithCollector(context.Background(), collector), []string{"example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com"}, report.Added)
+
+	got := collector.Warnings()
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, warnings.Audit, got[0].Category)
+	}
+}
@@ -0,0 +1,97 @@
+package connector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+// Number of processed resources between checkpoint saves
+const checkpointInterval = 50
+
+// checkpoint records the progress of a sync, so that a re-run with the same resources
+// can skip the ones that were already handled
+type checkpoint struct {
+	ScanID      string     `json:"scan_id"`
+	Fingerprint string     `json:"fingerprint"`
+	Report      SyncReport `json:"report"`
+}
+
+// fingerprint identifies a resource list independent of its order
+func fingerprint(scanID string, resources []string) string {
+	sorted := slices.Clone(resources)
+	slices.Sort(sorted)
+
+	h := sha256.New()
+	h.Write([]byte(scanID))
+	for _, r := range sorted {
+		h.Write([]byte{0})
+		h.Write([]byte(r))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkpointKey is unique to the scan and seed tag, as connectors for each of them share the store
+func (c *Connector) checkpointKey() string {
+	h := sha256.Sum256([]byte(c.scanID + "\x00" + c.seedTag))
+	return "checkpoint-" + hex.EncodeToString(h[:8])
+}
+
+// resume returns the report of an interrupted sync of the same resources, or report if there is none.
+// Failed seeds are dropped from the checkpoint report so they are retried.
+func (c *Connector) resume(ctx context.Context, fp string, report *SyncReport) *SyncReport {
+	var cp checkpoint
+	ok, err := c.store.Load(ctx, c.checkpointKey(), &cp)
+	if err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not load checkpoint, starting a full sync")
+		return report
+	}
+
+	if !ok || cp.ScanID != c.scanID || cp.Fingerprint != fp {
+		return report
+	}
+
+	cp.Report.Failed = nil
+	logger.GetLogger(ctx).Info().
+		Int("added", len(cp.Report.Added)).
+		Int("existing", len(cp.Report.Existing)).
+		Int("rejected", len(cp.Report.Rejected)).
+		Int("removed", len(cp.Report.Removed)).
+		Msg("Resuming interrupted sync from checkpoint")
+
+	return &cp.Report
+}
+
+// saveCheckpoint is best-effort, a sync is never failed because its progress couldn't be saved
+func (c *Connector) saveCheckpoint(ctx context.Context, fp string, report *SyncReport) {
+	err := c.store.Save(ctx, c.checkpointKey(), checkpoint{
+		ScanID:      c.scanID,
+		Fingerprint: fp,
+		Report:      *report,
+	})
+	if err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not save checkpoint")
+	}
+}
+
+func (c *Connector) clearCheckpoint(ctx context.Context) {
+	if err := c.store.Delete(ctx, c.checkpointKey()); err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not clear checkpoint")
+	}
+}
+
+// processed returns the resources the report has already dealt with
+func (r *SyncReport) processed() map[string]struct{} {
+	done := make(map[string]struct{}, len(r.Added)+len(r.Existing)+len(r.Rejected))
+	for _, name := range slices.Concat(r.Added, r.Existing) {
+		done[name] = struct{}{}
+	}
+	for _, rejected := range r.Rejected {
+		done[rejected.Name] = struct{}{}
+	}
+	return done
+}
@@ -0,0 +1,98 @@
+package connector
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	asm "github.com/hexiosec/asm-sdk-go"
+)
+
+func TestSyncResources_Interrupted_ResumesFromCheckpoint(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:  "scan-123",
+		SeedTag: "seed-tag",
+	}
+	cfg.State.Dir = t.TempDir()
+	resources := []string{"example.com", "bad.example.com", "example.org"}
+
+	// First run adds one seed, has one rejected and then fails
+	conn, mockAPI := newTestConnector(t, cfg)
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).Return([]asm.SeedsResponseInner{}, nil, nil).Once()
+	mockAPI.On("AddScanSeedById", cfg.ScanID, mock.MatchedBy(func(req asm.CreateScanSeedRequest) bool {
+		return req.Name == "example.com"
+	})).Return(&asm.NodeResponse{}, nil, nil).Once()
+	mockAPI.On("AddScanSeedById", cfg.ScanID, mock.MatchedBy(func(req asm.CreateScanSeedRequest) bool {
+		return req.Name == "bad.example.com"
+	})).Return(nil, &http.Response{StatusCode: 400, Body: io.NopCloser(strings.NewReader(`{"code":"INVALID"}`))}, assert.AnError).Once()
+	mockAPI.On("AddScanSeedById", cfg.ScanID, mock.MatchedBy(func(req asm.CreateScanSeedRequest) bool {
+		return req.Name == "example.org"
+	})).Return(nil, &http.Response{StatusCode: 401}, assert.AnError).Once()
+
+	_, err := conn.SyncResources(context.Background(), resources)
+	require.Error(t, err)
+
+	// Second run only attempts the remaining seed
+	conn, mockAPI = newTestConnector(t, cfg)
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).Return([]asm.SeedsResponseInner{{Id: "1", Name: "example.com", Tags: []string{"seed-tag"}}}, nil, nil).Once()
+	mockAPI.On("AddScanSeedById", cfg.ScanID, mock.MatchedBy(func(req asm.CreateScanSeedRequest) bool {
+		return req.Name == "example.org"
+	})).Return(&asm.NodeResponse{}, nil, nil).Once()
+
+	report, err := conn.SyncResources(context.Background(), resources)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "example.org"}, report.Added)
+	assert.Equal(t, []RejectedSeed{{Name: "bad.example.com", Reason: "rejected by Hexiosec ASM", Code: "INVALID"}}, report.Rejected)
+
+	// Checkpoint is cleared once the sync completes
+	var cp checkpoint
+	ok, err := conn.store.Load(context.Background(), conn.checkpointKey(), &cp)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestSyncResources_CheckpointForOtherResources_Ignored(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:  "scan-123",
+		SeedTag: "seed-tag",
+	}
+	cfg.State.Dir = t.TempDir()
+	conn, mockAPI := newTestConnector(t, cfg)
+
+	require.NoError(t, conn.store.Save(context.Background(), conn.checkpointKey(), checkpoint{
+		ScanID:      cfg.ScanID,
+		Fingerprint: fingerprint(cfg.ScanID, []string{"other.com"}),
+		Report:      SyncReport{Added: []string{"example.com"}},
+	}))
+
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).Return([]asm.SeedsResponseInner{}, nil, nil)
+	mockAPI.On("AddScanSeedById", cfg.ScanID, mock.Anything).Return(&asm.NodeResponse{}, nil, nil).Once()
+
+	report, err := conn.SyncResources(context.Background(), []string{"example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com"}, report.Added)
+}
+
+func TestFingerprint_OrderIndependent(t *testing.T) {
+	assert.Equal(t, fingerprint("scan", []string{"a", "b"}), fingerprint("scan", []string{"b", "a"}))
+	assert.NotEqual(t, fingerprint("scan", []string{"a", "b"}), fingerprint("other", []string{"a", "b"}))
+}
+
+func TestCheckpointKey_UniquePerScanAndTag(t *testing.T) {
+	newConn := func(scanID, seedTag string) *Connector {
+		conn, _ := newTestConnector(t, &config.Config{ScanID: scanID, SeedTag: seedTag})
+		return conn
+	}
+
+	key := newConn("scan-123", "seed-tag").checkpointKey()
+	assert.Equal(t, key, newConn("scan-123", "seed-tag").checkpointKey())
+	assert.NotEqual(t, key, newConn("scan-456", "seed-tag").checkpointKey())
+	assert.NotEqual(t, key, newConn("scan-123", "other-tag").checkpointKey())
+}
@@ -0,0 +1,85 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/rs/zerolog"
+)
+
+// seedTypes are the seed types a label can set
+var seedTypes = []string{resourceDomain, resourceIPv4, resourceIPRange}
+
+// Label is the extra tags and seed type of a resource, e.g. from the CSV or JSON input of a manual
+// sync
+type Label struct {
+	Tags []string
+	// Type is the seed type, Domain, IPv4 or IPRange, detected from the resource if empty. Seeds are
+	// validated as their type, so a resource that isn't of it is rejected.
+	Type string
+}
+
+// SetLabels labels the resources synced, by the resource as given to SyncResources. The label
+// applies to the seed the resource is normalised to, and is only added to new seeds. A label with
+// more tags than fit on a seed besides the seed tag and scope tag is rejected.
+func (c *Connector) SetLabels(labels map[string]Label) error {
+	ownTags, _ := c.seedTags(nil)
+	free := maxTags - len(ownTags)
+	for resource, label := range labels {
+		if len(label.Tags) > free {
+			return fmt.Errorf("label of %s has %d tags, only %d fit on a seed besides its seed tag and scope tag", resource, len(label.Tags), free)
+		}
+		for _, tag := range label.Tags {
+			if err := validateTag(tag); err != nil {
+				return fmt.Errorf("invalid tag %q of %s, %w", tag, resource, err)
+			}
+		}
+		if label.Type != "" && !slices.Contains(seedTypes, label.Type) {
+			return fmt.Errorf("invalid type %q of %s, expected one of %v", label.Type, resource, seedTypes)
+		}
+	}
+
+	c.labels = labels
+	return nil
+}
+
+// applyLabels adds the tags of labelled resources to the tags of the seeds they're normalised to,
+// and records the seed types they set
+func (c *Connector) applyLabels(ctx context.Context, tags map[string][]string) map[string][]string {
+	c.types = map[string]string{}
+	if len(c.labels) == 0 {
+		return tags
+	}
+	if tags == nil {
+		tags = map[string][]string{}
+	}
+
+	// The resources were normalised already, don't log about them again
+	quiet := logger.WithLogger(ctx, zerolog.Nop())
+	for _, resource := range slices.Sorted(maps.Keys(c.labels)) {
+		label := c.labels[resource]
+		names, _ := c.normaliser.normalise(quiet, []string{resource})
+		for _, name := range names {
+			for _, tag := range label.Tags {
+				if !slices.Contains(tags[name], tag) {
+					tags[name] = append(tags[name], tag)
+				}
+			}
+			if label.Type != "" {
+				c.types[name] = label.Type
+			}
+		}
+	}
+	return tags
+}
+
+// seedType returns the seed type of a resource, set by its label or detected from it
+func (c *Connector) seedType(resource string) string {
+	if seedType, ok := c.types[resource]; ok {
+		return seedType
+	}
+	return getResourceType(resource)
+}
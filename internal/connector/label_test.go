@@ -0,0 +1,87 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	asm "github.com/hexiosec/asm-sdk-go"
+)
+
+func TestSyncResources_Labels_TagsAndTypeAdded(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:  "scan-123",
+		SeedTag: "seed-tag",
+	}
+	conn, mockAPI := newTestConnector(t, cfg)
+	assert.NoError(t, conn.SetLabels(map[string]Label{
+		"https://Acquired.example.com/": {Tags: []string{"acquisition", "acme"}},
+		"192.0.2.0/24":                  {Type: resourceIPv4},
+	}))
+
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).
+		Return([]asm.SeedsResponseInner{}, nil, nil)
+
+	mockAPI.On("AddScanSeedById", cfg.ScanID, asm.CreateScanSeedRequest{
+		Name: "acquired.example.com",
+		Type: resourceDomain,
+		Tags: []string{"seed-tag", "acquisition", "acme"},
+	}).Return(&asm.NodeResponse{}, nil, nil).Once()
+
+	mockAPI.On("AddScanSeedById", cfg.ScanID, asm.CreateScanSeedRequest{
+		Name: "other.example.com",
+		Type: resourceDomain,
+		Tags: []string{"seed-tag"},
+	}).Return(&asm.NodeResponse{}, nil, nil).Once()
+
+	report, err := conn.SyncResources(context.Background(), []string{
+		"https://Acquired.example.com/",
+		"other.example.com",
+		"192.0.2.0/24",
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"acquired.example.com", "other.example.com"}, report.Added)
+	// The range isn't an IPv4 address, so is rejected as its type
+	assert.Len(t, report.Rejected, 1)
+}
+
+func TestSyncResources_Labels_TooManyTags_Dropped(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:  "scan-123",
+		SeedTag: "seed-tag",
+	}
+	conn, mockAPI := newTestConnector(t, cfg)
+	// Both resources are normalised to the same seed, which gets the tags of both labels
+	assert.NoError(t, conn.SetLabels(map[string]Label{
+		"https://example.com/": {Tags: []string{"one", "two", "three"}},
+		"example.com":          {Tags: []string{"four", "five"}},
+	}))
+
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).
+		Return([]asm.SeedsResponseInner{}, nil, nil)
+
+	mockAPI.On("AddScanSeedById", cfg.ScanID, asm.CreateScanSeedRequest{
+		Name: "example.com",
+		Type: resourceDomain,
+		Tags: []string{"seed-tag", "four", "five", "one", "two"},
+	}).Return(&asm.NodeResponse{}, nil, nil).Once()
+
+	report, err := conn.SyncResources(context.Background(), []string{"https://example.com/", "example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com"}, report.Added)
+	assert.Equal(t, []DroppedTags{{Name: "example.com", Tags: []string{"three"}}}, report.DroppedTags)
+}
+
+func TestConnector_SetLabels_Invalid_Err(t *testing.T) {
+	conn, _ := newTestConnector(t, &config.Config{ScanID: "scan-123", SeedTag: "seed-tag"})
+
+	assert.ErrorContains(t, conn.SetLabels(map[string]Label{"example.com": {Tags: []string{" "}}}), "invalid tag")
+	assert.ErrorContains(t, conn.SetLabels(map[string]Label{"example.com": {Type: "ASN"}}), "invalid type")
+	assert.ErrorContains(t, conn.SetLabels(map[string]Label{"example.com": {Tags: []string{"a", "b", "c", "d", "e"}}}), "only 4 fit")
+
+	// The scope tag takes one of the tags
+	assert.NoError(t, conn.SetScope("account"))
+	assert.ErrorContains(t, conn.SetLabels(map[string]Label{"example.com": {Tags: []string{"a", "b", "c", "d"}}}), "only 3 fit")
+}
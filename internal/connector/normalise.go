@@ -0,0 +1,289 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/idna"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+const (
+	portModeTag             = "tag"
+	portModeDropNonStandard = "drop_non_standard"
+	wildcardModeTag         = "tag"
+	idnFormatASCII          = "ascii"
+	idnFormatUnicode        = "unicode"
+)
+
+// normaliser turns discovered resources into seed names, as configured in the sync config
+type normaliser struct {
+	portMode     string
+	idnFormat    string
+	wildcardMode string
+	rules        []rule
+}
+
+// rule is a compiled config.NormalisationRule
+type rule struct {
+	match       *regexp.Regexp
+	replace     *string
+	stripPrefix string
+	keepCase    bool
+}
+
+func newNormaliser(cfg *config.Config) (normaliser, error) {
+	rules := make([]rule, 0, len(cfg.Sync.NormalisationRules))
+	for idx, r := range cfg.Sync.NormalisationRules {
+		var match *regexp.Regexp
+		if r.Match != "" {
+			var err error
+			if match, err = regexp.Compile(r.Match); err != nil {
+				return normaliser{}, fmt.Errorf("invalid match in normalisation rule %d, %w", idx, err)
+			}
+		}
+
+		rules = append(rules, rule{
+			match:       match,
+			replace:     r.Replace,
+			stripPrefix: r.StripPrefix,
+			keepCase:    r.KeepCase,
+		})
+	}
+
+	return normaliser{
+		portMode:     cfg.Sync.PortMode,
+		idnFormat:    cfg.Sync.IDNFormat,
+		wildcardMode: cfg.Sync.WildcardMode,
+		rules:        rules,
+	}, nil
+}
+
+// Normalise returns the seed names discovered resources are synced as, normalised, deduplicated
+// and with ranges expanded as configured in cfg, without calling the ASM API
+func Normalise(ctx context.Context, cfg *config.Config, resources []string) ([]string, error) {
+	c, err := NewConnector(cfg, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, _ = c.prepare(ctx, resources, &SyncReport{})
+	return resources, nil
+}
+
+// normalise returns the normalised resources, and any extra tags for them recording details lost
+// in normalisation, i.e. ports and wildcards, if the port or wildcard mode is "tag"
+func (n *normaliser) normalise(ctx context.Context, resources []string) ([]string, map[string][]string) {
+	if len(resources) == 0 {
+		return nil, nil
+	}
+
+	log := logger.GetLogger(ctx)
+	normalised := make([]string, 0, len(resources))
+	tags := map[string][]string{}
+	addTag := func(resource string, tag string) {
+		if !slices.Contains(tags[resource], tag) {
+			tags[resource] = append(tags[resource], tag)
+		}
+	}
+
+	for _, raw := range resources {
+		transformed, keepCase := n.applyRules(raw)
+		if strings.TrimSpace(transformed) == "" {
+			logger.PerResource(ctx, "Resource removed by normalisation rules").Debug().Str("resource", raw).Msg("Resource removed by normalisation rules")
+			continue
+		}
+
+		value, port, ok := normaliseResource(transformed, !keepCase)
+		if !ok {
+			log.Warn().Str("resource", raw).Msg("Unable to normalise resource")
+			continue
+		}
+		// Converted before tagging, so the tags are keyed by the seed name
+		value = n.formatIDN(value)
+
+		if port != "" {
+			switch n.portMode {
+			case portModeDropNonStandard:
+				if port != "80" && port != "443" {
+					log.Debug().Str("resource", raw).Msgf("Dropping resource on non-standard port %s", port)
+					continue
+				}
+			case portModeTag:
+				addTag(value, "port:"+port)
+			}
+		}
+
+		if n.wildcardMode == wildcardModeTag && strings.HasPrefix(strings.TrimSpace(transformed), "*.") {
+			addTag(value, "wildcard")
+		}
+
+		normalised = append(normalised, value)
+		if raw != value {
+			logger.PerResource(ctx, "Resource normalised").Debug().Str("resource", raw).Str("normalised", value).Msgf("'%s' was normalised to '%s'", raw, value)
+		}
+
+	}
+
+	return normalised, tags
+}
+
+// collapseSubdomains drops the domains that have a parent domain in resources, e.g. api.example.com
+// when example.com, perhaps from the wildcard *.example.com, is synced too. ASM discovers the
+// subdomains of a domain seed, so they don't need seeds of their own.
+func collapseSubdomains(ctx context.Context, resources []string) []string {
+	domains := map[string]struct{}{}
+	for _, resource := range resources {
+		if getResourceType(resource) == resourceDomain {
+			domains[resource] = struct{}{}
+		}
+	}
+
+	collapsed := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		if parent, ok := coveringDomain(resource, domains); ok {
+			logger.PerResource(ctx, "Resource covered by parent domain").Debug().Str("resource", resource).Str("parent", parent).Msgf("Dropping %s, covered by %s", resource, parent)
+			continue
+		}
+		collapsed = append(collapsed, resource)
+	}
+
+	logger.GetLogger(ctx).Trace().Msgf("Dropped %d subdomains covered by a parent domain", len(resources)-len(collapsed))
+	return collapsed
+}
+
+// coveringDomain returns the closest parent domain of resource in domains, if any
+func coveringDomain(resource string, domains map[string]struct{}) (string, bool) {
+	if _, ok := domains[resource]; !ok {
+		return "", false
+	}
+	for parent := resource; ; {
+		idx := strings.IndexByte(parent, '.')
+		if idx < 0 {
+			return "", false
+		}
+		parent = parent[idx+1:]
+		if _, ok := domains[parent]; ok {
+			return parent, true
+		}
+	}
+}
+
+// applyRules runs the user defined normalisation rules over a raw resource, returning the
+// transformed resource and whether its case should be kept
+func (n *normaliser) applyRules(raw string) (string, bool) {
+	keepCase := false
+	for _, r := range n.rules {
+		if r.match != nil && !r.match.MatchString(raw) {
+			continue
+		}
+
+		if r.stripPrefix != "" {
+			raw = strings.TrimPrefix(raw, r.stripPrefix)
+		}
+
+		// Replace requires a match, enforced by config validation
+		if r.replace != nil && r.match != nil {
+			raw = r.match.ReplaceAllString(raw, *r.replace)
+		}
+
+		keepCase = keepCase || r.keepCase
+	}
+
+	return raw, keepCase
+}
+
+// normaliseResource returns the host of a resource, and the port if it has one.
+// Domains are lowercased if lower is true.
+func normaliseResource(raw string, lower bool) (string, string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", "", false
+	}
+
+	// CIDR ranges, a single address range is reduced to the address itself
+	if ip, ipNet, err := net.ParseCIDR(raw); err == nil {
+		if ones, bits := ipNet.Mask.Size(); ones == bits {
+			return ip.String(), "", true
+		}
+		return ipNet.String(), "", true
+	}
+
+	// Strip leading wildcard prefix (e.g. *.example.com) before further parsing
+	raw = strings.TrimPrefix(raw, "*.")
+
+	// handle bare IPv6
+	if strings.Contains(raw, ":") && !strings.Contains(raw, "[") {
+		if ip := net.ParseIP(raw); ip != nil && ip.To4() == nil {
+			raw = "[" + raw + "]"
+		}
+	}
+
+	// Ensure it has a scheme so url.Parse behaves consistently
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+
+	host := u.Hostname()
+	port := u.Port()
+
+	// IPv6, IPv4, or domain
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), port, true
+	}
+
+	if lower {
+		host = strings.ToLower(host)
+	}
+	host = strings.TrimSuffix(host, ".")
+	if len(host) == 0 {
+		return "", "", false
+	}
+
+	// Validate as FQDN
+	if _, err := idna.Lookup.ToASCII(host); err != nil {
+		return "", "", false
+	}
+
+	return host, port, true
+}
+
+// formatIDN converts internationalised domain names to the configured form, so the same
+// domain is always submitted the same way regardless of how it was discovered
+func (n *normaliser) formatIDN(resource string) string {
+	if getResourceType(resource) != resourceDomain {
+		return resource
+	}
+
+	var (
+		formatted string
+		err       error
+	)
+	switch n.idnFormat {
+	case idnFormatASCII:
+		formatted, err = idna.Lookup.ToASCII(resource)
+	case idnFormatUnicode:
+		formatted, err = idna.Lookup.ToUnicode(resource)
+	default:
+		return resource
+	}
+
+	// Already validated by normaliseResource, but keep the original form rather than lose the resource
+	if err != nil {
+		return resource
+	}
+
+	return formatted
+}
@@ -0,0 +1,71 @@
+package connector
+
+import (
+	"context"
+	"slices"
+)
+
+// SyncPlan is the set of changes SyncResources would make
+type SyncPlan struct {
+	Add       []PlannedSeed  `json:"add"`
+	Remove    []string       `json:"remove"`
+	Unchanged []string       `json:"unchanged"`
+	Rejected  []RejectedSeed `json:"rejected"`
+}
+
+// PlannedSeed is a seed that would be added
+type PlannedSeed struct {
+	Name string   `json:"name"`
+	Type string   `json:"type"`
+	Tags []string `json:"tags"`
+	// DroppedTags are the extra tags that wouldn't fit in the tag limit of ASM
+	DroppedTags []string `json:"dropped_tags,omitempty"`
+}
+
+// Plan returns the changes SyncResources would make for the resources, without making them.
+// Seeds rejected by ASM itself (rather than by local validation) will be in Add, as that
+// can only be known by adding them.
+func (c *Connector) Plan(ctx context.Context, resources []string) (*SyncPlan, error) {
+	report := &SyncReport{}
+	resources, tags := c.prepare(ctx, resources, report)
+
+	existingSeeds, err := c.getSeeds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &SyncPlan{Rejected: report.Rejected}
+	for _, resource := range resources {
+		if _, ok := existingSeeds[resource]; ok {
+			delete(existingSeeds, resource)
+			plan.Unchanged = append(plan.Unchanged, resource)
+			continue
+		}
+
+		resourceType := c.seedType(resource)
+		if err := validateSeed(resource, resourceType); err != nil {
+			plan.Rejected = append(plan.Rejected, RejectedSeed{Name: resource, Reason: err.Error()})
+			continue
+		}
+
+		seedTags, dropped := c.seedTags(tags[resource])
+		plan.Add = append(plan.Add, PlannedSeed{
+			Name:        resource,
+			Type:        resourceType,
+			Tags:        seedTags,
+			DroppedTags: dropped,
+		})
+	}
+
+	if c.deleteStale {
+		for _, seed := range existingSeeds {
+			if c.isManaged(seed) {
+				plan.Remove = append(plan.Remove, seed.Name)
+			}
+		}
+		// Map iteration order is random, sort so plans can be compared
+		slices.Sort(plan.Remove)
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,79 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	asm "github.com/hexiosec/asm-sdk-go"
+)
+
+func TestPlan_AddRemoveUnchanged(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:           "scan-123",
+		SeedTag:          "seed-tag",
+		DeleteStaleSeeds: true,
+	}
+	conn, mockAPI := newTestConnector(t, cfg)
+
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).
+		Return([]asm.SeedsResponseInner{
+			{Id: "1", Name: "example.com", Tags: []string{"seed-tag"}},
+			{Id: "2", Name: "stale-b.com", Tags: []string{"seed-tag"}},
+			{Id: "3", Name: "stale-a.com", Tags: []string{"seed-tag"}},
+			{Id: "4", Name: "manual.com", Tags: []string{"other"}},
+		}, nil, nil)
+
+	plan, err := conn.Plan(context.Background(), []string{
+		"https://example.com/path",
+		"new.example.com",
+		"192.0.2.1",
+		"localhost",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []PlannedSeed{
+		{Name: "new.example.com", Type: resourceDomain, Tags: []string{"seed-tag"}},
+		{Name: "192.0.2.1", Type: resourceIPv4, Tags: []string{"seed-tag"}},
+	}, plan.Add)
+	assert.Equal(t, []string{"stale-a.com", "stale-b.com"}, plan.Remove)
+	assert.Equal(t, []string{"example.com"}, plan.Unchanged)
+	assert.Len(t, plan.Rejected, 1)
+	mockAPI.AssertNotCalled(t, "AddScanSeedById", mock.Anything, mock.Anything)
+	mockAPI.AssertNotCalled(t, "RemoveScanSeedById", mock.Anything, mock.Anything)
+}
+
+func TestPlan_NoDeleteStale_NothingRemoved(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:  "scan-123",
+		SeedTag: "seed-tag",
+	}
+	conn, mockAPI := newTestConnector(t, cfg)
+
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).
+		Return([]asm.SeedsResponseInner{{Id: "1", Name: "stale.com", Tags: []string{"seed-tag"}}}, nil, nil)
+
+	plan, err := conn.Plan(context.Background(), []string{"example.com"})
+
+	require.NoError(t, err)
+	assert.Empty(t, plan.Remove)
+	assert.Len(t, plan.Add, 1)
+}
+
+func TestPlan_GetSeedsErr_Err(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:  "scan-123",
+		SeedTag: "seed-tag",
+	}
+	conn, mockAPI := newTestConnector(t, cfg)
+
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).Return(nil, nil, assert.AnError)
+
+	_, err := conn.Plan(context.Background(), []string{"example.com"})
+
+	assert.ErrorIs(t, err, assert.AnError)
+}
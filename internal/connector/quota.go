@@ -0,0 +1,101 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	asm "github.com/hexiosec/asm-sdk-go"
+)
+
+const quotaModeTrim = "trim"
+
+// ErrQuotaExceeded is returned when the new seeds would exceed sync.seed_limit
+var ErrQuotaExceeded = errors.New("seed quota exceeded")
+
+// newQuotaPriority compiles the regexes of the seeds kept first when trimming to the seed limit
+func newQuotaPriority(patterns []string) ([]*regexp.Regexp, error) {
+	priority := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quota priority %s, %w", pattern, err)
+		}
+		priority = append(priority, re)
+	}
+	return priority, nil
+}
+
+// preflight checks the new seeds fit within the seed limit, sync.seed_limit, alongside the existing
+// seeds of the scan, before any are added. The seeds that don't fit are reported as overflow and
+// the sync aborted or, when trimming, removed from resources.
+func (c *Connector) preflight(ctx context.Context, resources []string, existingSeeds map[string]*asm.SeedsResponseInner, done map[string]struct{}, report *SyncReport) ([]string, error) {
+	report.Overflow = nil
+	if c.seedLimit == 0 {
+		logger.GetLogger(ctx).Trace().Msg("No seed limit")
+		return resources, nil
+	}
+
+	var pending []string
+	for _, resource := range resources {
+		if _, ok := done[resource]; ok {
+			continue
+		}
+		if _, ok := existingSeeds[resource]; ok {
+			continue
+		}
+		// Rejected without being added, so doesn't count towards the limit
+		if err := validateSeed(resource, c.seedType(resource)); err != nil {
+			continue
+		}
+		pending = append(pending, resource)
+	}
+
+	if len(pending) == 0 {
+		return resources, nil
+	}
+
+	limit := c.seedLimit
+	used := len(existingSeeds)
+	available := max(limit-used, 0)
+	logger.GetLogger(ctx).Debug().Int("limit", limit).Int("used", used).Int("new", len(pending)).Msg("Checked seed quota")
+	if len(pending) <= available {
+		return resources, nil
+	}
+
+	c.prioritise(pending)
+	report.Overflow = pending[available:]
+	if !c.trimToQuota {
+		return nil, fmt.Errorf("%w, %d new seeds but %d of the scan's %d seeds are available", ErrQuotaExceeded, len(pending), available, limit)
+	}
+
+	logger.GetLogger(ctx).Warn().Msgf("%d new seeds but %d of the scan's %d seeds are available, skipping %d seeds", len(pending), available, limit, len(report.Overflow))
+	overflow := make(map[string]struct{}, len(report.Overflow))
+	for _, resource := range report.Overflow {
+		overflow[resource] = struct{}{}
+	}
+	return slices.DeleteFunc(slices.Clone(resources), func(resource string) bool {
+		_, ok := overflow[resource]
+		return ok
+	}), nil
+}
+
+// prioritise sorts resources by the first quota priority regex they match, resources matching none
+// last. Resources of the same priority keep their order.
+func (c *Connector) prioritise(resources []string) {
+	rank := func(resource string) int {
+		for idx, re := range c.quotaPriority {
+			if re.MatchString(resource) {
+				return idx
+			}
+		}
+		return len(c.quotaPriority)
+	}
+
+	slices.SortStableFunc(resources, func(a, b string) int {
+		return rank(a) - rank(b)
+	})
+}
@@ -0,0 +1,100 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/api"
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/state"
+	asm "github.com/hexiosec/asm-sdk-go"
+)
+
+func newQuotaTestConnector(t *testing.T, cfg *config.Config) (*Connector, *api.MockAPI) {
+	t.Helper()
+	mockAPI := api.NewMockAPI(t).(*api.MockAPI)
+	store, err := state.NewStore(cfg)
+	require.NoError(t, err)
+	conn, err := NewConnector(cfg, mockAPI, store)
+	require.NoError(t, err)
+
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).
+		Return([]asm.SeedsResponseInner{{Id: "1", Name: "existing.com", Tags: []string{"tag"}}}, nil, nil)
+	return conn, mockAPI
+}
+
+func TestSyncResources_QuotaExceeded_Aborted(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:  "scan-123",
+		SeedTag: "tag",
+	}
+	cfg.Sync.SeedLimit = 3
+	conn, _ := newQuotaTestConnector(t, cfg)
+
+	report, err := conn.SyncResources(context.Background(), []string{"existing.com", "a.com", "b.com", "c.com"})
+
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.ErrorContains(t, err, "3 new seeds but 2 of the scan's 3 seeds are available")
+	assert.Empty(t, report.Added)
+	assert.Equal(t, []string{"c.com"}, report.Overflow)
+}
+
+func TestSyncResources_QuotaExceeded_TrimmedByPriority(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:  "scan-123",
+		SeedTag: "tag",
+	}
+	cfg.Sync.SeedLimit = 3
+	cfg.Sync.QuotaMode = "trim"
+	cfg.Sync.QuotaPriority = []string{`^prod\.`, `\.com$`}
+	conn, mockAPI := newQuotaTestConnector(t, cfg)
+
+	mockAPI.On("AddScanSeedById", cfg.ScanID, mock.MatchedBy(func(req asm.CreateScanSeedRequest) bool {
+		return req.Name == "prod.example.org" || req.Name == "a.com"
+	})).Return(&asm.NodeResponse{}, nil, nil).Twice()
+
+	report, err := conn.SyncResources(context.Background(), []string{"existing.com", "dev.example.org", "a.com", "prod.example.org"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.com", "prod.example.org"}, report.Added)
+	assert.Equal(t, []string{"existing.com"}, report.Existing)
+	assert.Equal(t, []string{"dev.example.org"}, report.Overflow)
+}
+
+func TestSyncResources_NoSeedLimit_AllAdded(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:  "scan-123",
+		SeedTag: "tag",
+	}
+	conn, mockAPI := newQuotaTestConnector(t, cfg)
+
+	mockAPI.On("AddScanSeedById", cfg.ScanID, mock.Anything).Return(&asm.NodeResponse{}, nil, nil).Times(4)
+
+	report, err := conn.SyncResources(context.Background(), []string{"existing.com", "a.com", "b.com", "c.com", "d.com"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.com", "b.com", "c.com", "d.com"}, report.Added)
+	assert.Empty(t, report.Overflow)
+}
+
+func TestSyncResources_WithinQuota_Added(t *testing.T) {
+	cfg := &config.Config{
+		ScanID:  "scan-123",
+		SeedTag: "tag",
+	}
+	cfg.Sync.SeedLimit = 3
+	conn, mockAPI := newQuotaTestConnector(t, cfg)
+
+	mockAPI.On("AddScanSeedById", cfg.ScanID, mock.Anything).Return(&asm.NodeResponse{}, nil, nil).Twice()
+
+	// Invalid seeds are rejected rather than added, so don't count towards the limit
+	report, err := conn.SyncResources(context.Background(), []string{"existing.com", "a.com", "b.com", "localhost"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.com", "b.com"}, report.Added)
+	assert.Empty(t, report.Overflow)
+}
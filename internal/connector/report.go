@@ -0,0 +1,60 @@
+package connector
+
+// SyncReport summarises the outcome of a SyncResources call
+type SyncReport struct {
+	Added    []string       `json:"added"`
+	Existing []string       `json:"existing"`
+	Removed  []string       `json:"removed"`
+	Rejected []RejectedSeed `json:"rejected"`
+	Failed   []FailedSeed   `json:"failed"`
+	// FailedRemovals are the stale seeds that could not be removed
+	FailedRemovals []FailedSeed `json:"failed_removals"`
+	// Overflow are the resources that would exceed the seed limit of the scan
+	Overflow []string `json:"overflow"`
+	// Dropped is the number of resources dropped by normalisation, e.g. removed by a rule or
+	// covered by a parent domain
+	Dropped int `json:"dropped"`
+	// DroppedTags are the extra tags of added seeds that didn't fit in the tag limit of ASM
+	DroppedTags []DroppedTags `json:"dropped_tags"`
+}
+
+// DroppedTags is a seed added without some of its extra tags, as it had more than ASM allows
+type DroppedTags struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// RejectedSeed is a resource that was not added as a seed, with the reason why
+type RejectedSeed struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	// Code is the error code ASM rejected the seed with, empty if it was rejected before being added
+	Code string `json:"code,omitempty"`
+}
+
+// FailedSeed is a resource that could not be added due to a transient error
+type FailedSeed struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+func (r *SyncReport) reject(name string, reason string) {
+	r.Rejected = append(r.Rejected, RejectedSeed{Name: name, Reason: reason})
+}
+
+// rejectCode records a seed ASM rejected with code
+func (r *SyncReport) rejectCode(name string, code string) {
+	r.Rejected = append(r.Rejected, RejectedSeed{Name: name, Reason: "rejected by Hexiosec ASM", Code: code})
+}
+
+func (r *SyncReport) fail(name string, err error) {
+	r.Failed = append(r.Failed, FailedSeed{Name: name, Error: err.Error()})
+}
+
+func (r *SyncReport) dropTags(name string, tags []string) {
+	r.DroppedTags = append(r.DroppedTags, DroppedTags{Name: name, Tags: tags})
+}
+
+func (r *SyncReport) failRemoval(name string, err error) {
+	r.FailedRemovals = append(r.FailedRemovals, FailedSeed{Name: name, Error: err.Error()})
+}
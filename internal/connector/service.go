@@ -3,45 +3,131 @@ package connector
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
-	"net/url"
+	"regexp"
 	"slices"
-	"strings"
-
-	"golang.org/x/net/idna"
+	"time"
 
 	"github.com/hexiosec/asm-cloud-connector/internal/api"
+	"github.com/hexiosec/asm-cloud-connector/internal/audit"
 	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	connector_http "github.com/hexiosec/asm-cloud-connector/internal/http"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/progress"
+	"github.com/hexiosec/asm-cloud-connector/internal/state"
 	asm "github.com/hexiosec/asm-sdk-go"
 )
 
 const (
-	resourceDomain string = "Domain"
-	resourceIPv4   string = "IPv4"
-	resourceIPv6   string = "IPv6"
+	resourceDomain  string = "Domain"
+	resourceIPv4    string = "IPv4"
+	resourceIPv6    string = "IPv6"
+	resourceIPRange string = "IPRange"
 )
 
+const ipRangeModeExpand = "expand"
+
+// inFlightGrace is how long a seed being added or removed has to finish once the sync is stopped
+const inFlightGrace = 30 * time.Second
+
 type Connector struct {
-	scanID      string
-	seedTag     string
-	deleteStale bool
-	sdk         api.API
+	scanID   string
+	scanName string
+	seedTag  string
+	// scopeTag narrows the seeds a partial sync manages to the seeds tagged with it, empty for all
+	// the seeds with the seed tag
+	scopeTag       string
+	deleteStale    bool
+	seedRetryCount int
+	seedRetryDelay time.Duration
+	failureBudget  int
+	expandRanges   bool
+	expandLimit    int
+	// collapse drops domains whose parent domain is also synced
+	collapse   bool
+	normaliser normaliser
+	// seedLimit caps the seeds of the scan, 0 for no cap
+	seedLimit     int
+	trimToQuota   bool
+	quotaPriority []*regexp.Regexp
+	// labels are the extra tags and seed types of resources, by resource, and types the seed types
+	// they set, by seed name
+	labels map[string]Label
+	types  map[string]string
+	// newScan is created when the scan doesn't exist, if set
+	newScan *config.NewScan
+	// auditor records the seeds added and removed, if set, and origins the resource each seed was
+	// normalised from
+	auditor audit.IAuditor
+	origins map[string]string
+	sdk     api.API
+	store   state.IStore
+}
+
+func NewConnector(cfg *config.Config, sdk api.API, store state.IStore) (*Connector, error) {
+	if err := validateTag(cfg.SeedTag); err != nil {
+		return nil, fmt.Errorf("invalid seed tag %s, %w", cfg.SeedTag, err)
+	}
+
+	n, err := newNormaliser(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	priority, err := newQuotaPriority(cfg.Sync.QuotaPriority)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &Connector{
+		scanID:         cfg.ScanID,
+		scanName:       cfg.ScanName,
+		seedTag:        cfg.SeedTag,
+		deleteStale:    cfg.DeleteStaleSeeds,
+		seedRetryCount: valueOrZero(cfg.Sync.SeedRetryCount),
+		seedRetryDelay: cfg.Sync.SeedRetryDelay,
+		failureBudget:  valueOrZero(cfg.Sync.FailureBudget),
+		expandRanges:   cfg.Sync.IPRangeMode == ipRangeModeExpand,
+		expandLimit:    cfg.Sync.IPRangeExpandLimit,
+		collapse:       cfg.Sync.CollapseSubdomains,
+		normaliser:     n,
+		seedLimit:      cfg.Sync.SeedLimit,
+		trimToQuota:    cfg.Sync.QuotaMode == quotaModeTrim,
+		quotaPriority:  priority,
+		sdk:            sdk,
+		store:          store,
+	}
+
+	if cfg.CreateScanIfMissing {
+		conn.newScan = cfg.NewScan
+	}
+
+	return conn, nil
+}
+
+// ScanID returns the ID of the scan synced to, which is only known after Authenticate if the
+// scan is created
+func (c *Connector) ScanID() string {
+	return c.scanID
 }
 
-func NewConnector(cfg *config.Config, sdk api.API) (*Connector, error) {
-	return &Connector{
-		scanID:      cfg.ScanID,
-		seedTag:     cfg.SeedTag,
-		deleteStale: cfg.DeleteStaleSeeds,
-		sdk:         sdk,
-	}, nil
+// SetScope tags the new seeds of a sync of part of the cloud, e.g. a single account, with tag, and
+// only deletes stale seeds with the tag, so seeds from the rest of the cloud are left alone
+func (c *Connector) SetScope(tag string) error {
+	if err := validateTag(tag); err != nil {
+		return fmt.Errorf("invalid scope tag %s, %w", tag, err)
+	}
+	c.scopeTag = tag
+	return nil
 }
 
-// Checks you can authenticate with the API key and the scan exists
+// Checks you can authenticate with the API key and the scan exists. Without a scan ID the scan
+// is found by scanName. If the scan doesn't exist and newScan is set, the scan with its name is
+// used, or created.
 func (c *Connector) Authenticate(ctx context.Context) error {
 	resp, _, err := c.sdk.GetState(ctx)
 	if err != nil {
@@ -52,63 +138,203 @@ func (c *Connector) Authenticate(ctx context.Context) error {
 		return fmt.Errorf("credentials not valid")
 	}
 
-	_, _, err = c.sdk.GetScanByID(ctx, c.scanID)
+	if c.scanID == "" && c.scanName != "" {
+		scan, err := c.findScan(ctx, c.scanName)
+		if err != nil {
+			return err
+		}
+		if scan != nil {
+			logger.GetLogger(ctx).Debug().Str("resolved_scan_id", scan.Id).Msgf("Found scan %s", scan.Name)
+			c.scanID = scan.Id
+			return nil
+		}
+		if c.newScan == nil {
+			return fmt.Errorf("no scan named %s", c.scanName)
+		}
+		logger.GetLogger(ctx).Info().Msgf("No scan named %s", c.scanName)
+	}
+
+	if c.scanID == "" && c.newScan != nil {
+		return c.createScan(ctx)
+	}
+
+	_, httpResp, err := c.sdk.GetScanByID(ctx, c.scanID)
 	if err != nil {
+		if c.newScan != nil && httpResp != nil && httpResp.StatusCode == http.StatusNotFound {
+			logger.GetLogger(ctx).Info().Msg("Scan not found")
+			return c.createScan(ctx)
+		}
 		return fmt.Errorf("failed to check %s scan exists, %w", c.scanID, err)
 	}
 
 	return nil
 }
 
+// createScan syncs to the scan named by newScan, creating it if there isn't one. Looking the scan
+// up by name first means later runs reuse the scan rather than creating another.
+func (c *Connector) createScan(ctx context.Context) error {
+	existing, err := c.findScan(ctx, c.newScan.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		logger.GetLogger(ctx).Info().Str("new_scan_id", existing.Id).Msgf("Using existing scan %s", existing.Name)
+		c.scanID = existing.Id
+		return nil
+	}
+
+	request := asm.NewCreateScanRequest(c.newScan.Name, c.newScan.ScanGroupID, asm.ScanType(c.newScan.Type))
+	scan, _, err := c.sdk.CreateScan(ctx, *request)
+	if err != nil {
+		return fmt.Errorf("failed to create %s scan, %w", c.newScan.Name, err)
+	}
+
+	logger.GetLogger(ctx).Warn().Str("new_scan_id", scan.Id).Msgf("Created scan %s, set scan_id to its ID to stop relying on its name", scan.Name)
+	c.scanID = scan.Id
+	return nil
+}
+
+// findScan returns the scan named name, or nil if there isn't one. It fails if several scans
+// have the name, as the scan to sync to would be ambiguous.
+func (c *Connector) findScan(ctx context.Context, name string) (*asm.ScanResponse, error) {
+	scans, _, err := c.sdk.GetScans(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for %s scan, %w", name, err)
+	}
+
+	// The search also matches partial names
+	var found *asm.ScanResponse
+	for _, scan := range scans {
+		if scan.Name != name {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("several scans are named %s, set the scan ID instead", name)
+		}
+		found = &scan
+	}
+
+	return found, nil
+}
+
 // SyncResources synchronises local resources with ASM seeds.
-// Returns an error only for fatal conditions (e.g. API unavailable).
-// Known validation failures or best-effort deletions are logged and skipped.
-func (c *Connector) SyncResources(ctx context.Context, resources []string) error {
+// Returns an error only for fatal conditions (e.g. API unavailable) or if the context is done,
+// in which case the report holds the changes made before the sync was interrupted.
+// Known validation failures or best-effort deletions are logged and skipped,
+// rejected seeds are collected in the returned report.
+// Progress is checkpointed to the state store, a failed sync of the same resources resumes from it.
+func (c *Connector) SyncResources(ctx context.Context, resources []string) (*SyncReport, error) {
+	report := &SyncReport{}
+	resources, tags := c.prepare(ctx, resources, report)
+
+	fp := fingerprint(c.scanID, resources)
+	report = c.resume(ctx, fp, report)
+
+	if err := c.sync(ctx, resources, tags, report, fp); err != nil {
+		c.saveCheckpoint(ctx, fp, report)
+		return report, err
+	}
+
+	c.clearCheckpoint(ctx)
+	return report, nil
+}
+
+// prepare turns discovered resources into the seed names to sync, and the extra tags for them.
+// Resources that can't be seeded at all are rejected in report.
+func (c *Connector) prepare(ctx context.Context, resources []string, report *SyncReport) ([]string, map[string][]string) {
 	// Remove duplicates
 	resources = dedup(ctx, resources)
+	c.traceOrigins(ctx, resources)
 
 	// Normalise i.e. extract domains from websites
-	resources = normalise(ctx, resources)
+	before := len(resources)
+	resources, tags := c.normaliser.normalise(ctx, resources)
+	report.Dropped = before - len(resources)
+	tags = c.applyLabels(ctx, tags)
+
+	// Replace small CIDR ranges with their individual addresses, if configured
+	if c.expandRanges {
+		resources = c.expand(ctx, resources, report)
+	}
 
 	// Remove duplicates again - just in case
 	resources = dedup(ctx, resources)
 
+	// Drop subdomains of domains synced too, if configured
+	if c.collapse {
+		before := len(resources)
+		resources = collapseSubdomains(ctx, resources)
+		report.Dropped += before - len(resources)
+	}
+
+	return resources, tags
+}
+
+// sync adds missing seeds and removes stale ones, recording the outcome in report
+func (c *Connector) sync(ctx context.Context, resources []string, tags map[string][]string, report *SyncReport, fp string) error {
 	// Get existing seeds
 	existingSeeds, err := c.getSeeds(ctx)
 	if err != nil {
 		return err
 	}
 
+	// Resources handled by an earlier, interrupted, sync
+	done := report.processed()
+
+	// Check the new seeds fit in the scan before adding any
+	resources, err = c.preflight(ctx, resources, existingSeeds, done, report)
+	if err != nil {
+		return err
+	}
+
 	// Add seeds to scan, if they don't exist
-	for _, resource := range resources {
+	for idx, resource := range resources {
+		progress.Seeds(ctx, c.scanID, idx, len(resources))
+
+		// Stop between seeds, before deleting anything, if the sync has run out of time
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("sync interrupted with %d resources remaining, %w", len(resources)-idx, err)
+		}
+
+		if idx > 0 && idx%checkpointInterval == 0 {
+			c.saveCheckpoint(ctx, fp, report)
+		}
+
+		if _, ok := done[resource]; ok {
+			// Added or kept by the earlier sync, so it's not stale
+			delete(existingSeeds, resource)
+			continue
+		}
+
 		iCtx := logger.WithLogger(ctx, logger.GetLogger(ctx).With().Str("resource", resource).Logger())
-		logger.GetLogger(iCtx).Trace().Msg("Processing resource")
+		logger.PerResource(iCtx, "Processing resource").Trace().Msg("Processing resource")
 
 		if _, ok := existingSeeds[resource]; ok {
 			delete(existingSeeds, resource)
-			logger.GetLogger(iCtx).Debug().Msgf("Seed %s already exists", resource)
+			report.Existing = append(report.Existing, resource)
+			logger.PerResource(iCtx, "Seed already exists").Debug().Msgf("Seed %s already exists", resource)
 			continue
 		}
 
-		resourceType := getResourceType(resource)
-		if resourceType == resourceIPv6 {
-			logger.GetLogger(iCtx).Warn().Msg("Cannot add IPv6 as seed, skipping")
+		resourceType := c.seedType(resource)
+		if err := validateSeed(resource, resourceType); err != nil {
+			logger.GetLogger(iCtx).Warn().Err(err).Msg("Seed failed validation, skipping")
+			report.reject(resource, err.Error())
 			continue
 		}
 
+		seedTags, dropped := c.seedTags(tags[resource])
+		for _, tag := range dropped {
+			logger.GetLogger(iCtx).Warn().Str("tag", tag).Msgf("Seed already has the maximum of %d tags, dropping tag", maxTags)
+		}
+
 		logger.GetLogger(iCtx).Debug().Msgf("Adding seed %s", resource)
-		// Semgrep false positive: resp is nil-checked before use
-		// nosemgrep: trailofbits.go.invalid-usage-of-modified-variable.invalid-usage-of-modified-variable
-		_, resp, err := c.sdk.AddScanSeedById(
-			ctx,
-			c.scanID,
-			asm.CreateScanSeedRequest{
-				Name: resource,
-				Type: resourceType,
-				Tags: []string{c.seedTag},
-			},
-		)
+		resp, err := c.addSeed(iCtx, resource, resourceType, seedTags)
 		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("sync interrupted while adding seed %s, %w", resource, err)
+			}
+
 			// Attempt to classify known recoverable errors (e.g. invalid seed, already exists)
 			if resp != nil && resp.StatusCode == http.StatusBadRequest && resp.Body != nil {
 				code, rErr := getErrorCode(resp.Body)
@@ -120,13 +346,36 @@ func (c *Connector) SyncResources(ctx context.Context, resources []string) error
 
 				// Known non-fatal case: seed invalid skip and continue.
 				logger.GetLogger(iCtx).Warn().Err(err).Str("code", code).Msgf("failed to add seed %s because %s", resource, code)
+				report.rejectCode(resource, code)
+				continue
+			}
+
+			// ASM is down, the remaining seeds would fail too
+			if errors.Is(err, api.ErrUnavailable) {
+				return fmt.Errorf("stopped with %d resources remaining, %w", len(resources)-idx, err)
+			}
+
+			// Transient failure that outlived the retries, tolerate it up to the failure budget
+			if isTransient(resp) {
+				logger.GetLogger(iCtx).Error().Err(err).Msgf("failed to add seed %s after %d retries", resource, c.seedRetryCount)
+				report.fail(resource, err)
+				if len(report.Failed) > c.failureBudget {
+					return fmt.Errorf("failure budget of %d exceeded, failed to add seed %s %w", c.failureBudget, resource, err)
+				}
 				continue
 			}
 
 			// Unexpected failure -> abort
 			return fmt.Errorf("failed to add seed %s %w", resource, err)
 		}
+
+		report.Added = append(report.Added, resource)
+		if len(dropped) > 0 {
+			report.dropTags(resource, dropped)
+		}
+		c.audit(iCtx, resource, audit.ActionAdd)
 	}
+	progress.Seeds(ctx, c.scanID, len(resources), len(resources))
 
 	if !c.deleteStale {
 		// Nothing more to do
@@ -134,25 +383,168 @@ func (c *Connector) SyncResources(ctx context.Context, resources []string) error
 		return nil
 	}
 
+	c.saveCheckpoint(ctx, fp, report)
+
 	logger.GetLogger(ctx).Trace().Msg("Deleting stale seeds")
 	// Deletion is best-effort: log but don't abort
 	// Stale seeds are existingSeeds that aren't in the resource list and have a matching seed tag, implying it was previously added by the Cloud Connector
 	for _, seed := range existingSeeds {
-		if !slices.Contains(seed.Tags, c.seedTag) {
-			logger.GetLogger(ctx).Debug().Msgf("skipping existing seed %s as it doesn't have tag %s, so was probably added manually", seed.Name, c.seedTag)
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("sync interrupted while removing stale seeds, %w", err)
+		}
+
+		if !c.isManaged(seed) {
+			logger.PerResource(ctx, "Skipping unmanaged seed").Debug().Msgf("skipping existing seed %s as it doesn't have tag %s, so was probably added manually", seed.Name, c.seedTag)
 			continue
 		}
 
 		logger.GetLogger(ctx).Debug().Str("seed", seed.Name).Msgf("Removing seed %s", seed.Name)
-		_, err := c.sdk.RemoveScanSeedById(ctx, c.scanID, seed.Id)
+		reqCtx, cancel := withGrace(ctx)
+		_, err := c.sdk.RemoveScanSeedById(reqCtx, c.scanID, seed.Id)
+		cancel()
 		if err != nil {
 			logger.GetLogger(ctx).Error().Err(err).Msgf("failed to remove stale seed %s", seed.Name)
+			report.failRemoval(seed.Name, err)
+			continue
 		}
+
+		report.Removed = append(report.Removed, seed.Name)
+		c.audit(ctx, seed.Name, audit.ActionRemove)
 	}
 
 	return nil
 }
 
+// addSeed adds a single seed to the scan, retrying transient failures with an exponential backoff.
+// The retries of the ASM API client are disabled for the request, so sync.seed_retry_count is the
+// only retry of a seed.
+func (c *Connector) addSeed(ctx context.Context, resource string, resourceType string, tags []string) (*http.Response, error) {
+	delay := c.seedRetryDelay
+	for attempt := 0; ; attempt++ {
+		// Semgrep false positive: resp is nil-checked before use
+		// nosemgrep: trailofbits.go.invalid-usage-of-modified-variable.invalid-usage-of-modified-variable
+		reqCtx, cancel := withGrace(ctx)
+		_, resp, err := c.sdk.AddScanSeedById(
+			connector_http.WithoutRetry(reqCtx),
+			c.scanID,
+			asm.CreateScanSeedRequest{
+				Name: resource,
+				Type: resourceType,
+				Tags: tags,
+			},
+		)
+		cancel()
+		if err == nil || !isTransient(resp) || errors.Is(err, api.ErrUnavailable) || attempt >= c.seedRetryCount {
+			return resp, err
+		}
+
+		logger.GetLogger(ctx).Debug().Err(err).Int("attempt", attempt+1).Msgf("Transient failure adding seed, retrying in %s", delay)
+		if resp != nil && resp.Body != nil {
+			// Drained so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return resp, errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// valueOrZero returns the value of p, or 0 if it's not set
+func valueOrZero(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+// graceKey is the context key of the in-flight grace of a sync
+type graceKey struct{}
+
+// WithInFlightGrace returns a context whose syncs give a seed being added or removed grace to finish
+// once stopped, rather than inFlightGrace, e.g. to finish before a container is killed
+func WithInFlightGrace(ctx context.Context, grace time.Duration) context.Context {
+	return context.WithValue(ctx, graceKey{}, grace)
+}
+
+// withGrace returns a context for a single request that is only cancelled inFlightGrace, or the
+// grace of WithInFlightGrace, after ctx is done, so a seed being added or removed when the sync is
+// stopped, e.g. by SIGTERM, finishes and its outcome is in the report
+func withGrace(ctx context.Context) (context.Context, context.CancelFunc) {
+	grace, ok := ctx.Value(graceKey{}).(time.Duration)
+	if !ok {
+		grace = inFlightGrace
+	}
+
+	graceCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(grace, cancel)
+	})
+	return graceCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// seedTags returns the tags for a new seed, the seed tag and scope tag plus any extra tags from
+// normalisation and labels, and the extra tags dropped as ASM allows at most maxTags tags
+func (c *Connector) seedTags(extra []string) (tags []string, dropped []string) {
+	tags = []string{c.seedTag}
+	if c.scopeTag != "" {
+		tags = append(tags, c.scopeTag)
+	}
+	for idx, tag := range extra {
+		if len(tags) == maxTags {
+			return tags, extra[idx:]
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// expand replaces IPv4 CIDR ranges with the addresses they contain. Ranges larger
+// than the expand limit are rejected rather than flooding the scan with seeds.
+func (c *Connector) expand(ctx context.Context, resources []string, report *SyncReport) []string {
+	expanded := make([]string, 0, len(resources))
+
+	for _, resource := range resources {
+		if getResourceType(resource) != resourceIPRange {
+			expanded = append(expanded, resource)
+			continue
+		}
+
+		_, ipNet, _ := net.ParseCIDR(resource)
+		ones, bits := ipNet.Mask.Size()
+		size := 1 << (bits - ones)
+		if size > c.expandLimit {
+			logger.GetLogger(ctx).Warn().Str("resource", resource).Msgf("Range contains %d addresses, more than the expand limit of %d, skipping", size, c.expandLimit)
+			report.reject(resource, fmt.Sprintf("range is larger than the expand limit of %d addresses", c.expandLimit))
+			continue
+		}
+
+		ip := ipNet.IP.To4()
+		for i := 0; i < size; i++ {
+			expanded = append(expanded, ip.String())
+			ip = nextIP(ip)
+		}
+		logger.GetLogger(ctx).Debug().Str("resource", resource).Msgf("Expanded range to %d addresses", size)
+	}
+
+	return expanded
+}
+
+// isManaged returns true if the seed has the seed tag, implying it was added by the Cloud Connector,
+// and the scope tag if set
+func (c *Connector) isManaged(seed *asm.SeedsResponseInner) bool {
+	if c.scopeTag != "" && !slices.Contains(seed.Tags, c.scopeTag) {
+		return false
+	}
+	return slices.Contains(seed.Tags, c.seedTag)
+}
+
 func (c *Connector) getSeeds(ctx context.Context) (map[string]*asm.SeedsResponseInner, error) {
 	seeds, _, err := c.sdk.GetScanSeedsById(ctx, c.scanID)
 	if err != nil {
@@ -189,78 +581,15 @@ func dedup(ctx context.Context, resources []string) []string {
 	return resources[:writeIdx]
 }
 
-func normalise(ctx context.Context, resources []string) []string {
-	if len(resources) == 0 {
-		return nil
-	}
-
-	log := logger.GetLogger(ctx)
-	normalised := make([]string, 0, len(resources))
-
-	for _, raw := range resources {
-		value, ok := normaliseResource(raw)
-		if !ok {
-			log.Warn().Str("resource", raw).Msg("Unable to normalise resource")
-			continue
-		}
-
-		normalised = append(normalised, value)
-		if raw != value {
-			log.Debug().Str("resource", raw).Str("normalised", value).Msgf("'%s' was normalised to '%s'", raw, value)
-		}
-
-	}
-
-	return normalised
-}
-
-func normaliseResource(raw string) (string, bool) {
-	raw = strings.TrimSpace(raw)
-	if raw == "" {
-		return "", false
-	}
-
-	// Strip leading wildcard prefix (e.g. *.example.com) before further parsing
-	raw = strings.TrimPrefix(raw, "*.")
-
-	// handle bare IPv6
-	if strings.Contains(raw, ":") && !strings.Contains(raw, "[") {
-		if ip := net.ParseIP(raw); ip != nil && ip.To4() == nil {
-			raw = "[" + raw + "]"
+func getResourceType(resource string) string {
+	if ip, _, err := net.ParseCIDR(resource); err == nil {
+		if ip.To4() != nil {
+			return resourceIPRange
 		}
+		// ASM has no IPv6 range seeds, report as IPv6 so it is rejected
+		return resourceIPv6
 	}
 
-	// Ensure it has a scheme so url.Parse behaves consistently
-	if !strings.Contains(raw, "://") {
-		raw = "http://" + raw
-	}
-
-	u, err := url.Parse(raw)
-	if err != nil || u.Host == "" {
-		return "", false
-	}
-
-	host := u.Hostname()
-
-	// IPv6, IPv4, or domain
-	if ip := net.ParseIP(host); ip != nil {
-		return ip.String(), true
-	}
-
-	host = strings.TrimSuffix(strings.ToLower(host), ".")
-	if len(host) == 0 {
-		return "", false
-	}
-
-	// Validate as FQDN
-	if _, err := idna.Lookup.ToASCII(host); err != nil {
-		return "", false
-	}
-
-	return host, true
-}
-
-func getResourceType(resource string) string {
 	ip := net.ParseIP(resource)
 	if ip == nil {
 		// Not an IP, assume domain
@@ -272,6 +601,29 @@ func getResourceType(resource string) string {
 	return resourceIPv6
 }
 
+// nextIP returns the address following ip
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// isTransient returns true if the request failed in a way that may succeed if retried
+func isTransient(resp *http.Response) bool {
+	if resp == nil {
+		// No response, likely a network error
+		return true
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
 func getErrorCode(body io.ReadCloser) (string, error) {
 	defer body.Close()
 	errBody := struct {
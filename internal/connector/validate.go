@@ -0,0 +1,109 @@
+package connector
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ASM naming rules, mirrored locally so that seeds which would be rejected
+// with a 400 are reported without spending an API call on them
+const (
+	maxDomainLength = 253
+	maxLabelLength  = 63
+	maxTagLength    = 255
+	maxTags         = 5
+)
+
+// validateSeed returns an error describing why ASM would reject the seed, or nil if it looks valid
+func validateSeed(name string, seedType string) error {
+	switch seedType {
+	case resourceDomain:
+		return validateDomain(name)
+	case resourceIPv4:
+		if ip := net.ParseIP(name); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("not a valid IPv4 address")
+		}
+		return nil
+	case resourceIPRange:
+		if ip, _, err := net.ParseCIDR(name); err != nil || ip.To4() == nil {
+			return fmt.Errorf("not a valid IPv4 CIDR range")
+		}
+		return nil
+	case resourceIPv6:
+		return fmt.Errorf("IPv6 seeds are not supported")
+	default:
+		return fmt.Errorf("unsupported seed type %s", seedType)
+	}
+}
+
+func validateDomain(name string) error {
+	// Length and character rules apply to the ASCII (punycode) form
+	ascii, err := idna.Lookup.ToASCII(name)
+	if err != nil {
+		return fmt.Errorf("not a valid domain name, %w", err)
+	}
+
+	if len(ascii) > maxDomainLength {
+		return fmt.Errorf("domain is longer than %d characters", maxDomainLength)
+	}
+
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("domain must have at least two labels")
+	}
+
+	for _, label := range labels {
+		if len(label) == 0 {
+			return fmt.Errorf("domain contains an empty label")
+		}
+
+		if len(label) > maxLabelLength {
+			return fmt.Errorf("label %s is longer than %d characters", label, maxLabelLength)
+		}
+
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("label %s starts or ends with a hyphen", label)
+		}
+
+		for _, r := range label {
+			if !isLabelChar(r) {
+				return fmt.Errorf("label %s contains invalid character %q", label, r)
+			}
+		}
+	}
+
+	// A numeric TLD means this is an IP-like value, not a domain
+	if isNumeric(labels[len(labels)-1]) {
+		return fmt.Errorf("top level domain %s is numeric", labels[len(labels)-1])
+	}
+
+	return nil
+}
+
+func validateTag(tag string) error {
+	if strings.TrimSpace(tag) == "" {
+		return fmt.Errorf("tag is empty")
+	}
+
+	if len(tag) > maxTagLength {
+		return fmt.Errorf("tag is longer than %d characters", maxTagLength)
+	}
+
+	return nil
+}
+
+func isLabelChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-'
+}
+
+func isNumeric(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,93 @@
+// Package ecs reads the metadata and settings of the ECS task the connector runs in
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// metadataTimeout bounds the request to the task metadata endpoint, which is local to the task
+	metadataTimeout = 2 * time.Second
+	// defaultStopTimeout is how long ECS waits after SIGTERM before SIGKILL, unless configured
+	defaultStopTimeout = 30 * time.Second
+)
+
+// TaskMetadata is the task the connector runs in, from the task metadata endpoint v4
+type TaskMetadata struct {
+	Cluster          string `json:"Cluster"`
+	TaskARN          string `json:"TaskARN"`
+	Family           string `json:"Family"`
+	Revision         string `json:"Revision"`
+	AvailabilityZone string `json:"AvailabilityZone"`
+	LaunchType       string `json:"LaunchType"`
+}
+
+// ReadTaskMetadata returns the metadata of the task. ok is false if the connector isn't running in
+// an ECS task, ECS_CONTAINER_METADATA_URI_V4 isn't set.
+func ReadTaskMetadata(ctx context.Context) (metadata *TaskMetadata, ok bool, err error) {
+	uri := os.Getenv("ECS_CONTAINER_METADATA_URI_V4")
+	if uri == "" {
+		return nil, false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(uri, "/")+"/task", nil)
+	if err != nil {
+		return nil, true, fmt.Errorf("ecs: could not create task metadata request, %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("ecs: could not get task metadata, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, true, fmt.Errorf("ecs: received non-200 code %d getting task metadata", resp.StatusCode)
+	}
+	metadata = &TaskMetadata{}
+	if err := json.NewDecoder(resp.Body).Decode(metadata); err != nil {
+		return nil, true, fmt.Errorf("ecs: could not decode task metadata, %w", err)
+	}
+	return metadata, true, nil
+}
+
+// LogFields returns the fields that correlate logs with the task, e.g. its ARN
+func (m *TaskMetadata) LogFields() map[string]string {
+	fields := map[string]string{
+		"ecs_cluster":           m.Cluster,
+		"ecs_task_arn":          m.TaskARN,
+		"ecs_task_definition":   m.Family + ":" + m.Revision,
+		"ecs_availability_zone": m.AvailabilityZone,
+		"ecs_launch_type":       m.LaunchType,
+	}
+	for key, value := range fields {
+		if value == "" || value == ":" {
+			delete(fields, key)
+		}
+	}
+	return fields
+}
+
+// StopTimeout returns how long ECS waits after SIGTERM before killing the task, from
+// ECS_CONTAINER_STOP_TIMEOUT as a duration, e.g. "2m", or seconds. It's 30s if not set or invalid.
+func StopTimeout() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("ECS_CONTAINER_STOP_TIMEOUT"))
+	if raw == "" {
+		return defaultStopTimeout
+	}
+	if timeout, err := time.ParseDuration(raw); err == nil && timeout > 0 {
+		return timeout
+	}
+	if seconds, err := strconv.Atoi(raw); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultStopTimeout
+}
@@ -0,0 +1,68 @@
+package ecs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTaskMetadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v4/abc/task", r.URL.Path)
+		_, _ = w.Write([]byte(`{"Cluster":"arn:aws:ecs:eu-west-2:111111111111:cluster/default","TaskARN":"arn:aws:ecs:eu-west-2:111111111111:task/default/123","Family":"asm-cloud-connector","Revision":"3","LaunchType":"FARGATE"}`))
+	}))
+	t.Cleanup(ts.Close)
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", ts.URL+"/v4/abc")
+
+	metadata, ok, err := ReadTaskMetadata(context.Background())
+
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"ecs_cluster":         "arn:aws:ecs:eu-west-2:111111111111:cluster/default",
+		"ecs_task_arn":        "arn:aws:ecs:eu-west-2:111111111111:task/default/123",
+		"ecs_task_definition": "asm-cloud-connector:3",
+		"ecs_launch_type":     "FARGATE",
+	}, metadata.LogFields())
+}
+
+func TestReadTaskMetadata_NotECS(t *testing.T) {
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "")
+
+	metadata, ok, err := ReadTaskMetadata(context.Background())
+
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, metadata)
+}
+
+func TestReadTaskMetadata_Non200_Err(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(ts.Close)
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", ts.URL)
+
+	_, ok, err := ReadTaskMetadata(context.Background())
+
+	assert.Error(t, err)
+	assert.True(t, ok)
+}
+
+func TestStopTimeout(t *testing.T) {
+	for raw, expected := range map[string]time.Duration{
+		"":        30 * time.Second,
+		"2m":      2 * time.Minute,
+		"90":      90 * time.Second,
+		"invalid": 30 * time.Second,
+		"-5s":     30 * time.Second,
+	} {
+		t.Setenv("ECS_CONTAINER_STOP_TIMEOUT", raw)
+		assert.Equal(t, expected, StopTimeout(), raw)
+	}
+}
@@ -0,0 +1,73 @@
+package fanout
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
+)
+
+// maxBatch is the most messages SQS accepts in one request
+const maxBatch = 10
+
+// IQueue sends the events of the worker invocations of a fanned out sync
+type IQueue interface {
+	Send(ctx context.Context, bodies []string) error
+}
+
+// sqsAPI is the part of the SQS client the queue uses
+type sqsAPI interface {
+	SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+}
+
+type sqsQueue struct {
+	url    string
+	client sqsAPI
+}
+
+// NewQueue returns the SQS queue of fan_out.queue_url, with the credentials of the Lambda function
+func NewQueue(ctx context.Context, cfg *config.Config) (IQueue, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, tlsmode.AWS)
+	if err != nil {
+		return nil, fmt.Errorf("fanout: could not load AWS config, %w", err)
+	}
+
+	return &sqsQueue{url: cfg.FanOut.QueueURL, client: sqs.NewFromConfig(awsCfg)}, nil
+}
+
+// Send sends a message with each body, in batches. It fails on the first batch with a message
+// SQS didn't accept, the messages of earlier batches are already sent.
+func (q *sqsQueue) Send(ctx context.Context, bodies []string) error {
+	for start := 0; start < len(bodies); start += maxBatch {
+		batch := bodies[start:min(start+maxBatch, len(bodies))]
+		entries := make([]types.SendMessageBatchRequestEntry, len(batch))
+		for idx, body := range batch {
+			entries[idx] = types.SendMessageBatchRequestEntry{
+				Id:          aws.String(strconv.Itoa(start + idx)),
+				MessageBody: aws.String(body),
+			}
+		}
+
+		out, err := q.client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(q.url),
+			Entries:  entries,
+		})
+		if err != nil {
+			return fmt.Errorf("fanout: could not send messages to %s, %w", q.url, err)
+		}
+		if len(out.Failed) > 0 {
+			failed := out.Failed[0]
+			return fmt.Errorf("fanout: %d messages were not accepted by %s, %s: %s",
+				len(out.Failed), q.url, aws.ToString(failed.Code), aws.ToString(failed.Message))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package fanout
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSQS struct {
+	batches [][]string
+	failed  []types.BatchResultErrorEntry
+	err     error
+}
+
+func (f *fakeSQS) SendMessageBatch(_ context.Context, params *sqs.SendMessageBatchInput, _ ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	var bodies []string
+	for _, entry := range params.Entries {
+		bodies = append(bodies, aws.ToString(entry.MessageBody))
+	}
+	f.batches = append(f.batches, bodies)
+	return &sqs.SendMessageBatchOutput{Failed: f.failed}, f.err
+}
+
+func TestSQSQueue_Send_Batches(t *testing.T) {
+	client := &fakeSQS{}
+	queue := &sqsQueue{url: "https://sqs.eu-west-2.amazonaws.com/123456789012/connector", client: client}
+
+	var bodies []string
+	for idx := 0; idx < 23; idx++ {
+		bodies = append(bodies, strconv.Itoa(idx))
+	}
+
+	assert.NoError(t, queue.Send(context.Background(), bodies))
+	assert.Len(t, client.batches, 3)
+	assert.Len(t, client.batches[0], 10)
+	assert.Len(t, client.batches[1], 10)
+	assert.Equal(t, []string{"20", "21", "22"}, client.batches[2])
+}
+
+func TestSQSQueue_Send_Error(t *testing.T) {
+	queue := &sqsQueue{url: "queue", client: &fakeSQS{err: assert.AnError}}
+	assert.ErrorIs(t, queue.Send(context.Background(), []string{"a"}), assert.AnError)
+}
+
+func TestSQSQueue_Send_Failed(t *testing.T) {
+	client := &fakeSQS{failed: []types.BatchResultErrorEntry{{Id: aws.String("0"), Code: aws.String("InvalidMessageContents")}}}
+	queue := &sqsQueue{url: "queue", client: client}
+
+	err := queue.Send(context.Background(), []string{"a", "b"})
+	assert.ErrorContains(t, err, "1 messages were not accepted")
+	assert.ErrorContains(t, err, "InvalidMessageContents")
+}
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	assetpb "cloud.google.com/go/asset/apiv1/assetpb"
 	certificatemanagerpb "cloud.google.com/go/certificatemanager/apiv1/certificatemanagerpb"
@@ -13,23 +16,37 @@ import (
 	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
 	"github.com/hexiosec/asm-cloud-connector/internal/config"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/progress"
+	"github.com/hexiosec/asm-cloud-connector/internal/reporting"
 	"github.com/hexiosec/asm-cloud-connector/internal/util"
+	"github.com/hexiosec/asm-cloud-connector/internal/warnings"
 )
 
+// projectNumber matches a project given by number rather than project ID
+var projectNumber = regexp.MustCompile(`^projects/[0-9]+$`)
+
 type GCPProvider struct {
 	cfg     *config.GCPCloudProvider
 	wrapper IGCPWrapper
+	// projects are the projects as configured, before Authenticate resolves them to their number
+	projects []string
 }
 
-func NewGCPProvider(cfg *config.Config) (cloud_provider_t.CloudProvider, error) {
-	wrapper, err := NewWrapper()
+func NewGCPProvider(cfg *config.GCPCloudProvider) (cloud_provider_t.CloudProvider, error) {
+	opts, err := profileOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapper, err := NewWrapper(opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &GCPProvider{
-		cfg:     cfg.GCP,
-		wrapper: wrapper,
+		cfg:      cfg,
+		wrapper:  wrapper,
+		projects: slices.Clone(cfg.Projects),
 	}, nil
 }
 
@@ -37,86 +54,157 @@ func (c *GCPProvider) GetName() string {
 	return "GCP"
 }
 
+func (c *GCPProvider) GetProfile() *config.CloudProvider {
+	return &c.cfg.CloudProvider
+}
+
 func (c *GCPProvider) Authenticate(ctx context.Context) error {
 	if err := c.wrapper.CheckConnection(ctx); err != nil {
-		return err
+		return c.diagnose(err, apiAssetInventory, "")
 	}
 
 	logger.GetLogger(ctx).Debug().Msg("authentication successful")
+
+	return c.resolveProjects(ctx)
+}
+
+// resolveProjects replaces projects given by project ID with their project number, which the
+// Asset and Certificate Manager APIs expect
+func (c *GCPProvider) resolveProjects(ctx context.Context) error {
+	projects := make([]string, 0, len(c.cfg.Projects))
+	for _, project := range c.cfg.Projects {
+		if projectNumber.MatchString(project) {
+			projects = append(projects, project)
+			continue
+		}
+
+		number, err := c.wrapper.GetProjectNumber(ctx, project)
+		if err != nil {
+			return c.diagnose(err, apiResourceManager, project)
+		}
+		logger.GetLogger(ctx).Debug().Str("project", project).Msgf("resolved project to %s", number)
+		projects = append(projects, number)
+	}
+
+	c.cfg.Projects = projects
 	return nil
 }
 
+// ListAccounts returns the projects as configured, without the "projects/" prefix, so they match
+// the projects of the config of another invocation
+func (c *GCPProvider) ListAccounts(_ context.Context) ([]string, error) {
+	projects := make([]string, 0, len(c.projects))
+	for _, project := range c.projects {
+		projects = append(projects, strings.TrimPrefix(project, "projects/"))
+	}
+	return projects, nil
+}
+
 // API Key will always be provided via the env variable
 func (c *GCPProvider) GetAPIKey(ctx context.Context) (string, error) {
 	return "", cloud_provider_t.ErrNoAPIKey
 }
 
-func (c *GCPProvider) GetResources(ctx context.Context) ([]string, error) {
-	defs := map[string]struct {
-		enabled bool
-		getter  func(ctx context.Context, asset *assetpb.Asset, data map[string]any) ([]string, error)
-	}{
+// Preflight checks Cloud Asset Inventory, and Certificate Manager if certificates are enabled, can
+// be listed in each project. Asset types share the one API, so they aren't checked separately.
+func (c *GCPProvider) Preflight(ctx context.Context) []cloud_provider_t.Check {
+	var checks []cloud_provider_t.Check
+	for _, project := range c.cfg.Projects {
+		svcCtx, cancel := cloud_provider_t.WithTimeout(ctx, c.cfg.ServiceTimeout)
+		checks = append(checks, cloud_provider_t.Check{
+			Name: "Asset Inventory " + project,
+			Err:  c.diagnose(c.wrapper.ProbeAssets(svcCtx, project), apiAssetInventory, project),
+			Hint: "Enable cloudasset.googleapis.com and grant roles/cloudasset.viewer on the project",
+		})
+		cancel()
+
+		if c.cfg.Services.CheckCertificates.Enabled {
+			svcCtx, cancel := cloud_provider_t.WithTimeout(ctx, c.cfg.ServiceTimeout)
+			checks = append(checks, cloud_provider_t.Check{
+				Name: "Certificate Manager " + project,
+				Err:  c.diagnose(c.wrapper.ProbeCertificates(svcCtx, project), apiCertificateManager, project),
+				Hint: "Enable certificatemanager.googleapis.com and grant certificatemanager.certs.list on the project",
+			})
+			cancel()
+		}
+	}
+	return checks
+}
+
+// assetCheck is the discovery of the resources of an asset type from Cloud Asset Inventory
+type assetCheck struct {
+	enabled bool
+	getter  func(ctx context.Context, asset *assetpb.Asset, data map[string]any) ([]string, error)
+}
+
+// assetChecks returns the discovery of each asset type, enabled as in the config
+func (c *GCPProvider) assetChecks() map[string]assetCheck {
+	return map[string]assetCheck{
 		"dns.googleapis.com/ResourceRecordSet": {
-			enabled: c.cfg.Services.CheckDNSResourceRecordSet,
+			enabled: c.cfg.Services.CheckDNSResourceRecordSet.Enabled,
 			getter:  c.getResourcesFromResourceRecordSet,
 		},
 		"dns.googleapis.com/ManagedZone": {
-			enabled: c.cfg.Services.CheckDNSManagedZone,
+			enabled: c.cfg.Services.CheckDNSManagedZone.Enabled,
 			getter:  c.getResourcesFromManagedZone,
 		},
 		"compute.googleapis.com/Instance": {
-			enabled: c.cfg.Services.CheckComputeInstance,
+			enabled: c.cfg.Services.CheckComputeInstance.Enabled,
 			getter:  c.getResourcesFromInstance,
 		},
 		"compute.googleapis.com/Address": {
-			enabled: c.cfg.Services.CheckComputeAddress,
+			enabled: c.cfg.Services.CheckComputeAddress.Enabled,
 			getter:  c.getResourcesFromAddress,
 		},
 		"storage.googleapis.com/Bucket": {
-			enabled: c.cfg.Services.CheckStorageBucket,
+			enabled: c.cfg.Services.CheckStorageBucket.Enabled,
 			getter:  c.getResourcesFromBucket,
 		},
 		"cloudfunctions.googleapis.com/Function": {
-			enabled: c.cfg.Services.CheckCloudFunction,
+			enabled: c.cfg.Services.CheckCloudFunction.Enabled,
 			getter:  c.getResourcesFromFunction,
 		},
 		"run.googleapis.com/Service": {
-			enabled: c.cfg.Services.CheckRunService,
+			enabled: c.cfg.Services.CheckRunService.Enabled,
 			getter:  c.getResourcesFromRunService,
 		},
 		"run.googleapis.com/DomainMapping": {
-			enabled: c.cfg.Services.CheckRunDomainMapping,
+			enabled: c.cfg.Services.CheckRunDomainMapping.Enabled,
 			getter:  c.getResourcesFromDomainMapping,
 		},
 		"apigateway.googleapis.com/Gateway": {
-			enabled: c.cfg.Services.CheckAPIGateway,
+			enabled: c.cfg.Services.CheckAPIGateway.Enabled,
 			getter:  c.getResourcesFromAPIGateway,
 		},
 		"sqladmin.googleapis.com/Instance": {
-			enabled: c.cfg.Services.CheckSQLInstance,
+			enabled: c.cfg.Services.CheckSQLInstance.Enabled,
 			getter:  c.getResourcesFromSQLInstance,
 		},
 		"compute.googleapis.com/ForwardingRule": {
-			enabled: c.cfg.Services.CheckComputeForwardingRule,
+			enabled: c.cfg.Services.CheckComputeForwardingRule.Enabled,
 			getter:  c.getResourcesFromForwardingRule,
 		},
 		"compute.googleapis.com/GlobalForwardingRule": {
-			enabled: c.cfg.Services.CheckComputeGlobalForwarding,
+			enabled: c.cfg.Services.CheckComputeGlobalForwarding.Enabled,
 			getter:  c.getResourcesFromForwardingRule,
 		},
 		"compute.googleapis.com/UrlMap": {
-			enabled: c.cfg.Services.CheckComputeURLMap,
+			enabled: c.cfg.Services.CheckComputeURLMap.Enabled,
 			getter:  c.getResourcesFromURLMap,
 		},
 		"appengine.googleapis.com/Service": {
-			enabled: c.cfg.Services.CheckAppEngineService,
+			enabled: c.cfg.Services.CheckAppEngineService.Enabled,
 			getter:  c.getResourcesFromAppEngineService,
 		},
 		"container.googleapis.com/Cluster": {
-			enabled: c.cfg.Services.CheckGKECluster,
+			enabled: c.cfg.Services.CheckGKECluster.Enabled,
 			getter:  c.getResourcesFromCluster,
 		},
 	}
+}
+
+func (c *GCPProvider) GetResources(ctx context.Context) ([]string, error) {
+	defs := c.assetChecks()
 
 	enabledAssetTypes := make([]string, 0, len(defs))
 	for k, v := range defs {
@@ -126,53 +214,94 @@ func (c *GCPProvider) GetResources(ctx context.Context) ([]string, error) {
 	}
 	logger.GetLogger(ctx).Debug().Strs("asset_types", enabledAssetTypes).Msg("enabled asset types")
 
+	// Each project is searched separately, so up to Concurrency projects are searched at a time
+	found := make([][]string, len(c.cfg.Projects))
+	errs := make([]error, len(c.cfg.Projects))
+	cloud_provider_t.RunAll(len(c.cfg.Projects), c.cfg.Concurrency, func(idx int) {
+		found[idx], errs[idx] = c.projectResources(ctx, c.cfg.Projects[idx], defs, enabledAssetTypes)
+	})
+
 	var resources []string
-	for _, project := range c.cfg.Projects {
-		logger.GetLogger(ctx).Debug().Msgf("searching project %s", project)
-		if len(enabledAssetTypes) > 0 {
-			assets, err := c.wrapper.GetAssets(ctx, project, enabledAssetTypes)
-			if err != nil {
-				return nil, err
-			}
+	for idx, res := range found {
+		if errs[idx] != nil {
+			return nil, errs[idx]
+		}
+		resources = append(resources, res...)
+	}
 
-			for _, asset := range assets {
-				logger.GetLogger(ctx).Trace().Str("asset_type", asset.AssetType).Msg("processing asset")
-				def, ok := defs[asset.AssetType]
-				if !ok {
-					// Should not be possible
-					logger.GetLogger(ctx).Warn().Str("asset_type", asset.AssetType).Msg("missing code to handle asset type")
-					continue
-				}
+	logger.GetLogger(ctx).Info().Int("resource_count", len(resources)).Msg("resource discovery complete")
+	return resources, nil
+}
 
-				data := asset.GetResource().GetData().AsMap()
+// projectResources returns the resources of the enabled asset types, and certificates if enabled,
+// of project
+func (c *GCPProvider) projectResources(ctx context.Context, project string, defs map[string]assetCheck, enabledAssetTypes []string) ([]string, error) {
+	defer reporting.Repanic(reporting.WithTags(ctx, "project", project))
 
-				assetResources, err := def.getter(ctx, asset, data)
-				if err != nil {
-					if errType := (&ValidationErr{}); errors.As(err, &errType) {
-						logger.GetLogger(ctx).Warn().Str("asset_type", asset.AssetType).Err(err).Msg("failed to decode asset, skipping")
-						continue
-					}
-					return nil, err
-				}
+	projectStart := time.Now()
+	var resources []string
+	logger.GetLogger(ctx).Debug().Msgf("searching project %s", project)
+	if len(enabledAssetTypes) > 0 {
+		start := time.Now()
+		svcCtx, cancel := cloud_provider_t.WithTimeout(ctx, c.cfg.ServiceTimeout)
+		assets, err := c.wrapper.GetAssets(svcCtx, project, enabledAssetTypes)
+		cancel()
+		if err != nil {
+			return nil, c.diagnose(err, apiAssetInventory, project)
+		}
 
-				resources = append(resources, assetResources...)
+		for _, asset := range assets {
+			logger.PerResource(ctx, "processing asset").Trace().Str("asset_type", asset.AssetType).Msg("processing asset")
+			def, ok := defs[asset.AssetType]
+			if !ok {
+				// Should not be possible
+				logger.GetLogger(ctx).Warn().Str("asset_type", asset.AssetType).Msg("missing code to handle asset type")
+				warnings.Record(ctx, warnings.SkippedResource, "%s asset of unhandled type %s skipped", asset.Name, asset.AssetType)
+				continue
 			}
-		}
 
-		// Certificates have to be retrieved separately because they are not available on the Assets API
-		if c.cfg.Services.CheckCertificates {
-			logger.GetLogger(ctx).Debug().Msg("fetching certificates")
-			certs, err := c.wrapper.GetCertificates(ctx, project)
+			data := asset.GetResource().GetData().AsMap()
+
+			assetResources, err := def.getter(ctx, asset, data)
 			if err != nil {
+				if errType := (&ValidationErr{}); errors.As(err, &errType) {
+					logger.GetLogger(ctx).Warn().Str("asset_type", asset.AssetType).Err(err).Msg("failed to decode asset, skipping")
+					warnings.Record(ctx, warnings.SkippedResource, "%s asset skipped, %s", asset.Name, err)
+					continue
+				}
 				return nil, err
 			}
-			logger.GetLogger(ctx).Trace().Int("certificate_count", len(certs)).Msg("certificates retrieved")
 
-			resources = append(resources, extractDomainsFromCertificates(certs)...)
+			resources = append(resources, assetResources...)
 		}
+		elapsed := time.Since(start)
+		logger.GetLogger(ctx).Debug().Int("resource_count", len(resources)).Dur("duration", elapsed).Msgf("checked Asset Inventory in project %s", project)
+		progress.Service(ctx, "Asset Inventory", project, len(resources), elapsed, nil)
+	}
+
+	// Certificates have to be retrieved separately because they are not available on the Assets API
+	if c.cfg.Services.CheckCertificates.Enabled {
+		logger.GetLogger(ctx).Debug().Msg("fetching certificates")
+		start := time.Now()
+		svcCtx, cancel := cloud_provider_t.WithTimeout(ctx, c.cfg.ServiceTimeout)
+		certs, err := c.wrapper.GetCertificates(svcCtx, project)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		logger.GetLogger(ctx).Trace().Int("certificate_count", len(certs)).Msg("certificates retrieved")
+
+		domains := extractDomainsFromCertificates(certs)
+		elapsed := time.Since(start)
+		logger.GetLogger(ctx).Debug().Int("resource_count", len(domains)).Dur("duration", elapsed).Msgf("checked Certificate Manager in project %s", project)
+		progress.Service(ctx, "Certificate Manager", project, len(domains), elapsed, nil)
+		resources = append(resources, domains...)
 	}
 
-	logger.GetLogger(ctx).Info().Int("resource_count", len(resources)).Msg("resource discovery complete")
+	logger.GetLogger(ctx).Info().
+		Int("resource_count", len(resources)).
+		Dur("duration", time.Since(projectStart)).
+		Msgf("got resources of project %s", project)
 	return resources, nil
 }
 
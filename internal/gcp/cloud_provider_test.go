@@ -2,6 +2,7 @@ package gcp
 
 import (
 	"context"
+	"slices"
 	"testing"
 
 	assetpb "cloud.google.com/go/asset/apiv1/assetpb"
@@ -16,7 +17,7 @@ func Test_GetResources_UsesEnabledAssetTypes(t *testing.T) {
 		CloudProvider: config.CloudProvider{Enabled: true},
 		Projects:      []string{"PROJECT_ID"},
 		Services: &config.GCPServices{
-			CheckDNSResourceRecordSet: true,
+			CheckDNSResourceRecordSet: config.Check{Enabled: true},
 		},
 	})
 
@@ -33,7 +34,7 @@ func Test_GetResources_CertificatesCalledSeparately(t *testing.T) {
 		CloudProvider: config.CloudProvider{Enabled: true},
 		Projects:      []string{"PROJECT_ID"},
 		Services: &config.GCPServices{
-			CheckCertificates: true,
+			CheckCertificates: config.Check{Enabled: true},
 		},
 	})
 
@@ -49,7 +50,7 @@ func Test_GetResources_GetAssetsErrs_ReturnsErr(t *testing.T) {
 		CloudProvider: config.CloudProvider{Enabled: true},
 		Projects:      []string{"PROJECT_ID"},
 		Services: &config.GCPServices{
-			CheckDNSResourceRecordSet: true,
+			CheckDNSResourceRecordSet: config.Check{Enabled: true},
 		},
 	})
 
@@ -66,7 +67,7 @@ func Test_GetResources_GetCertificatesErrs_ReturnsErr(t *testing.T) {
 		CloudProvider: config.CloudProvider{Enabled: true},
 		Projects:      []string{"PROJECT_ID"},
 		Services: &config.GCPServices{
-			CheckCertificates: true,
+			CheckCertificates: config.Check{Enabled: true},
 		},
 	})
 
@@ -82,7 +83,7 @@ func Test_GetResources_Asset_ReturnsResource(t *testing.T) {
 		CloudProvider: config.CloudProvider{Enabled: true},
 		Projects:      []string{"PROJECT_ID"},
 		Services: &config.GCPServices{
-			CheckDNSManagedZone: true,
+			CheckDNSManagedZone: config.Check{Enabled: true},
 		},
 	})
 
@@ -111,7 +112,7 @@ func Test_GetResources_AssetValidationErr_AssetSkipped(t *testing.T) {
 		CloudProvider: config.CloudProvider{Enabled: true},
 		Projects:      []string{"PROJECT_ID"},
 		Services: &config.GCPServices{
-			CheckDNSResourceRecordSet: true,
+			CheckDNSResourceRecordSet: config.Check{Enabled: true},
 		},
 	})
 
@@ -147,12 +148,98 @@ func Test_GetResources_AssetValidationErr_AssetSkipped(t *testing.T) {
 	assert.Contains(t, resources, "192.168.0.1")
 }
 
+func Test_Authenticate_ResolvesProjectIDs(t *testing.T) {
+	provider, wrapper := newProviderWithWrapper(t, &config.GCPCloudProvider{
+		CloudProvider: config.CloudProvider{Enabled: true},
+		Projects:      []string{"projects/123456", "projects/my-project"},
+	})
+
+	wrapper.On("CheckConnection").Return(nil)
+	wrapper.On("GetProjectNumber", "projects/my-project").Return("projects/654321", nil).Once()
+
+	err := provider.Authenticate(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"projects/123456", "projects/654321"}, provider.cfg.Projects)
+}
+
+func Test_Authenticate_ResolveProjectErrs_ReturnsErr(t *testing.T) {
+	provider, wrapper := newProviderWithWrapper(t, &config.GCPCloudProvider{
+		CloudProvider: config.CloudProvider{Enabled: true},
+		Projects:      []string{"projects/my-project"},
+	})
+
+	wrapper.On("CheckConnection").Return(nil)
+	wrapper.On("GetProjectNumber", "projects/my-project").Return("", assert.AnError)
+
+	err := provider.Authenticate(context.Background())
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func Test_ListAccounts_ConfiguredProjects(t *testing.T) {
+	provider, wrapper := newProviderWithWrapper(t, &config.GCPCloudProvider{
+		CloudProvider: config.CloudProvider{Enabled: true},
+		Projects:      []string{"projects/123456", "projects/my-project"},
+	})
+
+	wrapper.On("CheckConnection").Return(nil)
+	wrapper.On("GetProjectNumber", "projects/my-project").Return("projects/654321", nil).Once()
+	assert.NoError(t, provider.Authenticate(context.Background()))
+
+	accounts, err := provider.ListAccounts(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"123456", "my-project"}, accounts)
+}
+
+func Test_Preflight_ChecksEachProject(t *testing.T) {
+	provider, wrapper := newProviderWithWrapper(t, &config.GCPCloudProvider{
+		CloudProvider: config.CloudProvider{Enabled: true},
+		Projects:      []string{"projects/1", "projects/2"},
+		Services: &config.GCPServices{
+			CheckCertificates: config.Check{Enabled: true},
+		},
+	})
+
+	wrapper.On("ProbeAssets", "projects/1").Return(nil)
+	wrapper.On("ProbeAssets", "projects/2").Return(assert.AnError)
+	wrapper.On("ProbeCertificates", "projects/1").Return(nil)
+	wrapper.On("ProbeCertificates", "projects/2").Return(nil)
+
+	checks := provider.Preflight(context.Background())
+
+	assert.Len(t, checks, 4)
+	assert.Equal(t, "Asset Inventory projects/2", checks[2].Name)
+	assert.ErrorIs(t, checks[2].Err, assert.AnError)
+	for _, check := range []int{0, 1, 3} {
+		assert.NoError(t, checks[check].Err)
+	}
+}
+
+func Test_Policies_EnabledServices(t *testing.T) {
+	provider, _ := newProviderWithWrapper(t, &config.GCPCloudProvider{
+		CloudProvider: config.CloudProvider{Enabled: true},
+		Services: &config.GCPServices{
+			CheckStorageBucket: config.Check{Enabled: true},
+		},
+	})
+
+	policies := provider.Policies()
+
+	assert.Len(t, policies, 1)
+	assert.Equal(t, []string{
+		"cloudasset.assets.listResource",
+		"resourcemanager.projects.get",
+		"storage.buckets.get",
+		"storage.buckets.getIamPolicy",
+	}, policies[0].Document.(customRole).IncludedPermissions)
+}
+
 func newProviderWithWrapper(t *testing.T, cfg *config.GCPCloudProvider) (*GCPProvider, *MockWrapper) {
 	t.Helper()
 	wrapper := NewMockWrapper(t).(*MockWrapper)
 	provider := &GCPProvider{
-		cfg:     cfg,
-		wrapper: wrapper,
+		cfg:      cfg,
+		wrapper:  wrapper,
+		projects: slices.Clone(cfg.Projects),
 	}
 	return provider, wrapper
 }
@@ -0,0 +1,137 @@
+package gcp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+// The APIs the connector calls
+const (
+	apiResourceManager    = "cloudresourcemanager.googleapis.com"
+	apiAssetInventory     = "cloudasset.googleapis.com"
+	apiCertificateManager = "certificatemanager.googleapis.com"
+)
+
+// apiRoles are the roles granting the access the connector needs to each API
+var apiRoles = map[string]string{
+	apiResourceManager:    "roles/browser",
+	apiAssetInventory:     "roles/cloudasset.viewer",
+	apiCertificateManager: "roles/certificatemanager.viewer",
+}
+
+// disabledAPIPattern matches the API in the error of a call to an API that isn't enabled in the
+// project, which links to the page enabling it in the project
+var disabledAPIPattern = regexp.MustCompile(`apis/api/([a-z0-9-]+\.googleapis\.com)(?:/overview\?project=([a-z0-9-]+))?`)
+
+// diagnose is diagnose for the credentials of the profile, recognising the failures of workload
+// identity federation first if it is configured
+func (c *GCPProvider) diagnose(err error, api string, project string) error {
+	if err != nil && c.cfg.WorkloadIdentity != nil {
+		if authErr := diagnoseWorkloadIdentity(err, c.cfg.WorkloadIdentity); authErr != nil {
+			return authErr
+		}
+	}
+	return diagnose(err, api, project)
+}
+
+// diagnose returns err as a cloud_provider_t.AuthError if its cause is recognised, e.g. an API that
+// isn't enabled, or err unchanged otherwise. api is the API that was called and project the project
+// it was called for, if any.
+func diagnose(err error, api string, project string) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	project = strings.TrimPrefix(project, "projects/")
+
+	switch {
+	case strings.Contains(msg, "could not find default credentials"):
+		return &cloud_provider_t.AuthError{
+			Cause:       "no Google Cloud credentials were found",
+			Remediation: "run gcloud auth application-default login, set GOOGLE_APPLICATION_CREDENTIALS, or run the connector with a service account",
+			Err:         err,
+		}
+	case strings.Contains(msg, "invalid_grant") || strings.Contains(msg, "invalid_rapt"):
+		return &cloud_provider_t.AuthError{
+			Cause:       "the Google Cloud Application Default Credentials have expired",
+			Remediation: "run gcloud auth application-default login",
+			Err:         err,
+		}
+	case strings.Contains(msg, "SERVICE_DISABLED") || strings.Contains(msg, "has not been used in project"):
+		if match := disabledAPIPattern.FindStringSubmatch(msg); match != nil {
+			api = match[1]
+			if project == "" {
+				project = match[2]
+			}
+		}
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("%s is not enabled in project %s", api, project),
+			Remediation: fmt.Sprintf("run gcloud services enable %s --project %s", api, project),
+			Err:         err,
+		}
+	}
+
+	var apiErr *googleapi.Error
+	denied := status.Code(err) == codes.PermissionDenied || (errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden)
+	if denied && apiRoles[api] != "" {
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("the connector's identity is not authorised to call %s in project %s, or the project doesn't exist", api, project),
+			Remediation: fmt.Sprintf("check the project ID, and grant %s on the project", apiRoles[api]),
+			Err:         err,
+		}
+	}
+
+	return err
+}
+
+// diagnoseWorkloadIdentity returns err as a cloud_provider_t.AuthError if it is a recognised failure
+// to exchange a token with workload identity federation, or nil otherwise. The token exchange and
+// impersonation errors aren't wrapped, so only their messages can be matched.
+func diagnoseWorkloadIdentity(err error, cfg *config.GCPWorkloadIdentity) error {
+	msg := err.Error()
+
+	switch {
+	case cfg.Source == "file" && (errors.Is(err, fs.ErrNotExist) || strings.Contains(msg, "failed to open credential file")):
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("the workload identity token file %s could not be read", cfg.TokenFile),
+			Remediation: "check gcp.workload_identity.token_file, and that the token is written before the connector runs",
+			Err:         err,
+		}
+	case strings.Contains(msg, "invalid_target"):
+		return &cloud_provider_t.AuthError{
+			Cause:       "the workload identity pool provider of gcp.workload_identity.audience doesn't exist or is disabled",
+			Remediation: "check the audience is the full resource name of the provider, //iam.googleapis.com/projects/<number>/locations/global/workloadIdentityPools/<pool>/providers/<provider>",
+			Err:         err,
+		}
+	case strings.Contains(msg, "invalid_grant") && (strings.Contains(msg, "expired") || strings.Contains(msg, "stale")):
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("the %s token exchanged with workload identity federation has expired", cfg.Source),
+			Remediation: "check the token is renewed before it expires",
+			Err:         err,
+		}
+	case strings.Contains(msg, "invalid_grant"):
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("the workload identity pool provider rejected the %s token", cfg.Source),
+			Remediation: "check the issuer, allowed audiences and attribute condition of the provider match the token",
+			Err:         err,
+		}
+	case cfg.ServiceAccount != "" && strings.Contains(msg, "iam.serviceAccounts.getAccessToken"):
+		return &cloud_provider_t.AuthError{
+			Cause:       fmt.Sprintf("the workload identity is not authorised to impersonate %s", cfg.ServiceAccount),
+			Remediation: "grant roles/iam.workloadIdentityUser on the service account to the principal of the workload identity pool",
+			Err:         err,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package gcp
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		api         string
+		remediation string
+	}{
+		{
+			name:        "NoCredentials",
+			err:         fmt.Errorf("gcp: failed to create client, credentials: could not find default credentials"),
+			api:         apiAssetInventory,
+			remediation: "run gcloud auth application-default login, set GOOGLE_APPLICATION_CREDENTIALS, or run the connector with a service account",
+		},
+		{
+			name:        "AssetAPIDisabled",
+			err:         status.Error(codes.PermissionDenied, "Cloud Asset API has not been used in project 123456 before or it is disabled. Enable it by visiting https://console.developers.google.com/apis/api/cloudasset.googleapis.com/overview?project=123456 then retry."),
+			api:         apiAssetInventory,
+			remediation: "run gcloud services enable cloudasset.googleapis.com --project my-project",
+		},
+		{
+			name:        "PermissionDenied",
+			err:         status.Error(codes.PermissionDenied, "The caller does not have permission"),
+			api:         apiAssetInventory,
+			remediation: "check the project ID, and grant roles/cloudasset.viewer on the project",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var authErr *cloud_provider_t.AuthError
+			assert.ErrorAs(t, diagnose(tc.err, tc.api, "projects/my-project"), &authErr)
+			assert.Equal(t, tc.remediation, authErr.Remediation)
+		})
+	}
+}
+
+func TestDiagnose_DisabledAPI_ProjectFromError(t *testing.T) {
+	err := fmt.Errorf("gcp: failed to create client, %w", fmt.Errorf("SERVICE_DISABLED: see https://console.developers.google.com/apis/api/cloudasset.googleapis.com/overview?project=123456"))
+
+	var authErr *cloud_provider_t.AuthError
+	assert.ErrorAs(t, diagnose(err, apiAssetInventory, ""), &authErr)
+	assert.Equal(t, "cloudasset.googleapis.com is not enabled in project 123456", authErr.Cause)
+}
+
+func TestDiagnose_WorkloadIdentity(t *testing.T) {
+	wi := &config.GCPWorkloadIdentity{Source: "file", TokenFile: "/var/run/secrets/token", ServiceAccount: "connector@my-project.iam.gserviceaccount.com"}
+	provider := &GCPProvider{cfg: &config.GCPCloudProvider{WorkloadIdentity: wi}}
+
+	tests := []struct {
+		name  string
+		err   error
+		cause string
+	}{
+		{"MissingTokenFile", fmt.Errorf("credentials: failed to open credential file %q: %w", wi.TokenFile, fs.ErrNotExist), "the workload identity token file /var/run/secrets/token could not be read"},
+		{"UnknownProvider", fmt.Errorf(`credentials: status code 400: {"error":"invalid_target","error_description":"The target service indicated by the \"audience\" parameters is invalid."}`), "the workload identity pool provider of gcp.workload_identity.audience doesn't exist or is disabled"},
+		{"ExpiredToken", fmt.Errorf(`credentials: status code 400: {"error":"invalid_grant","error_description":"ID Token issued at 1700000000 is stale to sign-in."}`), "the file token exchanged with workload identity federation has expired"},
+		{"RejectedToken", status.Error(codes.Unauthenticated, `transport: per-RPC creds failed due to error: credentials: status code 400: {"error":"invalid_grant","error_description":"The given credential is rejected by the attribute condition."}`), "the workload identity pool provider rejected the file token"},
+		{"Impersonation", fmt.Errorf(`credentials: status code 403: {"error":{"code":403,"message":"Permission 'iam.serviceAccounts.getAccessToken' denied on resource (or it may not exist).","status":"PERMISSION_DENIED"}}`), "the workload identity is not authorised to impersonate connector@my-project.iam.gserviceaccount.com"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var authErr *cloud_provider_t.AuthError
+			assert.ErrorAs(t, provider.diagnose(tc.err, apiAssetInventory, "projects/my-project"), &authErr)
+			assert.Equal(t, tc.cause, authErr.Cause)
+		})
+	}
+}
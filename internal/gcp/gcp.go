@@ -11,28 +11,37 @@ import (
 	certificatemanagerpb "cloud.google.com/go/certificatemanager/apiv1/certificatemanagerpb"
 	"cloud.google.com/go/storage"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
+	cloudresourcemanager "google.golang.org/api/cloudresourcemanager/v3"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/status"
 )
 
 type IGCPWrapper interface {
 	CheckConnection(ctx context.Context) error
+	GetProjectNumber(ctx context.Context, project string) (string, error)
 	GetAssets(ctx context.Context, project string, assetTypes []string) ([]*assetpb.Asset, error)
 	GetCertificates(ctx context.Context, project string) ([]*certificatemanagerpb.Certificate, error)
 	IsBucketPublic(ctx context.Context, bucketName string) bool
+	ProbeAssets(ctx context.Context, project string) error
+	ProbeCertificates(ctx context.Context, project string) error
 }
 
-type GCPWrapper struct{}
+type GCPWrapper struct {
+	// opts authenticate the clients, with Application Default Credentials if empty
+	opts []option.ClientOption
+}
 
-func NewWrapper() (IGCPWrapper, error) {
-	return &GCPWrapper{}, nil
+func NewWrapper(opts ...option.ClientOption) (IGCPWrapper, error) {
+	return &GCPWrapper{opts: append(opts, tlsmode.GCP()...)}, nil
 }
 
 // Return nil if able to create any client and therefore can authenticate
 // doesn't check that the required permissions are set
 func (w *GCPWrapper) CheckConnection(ctx context.Context) error {
-	c, err := asset.NewClient(ctx)
+	c, err := asset.NewClient(ctx, w.opts...)
 	if err != nil {
 		return fmt.Errorf("gcp: failed to create client, %w", err)
 	}
@@ -40,8 +49,23 @@ func (w *GCPWrapper) CheckConnection(ctx context.Context) error {
 	return nil
 }
 
+// GetProjectNumber resolves a project given as projects/<project ID> to projects/<project number>
+func (w *GCPWrapper) GetProjectNumber(ctx context.Context, project string) (string, error) {
+	svc, err := cloudresourcemanager.NewService(ctx, w.opts...)
+	if err != nil {
+		return "", fmt.Errorf("gcp: failed to create resource manager client, %w", err)
+	}
+
+	p, err := svc.Projects.Get(project).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("gcp: failed to get project %s, %w", project, err)
+	}
+
+	return p.Name, nil
+}
+
 func (w *GCPWrapper) GetAssets(ctx context.Context, project string, assetTypes []string) ([]*assetpb.Asset, error) {
-	c, err := asset.NewClient(ctx)
+	c, err := asset.NewClient(ctx, w.opts...)
 	if err != nil {
 		return nil, fmt.Errorf("gcp: failed to create client, %w", err)
 	}
@@ -79,7 +103,7 @@ func (w *GCPWrapper) GetAssets(ctx context.Context, project string, assetTypes [
 // These are not available in Cloud Asset Inventory, so we must query
 // certificatemanager.googleapis.com directly.
 func (w *GCPWrapper) GetCertificates(ctx context.Context, project string) ([]*certificatemanagerpb.Certificate, error) {
-	client, err := certificatemanager.NewClient(ctx)
+	client, err := certificatemanager.NewClient(ctx, w.opts...)
 	if err != nil {
 		return nil, fmt.Errorf("gcp: failed to create certificate manager client: %w", err)
 	}
@@ -112,6 +136,44 @@ func (w *GCPWrapper) GetCertificates(ctx context.Context, project string) ([]*ce
 	return certificates, nil
 }
 
+// ProbeAssets lists at most one asset of project, unlike GetAssets a disabled API is an error
+func (w *GCPWrapper) ProbeAssets(ctx context.Context, project string) error {
+	c, err := asset.NewClient(ctx, w.opts...)
+	if err != nil {
+		return fmt.Errorf("gcp: failed to create client, %w", err)
+	}
+	defer c.Close()
+
+	it := c.ListAssets(ctx, &assetpb.ListAssetsRequest{
+		Parent:      project,
+		ContentType: assetpb.ContentType_RESOURCE,
+		PageSize:    1,
+	})
+	if _, err := it.Next(); err != nil && !errors.Is(err, iterator.Done) {
+		return fmt.Errorf("gcp: failed to list assets of %s, %w", project, err)
+	}
+	return nil
+}
+
+// ProbeCertificates lists at most one certificate of project, unlike GetCertificates a disabled
+// API is an error
+func (w *GCPWrapper) ProbeCertificates(ctx context.Context, project string) error {
+	client, err := certificatemanager.NewClient(ctx, w.opts...)
+	if err != nil {
+		return fmt.Errorf("gcp: failed to create certificate manager client: %w", err)
+	}
+	defer client.Close()
+
+	it := client.ListCertificates(ctx, &certificatemanagerpb.ListCertificatesRequest{
+		Parent:   fmt.Sprintf("%s/locations/-", project),
+		PageSize: 1,
+	})
+	if _, err := it.Next(); err != nil && !errors.Is(err, iterator.Done) {
+		return fmt.Errorf("gcp: failed to list certificates of %s: %w", project, err)
+	}
+	return nil
+}
+
 func (w *GCPWrapper) isBucketPolicyPublic(ctx context.Context, bucket *storage.BucketHandle) (bool, error) {
 	policy, err := bucket.IAM().Policy(ctx)
 	if err != nil {
@@ -157,7 +219,7 @@ func (w *GCPWrapper) isBucketACLPublic(ctx context.Context, bucket *storage.Buck
 func (w *GCPWrapper) IsBucketPublic(ctx context.Context, bucketName string) bool {
 	iCtx := logger.WithLogger(ctx, logger.GetLogger(ctx).With().Str("bucket", bucketName).Logger())
 
-	sc, err := storage.NewClient(iCtx)
+	sc, err := storage.NewClient(iCtx, w.opts...)
 	if err != nil {
 		logger.GetLogger(iCtx).Warn().Err(err).Msg("failed to create storage client — assuming bucket is not public")
 		return false
@@ -29,6 +29,11 @@ func (m *MockWrapper) CheckConnection(_ context.Context) error {
 	return args.Error(0)
 }
 
+func (m *MockWrapper) GetProjectNumber(_ context.Context, project string) (string, error) {
+	args := m.Called(project)
+	return args.String(0), args.Error(1)
+}
+
 func (m *MockWrapper) GetAssets(_ context.Context, project string, assetTypes []string) ([]*assetpb.Asset, error) {
 	args := m.Called(project, assetTypes)
 	if args.Get(0) == nil {
@@ -52,3 +57,13 @@ func (m *MockWrapper) IsBucketPublic(_ context.Context, bucketName string) bool
 	}
 	return false
 }
+
+func (m *MockWrapper) ProbeAssets(_ context.Context, project string) error {
+	args := m.Called(project)
+	return args.Error(0)
+}
+
+func (m *MockWrapper) ProbeCertificates(_ context.Context, project string) error {
+	args := m.Called(project)
+	return args.Error(0)
+}
@@ -0,0 +1,17 @@
+package gcp
+
+// projectPermissions are the permissions needed to resolve a project ID to its number
+var projectPermissions = []string{"resourcemanager.projects.get"}
+
+// assetPermissions are the permissions needed to list assets from Cloud Asset Inventory
+var assetPermissions = []string{"cloudasset.assets.listResource"}
+
+// assetTypePermissions are the permissions the getter of an asset type needs beyond listing
+// assets, by asset type
+var assetTypePermissions = map[string][]string{
+	// IsBucketPublic reads the IAM policy and ACLs of each bucket
+	"storage.googleapis.com/Bucket": {"storage.buckets.get", "storage.buckets.getIamPolicy"},
+}
+
+// certificatePermissions are the permissions GetCertificates needs
+var certificatePermissions = []string{"certificatemanager.certs.list"}
@@ -0,0 +1,44 @@
+package gcp
+
+import (
+	"slices"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+)
+
+// customRole is a GCP custom role, as created by gcloud iam roles create --file
+type customRole struct {
+	Title               string   `json:"title"`
+	Description         string   `json:"description"`
+	Stage               string   `json:"stage"`
+	IncludedPermissions []string `json:"includedPermissions"`
+}
+
+// Policies returns the custom role the enabled service checks need, to grant on each project
+func (c *GCPProvider) Policies() []cloud_provider_t.Policy {
+	permissions := slices.Clone(projectPermissions)
+	listsAssets := false
+	for assetType, check := range c.assetChecks() {
+		if check.enabled {
+			listsAssets = true
+			permissions = append(permissions, assetTypePermissions[assetType]...)
+		}
+	}
+	if listsAssets {
+		permissions = append(permissions, assetPermissions...)
+	}
+	if c.cfg.Services.CheckCertificates.Enabled {
+		permissions = append(permissions, certificatePermissions...)
+	}
+	slices.Sort(permissions)
+
+	return []cloud_provider_t.Policy{{
+		Name: "connector",
+		Document: customRole{
+			Title:               "Hexiosec ASM Cloud Connector",
+			Description:         "Reads the public resources of the enabled service checks",
+			Stage:               "GA",
+			IncludedPermissions: slices.Compact(permissions),
+		},
+	}}
+}
@@ -0,0 +1,38 @@
+package gcp
+
+import (
+	"context"
+
+	"google.golang.org/api/option"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/config/source"
+)
+
+// profileOptions returns the client options a profile authenticates with, none for the default
+// credentials
+func profileOptions(cfg *config.GCPCloudProvider) ([]option.ClientOption, error) {
+	if cfg.WorkloadIdentity == nil {
+		return nil, nil
+	}
+	return workloadIdentityOptions(cfg.WorkloadIdentity)
+}
+
+// ReadSecret returns the value of a Secret Manager secret, the secret of a secret://gcp-sm/
+// reference in the config, read with the credentials of profile, or the default credentials if
+// profile is nil
+func ReadSecret(ctx context.Context, profile *config.GCPCloudProvider, name string) (string, error) {
+	var opts []option.ClientOption
+	if profile != nil {
+		var err error
+		if opts, err = profileOptions(profile); err != nil {
+			return "", err
+		}
+	}
+
+	value, err := source.ReadGCPSecret(ctx, name, opts...)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
@@ -0,0 +1,80 @@
+package gcp
+
+import (
+	"fmt"
+	"net/url"
+
+	"cloud.google.com/go/auth"
+	"cloud.google.com/go/auth/credentials/externalaccount"
+	"google.golang.org/api/option"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+// stsTokenURL is the Security Token Service exchanging the tokens of other identity providers
+var stsTokenURL = "https://sts.googleapis.com/v1/token"
+
+const (
+	cloudPlatformScope  = "https://www.googleapis.com/auth/cloud-platform"
+	jwtTokenType        = "urn:ietf:params:oauth:token-type:jwt"
+	awsTokenType        = "urn:ietf:params:aws:token-type:aws4_request"
+	impersonationURLFmt = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken"
+)
+
+// workloadIdentityOptions returns the client options authenticating with the workload identity
+// federation of cfg
+func workloadIdentityOptions(cfg *config.GCPWorkloadIdentity) ([]option.ClientOption, error) {
+	creds, err := workloadIdentityCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return []option.ClientOption{option.WithAuthCredentials(creds)}, nil
+}
+
+// workloadIdentityCredentials returns the external account credentials of cfg, the same as those of
+// a credential configuration file of gcloud iam workload-identity-pools create-cred-config
+func workloadIdentityCredentials(cfg *config.GCPWorkloadIdentity) (*auth.Credentials, error) {
+	opts := &externalaccount.Options{
+		Audience: cfg.Audience,
+		TokenURL: stsTokenURL,
+		Scopes:   []string{cloudPlatformScope},
+	}
+	if cfg.ServiceAccount != "" {
+		opts.ServiceAccountImpersonationURL = fmt.Sprintf(impersonationURLFmt, cfg.ServiceAccount)
+	}
+
+	switch cfg.Source {
+	case "aws":
+		// The AWS credentials of the environment variables, or else of the instance metadata service
+		opts.SubjectTokenType = awsTokenType
+		opts.CredentialSource = &externalaccount.CredentialSource{
+			EnvironmentID:               "aws1",
+			RegionURL:                   "http://169.254.169.254/latest/meta-data/placement/availability-zone",
+			URL:                         "http://169.254.169.254/latest/meta-data/iam/security-credentials",
+			IMDSv2SessionTokenURL:       "http://169.254.169.254/latest/api/token",
+			RegionalCredVerificationURL: "https://sts.{region}.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15",
+		}
+	case "azure":
+		// A token of the managed identity of the VM from the instance metadata service
+		opts.SubjectTokenType = jwtTokenType
+		opts.CredentialSource = &externalaccount.CredentialSource{
+			URL:     "http://169.254.169.254/metadata/identity/oauth2/token?api-version=2018-02-01&resource=" + url.QueryEscape(cfg.AppIDURI),
+			Headers: map[string]string{"Metadata": "True"},
+			Format:  &externalaccount.Format{Type: "json", SubjectTokenFieldName: "access_token"},
+		}
+	case "file":
+		opts.SubjectTokenType = jwtTokenType
+		opts.CredentialSource = &externalaccount.CredentialSource{
+			File:   cfg.TokenFile,
+			Format: &externalaccount.Format{Type: "text"},
+		}
+	default:
+		return nil, fmt.Errorf("gcp: unknown workload identity source %s", cfg.Source)
+	}
+
+	creds, err := externalaccount.NewCredentials(opts)
+	if err != nil {
+		return nil, fmt.Errorf("gcp: failed to create workload identity credentials, %w", err)
+	}
+	return creds, nil
+}
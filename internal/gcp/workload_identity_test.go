@@ -0,0 +1,63 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+const testAudience = "//iam.googleapis.com/projects/123456/locations/global/workloadIdentityPools/pool/providers/provider"
+
+func Test_WorkloadIdentityCredentials_File_ExchangesToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "oidc-token", r.PostForm.Get("subject_token"))
+		assert.Equal(t, jwtTokenType, r.PostForm.Get("subject_token_type"))
+		assert.Equal(t, testAudience, r.PostForm.Get("audience"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":      "federated-token",
+			"issued_token_type": "urn:ietf:params:oauth:token-type:access_token",
+			"token_type":        "Bearer",
+			"expires_in":        3600,
+		})
+	}))
+	t.Cleanup(server.Close)
+	tokenURL := stsTokenURL
+	stsTokenURL = server.URL
+	t.Cleanup(func() { stsTokenURL = tokenURL })
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("oidc-token"), 0o600))
+
+	creds, err := workloadIdentityCredentials(&config.GCPWorkloadIdentity{Audience: testAudience, Source: "file", TokenFile: tokenFile})
+	require.NoError(t, err)
+
+	token, err := creds.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "federated-token", token.Value)
+}
+
+func Test_WorkloadIdentityCredentials_Sources(t *testing.T) {
+	for _, source := range []string{"aws", "azure", "file"} {
+		t.Run(source, func(t *testing.T) {
+			_, err := workloadIdentityCredentials(&config.GCPWorkloadIdentity{
+				Audience:       testAudience,
+				Source:         source,
+				TokenFile:      "/var/run/secrets/token",
+				AppIDURI:       "api://gcp-federation",
+				ServiceAccount: "connector@my-project.iam.gserviceaccount.com",
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
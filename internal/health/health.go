@@ -0,0 +1,145 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+// maxCheckInterval bounds how often Watch checks the staleness, so the liveness file is removed
+// soon after the threshold passes
+const maxCheckInterval = time.Minute
+
+// Status is the liveness of a daemon or server
+type Status struct {
+	Healthy bool `json:"healthy"`
+	// LastSuccess is the time of the last successful sync, zero if none has succeeded yet
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	// Reason is why the daemon is unhealthy
+	Reason string `json:"reason,omitempty"`
+}
+
+// Tracker tracks the last successful sync of a daemon or server, so a wedged connector can be
+// restarted. It becomes unhealthy once no sync has succeeded for maxStaleness, measured from when
+// it started until the first sync succeeds. A zero maxStaleness is always healthy.
+type Tracker struct {
+	file         string
+	maxStaleness time.Duration
+	started      time.Time
+
+	mu          sync.Mutex
+	lastSuccess time.Time
+	// unhealthy is whether the last refresh found the tracker unhealthy, so it's only logged once
+	unhealthy bool
+}
+
+// NewTracker returns a tracker started at now, keeping the liveness file at file if it's set
+func NewTracker(file string, maxStaleness time.Duration, now time.Time) *Tracker {
+	return &Tracker{file: file, maxStaleness: maxStaleness, started: now}
+}
+
+// Success records a sync that succeeded at the given time, refreshing the liveness file
+func (t *Tracker) Success(ctx context.Context, at time.Time) {
+	t.mu.Lock()
+	t.lastSuccess = at
+	t.mu.Unlock()
+
+	t.refresh(ctx, at)
+}
+
+// Status returns the liveness at now
+func (t *Tracker) Status(now time.Time) Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := Status{Healthy: true, LastSuccess: t.lastSuccess}
+	if t.maxStaleness <= 0 {
+		return status
+	}
+
+	since := t.lastSuccess
+	if since.IsZero() {
+		since = t.started
+	}
+	if stale := now.Sub(since); stale > t.maxStaleness {
+		status.Healthy = false
+		if t.lastSuccess.IsZero() {
+			status.Reason = fmt.Sprintf("no sync has succeeded since starting %s ago", stale.Round(time.Second))
+		} else {
+			status.Reason = fmt.Sprintf("no sync has succeeded for %s", stale.Round(time.Second))
+		}
+	}
+	return status
+}
+
+// Watch keeps the liveness file up to date until ctx is cancelled, writing the status while healthy
+// and removing the file once unhealthy, so an exec probe only has to check the file exists. The
+// file is removed when Watch returns.
+func (t *Tracker) Watch(ctx context.Context) {
+	if t.file == "" {
+		return
+	}
+
+	interval := maxCheckInterval
+	if t.maxStaleness > 0 && t.maxStaleness/4 < interval {
+		interval = t.maxStaleness / 4
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	t.refresh(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			t.remove(ctx)
+			return
+		case now := <-ticker.C:
+			t.refresh(ctx, now)
+		}
+	}
+}
+
+// refresh writes the status at now to the liveness file, or removes it if unhealthy
+func (t *Tracker) refresh(ctx context.Context, now time.Time) {
+	if t.file == "" {
+		return
+	}
+
+	status := t.Status(now)
+	t.mu.Lock()
+	changed := t.unhealthy == status.Healthy
+	t.unhealthy = !status.Healthy
+	t.mu.Unlock()
+
+	if !status.Healthy {
+		if changed {
+			logger.GetLogger(ctx).Warn().Str("reason", status.Reason).Msg("Connector is unhealthy, removing liveness file")
+		}
+		t.remove(ctx)
+		return
+	}
+	if changed {
+		logger.GetLogger(ctx).Info().Msg("Connector is healthy again")
+	}
+
+	body, err := json.Marshal(status)
+	if err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not encode liveness status")
+		return
+	}
+	if err := os.WriteFile(t.file, body, 0644); err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not write liveness file")
+	}
+}
+
+func (t *Tracker) remove(ctx context.Context) {
+	if err := os.Remove(t.file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not remove liveness file")
+	}
+}
@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker_Status(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("healthy before first sync within threshold", func(t *testing.T) {
+		tracker := NewTracker("", time.Hour, start)
+		assert.True(t, tracker.Status(start.Add(30*time.Minute)).Healthy)
+	})
+
+	t.Run("unhealthy without sync after threshold", func(t *testing.T) {
+		tracker := NewTracker("", time.Hour, start)
+		status := tracker.Status(start.Add(2 * time.Hour))
+		assert.False(t, status.Healthy)
+		assert.Contains(t, status.Reason, "since starting")
+	})
+
+	t.Run("success resets staleness", func(t *testing.T) {
+		tracker := NewTracker("", time.Hour, start)
+		tracker.Success(context.Background(), start.Add(90*time.Minute))
+		status := tracker.Status(start.Add(2 * time.Hour))
+		assert.True(t, status.Healthy)
+		assert.Equal(t, start.Add(90*time.Minute), status.LastSuccess)
+	})
+
+	t.Run("zero threshold always healthy", func(t *testing.T) {
+		tracker := NewTracker("", 0, start)
+		assert.True(t, tracker.Status(start.Add(1000*time.Hour)).Healthy)
+	})
+}
+
+func TestTracker_Refresh_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "healthy")
+	start := time.Now()
+	tracker := NewTracker(file, time.Hour, start)
+
+	tracker.Success(context.Background(), start)
+	body, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var status Status
+	require.NoError(t, json.Unmarshal(body, &status))
+	assert.True(t, status.Healthy)
+
+	tracker.refresh(context.Background(), start.Add(2*time.Hour))
+	assert.NoFileExists(t, file)
+}
+
+func TestTracker_Watch_RemovesFileOnStop(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "healthy")
+	tracker := NewTracker(file, time.Hour, time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		tracker.Watch(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(file)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	assert.NoFileExists(t, file)
+}
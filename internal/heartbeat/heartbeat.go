@@ -0,0 +1,91 @@
+// Package heartbeat posts the status of the connector to a status URL after each run, so its health
+// can be shown without access to its logs. The ASM API has no connector check-in endpoint, so the
+// status goes to a URL of the operator's choosing.
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	connector_http "github.com/hexiosec/asm-cloud-connector/internal/http"
+	"github.com/hexiosec/asm-cloud-connector/internal/version"
+)
+
+const heartbeatTimeout = 10 * time.Second
+
+// Status is the health of the connector, as sent to the status URL
+type Status struct {
+	Version string `json:"version"`
+	// ConfigHash is the SHA-256 hash of the config with credentials redacted
+	ConfigHash string `json:"config_hash"`
+	LastRun    Run    `json:"last_run"`
+	// NextRun is when the connector runs next, nil if it has no schedule
+	NextRun *time.Time `json:"next_run,omitempty"`
+}
+
+// Run is the outcome of the last run
+type Run struct {
+	ID       string        `json:"run_id,omitempty"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"-"`
+	Success  bool          `json:"success"`
+	Error    string        `json:"error,omitempty"`
+	Added    int           `json:"added"`
+	Removed  int           `json:"removed"`
+	// ScanIDs are the scans synced by the run
+	ScanIDs []string `json:"scan_ids,omitempty"`
+}
+
+// MarshalJSON sends the duration in seconds, rather than nanoseconds
+func (r Run) MarshalJSON() ([]byte, error) {
+	type run Run
+	return json.Marshal(struct {
+		run
+		DurationSeconds float64 `json:"duration_seconds"`
+	}{run(r), r.Duration.Seconds()})
+}
+
+type ISender interface {
+	Send(ctx context.Context, status Status) error
+}
+
+// NewSender returns a sender posting to the heartbeat URL, or one that does nothing if it isn't set
+func NewSender(cfg *config.Config) (ISender, error) {
+	if cfg.Heartbeat.URL == "" {
+		return &nopSender{}, nil
+	}
+
+	client, err := connector_http.NewHttpService(cfg, version.UserAgent())
+	if err != nil {
+		return nil, fmt.Errorf("heartbeat: failed to create http service, %w", err)
+	}
+
+	return &httpSender{url: cfg.Heartbeat.URL, client: client}, nil
+}
+
+type httpSender struct {
+	url    string
+	client connector_http.IHttpService
+}
+
+func (s *httpSender) Send(ctx context.Context, status Status) error {
+	resp, err := s.client.Post(ctx, s.url, status, connector_http.HttpOptions{Timeout: heartbeatTimeout})
+	if err != nil {
+		return fmt.Errorf("heartbeat: request failed, %w", err)
+	}
+
+	if resp.GetStatusCode() < 200 || resp.GetStatusCode() > 299 {
+		return fmt.Errorf("heartbeat: status URL returned status %d", resp.GetStatusCode())
+	}
+
+	return nil
+}
+
+type nopSender struct{}
+
+func (s *nopSender) Send(_ context.Context, _ Status) error {
+	return nil
+}
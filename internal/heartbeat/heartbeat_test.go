@@ -0,0 +1,99 @@
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+func newTestServer(t *testing.T, status int) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	received := []map[string]any{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var payload map[string]any
+		require.NoError(t, json.Unmarshal(body, &payload))
+		received = append(received, payload)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func newTestSender(t *testing.T, url string) ISender {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Heartbeat.URL = url
+	s, err := NewSender(cfg)
+	require.NoError(t, err)
+	return s
+}
+
+func TestSend_PostsStatus(t *testing.T) {
+	server, received := newTestServer(t, http.StatusOK)
+	s := newTestSender(t, server.URL)
+	next := time.Date(2026, 1, 2, 4, 0, 0, 0, time.UTC)
+
+	err := s.Send(context.Background(), Status{
+		Version:    "1.4.0",
+		ConfigHash: "abc123",
+		LastRun: Run{
+			Start:    time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC),
+			Duration: 90 * time.Second,
+			Success:  false,
+			Error:    "core: could not sync",
+			Added:    2,
+			ScanIDs:  []string{"scan-123"},
+		},
+		NextRun: &next,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, *received, 1)
+	payload := (*received)[0]
+	assert.Equal(t, "1.4.0", payload["version"])
+	assert.Equal(t, "abc123", payload["config_hash"])
+	assert.Equal(t, "2026-01-02T04:00:00Z", payload["next_run"])
+	lastRun := payload["last_run"].(map[string]any)
+	assert.Equal(t, false, lastRun["success"])
+	assert.Equal(t, "core: could not sync", lastRun["error"])
+	assert.Equal(t, float64(90), lastRun["duration_seconds"])
+	assert.Equal(t, []any{"scan-123"}, lastRun["scan_ids"])
+}
+
+func TestSend_NoSchedule_OmitsNextRun(t *testing.T) {
+	server, received := newTestServer(t, http.StatusOK)
+	s := newTestSender(t, server.URL)
+
+	require.NoError(t, s.Send(context.Background(), Status{Version: "1.4.0", LastRun: Run{Success: true}}))
+
+	require.Len(t, *received, 1)
+	assert.NotContains(t, (*received)[0], "next_run")
+}
+
+func TestSend_ErrorStatus_Fails(t *testing.T) {
+	server, _ := newTestServer(t, http.StatusBadRequest)
+	s := newTestSender(t, server.URL)
+
+	err := s.Send(context.Background(), Status{})
+
+	assert.ErrorContains(t, err, "heartbeat: status URL returned status 400")
+}
+
+func TestSend_NoURL_DoesNothing(t *testing.T) {
+	s := newTestSender(t, "")
+
+	assert.NoError(t, s.Send(context.Background(), Status{}))
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+const (
+	jitterNone  = "none"
+	jitterEqual = "equal"
+)
+
+// Backoff is the exponential backoff with jitter between retries, shared by the HTTP service and
+// the ASM API client so both back off the same way
+type Backoff struct {
+	Base       time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     string
+}
+
+func NewBackoff(cfg *config.Config) *Backoff {
+	return &Backoff{
+		Base:       cfg.Http.RetryBaseDelay,
+		Max:        cfg.Http.RetryMaxDelay,
+		Multiplier: cfg.Http.RetryMultiplier,
+		Jitter:     cfg.Http.RetryJitter,
+	}
+}
+
+// Delay returns how long to wait before retrying after attempt, counting from 0. It is never less
+// than the base delay or more than the max delay.
+func (b *Backoff) Delay(attempt int) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	backoff := float64(b.Base) * math.Pow(multiplier, float64(attempt))
+	if b.Max > 0 && backoff > float64(b.Max) {
+		backoff = float64(b.Max)
+	}
+
+	var delay time.Duration
+	switch b.Jitter {
+	case jitterNone:
+		delay = time.Duration(backoff)
+	case jitterEqual:
+		delay = time.Duration(backoff/2 + rand.Float64()*backoff/2)
+	default:
+		delay = time.Duration(rand.Float64() * backoff)
+	}
+
+	return max(delay, b.Base)
+}
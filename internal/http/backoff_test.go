@@ -0,0 +1,43 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff_Delay_None(t *testing.T) {
+	b := &Backoff{Base: time.Second, Max: 10 * time.Second, Multiplier: 2, Jitter: "none"}
+
+	assert.Equal(t, time.Second, b.Delay(0))
+	assert.Equal(t, 2*time.Second, b.Delay(1))
+	assert.Equal(t, 8*time.Second, b.Delay(3))
+	assert.Equal(t, 10*time.Second, b.Delay(4))
+	assert.Equal(t, 10*time.Second, b.Delay(100))
+}
+
+func TestBackoff_Delay_Jitter(t *testing.T) {
+	tests := []struct {
+		jitter string
+		min    time.Duration
+	}{
+		{"full", time.Second},
+		{"equal", 4 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.jitter, func(t *testing.T) {
+			b := &Backoff{Base: time.Second, Max: 10 * time.Second, Multiplier: 2, Jitter: tt.jitter}
+
+			delays := map[time.Duration]bool{}
+			for range 100 {
+				delay := b.Delay(3)
+				assert.GreaterOrEqual(t, delay, tt.min)
+				assert.LessOrEqual(t, delay, 8*time.Second)
+				delays[delay] = true
+			}
+			assert.Greater(t, len(delays), 1, "delays should be randomised")
+		})
+	}
+}
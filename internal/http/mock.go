@@ -29,3 +29,27 @@ func (m *MockHttpService) Get(ctx context.Context, url string, options HttpOptio
 	}
 	return args.Get(0).(*MockHttpResponse), args.Error(1)
 }
+
+func (m *MockHttpService) Post(ctx context.Context, url string, body interface{}, options HttpOptions) (IHttpResponse, error) {
+	args := m.Called(url, body, options)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*MockHttpResponse), args.Error(1)
+}
+
+func (m *MockHttpService) Put(ctx context.Context, url string, body interface{}, options HttpOptions) (IHttpResponse, error) {
+	args := m.Called(url, body, options)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*MockHttpResponse), args.Error(1)
+}
+
+func (m *MockHttpService) Delete(ctx context.Context, url string, body interface{}, options HttpOptions) (IHttpResponse, error) {
+	args := m.Called(url, body, options)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*MockHttpResponse), args.Error(1)
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/metrics"
+)
+
+var retriesTotal = metrics.Default.Counter(
+	"cloud_connector_http_retries_total",
+	"Requests retried after a network error, 429 or 5xx response, by host",
+	"host",
+)
+
+// noRetryKey is the context key of requests that aren't retried
+type noRetryKey struct{}
+
+// WithoutRetry returns a context whose requests are sent once, for callers that retry them
+// themselves, so the retries don't multiply
+func WithoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// ShouldRetry is the retry policy of outbound requests. Network errors, 429 Too Many Requests and
+// server errors other than 501 Not Implemented are retried, unless the request was cancelled or
+// its context is WithoutRetry.
+func ShouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if noRetry, _ := ctx.Value(noRetryKey{}).(bool); noRetry {
+		return false
+	}
+
+	// Request failed with no response, likely recoverable (i.e. network error)
+	if err != nil || resp == nil {
+		return true
+	}
+
+	// 429 Too Many Requests should be recoverable with the retry and backoff
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	return resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented
+}
+
+// retryTransport retries requests with the policy and backoff of the HTTP service, for clients
+// such as the ASM SDK that take an http.Client rather than using the HTTP service
+type retryTransport struct {
+	retries  int
+	maxDelay time.Duration
+	backoff  *Backoff
+	next     http.RoundTripper
+}
+
+// NewRetryTransport returns a transport retrying requests to next as the HTTP service does, up to
+// http.retry_count times. The last response is returned once retries are exhausted.
+func NewRetryTransport(cfg *config.Config, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &retryTransport{
+		retries:  cfg.Http.RetryCount,
+		maxDelay: cfg.Http.RetryMaxDelay,
+		backoff:  NewBackoff(cfg),
+		next:     next,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	// The body is sent again with each attempt, so must be readable more than once
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.Body, _ = req.GetBody()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.retries || !ShouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+
+		delay, ok := RetryAfter(resp, t.maxDelay)
+		if !ok {
+			delay = t.backoff.Delay(attempt)
+		}
+		event := logger.GetLogger(ctx).Debug().Str("method", req.Method).Str("path", req.URL.Path).Int("attempt", attempt+1).Dur("delay", delay)
+		if err != nil {
+			event = event.Err(err)
+		} else {
+			event = event.Int("status_code", resp.StatusCode)
+		}
+		event.Msg("Request failed, retrying")
+		retriesTotal.Inc(req.URL.Host)
+
+		if resp != nil {
+			// Drained so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// timeNow is replaced in tests
+var timeNow = time.Now
+
+// RetryAfter returns how long a 429 or 503 response asks the client to wait before retrying,
+// from its Retry-After header in seconds or as an HTTP date, capped at maxDelay. ok is false if the
+// response has no valid Retry-After header, and the usual backoff should be used.
+func RetryAfter(resp *http.Response, maxDelay time.Duration) (delay time.Duration, ok bool) {
+	if resp == nil {
+		return 0, false
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(header, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		// A date in the past means the request can be retried straight away
+		delay = max(date.Sub(timeNow()), 0)
+	} else {
+		return 0, false
+	}
+
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay, true
+}
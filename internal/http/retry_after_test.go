@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	tests := []struct {
+		name       string
+		statusCode int
+		header     string
+		maxDelay   time.Duration
+		expected   time.Duration
+		ok         bool
+	}{
+		{"seconds", http.StatusTooManyRequests, "3", time.Minute, 3 * time.Second, true},
+		{"http date", http.StatusTooManyRequests, now.Add(10 * time.Second).Format(http.TimeFormat), time.Minute, 10 * time.Second, true},
+		{"past http date", http.StatusTooManyRequests, now.Add(-time.Minute).Format(http.TimeFormat), time.Minute, 0, true},
+		{"capped at max delay", http.StatusTooManyRequests, "120", 5 * time.Second, 5 * time.Second, true},
+		{"service unavailable", http.StatusServiceUnavailable, "2", time.Minute, 2 * time.Second, true},
+		{"no header", http.StatusTooManyRequests, "", time.Minute, 0, false},
+		{"invalid header", http.StatusTooManyRequests, "soon", time.Minute, 0, false},
+		{"negative seconds", http.StatusTooManyRequests, "-1", time.Minute, 0, false},
+		{"other status", http.StatusInternalServerError, "3", time.Minute, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.statusCode, Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+
+			delay, ok := RetryAfter(resp, tt.maxDelay)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, delay)
+		})
+	}
+}
+
+func TestRetryAfter_NilResponse(t *testing.T) {
+	_, ok := RetryAfter(nil, time.Minute)
+	assert.False(t, ok)
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldRetry(t *testing.T) {
+	ctx := context.Background()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	assert.True(t, ShouldRetry(ctx, nil, errors.New("connection reset")))
+	assert.True(t, ShouldRetry(ctx, &http.Response{StatusCode: http.StatusTooManyRequests}, nil))
+	assert.True(t, ShouldRetry(ctx, &http.Response{StatusCode: http.StatusBadGateway}, nil))
+	assert.False(t, ShouldRetry(ctx, &http.Response{StatusCode: http.StatusNotImplemented}, nil))
+	assert.False(t, ShouldRetry(ctx, &http.Response{StatusCode: http.StatusBadRequest}, nil))
+	assert.False(t, ShouldRetry(ctx, &http.Response{StatusCode: http.StatusOK}, nil))
+	assert.False(t, ShouldRetry(cancelled, nil, errors.New("connection reset")))
+	assert.False(t, ShouldRetry(WithoutRetry(ctx), &http.Response{StatusCode: http.StatusBadGateway}, nil))
+}
+
+func newRetryTestConfig(retries int) *config.Config {
+	cfg := &config.Config{}
+	cfg.Http.RetryCount = retries
+	cfg.Http.RetryBaseDelay = time.Millisecond
+	cfg.Http.RetryMaxDelay = time.Millisecond
+	return cfg
+}
+
+func TestRetryTransport_RetriesWithBody(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "seed", string(body))
+
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: NewRetryTransport(newRetryTestConfig(2), nil)}
+	// A reader without GetBody, which the transport has to buffer
+	req, err := http.NewRequest(http.MethodPost, server.URL, io.NopCloser(strings.NewReader("seed")))
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestRetryTransport_ReturnsLastResponse(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: NewRetryTransport(newRetryTestConfig(1), nil)}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestRetryTransport_NotRetried(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: NewRetryTransport(newRetryTestConfig(3), nil)}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RunIDHeader is sent with every request to the ASM API made during a run, so the requests of a
+// problem sync can be found by support
+const RunIDHeader = "X-Correlation-ID"
+
+type runIDKey struct{}
+
+// NewRunID returns a random ID for a run
+func NewRunID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRunID adds the ID of the run to a context
+func WithRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, id)
+}
+
+// RunID returns the ID of the run from the context, or "" if not set
+func RunID(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// runIDTransport sets the RunIDHeader of requests made with a run ID in their context
+type runIDTransport struct {
+	next http.RoundTripper
+}
+
+// NewRunIDTransport returns a transport setting the run ID header of requests, then sending them
+// with next. Only use it for requests to ASM, other services have no use for the ID.
+func NewRunIDTransport(next http.RoundTripper) http.RoundTripper {
+	return &runIDTransport{next: next}
+}
+
+func (t *runIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := RunID(req.Context())
+	if id == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set(RunIDHeader, id)
+	return t.next.RoundTrip(req)
+}
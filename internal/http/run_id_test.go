@@ -0,0 +1,35 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunIDTransport(t *testing.T) {
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(RunIDHeader)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: NewRunIDTransport(http.DefaultTransport)}
+	get := func(ctx context.Context) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	id := NewRunID()
+	get(WithRunID(context.Background(), id))
+	assert.Equal(t, id, header)
+
+	get(context.Background())
+	assert.Empty(t, header)
+}
@@ -3,8 +3,10 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/hexiosec/asm-cloud-connector/internal/config"
@@ -24,17 +26,37 @@ func (*requestLogger) Debugf(format string, v ...interface{}) {
 	logger.GetGlobalLogger().Debug().Bool("is_resty_err", true).Msgf(format, v...)
 }
 
+// RawBody is a request body sent as it is, rather than encoded as JSON
+type RawBody struct {
+	ContentType string
+	Data        []byte
+}
+
 type IHttpService interface {
 	Get(ctx context.Context, url string, options HttpOptions) (IHttpResponse, error)
+	// Post, Put and Delete send body encoded as JSON, or as it is if it's a RawBody, no body is sent
+	// if it is nil
+	Post(ctx context.Context, url string, body interface{}, options HttpOptions) (IHttpResponse, error)
+	Put(ctx context.Context, url string, body interface{}, options HttpOptions) (IHttpResponse, error)
+	Delete(ctx context.Context, url string, body interface{}, options HttpOptions) (IHttpResponse, error)
 }
 
 type HttpService struct {
 	client    *resty.Client
 	userAgent string
+	timeout   time.Duration
 }
 
-func NewHttpService(config *config.Config, userAgent string) IHttpService {
-	client := resty.New()
+func NewHttpService(config *config.Config, userAgent string) (IHttpService, error) {
+	transport, err := NewTransport(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Not sent with the run ID, which is only for the ASM API client, as the service calls third
+	// parties such as GitHub and webhooks
+	client := resty.New().SetTransport(NewTraceTransport(config, transport))
+	backoff := NewBackoff(config)
 
 	// Configure automatic retry
 	client.
@@ -42,35 +64,72 @@ func NewHttpService(config *config.Config, userAgent string) IHttpService {
 		SetRetryCount(config.Http.RetryCount).          // Maximum retries
 		SetRetryWaitTime(config.Http.RetryBaseDelay).   // Initial backoff time
 		SetRetryMaxWaitTime(config.Http.RetryMaxDelay). // Maximum backoff time
+		SetRetryAfter(func(_ *resty.Client, r *resty.Response) (time.Duration, error) {
+			// Only called before a retry, unlike the retry hooks
+			if r.Request.RawRequest != nil {
+				retriesTotal.Inc(r.Request.RawRequest.URL.Host)
+			}
+			// Wait as long as a rate limited response asks, otherwise back off
+			if delay, ok := RetryAfter(r.RawResponse, config.Http.RetryMaxDelay); ok {
+				logger.GetLogger(r.Request.Context()).Debug().Dur("delay", delay).Msg("Retrying after delay from Retry-After header")
+				return delay, nil
+			}
+			return backoff.Delay(r.Request.Attempt - 1), nil
+		}).
 		AddRetryCondition(
 			func(r *resty.Response, err error) bool {
-				// Request failed with no response, likely recoverable (i.e. network error)
-				if err != nil {
-					return true
-				}
-
-				// 429 Too Many Requests should be recoverable with the retry and backoff
-				if r.StatusCode() == http.StatusTooManyRequests {
-					return true
-				}
-
-				if r.StatusCode() >= 500 && r.StatusCode() != http.StatusNotImplemented {
+				retry := ShouldRetry(r.Request.Context(), r.RawResponse, err)
+				if retry && err == nil {
 					logger.GetLogger(r.Request.Context()).Debug().Int("status_code", r.StatusCode()).Msg("Unexpected HTTP status, retrying")
-					return true
 				}
-
-				return false
+				return retry
 			},
 		)
 
 	return &HttpService{
 		client:    client,
 		userAgent: userAgent,
-	}
+		timeout:   config.Http.Timeout,
+	}, nil
 }
 
 // Get performs a GET request to the given URL.
 func (s *HttpService) Get(ctx context.Context, url string, options HttpOptions) (IHttpResponse, error) {
+	return s.do(ctx, http.MethodGet, url, nil, options)
+}
+
+// Post performs a POST request to the given URL, with body encoded as JSON.
+func (s *HttpService) Post(ctx context.Context, url string, body interface{}, options HttpOptions) (IHttpResponse, error) {
+	return s.do(ctx, http.MethodPost, url, body, options)
+}
+
+// Put performs a PUT request to the given URL, with body encoded as JSON.
+func (s *HttpService) Put(ctx context.Context, url string, body interface{}, options HttpOptions) (IHttpResponse, error) {
+	return s.do(ctx, http.MethodPut, url, body, options)
+}
+
+// Delete performs a DELETE request to the given URL, with body encoded as JSON if not nil.
+func (s *HttpService) Delete(ctx context.Context, url string, body interface{}, options HttpOptions) (IHttpResponse, error) {
+	return s.do(ctx, http.MethodDelete, url, body, options)
+}
+
+// do sends a request with the retry policy of the client, and decodes JSON and text responses
+func (s *HttpService) do(ctx context.Context, method string, url string, body interface{}, options HttpOptions) (IHttpResponse, error) {
+	// Without a deadline, a stalled endpoint would hang the run
+	timeout := s.timeout
+	if options.Timeout > 0 {
+		timeout = options.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if options.NoRetry {
+		ctx = WithoutRetry(ctx)
+	}
+
 	req := s.client.R()
 
 	req.SetHeaders(options.Headers)
@@ -78,27 +137,40 @@ func (s *HttpService) Get(ctx context.Context, url string, options HttpOptions)
 	req.SetQueryParams(options.QueryParams)
 	req.SetContext(ctx)
 
-	httpRes, err := req.Get(url)
+	if raw, ok := body.(RawBody); ok {
+		req.SetHeader("Content-Type", raw.ContentType)
+		req.SetBody(raw.Data)
+	} else if body != nil {
+		// Encoded up front, so every retry sends the same bytes
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("http: could not encode request body, %w", err)
+		}
+		req.SetHeader("Content-Type", "application/json")
+		req.SetBody(encoded)
+	}
+
+	httpRes, err := req.Execute(method, url)
 	if err != nil {
 		return nil, err
 	}
 
 	contentType := strings.ToLower(httpRes.Header().Get("Content-Type"))
 
-	var body interface{}
+	var resBody interface{}
 	if strings.HasPrefix(contentType, "application/json") {
-		if err := json.Unmarshal(httpRes.Body(), &body); err != nil {
+		if err := json.Unmarshal(httpRes.Body(), &resBody); err != nil {
 			return nil, err
 		}
 	} else if strings.HasPrefix(contentType, "text/plain") {
 		// Look for header prefix to make sure 'text/plain; charset=utf-8' is captured
-		body = string(httpRes.Body())
+		resBody = string(httpRes.Body())
 	}
 
 	return &HttpResponse{
 		StatusCode: httpRes.StatusCode(),
 		RawBody:    httpRes.Body(),
-		Body:       body,
+		Body:       resBody,
 		Header:     httpRes.Header(),
 	}, nil
 }
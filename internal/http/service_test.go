@@ -0,0 +1,203 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpService_Post_EncodesJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "test-agent", r.Header.Get("User-Agent"))
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "bar", body["foo"])
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	svc, err := NewHttpService(&config.Config{}, "test-agent")
+	require.NoError(t, err)
+
+	res, err := svc.Post(context.Background(), server.URL, map[string]string{"foo": "bar"}, HttpOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.GetStatusCode())
+	assert.Equal(t, map[string]interface{}{"ok": true}, res.GetBody())
+}
+
+func TestHttpService_Post_RawBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "text/plain", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "line 1\nline 2\n", string(body))
+	}))
+	t.Cleanup(server.Close)
+
+	svc, err := NewHttpService(&config.Config{}, "test-agent")
+	require.NoError(t, err)
+
+	res, err := svc.Post(context.Background(), server.URL, RawBody{ContentType: "text/plain", Data: []byte("line 1\nline 2\n")}, HttpOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.GetStatusCode())
+}
+
+func TestHttpService_Put_RetriesWithBody(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"foo":"bar"}`, string(body))
+
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Http.RetryCount = 1
+	cfg.Http.RetryBaseDelay = time.Millisecond
+	cfg.Http.RetryMaxDelay = time.Millisecond
+
+	svc, err := NewHttpService(cfg, "test-agent")
+	require.NoError(t, err)
+
+	res, err := svc.Put(context.Background(), server.URL, map[string]string{"foo": "bar"}, HttpOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, res.GetStatusCode())
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestHttpService_Post_NoRetry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Http.RetryCount = 2
+	cfg.Http.RetryBaseDelay = time.Millisecond
+	cfg.Http.RetryMaxDelay = time.Millisecond
+
+	svc, err := NewHttpService(cfg, "test-agent")
+	require.NoError(t, err)
+
+	res, err := svc.Post(context.Background(), server.URL, map[string]string{"foo": "bar"}, HttpOptions{NoRetry: true})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, res.GetStatusCode())
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestHttpService_Get_NoRunID(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+	}))
+	t.Cleanup(server.Close)
+
+	svc, err := NewHttpService(&config.Config{}, "test-agent")
+	require.NoError(t, err)
+
+	_, err = svc.Get(WithRunID(context.Background(), "run-123"), server.URL, HttpOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "test-agent", header.Get("User-Agent"))
+	assert.Empty(t, header.Get(RunIDHeader))
+}
+
+func TestHttpService_Delete_NoBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		assert.Empty(t, r.Header.Get("Content-Type"))
+		assert.Equal(t, "1", r.URL.Query().Get("id"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	svc, err := NewHttpService(&config.Config{}, "test-agent")
+	require.NoError(t, err)
+
+	res, err := svc.Delete(context.Background(), server.URL, nil, HttpOptions{QueryParams: map[string]string{"id": "1"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, res.GetStatusCode())
+}
+
+func TestHttpService_Post_InvalidBody_Fails(t *testing.T) {
+	svc, err := NewHttpService(&config.Config{}, "test-agent")
+	require.NoError(t, err)
+
+	_, err = svc.Post(context.Background(), "http://localhost", map[string]interface{}{"ch": make(chan int)}, HttpOptions{})
+	assert.ErrorContains(t, err, "could not encode request body")
+}
+
+func TestHttpService_Get_HonoursRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Http.RetryCount = 1
+	cfg.Http.RetryBaseDelay = time.Millisecond
+	cfg.Http.RetryMaxDelay = 200 * time.Millisecond
+
+	svc, err := NewHttpService(cfg, "test-agent")
+	require.NoError(t, err)
+
+	start := time.Now()
+	res, err := svc.Get(context.Background(), server.URL, HttpOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.GetStatusCode())
+	// Retry-After is capped at the max delay, rather than waiting two minutes
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestHttpService_Get_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	cfg := &config.Config{}
+	cfg.Http.Timeout = time.Minute
+
+	svc, err := NewHttpService(cfg, "test-agent")
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = svc.Get(context.Background(), server.URL, HttpOptions{Timeout: 50 * time.Millisecond})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
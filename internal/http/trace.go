@@ -0,0 +1,138 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+// traceBodyLimit is the number of bytes of request and response bodies logged
+const traceBodyLimit = 2048
+
+const redacted = "[REDACTED]"
+
+// redactedHeaders carry credentials, their values are never logged
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "X-Hexiosec-Api-Key", "X-Api-Key", "Api-Key", "Cookie", "Set-Cookie"}
+
+// secretParams matches the names of query params holding credentials, e.g. a SAS signature
+var secretParams = regexp.MustCompile(`(?i)key|token|secret|password|sig`)
+
+// secretFields matches credentials in JSON and form encoded bodies, e.g. an OAuth token request,
+// capturing everything before the value
+var secretFields = regexp.MustCompile(`(?i)("?(?:client_secret|access_token|refresh_token|id_token|api_key|apikey|password|secret)"?\s*[:=]\s*"?)[^"&,\s}]+`)
+
+// traceTransport logs each request and response, with credentials redacted, to debug proxies and
+// 4xx responses without exposing secrets in the logs
+type traceTransport struct {
+	next http.RoundTripper
+}
+
+// NewTraceTransport returns next logging requests and responses if log.http_trace is set, or next
+// as it is otherwise
+func NewTraceTransport(cfg *config.Config, next http.RoundTripper) http.RoundTripper {
+	if !cfg.Log.HTTPTrace {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &traceTransport{next: next}
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	event := logger.GetLogger(req.Context()).Info().
+		Str("method", req.Method).
+		Str("url", redactURL(req)).
+		Dur("duration", time.Since(start)).
+		Interface("request_headers", redactHeaders(req.Header)).
+		Str("request_body", redactBody(reqBody))
+	if err != nil {
+		event.Err(err).Msg("HTTP request failed")
+		return resp, err
+	}
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	var body io.Reader = bytes.NewReader(respBody)
+	if readErr != nil {
+		// The caller gets the error once it has read what was received
+		body = io.MultiReader(body, &errReader{readErr})
+	}
+	resp.Body = io.NopCloser(body)
+
+	event.
+		Int("status_code", resp.StatusCode).
+		Interface("response_headers", redactHeaders(resp.Header)).
+		Str("response_body", redactBody(respBody)).
+		Msg("HTTP request")
+
+	return resp, nil
+}
+
+// redactURL returns the URL of req with its user info and credential query params removed
+func redactURL(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	query := u.Query()
+	for key := range query {
+		if secretParams.MatchString(key) {
+			query.Set(key, redacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		headers[name] = strings.Join(values, ", ")
+	}
+	for _, name := range redactedHeaders {
+		if _, ok := header[http.CanonicalHeaderKey(name)]; ok {
+			headers[http.CanonicalHeaderKey(name)] = redacted
+		}
+	}
+	return headers
+}
+
+// redactBody returns up to traceBodyLimit bytes of body with credential fields redacted
+func redactBody(body []byte) string {
+	truncated := len(body) > traceBodyLimit
+	if truncated {
+		body = body[:traceBodyLimit]
+	}
+	s := secretFields.ReplaceAllString(string(body), "${1}"+redacted)
+	if truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTraceTransport_Disabled(t *testing.T) {
+	next := http.DefaultTransport
+	assert.Equal(t, next, NewTraceTransport(&config.Config{}, next))
+}
+
+func TestTraceTransport_Redacts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		// The body is still sent after being logged
+		assert.Equal(t, "grant_type=client_credentials&client_secret=hunter2", string(body))
+
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"tok123","expires_in":3600}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Log.HTTPTrace = true
+	client := &http.Client{Transport: NewTraceTransport(cfg, nil)}
+
+	buf := &bytes.Buffer{}
+	ctx := logger.WithLogger(context.Background(), zerolog.New(buf))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/token?scope=asm&sig=abc", strings.NewReader("grant_type=client_credentials&client_secret=hunter2"))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Basic c2VjcmV0")
+	req.Header.Set("X-Hexiosec-API-Key", "key123")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	// The response body can still be read after being logged
+	assert.Contains(t, string(body), "tok123")
+
+	log := buf.String()
+	assert.Contains(t, log, `"status_code":200`)
+	assert.Contains(t, log, "scope=asm")
+	assert.Contains(t, log, "expires_in")
+	for _, secret := range []string{"c2VjcmV0", "key123", "hunter2", "tok123", "session=abc", "sig=abc"} {
+		assert.NotContains(t, log, secret)
+	}
+}
+
+func TestRedactBody_Truncates(t *testing.T) {
+	body := redactBody(bytes.Repeat([]byte("a"), traceBodyLimit+10))
+	assert.Len(t, body, traceBodyLimit+len("...(truncated)"))
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// NewTransport returns the transport for outbound requests, with the proxy and TLS settings of
+// the network config. Without a proxy URL the HTTPS_PROXY, HTTP_PROXY and NO_PROXY env vars apply,
+// with the hosts of network.no_proxy added to NO_PROXY.
+func NewTransport(cfg *config.Config) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Network.ProxyURL != "" || len(cfg.Network.NoProxy) > 0 {
+		proxyConfig := httpproxy.FromEnvironment()
+		noProxy := cfg.Network.NoProxy
+		if cfg.Network.ProxyURL != "" {
+			proxyConfig.HTTPProxy = cfg.Network.ProxyURL
+			proxyConfig.HTTPSProxy = cfg.Network.ProxyURL
+		} else if proxyConfig.NoProxy != "" {
+			noProxy = append([]string{proxyConfig.NoProxy}, noProxy...)
+		}
+		proxyConfig.NoProxy = strings.Join(noProxy, ",")
+
+		proxy := proxyConfig.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxy(req.URL)
+		}
+	}
+
+	tlsConfig := &tls.Config{MinVersion: minTLSVersion(cfg.Network.MinTLSVersion)}
+	if err := tlsmode.Restrict(tlsConfig, cfg.Network.TLSMode); err != nil {
+		return nil, err
+	}
+
+	if cfg.Network.CABundle != "" {
+		// Trust the bundle in addition to the system CAs, so only the proxy's CA needs adding
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if err := appendCABundle(pool, cfg.Network.CABundle); err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.Network.InsecureSkipVerify {
+		logger.GetGlobalLogger().Warn().Msg("TLS certificate verification is disabled by network.insecure_skip_verify")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// NewASMTransport returns the transport for requests to the ASM API, the transport of NewTransport
+// with the CA bundle and client certificate of the asm config, e.g. to reach ASM through an egress
+// gateway enforcing mTLS.
+func NewASMTransport(cfg *config.Config) (*http.Transport, error) {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ASM.CABundle == "" && cfg.ASM.ClientCert == "" {
+		return transport, nil
+	}
+
+	tlsConfig := transport.TLSClientConfig.Clone()
+
+	if cfg.ASM.CABundle != "" {
+		// Added to the network CA bundle, if there is one, as the proxy may still intercept ASM requests
+		pool := tlsConfig.RootCAs
+		if pool == nil {
+			pool, err = x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+		} else {
+			pool = pool.Clone()
+		}
+		if err := appendCABundle(pool, cfg.ASM.CABundle); err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ASM.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ASM.ClientCert, cfg.ASM.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("http: failed to load client certificate %s, %w", cfg.ASM.ClientCert, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// minTLSVersion returns the TLS version of network.min_tls_version, TLS 1.2 if it isn't set
+func minTLSVersion(version string) uint16 {
+	if version == "1.3" {
+		return tls.VersionTLS13
+	}
+	return tls.VersionTLS12
+}
+
+// appendCABundle adds the PEM certificates of the bundle file to pool
+func appendCABundle(pool *x509.CertPool, path string) error {
+	bundle, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("http: failed to read CA bundle, %w", err)
+	}
+	if !pool.AppendCertsFromPEM(bundle) {
+		return fmt.Errorf("http: no PEM certificates found in CA bundle %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/fips140"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/tlsmode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransport_Proxy(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Network.ProxyURL = "http://proxy.internal:3128"
+	cfg.Network.NoProxy = []string{".example.internal"}
+
+	transport, err := NewTransport(cfg)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "https://asm.hexiosec.com/api", nil)
+	require.NoError(t, err)
+	proxy, err := transport.Proxy(req)
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.internal:3128", proxy.String())
+
+	req, err = http.NewRequest(http.MethodGet, "https://hooks.example.internal/webhook", nil)
+	require.NoError(t, err)
+	proxy, err = transport.Proxy(req)
+	require.NoError(t, err)
+	assert.Nil(t, proxy)
+}
+
+func TestNewTransport_NoProxy_EnvProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://env-proxy.internal:3128")
+	t.Setenv("NO_PROXY", "metadata.internal")
+	cfg := &config.Config{}
+	cfg.Network.NoProxy = []string{".example.internal"}
+
+	transport, err := NewTransport(cfg)
+	require.NoError(t, err)
+
+	proxyOf := func(target string) *url.URL {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		require.NoError(t, err)
+		proxy, err := transport.Proxy(req)
+		require.NoError(t, err)
+		return proxy
+	}
+	assert.Equal(t, "http://env-proxy.internal:3128", proxyOf("https://asm.hexiosec.com/api").String())
+	assert.Nil(t, proxyOf("https://hooks.example.internal/webhook"))
+	assert.Nil(t, proxyOf("https://metadata.internal/token"))
+}
+
+func TestNewTransport_CABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(bundle, cert, 0600))
+
+	// Untrusted without the bundle
+	transport, err := NewTransport(&config.Config{})
+	require.NoError(t, err)
+	_, err = (&http.Client{Transport: transport}).Get(server.URL)
+	assert.Error(t, err)
+
+	cfg := &config.Config{}
+	cfg.Network.CABundle = bundle
+	transport, err = NewTransport(cfg)
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestNewTransport_InvalidCABundle_Fails(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, []byte("not a certificate"), 0600))
+
+	cfg := &config.Config{}
+	cfg.Network.CABundle = bundle
+	_, err := NewTransport(cfg)
+	assert.ErrorContains(t, err, "no PEM certificates found in CA bundle")
+}
+
+func TestNewTransport_InsecureSkipVerify(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Network.InsecureSkipVerify = true
+
+	transport, err := NewTransport(cfg)
+	require.NoError(t, err)
+	assert.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+}
+
+func TestNewTransport_MinTLSVersion(t *testing.T) {
+	cfg := &config.Config{}
+
+	transport, err := NewTransport(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+
+	cfg.Network.MinTLSVersion = "1.3"
+	transport, err = NewTransport(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), transport.TLSClientConfig.MinVersion)
+}
+
+func TestNewTransport_TLSModeRestricted(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Network.TLSMode = "restricted"
+
+	transport, err := NewTransport(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+	assert.Equal(t, tlsmode.RestrictedCipherSuites, transport.TLSClientConfig.CipherSuites)
+	assert.Equal(t, tlsmode.RestrictedCurves, transport.TLSClientConfig.CurvePreferences)
+
+	// A higher min_tls_version is kept
+	cfg.Network.MinTLSVersion = "1.3"
+	transport, err = NewTransport(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), transport.TLSClientConfig.MinVersion)
+
+	// The ASM transport keeps the restrictions
+	asmTransport, err := NewASMTransport(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, tlsmode.RestrictedCipherSuites, asmTransport.TLSClientConfig.CipherSuites)
+}
+
+func TestNewTransport_TLSModeRestricted_Connects(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	cfg := &config.Config{}
+	cfg.Network.CABundle = bundle
+	cfg.Network.TLSMode = "restricted"
+	transport, err := NewTransport(cfg)
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.True(t, slices.Contains(tlsmode.RestrictedCipherSuites, resp.TLS.CipherSuite), tls.CipherSuiteName(resp.TLS.CipherSuite))
+}
+
+func TestNewTransport_TLSModeFIPS(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Network.TLSMode = "fips"
+
+	transport, err := NewTransport(cfg)
+	if !fips140.Enabled() {
+		assert.ErrorContains(t, err, "network.tls_mode fips needs Go's FIPS 140-3 mode")
+		return
+	}
+	require.NoError(t, err)
+	assert.Equal(t, tlsmode.RestrictedCipherSuites, transport.TLSClientConfig.CipherSuites)
+}
+
+func TestNewASMTransport_ClientCert(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "cloud-connector"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	// Rejected without the client certificate
+	cfg := &config.Config{}
+	cfg.ASM.CABundle = bundle
+	transport, err := NewASMTransport(cfg)
+	require.NoError(t, err)
+	_, err = (&http.Client{Transport: transport}).Get(server.URL)
+	assert.Error(t, err)
+
+	cfg.ASM.ClientCert = certFile
+	cfg.ASM.ClientKey = keyFile
+	transport, err = NewASMTransport(cfg)
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestNewASMTransport_InvalidClientCert_Fails(t *testing.T) {
+	cert := filepath.Join(t.TempDir(), "client.pem")
+	require.NoError(t, os.WriteFile(cert, []byte("not a certificate"), 0600))
+
+	cfg := &config.Config{}
+	cfg.ASM.ClientCert = cert
+	cfg.ASM.ClientKey = cert
+	_, err := NewASMTransport(cfg)
+	assert.ErrorContains(t, err, "failed to load client certificate")
+}
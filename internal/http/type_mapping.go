@@ -3,6 +3,7 @@ package http
 import (
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -10,6 +11,10 @@ import (
 type HttpOptions struct {
 	Headers     map[string]string
 	QueryParams map[string]string
+	// Timeout bounds the call, including retries, overriding http.timeout if set
+	Timeout time.Duration
+	// NoRetry sends the request once, for requests that aren't safe to repeat
+	NoRetry bool
 }
 
 type IHttpResponse interface {
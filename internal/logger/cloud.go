@@ -0,0 +1,144 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Formats of JSON logs with their fields mapped to the conventions of a cloud's log service
+const (
+	// FormatGCP maps the level, caller and run ID to the severity, source location and trace of
+	// Cloud Logging structured logs
+	FormatGCP = "gcp"
+	// FormatAzure maps the level and run ID to the severity level and operation ID of Azure Monitor
+	FormatAzure = "azure"
+)
+
+const (
+	gcpSeverityField       = "severity"
+	gcpSourceLocationField = "logging.googleapis.com/sourceLocation"
+	gcpTraceField          = "logging.googleapis.com/trace"
+	azureSeverityField     = "severityLevel"
+	azureOperationIDField  = "operation_Id"
+	// runIDField is the ID of the run each log of a run is tagged with, which is a 32 hex character
+	// W3C trace ID, so it's the trace of the log in both clouds
+	runIDField = "run_id"
+)
+
+// gcpSeverities are the Cloud Logging severities of the zerolog levels
+var gcpSeverities = map[string]string{
+	zerolog.TraceLevel.String(): "DEBUG",
+	zerolog.DebugLevel.String(): "DEBUG",
+	zerolog.InfoLevel.String():  "INFO",
+	zerolog.WarnLevel.String():  "WARNING",
+	zerolog.ErrorLevel.String(): "ERROR",
+	zerolog.FatalLevel.String(): "CRITICAL",
+	zerolog.PanicLevel.String(): "ALERT",
+}
+
+// azureSeverities are the Azure Monitor severity levels of the zerolog levels, from 0 (verbose) to
+// 4 (critical)
+var azureSeverities = map[string]int{
+	zerolog.TraceLevel.String(): 0,
+	zerolog.DebugLevel.String(): 0,
+	zerolog.InfoLevel.String():  1,
+	zerolog.WarnLevel.String():  2,
+	zerolog.ErrorLevel.String(): 3,
+	zerolog.FatalLevel.String(): 4,
+	zerolog.PanicLevel.String(): 4,
+}
+
+// cloudWriter maps the fields of each JSON log event written to it to the conventions of a cloud's
+// log service. zerolog's field names are global, so rather than renaming them for every logger the
+// event is rewritten once it's encoded.
+type cloudWriter struct {
+	out    io.Writer
+	format string
+	// gcpProject is the project the trace of a Cloud Logging log is in, no trace is set if empty
+	gcpProject string
+}
+
+// NewCloudWriter returns a writer mapping the fields of the JSON logs written to it to the
+// conventions of format, FormatGCP or FormatAzure, before writing them to out. gcpProject is the
+// Google Cloud project the traces of FormatGCP logs are in.
+func NewCloudWriter(out io.Writer, format string, gcpProject string) io.Writer {
+	return &cloudWriter{out: out, format: format, gcpProject: gcpProject}
+}
+
+func (w *cloudWriter) Write(p []byte) (int, error) {
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(p, &event); err != nil {
+		// Not a JSON event, written as is
+		return w.out.Write(p)
+	}
+
+	switch w.format {
+	case FormatGCP:
+		w.mapGCP(event)
+	case FormatAzure:
+		w.mapAzure(event)
+	}
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return w.out.Write(p)
+	}
+	if _, err := w.out.Write(append(encoded, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *cloudWriter) mapGCP(event map[string]json.RawMessage) {
+	if severity, ok := gcpSeverities[stringField(event, zerolog.LevelFieldName)]; ok {
+		event[gcpSeverityField] = encodeField(severity)
+		delete(event, zerolog.LevelFieldName)
+	}
+
+	// The caller is file:line, the file may contain colons on Windows
+	if caller := stringField(event, zerolog.CallerFieldName); caller != "" {
+		if idx := strings.LastIndex(caller, ":"); idx > 0 {
+			event[gcpSourceLocationField] = encodeField(map[string]string{
+				"file": caller[:idx],
+				"line": caller[idx+1:],
+			})
+			delete(event, zerolog.CallerFieldName)
+		}
+	}
+
+	if runID := stringField(event, runIDField); runID != "" && w.gcpProject != "" {
+		event[gcpTraceField] = encodeField("projects/" + w.gcpProject + "/traces/" + runID)
+	}
+}
+
+func (w *cloudWriter) mapAzure(event map[string]json.RawMessage) {
+	if severity, ok := azureSeverities[stringField(event, zerolog.LevelFieldName)]; ok {
+		event[azureSeverityField] = encodeField(severity)
+		delete(event, zerolog.LevelFieldName)
+	}
+
+	if runID := stringField(event, runIDField); runID != "" {
+		event[azureOperationIDField] = encodeField(runID)
+	}
+}
+
+// stringField returns the string field name of event, or "" if it's not set or not a string
+func stringField(event map[string]json.RawMessage, name string) string {
+	raw, ok := event[name]
+	if !ok || !bytes.HasPrefix(raw, []byte(`"`)) {
+		return ""
+	}
+	var s string
+	_ = json.Unmarshal(raw, &s)
+	return s
+}
+
+// encodeField encodes the value of a field, a string, int or map of strings, which can't fail
+func encodeField(v any) json.RawMessage {
+	encoded, _ := json.Marshal(v)
+	return encoded
+}
@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRunID = "4f0c2a9b8d7e6f5a4b3c2d1e0f9a8b7c"
+
+func TestCloudWriter_GCP_MapsSeverityAndTrace(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := zerolog.New(NewCloudWriter(out, FormatGCP, "my-project")).With().Str("run_id", testRunID).Caller().Logger()
+
+	l.Warn().Str("account", "123456789012").Msg("Could not get EC2 instances")
+
+	events := messages(t, out)
+	require.Len(t, events, 1)
+	event := events[0]
+	assert.Equal(t, "WARNING", event["severity"])
+	assert.NotContains(t, event, "level")
+	assert.Equal(t, "Could not get EC2 instances", event["message"])
+	assert.Equal(t, "123456789012", event["account"])
+	assert.Equal(t, testRunID, event["run_id"])
+	assert.Equal(t, "projects/my-project/traces/"+testRunID, event["logging.googleapis.com/trace"])
+
+	location := event["logging.googleapis.com/sourceLocation"].(map[string]any)
+	assert.Contains(t, location["file"], "cloud_test.go")
+	assert.NotEmpty(t, location["line"])
+	assert.NotContains(t, event, "caller")
+}
+
+func TestCloudWriter_GCP_NoProject_NoTrace(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := zerolog.New(NewCloudWriter(out, FormatGCP, "")).With().Str("run_id", testRunID).Logger()
+
+	l.Error().Msg("failed to run")
+
+	events := messages(t, out)
+	require.Len(t, events, 1)
+	assert.Equal(t, "ERROR", events[0]["severity"])
+	assert.NotContains(t, events[0], "logging.googleapis.com/trace")
+}
+
+func TestCloudWriter_Azure_MapsSeverityAndOperation(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := zerolog.New(NewCloudWriter(out, FormatAzure, "")).With().Str("run_id", testRunID).Logger()
+
+	l.Info().Msg("Run summary")
+
+	events := messages(t, out)
+	require.Len(t, events, 1)
+	assert.Equal(t, float64(1), events[0]["severityLevel"])
+	assert.NotContains(t, events[0], "level")
+	assert.Equal(t, testRunID, events[0]["operation_Id"])
+}
+
+func TestCloudWriter_NotJSON_WrittenAsIs(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := NewCloudWriter(out, FormatGCP, "my-project")
+
+	n, err := w.Write([]byte("not json\n"))
+
+	require.NoError(t, err)
+	assert.Equal(t, 9, n)
+	assert.Equal(t, "not json\n", out.String())
+}
@@ -3,6 +3,8 @@ package logger
 
 import (
 	"context"
+	"fmt"
+	"io"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -23,38 +25,65 @@ func GetGlobalLogger() *zerolog.Logger {
 	return &log.Logger
 }
 
-// WithLogger adds a logger to a context
-func WithLogger(parent context.Context, logger zerolog.Logger) context.Context {
-	return context.WithValue(parent, loggerKey{}, &logger)
-}
+// Setup configures the global logger to write to out at level, as human readable console output
+// rather than JSON if console is true
+func Setup(level string, console bool, out io.Writer) error {
+	logLevel, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("logger: could not parse log level, %w", err)
+	}
+	// The logger is filtered by level, the global level only as far as info so summaries are
+	// logged at any level
+	zerolog.SetGlobalLevel(min(logLevel, zerolog.InfoLevel))
 
-// Wrapper to map go-retryablehttp logger
-type RetryableLogger struct{}
+	// Secrets are redacted from the output, whatever its format
+	out = NewRedactWriter(out)
+	if console {
+		out = zerolog.ConsoleWriter{Out: out}
+	}
 
-func (*RetryableLogger) Error(msg string, keysAndValues ...interface{}) {
-	logRetryable(GetGlobalLogger().Warn(), msg, keysAndValues) // Downgrade
-}
-func (*RetryableLogger) Info(msg string, keysAndValues ...interface{}) {
-	logRetryable(GetGlobalLogger().Debug(), msg, keysAndValues) // Downgrade
-}
-func (*RetryableLogger) Debug(msg string, keysAndValues ...interface{}) {
-	logRetryable(GetGlobalLogger().Trace(), msg, keysAndValues) // Downgrade
+	// Add file and line number to log output
+	log.Logger = zerolog.New(out).Level(logLevel).With().Timestamp().Caller().Logger()
+
+	return nil
 }
-func (*RetryableLogger) Warn(msg string, keysAndValues ...interface{}) {
-	logRetryable(GetGlobalLogger().Warn(), msg, keysAndValues) // Downgrade
+
+// New returns a logger writing to out at level, rather than the global logger, for a run with its
+// own log config, e.g. one of several configs run together. The global level is lowered to level if
+// needed, pinning the global logger to the previous level, so the logger isn't filtered by it.
+// It isn't safe to call concurrently.
+func New(level string, console bool, out io.Writer) (zerolog.Logger, error) {
+	logLevel, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return zerolog.Logger{}, fmt.Errorf("logger: could not parse log level, %w", err)
+	}
+	if global := zerolog.GlobalLevel(); min(logLevel, zerolog.InfoLevel) < global {
+		log.Logger = log.Logger.Level(max(global, log.Logger.GetLevel()))
+		zerolog.SetGlobalLevel(min(logLevel, zerolog.InfoLevel))
+	}
+
+	// Secrets are redacted from the output, whatever its format
+	out = NewRedactWriter(out)
+	if console {
+		out = zerolog.ConsoleWriter{Out: out}
+	}
+
+	return zerolog.New(out).Level(logLevel).With().Timestamp().Caller().Logger(), nil
 }
 
-func logRetryable(e *zerolog.Event, msg string, keysAndValues []interface{}) {
-	for i := 0; i < len(keysAndValues)-1; i += 2 {
-		key, ok := keysAndValues[i].(string)
-		if !ok {
-			continue
-		}
-		if i+1 >= len(keysAndValues) {
-			continue
-		}
-		e.Interface(key, keysAndValues[i+1])
+// Summary returns the logger of ctx for the summary of a run, which is logged at info even if the
+// level is higher, e.g. error for --quiet, so a quiet run still logs its outcome. Nothing is logged
+// if logging is disabled.
+func Summary(ctx context.Context) *zerolog.Logger {
+	l := GetLogger(ctx)
+	if l.GetLevel() <= zerolog.InfoLevel || l.GetLevel() == zerolog.Disabled {
+		return l
 	}
-	e.Bool("is_retryable_http_log", true)
-	e.Msg(msg)
+	summary := l.Level(zerolog.InfoLevel)
+	return &summary
+}
+
+// WithLogger adds a logger to a context
+func WithLogger(parent context.Context, logger zerolog.Logger) context.Context {
+	return context.WithValue(parent, loggerKey{}, &logger)
 }
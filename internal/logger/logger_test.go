@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummary_LevelAboveInfo_Logged(t *testing.T) {
+	out := &bytes.Buffer{}
+	ctx := WithLogger(context.Background(), zerolog.New(out).Level(zerolog.ErrorLevel))
+
+	GetLogger(ctx).Info().Msg("Getting cloud resources")
+	Summary(ctx).Info().Msg("Run summary")
+	// Debug stays filtered out
+	Summary(ctx).Debug().Msg("Summary detail")
+
+	events := messages(t, out)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Run summary", events[0]["message"])
+	assert.Equal(t, "info", events[0]["level"])
+}
+
+func TestSummary_Disabled_NotLogged(t *testing.T) {
+	out := &bytes.Buffer{}
+	ctx := WithLogger(context.Background(), zerolog.New(out).Level(zerolog.Disabled))
+
+	Summary(ctx).Info().Msg("Run summary")
+
+	assert.Empty(t, out.String())
+}
+
+func TestSetup_LevelError_SummaryLogged(t *testing.T) {
+	previous, level := log.Logger, zerolog.GlobalLevel()
+	t.Cleanup(func() {
+		log.Logger = previous
+		zerolog.SetGlobalLevel(level)
+	})
+	out := &bytes.Buffer{}
+	require.NoError(t, Setup("error", false, out))
+
+	GetGlobalLogger().Warn().Msg("Could not load .env file")
+	Summary(context.Background()).Info().Msg("Run summary")
+	GetGlobalLogger().Error().Msg("failed to run")
+
+	events := messages(t, out)
+	require.Len(t, events, 2)
+	assert.Equal(t, "Run summary", events[0]["message"])
+	assert.Equal(t, "failed to run", events[1]["message"])
+}
@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Privacy modes of the hostnames and IP addresses in logs
+const (
+	PrivacyOff    = "off"
+	PrivacyHash   = "hash"
+	PrivacyRedact = "redact"
+)
+
+// hashLength is the number of hex characters of a hash kept in logs, enough to tell the hostnames of
+// a run apart
+const hashLength = 12
+
+var (
+	// hostPattern matches hostnames, checked against the top-level domains so identifiers like
+	// json.Unmarshal or aws.go aren't taken for one
+	hostPattern = regexp.MustCompile(`\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}\b`)
+	// ipPattern matches IPv4 and IPv6 addresses and CIDR ranges, checked by parsing them so times
+	// like 12:30:00 aren't taken for one
+	ipPattern = regexp.MustCompile(`(?i)\b[0-9a-f]*(?::[0-9a-f]*){2,7}(?:/\d{1,3})?|\b\d{1,3}(?:\.\d{1,3}){3}(?:/\d{1,2})?\b`)
+	// awsEndpointPattern matches the AWS API endpoints, e.g. ec2.eu-west-2.amazonaws.com, rather than
+	// the hostnames of resources under amazonaws.com
+	awsEndpointPattern = regexp.MustCompile(`^[a-z0-9-]+(?:\.[a-z]{2}(?:-gov)?-[a-z]+-\d)?\.amazonaws\.com$`)
+)
+
+// apiHosts are the APIs the connector calls, kept in logs for the context of their errors
+var apiHosts = []string{"api.github.com", "github.com", "hub.docker.com", "login.microsoftonline.com", "management.azure.com"}
+
+// privacyWriter replaces the hostnames and IP addresses of the log events written to it, e.g. the
+// resources discovered, for logs shipped somewhere less trusted than the asset inventory. Counts,
+// levels and error messages are left as they are.
+type privacyWriter struct {
+	out  io.Writer
+	mode string
+	key  []byte
+	keep map[string]struct{}
+}
+
+// NewPrivacyWriter returns a writer replacing the hostnames and IP addresses written to it before
+// writing them to out, with a hash keyed with key for PrivacyHash or a placeholder for
+// PrivacyRedact. Without a key a random one is used, so hashes can't be matched across runs. The
+// APIs the connector calls and keep, e.g. the host of the ASM API, aren't replaced.
+func NewPrivacyWriter(out io.Writer, mode string, key string, keep ...string) io.Writer {
+	if mode != PrivacyHash && mode != PrivacyRedact {
+		return out
+	}
+
+	w := &privacyWriter{out: out, mode: mode, key: []byte(key), keep: map[string]struct{}{}}
+	if key == "" {
+		w.key = make([]byte, 32)
+		_, _ = rand.Read(w.key)
+	}
+	for _, host := range slices.Concat(keep, apiHosts) {
+		w.keep[strings.ToLower(host)] = struct{}{}
+	}
+	return w
+}
+
+func (w *privacyWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, w.replace(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// replace returns s with its hostnames and IP addresses replaced
+func (w *privacyWriter) replace(s string) string {
+	s = ipPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if !isIP(match) {
+			return match
+		}
+		return w.placeholder("ip", match)
+	})
+	return hostPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if !w.isResourceHost(match) {
+			return match
+		}
+		return w.placeholder("host", match)
+	})
+}
+
+// placeholder returns what value, an IP address or hostname, is replaced with
+func (w *privacyWriter) placeholder(kind string, value string) string {
+	if w.mode == PrivacyRedact {
+		return "[" + kind + "]"
+	}
+	mac := hmac.New(sha256.New, w.key)
+	mac.Write([]byte(value))
+	return "[" + kind + ":" + hex.EncodeToString(mac.Sum(nil))[:hashLength] + "]"
+}
+
+// isIP returns whether s is an IP address or CIDR range
+func isIP(s string) bool {
+	if _, err := netip.ParseAddr(s); err == nil {
+		return true
+	}
+	_, err := netip.ParsePrefix(s)
+	return err == nil
+}
+
+// isResourceHost returns whether host is a hostname with a top-level domain, other than the APIs the
+// connector calls
+func (w *privacyWriter) isResourceHost(host string) bool {
+	if _, ok := w.keep[host]; ok {
+		return false
+	}
+	if strings.HasSuffix(host, ".googleapis.com") || awsEndpointPattern.MatchString(host) {
+		return false
+	}
+	// Only the top-level domain is checked, as resources are often under private suffixes, e.g.
+	// s3.eu-west-2.amazonaws.com
+	_, icann := publicsuffix.PublicSuffix(host[strings.LastIndex(host, ".")+1:])
+	return icann
+}
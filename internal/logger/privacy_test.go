@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivacyWriter_Redact(t *testing.T) {
+	w := NewPrivacyWriter(nil, PrivacyRedact, "", "asm.hexiosec.com").(*privacyWriter)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Hostname_Redacted",
+			input:    "found load balancer web-1.example.co.uk",
+			expected: "found load balancer [host]",
+		},
+		{
+			name:     "IPAddresses_Redacted",
+			input:    "found address 203.0.113.7, range 198.51.100.0/24 and 2001:db8::1",
+			expected: "found address [ip], range [ip] and [ip]",
+		},
+		{
+			name:     "ResourceUnderAmazonAWS_Redacted",
+			input:    "found bucket assets.s3.eu-west-2.amazonaws.com",
+			expected: "found bucket [host]",
+		},
+		{
+			name:     "APIHosts_Unchanged",
+			input:    `Get "https://ec2.eu-west-2.amazonaws.com/": dial tcp, then https://asm.hexiosec.com/api and storage.googleapis.com`,
+			expected: `Get "https://ec2.eu-west-2.amazonaws.com/": dial tcp, then https://asm.hexiosec.com/api and storage.googleapis.com`,
+		},
+		{
+			name:     "Identifiers_Unchanged",
+			input:    `{"time":"2026-01-02T12:30:00Z","caller":"internal/aws/aws.go:199","message":"json.Unmarshal failed for v1.2.3 after 12 resources"}`,
+			expected: `{"time":"2026-01-02T12:30:00Z","caller":"internal/aws/aws.go:199","message":"json.Unmarshal failed for v1.2.3 after 12 resources"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, w.replace(tc.input))
+		})
+	}
+}
+
+func TestPrivacyWriter_Hash_SameForSameValue(t *testing.T) {
+	var buf bytes.Buffer
+	log := zerolog.New(NewPrivacyWriter(&buf, PrivacyHash, "key"))
+
+	log.Info().Str("resource", "app.example.com").Msg("Resource normalised, app.example.com")
+	log.Info().Msg("Resource other.example.com")
+
+	first := NewPrivacyWriter(nil, PrivacyHash, "key").(*privacyWriter).placeholder("host", "app.example.com")
+	assert.Regexp(t, `^\[host:[0-9a-f]{12}\]$`, first)
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte(first)))
+	assert.NotContains(t, buf.String(), "example.com")
+
+	// A different key gives different hashes
+	assert.NotEqual(t, first, NewPrivacyWriter(nil, PrivacyHash, "other").(*privacyWriter).placeholder("host", "app.example.com"))
+}
+
+func TestPrivacyWriter_Off_Unchanged(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.Same(t, &buf, NewPrivacyWriter(&buf, PrivacyOff, ""))
+}
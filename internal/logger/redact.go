@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// minSecretLength is the shortest secret value redacted, so a short or placeholder value doesn't
+// redact every occurrence of a common word
+const minSecretLength = 8
+
+// secretPatterns match credentials whatever their value, capturing everything before the value:
+//   - bearer tokens, e.g. an OAuth access token in an Authorization header
+//   - fields of JSON, form encoded bodies and URL queries holding credentials, including assumed
+//     role credentials and SAS signatures, as JSON escaped in the fields of a JSON log
+//   - AWS access key IDs, long-lived (AKIA) and temporary (ASIA)
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+	regexp.MustCompile(`(?i)(\b(?:client_secret|access_token|refresh_token|id_token|api_?key|password|secret_?access_?key|session_?token|x-amz-security-token|x-amz-signature|x-hexiosec-api-key|sig)(?:\\?")?\s*[:=]\s*(?:\\?")?)[^"&,\s}\\]+`),
+	regexp.MustCompile(`()\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`),
+}
+
+var (
+	secretsMu sync.RWMutex
+	// secrets are the secret values to redact, and replacer replaces them
+	secrets  = map[string]struct{}{}
+	replacer = strings.NewReplacer()
+)
+
+// AddSecrets registers secret values, e.g. an API key, to redact from all logs. Values shorter
+// than minSecretLength are ignored.
+func AddSecrets(values ...string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	added := false
+	for _, value := range values {
+		if len(value) < minSecretLength {
+			continue
+		}
+		if _, ok := secrets[value]; ok {
+			continue
+		}
+		secrets[value] = struct{}{}
+		added = true
+	}
+	if !added {
+		return
+	}
+
+	// Secrets are replaced as written and as JSON escaped in the fields of a JSON log
+	pairs := make([]string, 0, 4*len(secrets))
+	for value := range secrets {
+		pairs = append(pairs, value, redacted)
+		if escaped := jsonEscape(value); escaped != value {
+			pairs = append(pairs, escaped, redacted)
+		}
+	}
+	replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact returns s with the registered secret values and any credentials matching secretPatterns
+// replaced
+func Redact(s string) string {
+	secretsMu.RLock()
+	r := replacer
+	secretsMu.RUnlock()
+
+	s = r.Replace(s)
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+redacted)
+	}
+	return s
+}
+
+func jsonEscape(s string) string {
+	encoded, _ := json.Marshal(s)
+	return string(encoded[1 : len(encoded)-1])
+}
+
+// redactWriter redacts secrets from each log event written to it. A zerolog hook can only add to an
+// event, not change the fields and message already in it, so secrets are redacted from the event
+// once it's encoded. zerolog writes each event in a single call, so a secret is never split across
+// writes.
+type redactWriter struct {
+	out io.Writer
+}
+
+// NewRedactWriter returns a writer redacting secrets from what's written to it before writing it
+// to out
+func NewRedactWriter(out io.Writer) io.Writer {
+	return &redactWriter{out: out}
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the time a log file was rotated, in the name of the backup. It sorts in time
+// order.
+const backupTimeFormat = "20060102T150405.000"
+
+// Rotation is when a log file is rotated, it's never rotated if MaxSize and MaxAge are zero
+type Rotation struct {
+	// MaxSize is the size in bytes a log file is rotated at
+	MaxSize int64
+	// MaxAge is how long a log file is written to before it's rotated
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept, the oldest are removed
+	MaxBackups int
+}
+
+// RotatingFile is a log file that's appended to, and rotated once it's too large or old. The
+// rotated file is renamed with the time it was rotated, e.g. connector-20260102T030405.000.log.
+type RotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	rotation Rotation
+	file     *os.File
+	size     int64
+	opened   time.Time
+	now      func() time.Time
+}
+
+// OpenFile opens the log file at path to append to, rotating it as rotation sets
+func OpenFile(path string, rotation Rotation) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, rotation: rotation, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p to the log file, rotating it first if p would take it over the size limit or
+// it's too old. A single write is never split across files.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// due returns whether the file needs rotating before writing n bytes. An empty file isn't rotated,
+// a write larger than the size limit goes in a file of its own.
+func (f *RotatingFile) due(n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.rotation.MaxSize > 0 && f.size+n > f.rotation.MaxSize {
+		return true
+	}
+	return f.rotation.MaxAge > 0 && f.now().Sub(f.opened) >= f.rotation.MaxAge
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("logger: could not open log file, %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("logger: could not stat log file, %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.opened = f.now()
+	return nil
+}
+
+// rotate renames the log file to a backup, opens a new one and removes the oldest backups
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("logger: could not close log file, %w", err)
+	}
+
+	prefix, ext := f.backupName()
+	backup := prefix + f.now().UTC().Format(backupTimeFormat) + ext
+	if err := os.Rename(f.path, backup); err != nil {
+		// Keep appending to the file rather than losing logs
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("logger: could not rotate log file, %w", err)
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+// backupName returns the prefix and extension of the name of a backup of the log file
+func (f *RotatingFile) backupName() (string, string) {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-", ext
+}
+
+// prune removes the oldest backups beyond MaxBackups. Failures are ignored, the backups are removed
+// on the next rotation instead.
+func (f *RotatingFile) prune() {
+	if f.rotation.MaxBackups <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return
+	}
+	prefix, ext := f.backupName()
+	prefix = filepath.Base(prefix)
+	var backups []string
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+		stamp, ok = strings.CutSuffix(stamp, ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); !ok || err != nil {
+			continue
+		}
+		backups = append(backups, entry.Name())
+	}
+	if len(backups) <= f.rotation.MaxBackups {
+		return
+	}
+
+	// Backup names sort in the order they were rotated
+	slices.Sort(backups)
+	for _, backup := range backups[:len(backups)-f.rotation.MaxBackups] {
+		_ = os.Remove(filepath.Join(filepath.Dir(f.path), backup))
+	}
+}
@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backups returns the names of the rotated files of the log file at path
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(strings.TrimSuffix(path, ".log") + "-*.log")
+	require.NoError(t, err)
+	for i, match := range matches {
+		matches[i] = filepath.Base(match)
+	}
+	return matches
+}
+
+func TestRotatingFile_MaxSize_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "connector.log")
+	f, err := OpenFile(path, Rotation{MaxSize: 10})
+	require.NoError(t, err)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	_, err = f.Write([]byte("12345\n"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("678\n"))
+	require.NoError(t, err)
+	// Over the limit, so it starts a new file
+	_, err = f.Write([]byte("abc\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, []string{"connector-20260102T030405.000.log"}, backups(t, path))
+	rotated, err := os.ReadFile(filepath.Join(filepath.Dir(path), "connector-20260102T030405.000.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "12345\n678\n", string(rotated))
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "abc\n", string(current))
+}
+
+func TestRotatingFile_MaxAge_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "connector.log")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, os.WriteFile(path, []byte("earlier\n"), 0644))
+
+	f, err := OpenFile(path, Rotation{MaxAge: time.Hour})
+	require.NoError(t, err)
+	f.now = func() time.Time { return now }
+	f.opened = now
+
+	_, err = f.Write([]byte("a\n"))
+	require.NoError(t, err)
+	assert.Empty(t, backups(t, path))
+
+	now = now.Add(time.Hour)
+	_, err = f.Write([]byte("b\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, []string{"connector-20260102T040405.000.log"}, backups(t, path))
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "b\n", string(current))
+}
+
+func TestRotatingFile_MaxBackups_RemovesOldest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "connector.log")
+	// Not a backup, so never removed
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "connector-old.log"), nil, 0644))
+
+	f, err := OpenFile(path, Rotation{MaxSize: 1, MaxBackups: 2})
+	require.NoError(t, err)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	for range 4 {
+		_, err = f.Write([]byte("x\n"))
+		require.NoError(t, err)
+		now = now.Add(time.Second)
+	}
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, []string{
+		"connector-20260102T030407.000.log",
+		"connector-20260102T030408.000.log",
+		"connector-old.log",
+	}, backups(t, path))
+}
+
+func TestRotatingFile_NoRotation_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "connector.log")
+	require.NoError(t, os.WriteFile(path, []byte("earlier\n"), 0644))
+
+	f, err := OpenFile(path, Rotation{})
+	require.NoError(t, err)
+	_, err = f.Write([]byte("later\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Empty(t, backups(t, path))
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "earlier\nlater\n", string(current))
+}
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// Sampling samples the per-resource debug and trace logs of a run, which on a large estate are
+// millions of lines, to the first and then every nth message of each kind. It counts the messages
+// of each kind, so the totals can be logged once the run finishes.
+type Sampling struct {
+	every int
+
+	mu    sync.Mutex
+	kinds map[string]*kindSampler
+}
+
+// kindSampler samples the messages of one kind, counting them
+type kindSampler struct {
+	every  uint64
+	total  atomic.Uint64
+	logged atomic.Uint64
+}
+
+// Sample samples debug and trace messages, messages of a higher level are always logged
+func (s *kindSampler) Sample(lvl zerolog.Level) bool {
+	if lvl > zerolog.DebugLevel {
+		return true
+	}
+	n := s.total.Add(1)
+	if s.every > 1 && (n-1)%s.every != 0 {
+		return false
+	}
+	s.logged.Add(1)
+	return true
+}
+
+type samplingKey struct{}
+
+// WithSampling returns a context whose per-resource logs, logged with PerResource, are sampled to
+// every nth message of each kind. Every message is logged if every is 1 or less.
+func WithSampling(ctx context.Context, every int) (context.Context, *Sampling) {
+	s := &Sampling{every: every, kinds: map[string]*kindSampler{}}
+	return context.WithValue(ctx, samplingKey{}, s), s
+}
+
+// PerResource returns the logger of ctx for a per-resource message of kind, e.g. "found instance",
+// sampled if ctx has sampling
+func PerResource(ctx context.Context, kind string) *zerolog.Logger {
+	l := GetLogger(ctx)
+	s, ok := ctx.Value(samplingKey{}).(*Sampling)
+	if !ok || s.every <= 1 {
+		return l
+	}
+
+	sampled := l.Sample(s.sampler(kind))
+	return &sampled
+}
+
+func (s *Sampling) sampler(kind string) *kindSampler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.kinds[kind]
+	if !ok {
+		k = &kindSampler{every: uint64(s.every)}
+		s.kinds[kind] = k
+	}
+	return k
+}
+
+// Log logs the number of per-resource messages of each kind, if any were dropped by sampling
+func (s *Sampling) Log(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := map[string]uint64{}
+	dropped := false
+	for kind, k := range s.kinds {
+		total[kind] = k.total.Load()
+		dropped = dropped || k.logged.Load() < total[kind]
+	}
+	if !dropped {
+		return
+	}
+
+	GetLogger(ctx).Debug().
+		Int("sample_every", s.every).
+		Interface("messages", total).
+		Msgf("Logged 1 in %d per-resource debug and trace messages", s.every)
+}
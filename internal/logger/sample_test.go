@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// messages returns the messages of the JSON logs in out
+func messages(t *testing.T, out *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var events []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var event map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestPerResource_Sampled(t *testing.T) {
+	out := &bytes.Buffer{}
+	ctx := WithLogger(context.Background(), zerolog.New(out).Level(zerolog.TraceLevel))
+	ctx, sampling := WithSampling(ctx, 3)
+
+	for i := range 7 {
+		PerResource(ctx, "found instance").Trace().Int("i", i).Msg("found instance")
+	}
+	PerResource(ctx, "found bucket").Trace().Msg("found bucket")
+	// Warnings are never sampled
+	for range 2 {
+		PerResource(ctx, "found instance").Warn().Msg("instance skipped")
+	}
+
+	var logged []float64
+	for _, event := range messages(t, out) {
+		if event["message"] == "found instance" {
+			logged = append(logged, event["i"].(float64))
+		}
+	}
+	assert.Equal(t, []float64{0, 3, 6}, logged)
+	assert.Equal(t, 1, strings.Count(out.String(), "found bucket"))
+	assert.Equal(t, 2, strings.Count(out.String(), "instance skipped"))
+
+	out.Reset()
+	sampling.Log(ctx)
+	events := messages(t, out)
+	require.Len(t, events, 1)
+	assert.Equal(t, map[string]any{"found instance": float64(7), "found bucket": float64(1)}, events[0]["messages"])
+}
+
+func TestPerResource_NoSampling_AllLogged(t *testing.T) {
+	out := &bytes.Buffer{}
+	ctx := WithLogger(context.Background(), zerolog.New(out).Level(zerolog.TraceLevel))
+	ctx, sampling := WithSampling(ctx, 0)
+
+	for range 3 {
+		PerResource(ctx, "found instance").Trace().Msg("found instance")
+	}
+	assert.Equal(t, 3, strings.Count(out.String(), "found instance"))
+
+	out.Reset()
+	sampling.Log(ctx)
+	assert.Empty(t, out.String())
+}
+
+func TestPerResource_LevelDisabled_NotCounted(t *testing.T) {
+	out := &bytes.Buffer{}
+	ctx := WithLogger(context.Background(), zerolog.New(out).Level(zerolog.InfoLevel))
+	ctx, sampling := WithSampling(ctx, 2)
+
+	for range 3 {
+		PerResource(ctx, "found instance").Trace().Msg("found instance")
+	}
+
+	sampling.Log(ctx)
+	assert.Empty(t, out.String())
+}
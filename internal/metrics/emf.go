@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+)
+
+// EMFMetric is a value of a CloudWatch Embedded Metric Format record
+type EMFMetric struct {
+	Name string
+	// Unit is a CloudWatch unit, e.g. "Count" or "Seconds"
+	Unit  string
+	Value float64
+}
+
+// WriteEMF writes metrics as a record of the CloudWatch Embedded Metric Format, one line of JSON
+// that CloudWatch Logs turns into metrics in namespace, with the dimensions. Lambda sends what the
+// function writes to stdout to CloudWatch Logs, so no agent or API call is needed.
+func WriteEMF(w io.Writer, namespace string, dimensions map[string]string, metrics []EMFMetric, at time.Time) error {
+	type definition struct {
+		Name string `json:"Name"`
+		Unit string `json:"Unit,omitempty"`
+	}
+
+	record := map[string]any{}
+	definitions := make([]definition, 0, len(metrics))
+	for _, m := range metrics {
+		definitions = append(definitions, definition{Name: m.Name, Unit: m.Unit})
+		record[m.Name] = m.Value
+	}
+	for name, value := range dimensions {
+		record[name] = value
+	}
+	record["_aws"] = map[string]any{
+		"Timestamp": at.UnixMilli(),
+		"CloudWatchMetrics": []map[string]any{{
+			"Namespace":  namespace,
+			"Dimensions": [][]string{slices.Sorted(maps.Keys(dimensions))},
+			"Metrics":    definitions,
+		}},
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("metrics: could not encode EMF record, %w", err)
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("metrics: could not write EMF record, %w", err)
+	}
+	return nil
+}
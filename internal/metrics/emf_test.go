@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteEMF(t *testing.T) {
+	var out strings.Builder
+	err := WriteEMF(&out, "Connector", map[string]string{"FunctionName": "sync", "Config": "prod"}, []EMFMetric{
+		{Name: "Resources", Unit: "Count", Value: 12},
+		{Name: "Duration", Unit: "Seconds", Value: 1.5},
+	}, time.UnixMilli(1700000000000))
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasSuffix(out.String(), "}\n"))
+	assert.JSONEq(t, `{
+		"_aws": {
+			"Timestamp": 1700000000000,
+			"CloudWatchMetrics": [{
+				"Namespace": "Connector",
+				"Dimensions": [["Config", "FunctionName"]],
+				"Metrics": [{"Name": "Resources", "Unit": "Count"}, {"Name": "Duration", "Unit": "Seconds"}]
+			}]
+		},
+		"FunctionName": "sync",
+		"Config": "prod",
+		"Resources": 12,
+		"Duration": 1.5
+	}`, out.String())
+}
@@ -0,0 +1,284 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are the upper bounds of latency histograms, in seconds
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Default is the registry the connector records its metrics in
+var Default = NewRegistry()
+
+// Registry holds metrics by name, so they can be written out together
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+	sink    atomic.Pointer[Sink]
+}
+
+// Sink receives each update of the metrics of a registry as it's made, e.g. to forward them to
+// StatsD. labels are the label names of the metric and values their values. Its methods may be
+// called concurrently.
+type Sink interface {
+	// Count receives v added to a counter
+	Count(name string, v float64, labels []string, values []string)
+	// Observe receives v observed by a histogram
+	Observe(name string, v float64, labels []string, values []string)
+}
+
+// metric is a counter or histogram with its values by label values
+type metric interface {
+	write(w io.Writer) error
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+// SetSink sends each update of the metrics of the registry to s as well, or stops sending them if
+// s is nil
+func (r *Registry) SetSink(s Sink) {
+	if s == nil {
+		r.sink.Store(nil)
+		return
+	}
+	r.sink.Store(&s)
+}
+
+func (r *Registry) currentSink() Sink {
+	if s := r.sink.Load(); s != nil {
+		return *s
+	}
+	return nil
+}
+
+// Counter returns the counter named name, registering it if it doesn't exist
+func (r *Registry) Counter(name string, help string, labels ...string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m, ok := r.metrics[name].(*Counter); ok {
+		return m
+	}
+	c := &Counter{desc: desc{name: name, help: help, labels: labels, registry: r}, values: map[string]float64{}}
+	r.metrics[name] = c
+	return c
+}
+
+// Histogram returns the histogram named name, registering it with buckets if it doesn't exist
+func (r *Registry) Histogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m, ok := r.metrics[name].(*Histogram); ok {
+		return m
+	}
+	h := &Histogram{desc: desc{name: name, help: help, labels: labels, registry: r}, buckets: buckets, values: map[string]*histogramValue{}}
+	r.metrics[name] = h
+	return h
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format, sorted by name
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	metrics := make([]metric, 0, len(names))
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// desc is the name, help and label names of a metric, and the registry it's in
+type desc struct {
+	name     string
+	help     string
+	labels   []string
+	registry *Registry
+}
+
+// key joins label values into a map key, they are split again when written
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats the labels of key, plus any extra pairs, as {name="value",...}
+func (d *desc) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for idx, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", d.labels[idx], value))
+		}
+	}
+	for idx := 0; idx+1 < len(extra); idx += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", extra[idx], extra[idx+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (d *desc) header(w io.Writer, kind string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, kind)
+	return err
+}
+
+// sortedKeys returns the keys of values in order, so output is stable
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Counter is a total that only goes up, by label values
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// Inc adds 1 to the counter with the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter with the label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+
+	if s := c.registry.currentSink(); s != nil {
+		s.Count(c.name, v, c.labels, labelValues)
+	}
+}
+
+// Value returns the counter with the label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.header(w, "counter"); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(c.values) {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(key), formatFloat(c.values[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Histogram counts observations, e.g. latencies, in buckets by label values
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	// counts are the observations in each bucket, not cumulative
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe records v in the histogram with the label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	value, ok := h.values[key]
+	if !ok {
+		value = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = value
+	}
+	if idx, found := slices.BinarySearch(h.buckets, v); found || idx < len(h.buckets) {
+		value.counts[idx]++
+	}
+	value.count++
+	value.sum += v
+	h.mu.Unlock()
+
+	if s := h.registry.currentSink(); s != nil {
+		s.Observe(h.name, v, h.labels, labelValues)
+	}
+}
+
+// Count returns the number of observations with the label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if value, ok := h.values[key]; ok {
+		return value.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.header(w, "histogram"); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(h.values) {
+		value := h.values[key]
+		var cumulative uint64
+		for idx, bound := range h.buckets {
+			cumulative += value.counts[idx]
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", formatFloat(bound)), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, h.labelPairs(key, "le", "+Inf"), value.count,
+			h.name, h.labelPairs(key), formatFloat(value.sum),
+			h.name, h.labelPairs(key), value.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("requests_total", "Requests sent", "endpoint", "status")
+	requests.Inc("GetState", "200")
+	requests.Add(2, "AddScanSeedById", "500")
+	latency := r.Histogram("request_duration_seconds", "Request latency", []float64{0.1, 1}, "endpoint")
+	latency.Observe(0.05, "GetState")
+	latency.Observe(0.5, "GetState")
+	latency.Observe(5, "GetState")
+
+	// Registering again returns the same metric
+	assert.Same(t, requests, r.Counter("requests_total", "Requests sent", "endpoint", "status"))
+
+	var out strings.Builder
+	require.NoError(t, r.WritePrometheus(&out))
+	assert.Equal(t, `# HELP request_duration_seconds Request latency
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{endpoint="GetState",le="0.1"} 1
+request_duration_seconds_bucket{endpoint="GetState",le="1"} 2
+request_duration_seconds_bucket{endpoint="GetState",le="+Inf"} 3
+request_duration_seconds_sum{endpoint="GetState"} 5.55
+request_duration_seconds_count{endpoint="GetState"} 3
+# HELP requests_total Requests sent
+# TYPE requests_total counter
+requests_total{endpoint="AddScanSeedById",status="500"} 2
+requests_total{endpoint="GetState",status="200"} 1
+`, out.String())
+}
+
+func TestCounter_WrongLabelCount_Panics(t *testing.T) {
+	c := NewRegistry().Counter("requests_total", "Requests sent", "endpoint")
+	assert.Panics(t, func() { c.Inc() })
+}
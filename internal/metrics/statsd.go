@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Formats of the StatsD protocol
+const (
+	// FormatDogStatsD sends the labels of a metric as DogStatsD tags
+	FormatDogStatsD = "dogstatsd"
+	// FormatStatsD appends the label values of a metric to its name, as plain StatsD has no tags
+	FormatStatsD = "statsd"
+)
+
+var (
+	// statsdReplacer replaces the characters with a meaning in the StatsD protocol in label values
+	statsdReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+	// tagReplacer replaces them in configured tags, which are already name:value
+	tagReplacer = strings.NewReplacer("|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+)
+
+// StatsD is a Sink sending each update of a metric to a StatsD server or Datadog agent over UDP.
+// A datagram that can't be sent is dropped, as StatsD over UDP is best effort.
+type StatsD struct {
+	conn   net.Conn
+	format string
+	tags   []string
+}
+
+// NewStatsD returns a sink sending to the StatsD server at address, e.g. "localhost:8125", in
+// format, with tags, e.g. "env:prod", added to every metric sent to DogStatsD
+func NewStatsD(address string, format string, tags []string) (*StatsD, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: could not connect to StatsD at %s, %w", address, err)
+	}
+	if format == "" {
+		format = FormatDogStatsD
+	}
+
+	sanitised := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		sanitised = append(sanitised, tagReplacer.Replace(tag))
+	}
+	return &StatsD{conn: conn, format: format, tags: sanitised}, nil
+}
+
+// Count sends v added to the counter name
+func (s *StatsD) Count(name string, v float64, labels []string, values []string) {
+	s.send(name, formatFloat(v), "c", labels, values)
+}
+
+// Observe sends v observed by the histogram name, as a histogram to DogStatsD or a timer in
+// milliseconds to StatsD, as the histograms are latencies in seconds
+func (s *StatsD) Observe(name string, v float64, labels []string, values []string) {
+	if s.format == FormatStatsD {
+		s.send(name, formatFloat(v*1000), "ms", labels, values)
+		return
+	}
+	s.send(name, formatFloat(v), "h", labels, values)
+}
+
+// Close closes the connection to the StatsD server
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsD) send(name string, value string, kind string, labels []string, values []string) {
+	_, _ = s.conn.Write([]byte(s.line(name, value, kind, labels, values)))
+}
+
+// line returns the StatsD line of a metric, e.g. name:1|c|#endpoint:GetState,env:prod
+func (s *StatsD) line(name string, value string, kind string, labels []string, values []string) string {
+	if s.format == FormatStatsD {
+		for _, v := range values {
+			name += "." + statsdReplacer.Replace(v)
+		}
+		return fmt.Sprintf("%s:%s|%s", name, value, kind)
+	}
+
+	tags := make([]string, 0, len(labels)+len(s.tags))
+	for idx, label := range labels {
+		tags = append(tags, label+":"+statsdReplacer.Replace(values[idx]))
+	}
+	tags = append(tags, s.tags...)
+	if len(tags) == 0 {
+		return fmt.Sprintf("%s:%s|%s", name, value, kind)
+	}
+	return fmt.Sprintf("%s:%s|%s|#%s", name, value, kind, strings.Join(tags, ","))
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listenStatsD returns the address of a UDP listener, and a func reading the next datagram sent to it
+func listenStatsD(t *testing.T) (string, func() string) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn.LocalAddr().String(), func() string {
+		t.Helper()
+		buf := make([]byte, 1024)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+}
+
+func TestStatsD_DogStatsD_SendsTags(t *testing.T) {
+	address, read := listenStatsD(t)
+	s, err := NewStatsD(address, "", []string{"env:prod"})
+	require.NoError(t, err)
+	defer s.Close()
+
+	r := NewRegistry()
+	r.SetSink(s)
+	r.Counter("requests_total", "Requests sent", "endpoint", "status").Inc("GetState", "200")
+	r.Histogram("request_duration_seconds", "Request latency", DefaultBuckets, "endpoint").Observe(0.25, "GetState")
+
+	assert.Equal(t, "requests_total:1|c|#endpoint:GetState,status:200,env:prod", read())
+	assert.Equal(t, "request_duration_seconds:0.25|h|#endpoint:GetState,env:prod", read())
+}
+
+func TestStatsD_StatsD_LabelsInName(t *testing.T) {
+	address, read := listenStatsD(t)
+	s, err := NewStatsD(address, FormatStatsD, []string{"env:prod"})
+	require.NoError(t, err)
+	defer s.Close()
+
+	r := NewRegistry()
+	r.SetSink(s)
+	r.Counter("retries_total", "Retries", "host").Inc("api.example.com:443")
+	r.Histogram("request_duration_seconds", "Request latency", DefaultBuckets, "endpoint").Observe(0.25, "GetState")
+
+	assert.Equal(t, "retries_total.api.example.com_443:1|c", read())
+	assert.Equal(t, "request_duration_seconds.GetState:250|ms", read())
+}
+
+func TestRegistry_SetSink_Nil_StopsSending(t *testing.T) {
+	sink := &recordingSink{}
+	r := NewRegistry()
+	counter := r.Counter("requests_total", "Requests sent")
+
+	r.SetSink(sink)
+	counter.Inc()
+	r.SetSink(nil)
+	counter.Inc()
+
+	assert.Equal(t, 1, sink.counts)
+	assert.Equal(t, float64(2), counter.Value())
+}
+
+type recordingSink struct {
+	counts int
+}
+
+func (s *recordingSink) Count(string, float64, []string, []string) {
+	s.counts++
+}
+
+func (s *recordingSink) Observe(string, float64, []string, []string) {}
@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	connector_http "github.com/hexiosec/asm-cloud-connector/internal/http"
+	"github.com/hexiosec/asm-cloud-connector/internal/version"
+)
+
+const (
+	formatSlack = "slack"
+	formatTeams = "teams"
+
+	onChange  = "change"
+	onFailure = "failure"
+
+	webhookTimeout = 10 * time.Second
+)
+
+// Summary is the outcome of a connector run, as sent to the webhook
+type Summary struct {
+	ScanID   string `json:"scan_id"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"`
+	Added    int    `json:"added"`
+	Existing int    `json:"existing"`
+	Removed  int    `json:"removed"`
+	Rejected int    `json:"rejected"`
+	Failed   int    `json:"failed"`
+	// FailedRemovals are the stale seeds that couldn't be removed
+	FailedRemovals int           `json:"failed_removals"`
+	Overflow       int           `json:"overflow"`
+	Dropped        int           `json:"dropped"`
+	FailedSeeds    []string      `json:"failed_seeds,omitempty"`
+	Duration       time.Duration `json:"-"`
+}
+
+// MarshalJSON sends the duration in seconds, rather than nanoseconds
+func (s Summary) MarshalJSON() ([]byte, error) {
+	type summary Summary
+	return json.Marshal(struct {
+		summary
+		DurationSeconds float64 `json:"duration_seconds"`
+	}{summary(s), s.Duration.Seconds()})
+}
+
+type INotifier interface {
+	Notify(ctx context.Context, summary Summary) error
+}
+
+// NewNotifier returns a notifier posting to the configured webhook, or one that does nothing
+// if no webhook is configured
+func NewNotifier(cfg *config.Config) (INotifier, error) {
+	if cfg.Notify.WebhookURL == "" {
+		return &nopNotifier{}, nil
+	}
+
+	client, err := connector_http.NewHttpService(cfg, version.UserAgent())
+	if err != nil {
+		return nil, fmt.Errorf("notify: failed to create http service, %w", err)
+	}
+
+	return &webhookNotifier{
+		url:    cfg.Notify.WebhookURL,
+		format: cfg.Notify.WebhookFormat,
+		on:     cfg.Notify.On,
+		client: client,
+	}, nil
+}
+
+type webhookNotifier struct {
+	url    string
+	format string
+	on     string
+	client connector_http.IHttpService
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, summary Summary) error {
+	if !n.shouldNotify(summary) {
+		return nil
+	}
+
+	// Not retried, as a webhook that timed out or failed may still have delivered the notification,
+	// and a retry would send it twice
+	resp, err := n.client.Post(ctx, n.url, n.payload(summary), connector_http.HttpOptions{Timeout: webhookTimeout, NoRetry: true})
+	if err != nil {
+		return fmt.Errorf("notify: webhook request failed, %w", err)
+	}
+
+	if resp.GetStatusCode() < 200 || resp.GetStatusCode() > 299 {
+		return fmt.Errorf("notify: webhook returned status %d", resp.GetStatusCode())
+	}
+
+	return nil
+}
+
+func (n *webhookNotifier) shouldNotify(summary Summary) bool {
+	switch n.on {
+	case onFailure:
+		return !summary.Success || summary.Failed > 0
+	case onChange:
+		return !summary.Success || summary.Failed > 0 || summary.Added > 0 || summary.Removed > 0
+	default:
+		return true
+	}
+}
+
+func (n *webhookNotifier) payload(summary Summary) any {
+	switch n.format {
+	case formatSlack:
+		return map[string]string{"text": summary.text()}
+	case formatTeams:
+		return map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  summary.title(),
+			"text":     strings.ReplaceAll(summary.text(), "\n", "<br>"),
+		}
+	default:
+		return summary
+	}
+}
+
+func (s Summary) title() string {
+	if !s.Success {
+		return fmt.Sprintf("Hexiosec ASM Cloud Connector sync failed for scan %s", s.ScanID)
+	}
+	return fmt.Sprintf("Hexiosec ASM Cloud Connector sync completed for scan %s", s.ScanID)
+}
+
+// text is a human readable summary for chat webhooks
+func (s Summary) text() string {
+	var b strings.Builder
+	b.WriteString(s.title())
+	fmt.Fprintf(&b, "\nAdded: %d, existing: %d, removed: %d, rejected: %d, failed: %d", s.Added, s.Existing, s.Removed, s.Rejected, s.Failed)
+	fmt.Fprintf(&b, "\nDuration: %s", s.Duration.Round(time.Second))
+	if s.Error != "" {
+		fmt.Fprintf(&b, "\nError: %s", s.Error)
+	}
+	if len(s.FailedSeeds) > 0 {
+		fmt.Fprintf(&b, "\nFailed seeds: %s", strings.Join(s.FailedSeeds, ", "))
+	}
+	if s.Overflow > 0 {
+		fmt.Fprintf(&b, "\n%d seeds exceed the seed limit of the scan", s.Overflow)
+	}
+	return b.String()
+}
+
+type nopNotifier struct{}
+
+func (n *nopNotifier) Notify(_ context.Context, _ Summary) error {
+	return nil
+}
@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+func newTestServer(t *testing.T, status int) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	received := []map[string]any{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var payload map[string]any
+		require.NoError(t, json.Unmarshal(body, &payload))
+		received = append(received, payload)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func newTestNotifier(t *testing.T, url string, format string, on string) INotifier {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Notify.WebhookURL = url
+	cfg.Notify.WebhookFormat = format
+	cfg.Notify.On = on
+	n, err := NewNotifier(cfg)
+	require.NoError(t, err)
+	return n
+}
+
+func TestNotify_JSON_Success(t *testing.T) {
+	server, received := newTestServer(t, http.StatusOK)
+	n := newTestNotifier(t, server.URL, "json", "always")
+
+	err := n.Notify(context.Background(), Summary{
+		ScanID:   "scan-123",
+		Success:  true,
+		Added:    2,
+		Duration: 90 * time.Second,
+	})
+
+	require.NoError(t, err)
+	require.Len(t, *received, 1)
+	assert.Equal(t, "scan-123", (*received)[0]["scan_id"])
+	assert.Equal(t, true, (*received)[0]["success"])
+	assert.Equal(t, float64(2), (*received)[0]["added"])
+	assert.Equal(t, float64(90), (*received)[0]["duration_seconds"])
+}
+
+func TestNotify_Slack_Text(t *testing.T) {
+	server, received := newTestServer(t, http.StatusOK)
+	n := newTestNotifier(t, server.URL, "slack", "always")
+
+	err := n.Notify(context.Background(), Summary{
+		ScanID:      "scan-123",
+		Error:       "core: could not get resources",
+		Failed:      1,
+		FailedSeeds: []string{"example.com"},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, *received, 1)
+	assert.Contains(t, (*received)[0]["text"], "sync failed for scan scan-123")
+	assert.Contains(t, (*received)[0]["text"], "Failed seeds: example.com")
+}
+
+func TestNotify_Teams_MessageCard(t *testing.T) {
+	server, received := newTestServer(t, http.StatusOK)
+	n := newTestNotifier(t, server.URL, "teams", "always")
+
+	err := n.Notify(context.Background(), Summary{ScanID: "scan-123", Success: true})
+
+	require.NoError(t, err)
+	require.Len(t, *received, 1)
+	assert.Equal(t, "MessageCard", (*received)[0]["@type"])
+	assert.Contains(t, (*received)[0]["summary"], "sync completed")
+}
+
+func TestNotify_ErrorStatus_Err(t *testing.T) {
+	server, _ := newTestServer(t, http.StatusInternalServerError)
+	n := newTestNotifier(t, server.URL, "json", "always")
+
+	err := n.Notify(context.Background(), Summary{Success: true})
+
+	assert.ErrorContains(t, err, "webhook returned status 500")
+}
+
+func TestNotify_ErrorStatus_NotRetried(t *testing.T) {
+	server, received := newTestServer(t, http.StatusServiceUnavailable)
+	cfg := &config.Config{}
+	cfg.Notify.WebhookURL = server.URL
+	cfg.Notify.WebhookFormat = "json"
+	cfg.Http.RetryCount = 2
+	cfg.Http.RetryBaseDelay = time.Millisecond
+	cfg.Http.RetryMaxDelay = time.Millisecond
+	n, err := NewNotifier(cfg)
+	require.NoError(t, err)
+
+	err = n.Notify(context.Background(), Summary{Success: true})
+
+	assert.ErrorContains(t, err, "webhook returned status 503")
+	assert.Len(t, *received, 1)
+}
+
+func TestNotify_On(t *testing.T) {
+	tests := []struct {
+		name    string
+		on      string
+		summary Summary
+		sent    bool
+	}{
+		{name: "Always_NoChange_Sent", on: "always", summary: Summary{Success: true}, sent: true},
+		{name: "Change_NoChange_NotSent", on: "change", summary: Summary{Success: true, Existing: 3}},
+		{name: "Change_Added_Sent", on: "change", summary: Summary{Success: true, Added: 1}, sent: true},
+		{name: "Change_Failed_Sent", on: "change", summary: Summary{Success: false}, sent: true},
+		{name: "Failure_Removed_NotSent", on: "failure", summary: Summary{Success: true, Removed: 1}},
+		{name: "Failure_FailedSeeds_Sent", on: "failure", summary: Summary{Success: true, Failed: 1}, sent: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server, received := newTestServer(t, http.StatusOK)
+			n := newTestNotifier(t, server.URL, "json", tc.on)
+
+			require.NoError(t, n.Notify(context.Background(), tc.summary))
+			assert.Equal(t, tc.sent, len(*received) == 1)
+		})
+	}
+}
+
+func TestNewNotifier_NoURL_Nop(t *testing.T) {
+	n, err := NewNotifier(&config.Config{})
+	require.NoError(t, err)
+
+	assert.NoError(t, n.Notify(context.Background(), Summary{}))
+}
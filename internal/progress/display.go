@@ -0,0 +1,196 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// redrawInterval throttles redrawing, so a fast run doesn't flood the terminal
+	redrawInterval = 100 * time.Millisecond
+	// maxLineWidth keeps status lines on one line of a narrow terminal, a wrapped line isn't cleared
+	maxLineWidth = 80
+)
+
+// Display shows the progress of a run as status lines at the bottom of a terminal, redrawn as it
+// changes. Logs written through it are printed above the status lines.
+type Display struct {
+	mu       sync.Mutex
+	out      io.Writer
+	profiles []*profileProgress
+	scans    []*scanProgress
+	// lines is the number of status lines drawn, to clear before drawing again
+	lines    int
+	lastDraw time.Time
+}
+
+type profileProgress struct {
+	name      string
+	service   string
+	location  string
+	services  map[string]struct{}
+	locations map[string]struct{}
+	found     int
+	failed    int
+	done      bool
+}
+
+type scanProgress struct {
+	name  string
+	done  int
+	total int
+}
+
+// NewDisplay returns a display drawing to out, a terminal
+func NewDisplay(out io.Writer) *Display {
+	return &Display{out: out}
+}
+
+// IsTerminal returns whether f is a terminal, which a display needs to redraw its status lines
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Write prints p, e.g. a log line, above the status lines
+func (d *Display) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.clear()
+	n, err := d.out.Write(p)
+	d.draw()
+	return n, err
+}
+
+func (d *Display) Service(profile string, service string, location string, found int, _ time.Duration, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p := d.profile(profile)
+	p.service, p.location = service, location
+	p.services[service] = struct{}{}
+	if location != "" {
+		p.locations[location] = struct{}{}
+	}
+	p.found += found
+	if err != nil {
+		p.failed++
+	}
+	d.redraw()
+}
+
+func (d *Display) Discovered(profile string, resources int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p := d.profile(profile)
+	p.found = resources
+	p.done = true
+	d.redraw()
+}
+
+func (d *Display) Seeds(scan string, done int, total int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var s *scanProgress
+	for _, existing := range d.scans {
+		if existing.name == scan {
+			s = existing
+		}
+	}
+	if s == nil {
+		s = &scanProgress{name: scan}
+		d.scans = append(d.scans, s)
+	}
+	s.done, s.total = done, total
+	d.redraw()
+}
+
+// Close draws the final progress, leaving it above any later output
+func (d *Display) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.clear()
+	d.draw()
+	d.lines = 0
+	return nil
+}
+
+// profile returns the progress of profile, adding it if it's the first report of it
+func (d *Display) profile(name string) *profileProgress {
+	for _, p := range d.profiles {
+		if p.name == name {
+			return p
+		}
+	}
+	p := &profileProgress{name: name, services: map[string]struct{}{}, locations: map[string]struct{}{}}
+	d.profiles = append(d.profiles, p)
+	return p
+}
+
+// redraw redraws the status lines, unless they were drawn within redrawInterval
+func (d *Display) redraw() {
+	if time.Since(d.lastDraw) < redrawInterval {
+		return
+	}
+	d.clear()
+	d.draw()
+}
+
+// clear moves the cursor to the first status line and clears them
+func (d *Display) clear() {
+	if d.lines > 0 {
+		fmt.Fprintf(d.out, "\033[%dF\033[J", d.lines)
+		d.lines = 0
+	}
+}
+
+func (d *Display) draw() {
+	var lines []string
+	for _, p := range d.profiles {
+		if p.done {
+			lines = append(lines, fmt.Sprintf("%s  done, %d resources", p.name, p.found))
+			continue
+		}
+		current := p.service
+		if p.location != "" {
+			current += " in " + p.location
+		}
+		status := fmt.Sprintf("%s  %s  %d services", p.name, current, len(p.services))
+		if len(p.locations) > 0 {
+			status += fmt.Sprintf(", %d locations", len(p.locations))
+		}
+		status += fmt.Sprintf(", %d resources", p.found)
+		if p.failed > 0 {
+			status += fmt.Sprintf(", %d failed", p.failed)
+		}
+		lines = append(lines, status)
+	}
+	for _, s := range d.scans {
+		if s.done >= s.total {
+			lines = append(lines, fmt.Sprintf("scan %s  synced %d resources", s.name, s.total))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("scan %s  syncing %d/%d resources", s.name, s.done, s.total))
+	}
+
+	for _, line := range lines {
+		if len(line) > maxLineWidth {
+			line = line[:maxLineWidth-3] + "..."
+		}
+		fmt.Fprintln(d.out, line)
+	}
+	d.lines = len(lines)
+	d.lastDraw = time.Now()
+}
+
+var (
+	_ Reporter  = (*Display)(nil)
+	_ io.Writer = (*Display)(nil)
+)
@@ -0,0 +1,70 @@
+package progress
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var escapes = regexp.MustCompile(`\033\[[0-9]*[A-Za-z]`)
+
+// lines returns the lines written to out, with the escape sequences redrawing them removed
+func lines(out *bytes.Buffer) []string {
+	return strings.Split(strings.TrimSuffix(escapes.ReplaceAllString(out.String(), ""), "\n"), "\n")
+}
+
+func TestDisplay_Close_DrawsFinalProgress(t *testing.T) {
+	out := &bytes.Buffer{}
+	d := NewDisplay(out)
+
+	d.Service("aws/prod", "EC2", "eu-west-1", 2, time.Second, nil)
+	d.Service("aws/prod", "EC2", "eu-west-2", 1, time.Second, nil)
+	d.Service("aws/prod", "S3", "eu-west-1", 3, time.Second, nil)
+	d.Service("gcp/default", "Asset Inventory", "project-a", 4, time.Second, nil)
+	d.Discovered("gcp/default", 4)
+	d.Seeds("scan-1", 2, 5)
+	out.Reset()
+
+	assert.NoError(t, d.Close())
+
+	assert.Equal(t, []string{
+		"aws/prod  S3 in eu-west-1  2 services, 2 locations, 6 resources",
+		"gcp/default  done, 4 resources",
+		"scan scan-1  syncing 2/5 resources",
+	}, lines(out))
+}
+
+func TestDisplay_Write_PrintsAboveStatusLines(t *testing.T) {
+	out := &bytes.Buffer{}
+	d := NewDisplay(out)
+
+	d.Seeds("scan-1", 5, 5)
+	_, err := d.Write([]byte("a log line\n"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"scan scan-1  synced 5 resources",
+		"a log line",
+		"scan scan-1  synced 5 resources",
+	}, lines(out))
+	// The status lines are cleared before the log line
+	assert.Contains(t, out.String(), "\033[1F\033[J")
+}
+
+func TestDisplay_TruncatesLongLines(t *testing.T) {
+	out := &bytes.Buffer{}
+	d := NewDisplay(out)
+
+	d.Discovered(strings.Repeat("a", 100), 1)
+	out.Reset()
+	d.Close()
+
+	got := lines(out)
+	assert.Len(t, got, 1)
+	assert.Len(t, got[0], maxLineWidth)
+	assert.True(t, strings.HasSuffix(got[0], "..."))
+}
@@ -0,0 +1,85 @@
+// Package progress reports the progress of a run, e.g. to an updating display in a terminal
+package progress
+
+import (
+	"context"
+	"time"
+)
+
+// Reporter receives the progress of a run. Its methods may be called concurrently.
+type Reporter interface {
+	// Service reports a service of a profile checked in location, e.g. an AWS region or GCP
+	// project, empty if it isn't checked by location, the resources found, how long the check
+	// took, and the error if the check failed and was skipped
+	Service(profile string, service string, location string, found int, elapsed time.Duration, err error)
+	// Discovered reports a profile's discovery finished, with the resources found
+	Discovered(profile string, resources int)
+	// Seeds reports done of the total resources of a scan synced
+	Seeds(scan string, done int, total int)
+}
+
+type (
+	reporterKey struct{}
+	profileKey  struct{}
+)
+
+// WithReporter returns a context whose runs report their progress to r, as well as to the reporter
+// of ctx if it has one
+func WithReporter(ctx context.Context, r Reporter) context.Context {
+	if parent, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		r = reporters{parent, r}
+	}
+	return context.WithValue(ctx, reporterKey{}, r)
+}
+
+// WithProfile returns a context reporting the progress of the discovery of profile, e.g. "aws/prod"
+func WithProfile(ctx context.Context, profile string) context.Context {
+	return context.WithValue(ctx, profileKey{}, profile)
+}
+
+// Service reports a service of the profile of ctx checked, if ctx has a reporter
+func Service(ctx context.Context, service string, location string, found int, elapsed time.Duration, err error) {
+	if r, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		r.Service(profileOf(ctx), service, location, found, elapsed, err)
+	}
+}
+
+// Discovered reports the discovery of the profile of ctx finished, if ctx has a reporter
+func Discovered(ctx context.Context, resources int) {
+	if r, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		r.Discovered(profileOf(ctx), resources)
+	}
+}
+
+// Seeds reports done of the total resources of scan synced, if ctx has a reporter
+func Seeds(ctx context.Context, scan string, done int, total int) {
+	if r, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		r.Seeds(scan, done, total)
+	}
+}
+
+func profileOf(ctx context.Context) string {
+	profile, _ := ctx.Value(profileKey{}).(string)
+	return profile
+}
+
+// reporters reports progress to each of its reporters in turn
+type reporters []Reporter
+
+func (rs reporters) Service(profile string, service string, location string, found int, elapsed time.Duration, err error) {
+	for _, r := range rs {
+		r.Service(profile, service, location, found, elapsed, err)
+	}
+}
+
+func (rs reporters) Discovered(profile string, resources int) {
+	for _, r := range rs {
+		r.Discovered(profile, resources)
+	}
+}
+
+func (rs reporters) Seeds(scan string, done int, total int) {
+	for _, r := range rs {
+		r.Seeds(scan, done, total)
+	}
+}
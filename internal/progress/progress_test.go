@@ -0,0 +1,47 @@
+package progress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_WithoutReporter(t *testing.T) {
+	assert.NotPanics(t, func() {
+		Service(context.Background(), "EC2", "eu-west-1", 1, time.Second, nil)
+		Discovered(context.Background(), 1)
+		Seeds(context.Background(), "scan-1", 1, 1)
+	})
+}
+
+type recorder struct {
+	profiles []string
+}
+
+func (r *recorder) Service(profile string, _ string, _ string, _ int, _ time.Duration, _ error) {
+	r.profiles = append(r.profiles, profile)
+}
+func (r *recorder) Discovered(profile string, _ int) { r.profiles = append(r.profiles, profile) }
+func (r *recorder) Seeds(string, int, int)           {}
+
+func TestService_ReportsProfileOfContext(t *testing.T) {
+	r := &recorder{}
+	ctx := WithProfile(WithReporter(context.Background(), r), "azure/main")
+
+	Service(ctx, "DNS", "", 1, time.Second, nil)
+	Discovered(ctx, 1)
+
+	assert.Equal(t, []string{"azure/main", "azure/main"}, r.profiles)
+}
+
+func TestWithReporter_ReportsToEachReporter(t *testing.T) {
+	first, second := &recorder{}, &recorder{}
+	ctx := WithReporter(WithReporter(context.Background(), first), second)
+
+	Discovered(WithProfile(ctx, "gcp/default"), 1)
+
+	assert.Equal(t, []string{"gcp/default"}, first.profiles)
+	assert.Equal(t, []string{"gcp/default"}, second.profiles)
+}
@@ -0,0 +1,211 @@
+// Package reporting reports panics and failed runs to Sentry or an error webhook, so operators hear
+// about a failed scheduled sync without watching the logs
+package reporting
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"runtime/debug"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	connector_http "github.com/hexiosec/asm-cloud-connector/internal/http"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/version"
+)
+
+// reportTimeout bounds sending a report, which happens as the run ends or crashes
+const reportTimeout = 10 * time.Second
+
+// Levels of an event
+const (
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// Event is a panic or failed run, as sent to the error webhook
+type Event struct {
+	ID        string            `json:"event_id"`
+	Timestamp time.Time         `json:"timestamp"`
+	Level     string            `json:"level"`
+	Message   string            `json:"message"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	// Stack is the stack trace of a panic
+	Stack string `json:"stack,omitempty"`
+}
+
+type tagsKey struct{}
+
+// WithTags returns a context whose panics and errors are reported with the tags of ctx and the
+// given key value pairs, e.g. "account", "123456789012"
+func WithTags(ctx context.Context, keyValues ...string) context.Context {
+	tags := maps.Clone(tagsOf(ctx))
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		tags[keyValues[i]] = keyValues[i+1]
+	}
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+func tagsOf(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
+
+// Panic is a recovered panic, with the stack it was raised from and the tags of the innermost
+// context it was recovered in
+type Panic struct {
+	Value any
+	Stack []byte
+	Tags  map[string]string
+}
+
+func (p *Panic) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// Recovered returns r, the value of recover, as a Panic with the current stack and the tags of
+// ctx. If r is a Panic already, the tags of ctx it doesn't have are added to it.
+func Recovered(ctx context.Context, r any) *Panic {
+	p, ok := r.(*Panic)
+	if !ok {
+		p = &Panic{Value: r, Stack: debug.Stack(), Tags: map[string]string{}}
+	}
+	for key, value := range tagsOf(ctx) {
+		if _, ok := p.Tags[key]; !ok {
+			p.Tags[key] = value
+		}
+	}
+	return p
+}
+
+// Repanic recovers a panic and panics again with it as a Panic with the tags of ctx, so the
+// tags of where it was raised are reported. Call it deferred.
+func Repanic(ctx context.Context) {
+	if r := recover(); r != nil {
+		panic(Recovered(ctx, r))
+	}
+}
+
+// Reporter reports panics and failed runs to Sentry and the error webhook, those that are
+// configured. It does nothing if neither is, or it's nil.
+type Reporter struct {
+	sentry  *sentryDSN
+	webhook string
+	env     string
+	client  connector_http.IHttpService
+}
+
+// NewReporter returns a reporter for the error reporting config of cfg
+func NewReporter(cfg *config.Config) (*Reporter, error) {
+	r := &Reporter{webhook: cfg.ErrorReporting.WebhookURL, env: cfg.ErrorReporting.Environment}
+	if cfg.ErrorReporting.SentryDSN != "" {
+		dsn, err := parseDSN(cfg.ErrorReporting.SentryDSN)
+		if err != nil {
+			return nil, err
+		}
+		r.sentry = dsn
+	}
+	if r.sentry == nil && r.webhook == "" {
+		return r, nil
+	}
+
+	client, err := connector_http.NewHttpService(cfg, version.UserAgent())
+	if err != nil {
+		return nil, fmt.Errorf("reporting: failed to create http service, %w", err)
+	}
+	r.client = client
+	return r, nil
+}
+
+// Recover reports a panic with the tags of ctx, then panics again so the process still crashes.
+// Call it deferred.
+func (r *Reporter) Recover(ctx context.Context) {
+	rec := recover()
+	if rec == nil {
+		return
+	}
+
+	p := Recovered(ctx, rec)
+	r.send(ctx, Event{
+		Level:   LevelFatal,
+		Message: p.Error(),
+		Tags:    p.Tags,
+		Stack:   string(p.Stack),
+	})
+	panic(p)
+}
+
+// Error reports the error of a failed run with the tags of ctx and tags
+func (r *Reporter) Error(ctx context.Context, err error, tags map[string]string) {
+	all := maps.Clone(tagsOf(ctx))
+	if all == nil {
+		all = map[string]string{}
+	}
+	maps.Copy(all, tags)
+
+	// A panic recovered as an error is reported with where it was raised
+	var p *Panic
+	if errors.As(err, &p) {
+		r.send(ctx, Event{Level: LevelFatal, Message: err.Error(), Tags: all, Stack: string(p.Stack)})
+		return
+	}
+	r.send(ctx, Event{Level: LevelError, Message: err.Error(), Tags: all})
+}
+
+// send sends event to Sentry and the webhook. Failures are only logged, reporting never fails the
+// run.
+func (r *Reporter) send(ctx context.Context, event Event) {
+	if r == nil || r.client == nil {
+		return
+	}
+
+	// Reported as the run ends, maybe because it was stopped
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), reportTimeout)
+	defer cancel()
+
+	event.ID = newEventID()
+	event.Timestamp = time.Now().UTC()
+	if event.Tags == nil {
+		event.Tags = map[string]string{}
+	}
+	event.Tags["version"] = version.Version()
+	if runID := connector_http.RunID(ctx); runID != "" {
+		event.Tags["run_id"] = runID
+	}
+
+	if r.sentry != nil {
+		if err := r.sendSentry(ctx, event); err != nil {
+			logger.GetLogger(ctx).Warn().Err(err).Msg("Could not report error to Sentry")
+		}
+	}
+	if r.webhook != "" {
+		if err := r.sendWebhook(ctx, event); err != nil {
+			logger.GetLogger(ctx).Warn().Err(err).Msg("Could not report error to error webhook")
+		}
+	}
+}
+
+func (r *Reporter) sendWebhook(ctx context.Context, event Event) error {
+	resp, err := r.client.Post(ctx, r.webhook, event, connector_http.HttpOptions{Timeout: reportTimeout})
+	if err != nil {
+		return fmt.Errorf("reporting: webhook request failed, %w", err)
+	}
+	if resp.GetStatusCode() < 200 || resp.GetStatusCode() > 299 {
+		return fmt.Errorf("reporting: webhook returned status %d", resp.GetStatusCode())
+	}
+	return nil
+}
+
+// newEventID returns a random ID for an event, 32 hex characters as Sentry expects
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
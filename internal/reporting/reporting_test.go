@@ -0,0 +1,171 @@
+package reporting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+type request struct {
+	path    string
+	headers http.Header
+	body    []byte
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *[]request) {
+	t.Helper()
+	received := []request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received = append(received, request{path: r.URL.Path, headers: r.Header, body: body})
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func TestReporter_Webhook_ReportsError(t *testing.T) {
+	server, received := newTestServer(t)
+	cfg := &config.Config{}
+	cfg.ErrorReporting.WebhookURL = server.URL
+	r, err := NewReporter(cfg)
+	require.NoError(t, err)
+
+	ctx := WithTags(context.Background(), "cloud_provider", "aws")
+	r.Error(ctx, errors.New("core: could not sync"), map[string]string{"scan_id": "scan-123"})
+
+	require.Len(t, *received, 1)
+	var event Event
+	require.NoError(t, json.Unmarshal((*received)[0].body, &event))
+	assert.Equal(t, LevelError, event.Level)
+	assert.Equal(t, "core: could not sync", event.Message)
+	assert.Equal(t, "aws", event.Tags["cloud_provider"])
+	assert.Equal(t, "scan-123", event.Tags["scan_id"])
+	assert.Len(t, event.ID, 32)
+}
+
+func TestReporter_Sentry_SendsEnvelope(t *testing.T) {
+	server, received := newTestServer(t)
+	cfg := &config.Config{}
+	cfg.ErrorReporting.SentryDSN = strings.Replace(server.URL, "://", "://public-key@", 1) + "/sentry/42"
+	cfg.ErrorReporting.Environment = "production"
+	r, err := NewReporter(cfg)
+	require.NoError(t, err)
+
+	r.Error(context.Background(), errors.New("core: could not sync"), nil)
+
+	require.Len(t, *received, 1)
+	req := (*received)[0]
+	assert.Equal(t, "/sentry/api/42/envelope/", req.path)
+	assert.Equal(t, envelopeContentType, req.headers.Get("Content-Type"))
+	assert.Contains(t, req.headers.Get("X-Sentry-Auth"), "sentry_key=public-key")
+
+	lines := bytes.Split(bytes.TrimSpace(req.body), []byte("\n"))
+	require.Len(t, lines, 3)
+	var header, item map[string]any
+	var event sentryEvent
+	require.NoError(t, json.Unmarshal(lines[0], &header))
+	require.NoError(t, json.Unmarshal(lines[1], &item))
+	require.NoError(t, json.Unmarshal(lines[2], &event))
+	assert.Equal(t, event.ID, header["event_id"])
+	assert.Equal(t, "event", item["type"])
+	assert.Equal(t, float64(len(lines[2])), item["length"])
+	assert.Equal(t, "core: could not sync", event.Message)
+	assert.Equal(t, "production", event.Environment)
+	assert.Equal(t, "go", event.Platform)
+}
+
+func TestReporter_Recover_ReportsAndPanics(t *testing.T) {
+	server, received := newTestServer(t)
+	cfg := &config.Config{}
+	cfg.ErrorReporting.WebhookURL = server.URL
+	r, err := NewReporter(cfg)
+	require.NoError(t, err)
+
+	ctx := WithTags(context.Background(), "cloud_provider", "aws")
+	defer func() {
+		p, ok := recover().(*Panic)
+		require.True(t, ok)
+		assert.Equal(t, "boom", p.Value)
+
+		require.Len(t, *received, 1)
+		var event Event
+		require.NoError(t, json.Unmarshal((*received)[0].body, &event))
+		assert.Equal(t, LevelFatal, event.Level)
+		assert.Equal(t, "panic: boom", event.Message)
+		// The tags of where it was raised, as well as of where it was recovered
+		assert.Equal(t, "123456789012", event.Tags["account"])
+		assert.Equal(t, "aws", event.Tags["cloud_provider"])
+		assert.Contains(t, event.Stack, "reporting_test.go")
+	}()
+	defer r.Recover(ctx)
+
+	func() {
+		defer Repanic(WithTags(ctx, "account", "123456789012"))
+		panic("boom")
+	}()
+}
+
+func TestReporter_NotConfigured_DoesNothing(t *testing.T) {
+	r, err := NewReporter(&config.Config{})
+	require.NoError(t, err)
+	r.Error(context.Background(), errors.New("core: could not sync"), nil)
+
+	var nilReporter *Reporter
+	nilReporter.Error(context.Background(), errors.New("core: could not sync"), nil)
+}
+
+func TestParseDSN(t *testing.T) {
+	testCases := []struct {
+		name     string
+		dsn      string
+		endpoint string
+		errText  string
+	}{
+		{
+			name:     "Valid",
+			dsn:      "https://key@o1.ingest.sentry.io/123",
+			endpoint: "https://o1.ingest.sentry.io/api/123/envelope/",
+		},
+		{
+			name:     "Path_Kept",
+			dsn:      "https://key@sentry.example.com/sentry/123",
+			endpoint: "https://sentry.example.com/sentry/api/123/envelope/",
+		},
+		{
+			name:    "MissingKey_Fails",
+			dsn:     "https://o1.ingest.sentry.io/123",
+			errText: "missing public key",
+		},
+		{
+			name:    "MissingProject_Fails",
+			dsn:     "https://key@o1.ingest.sentry.io/",
+			errText: "missing project ID",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dsn, err := parseDSN(tc.dsn)
+			if tc.errText != "" {
+				require.ErrorContains(t, err, tc.errText)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "key", dsn.key)
+			assert.Equal(t, tc.endpoint, dsn.endpoint)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package reporting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	connector_http "github.com/hexiosec/asm-cloud-connector/internal/http"
+	"github.com/hexiosec/asm-cloud-connector/internal/version"
+)
+
+// envelopeContentType is the content type of a Sentry envelope, a header line and then an item
+// header and payload line per item
+const envelopeContentType = "application/x-sentry-envelope"
+
+// sentryDSN is a parsed Sentry DSN, https://<key>@<host>[/<path>]/<project>
+type sentryDSN struct {
+	key      string
+	endpoint string
+}
+
+func parseDSN(dsn string) (*sentryDSN, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("reporting: invalid Sentry DSN, %w", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("reporting: invalid Sentry DSN, scheme must be https")
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("reporting: invalid Sentry DSN, missing public key")
+	}
+	// The project is the last element of the path, Sentry may be hosted under the rest of it
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 || path[i+1:] == "" {
+		return nil, fmt.Errorf("reporting: invalid Sentry DSN, missing project ID")
+	}
+	path, project := path[:i], path[i+1:]
+
+	return &sentryDSN{
+		key:      u.User.Username(),
+		endpoint: fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path, project),
+	}, nil
+}
+
+// sentryEvent is an event as Sentry takes it
+type sentryEvent struct {
+	ID          string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Release     string            `json:"release"`
+	Environment string            `json:"environment,omitempty"`
+	Message     string            `json:"message"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+// sendSentry sends event to Sentry as an envelope with one event item
+func (r *Reporter) sendSentry(ctx context.Context, event Event) error {
+	payload := sentryEvent{
+		ID:          event.ID,
+		Timestamp:   event.Timestamp.Format(time.RFC3339),
+		Level:       event.Level,
+		Platform:    "go",
+		Release:     version.UserAgent(),
+		Environment: r.env,
+		Message:     event.Message,
+		Tags:        event.Tags,
+	}
+	if event.Stack != "" {
+		payload.Extra = map[string]string{"stack": event.Stack}
+	}
+
+	body, err := envelope(event.ID, payload)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Post(ctx, r.sentry.endpoint, connector_http.RawBody{ContentType: envelopeContentType, Data: body}, connector_http.HttpOptions{
+		Timeout: reportTimeout,
+		Headers: map[string]string{
+			"X-Sentry-Auth": fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", version.UserAgent(), r.sentry.key),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reporting: Sentry request failed, %w", err)
+	}
+	if resp.GetStatusCode() < 200 || resp.GetStatusCode() > 299 {
+		return fmt.Errorf("reporting: Sentry returned status %d", resp.GetStatusCode())
+	}
+	return nil
+}
+
+func envelope(id string, payload sentryEvent) ([]byte, error) {
+	item, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("reporting: failed to encode Sentry event, %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `{"event_id":%q}`+"\n", id)
+	fmt.Fprintf(&b, `{"type":"event","length":%d}`+"\n", len(item))
+	b.Write(item)
+	b.WriteString("\n")
+	return []byte(b.String()), nil
+}
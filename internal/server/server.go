@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/health"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/metrics"
+	"github.com/hexiosec/asm-cloud-connector/internal/notify"
+)
+
+// ErrSyncInProgress is returned by a SyncFunc when a sync is already running
+var ErrSyncInProgress = errors.New("sync already in progress")
+
+const shutdownTimeout = 10 * time.Second
+
+// SyncFunc runs a sync, returning the summary of each scan synced
+type SyncFunc func(ctx context.Context) ([]notify.Summary, error)
+
+// Server serves health checks for Kubernetes probes, the metrics in the Prometheus text format, and
+// an authenticated endpoint that triggers a sync, e.g. from a webhook
+type Server struct {
+	listen string
+	token  string
+	sync   SyncFunc
+	// health is the liveness of the connector, /healthz is always healthy if nil
+	health *health.Tracker
+	// ctx is the context of the server, which syncs run with rather than the request's, so a
+	// client disconnecting doesn't stop a sync part way
+	ctx      context.Context
+	draining atomic.Bool
+}
+
+func New(listen string, token string, sync SyncFunc, tracker *health.Tracker) *Server {
+	return &Server{listen: listen, token: token, sync: sync, health: tracker, ctx: context.Background()}
+}
+
+// Handler returns the routes of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /readyz", s.readyz)
+	mux.HandleFunc("GET /metrics", s.metrics)
+	mux.HandleFunc("POST /sync", s.triggerSync)
+	return mux
+}
+
+// ListenAndServe serves until ctx is cancelled, then reports not ready and shuts down once
+// requests in progress complete
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	s.ctx = ctx
+	srv := &http.Server{
+		Addr:              s.listen,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		logger.GetLogger(ctx).Info().Str("listen", s.listen).Msg("Starting server")
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.draining.Store(true)
+	logger.GetLogger(ctx).Info().Msg("Stopping server")
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// healthz fails once no sync has succeeded for health.max_staleness, so a wedged connector is
+// restarted
+func (s *Server) healthz(w http.ResponseWriter, _ *http.Request) {
+	if s.health == nil {
+		writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+		return
+	}
+
+	status := s.health.Status(time.Now())
+	res := healthResponse{Status: "ok", LastSuccess: status.LastSuccess, Reason: status.Reason}
+	if !status.Healthy {
+		res.Status = "stale"
+		writeJSON(w, http.StatusServiceUnavailable, res)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// readyz fails once the server is shutting down, so no more syncs are sent to it
+func (s *Server) readyz(w http.ResponseWriter, _ *http.Request) {
+	if s.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := metrics.Default.WritePrometheus(w); err != nil {
+		logger.GetLogger(r.Context()).Warn().Err(err).Msg("Could not write metrics")
+	}
+}
+
+// healthResponse is the body of GET /healthz responses
+type healthResponse struct {
+	Status      string    `json:"status"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+// syncResponse is the body of POST /sync responses
+type syncResponse struct {
+	Success bool             `json:"success"`
+	Error   string           `json:"error,omitempty"`
+	Scans   []notify.Summary `json:"scans"`
+}
+
+func (s *Server) triggerSync(w http.ResponseWriter, r *http.Request) {
+	if !s.authorised(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, syncResponse{Error: "unauthorised"})
+		return
+	}
+
+	summaries, err := s.sync(s.ctx)
+	if errors.Is(err, ErrSyncInProgress) {
+		writeJSON(w, http.StatusConflict, syncResponse{Error: err.Error()})
+		return
+	}
+
+	res := syncResponse{Success: err == nil, Scans: summaries}
+	if res.Scans == nil {
+		res.Scans = []notify.Summary{}
+	}
+	status := http.StatusOK
+	if err != nil {
+		res.Error = err.Error()
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, res)
+}
+
+// authorised checks the bearer token of r in constant time
+func (s *Server) authorised(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || s.token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/health"
+	"github.com/hexiosec/asm-cloud-connector/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, sync SyncFunc) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(New(":0", "secret", sync, nil).Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func postSync(t *testing.T, url string, token string) (*http.Response, syncResponse) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url+"/sync", nil)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body syncResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp, body
+}
+
+func TestServer_Health(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	for _, path := range []string{"/healthz", "/readyz", "/metrics"} {
+		resp, err := http.Get(ts.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+	}
+}
+
+func TestServer_Readyz_Draining(t *testing.T) {
+	s := New(":0", "secret", nil, nil)
+	s.draining.Store(true)
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+
+	resp, err := http.Get(ts.URL + "/readyz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestServer_Sync_Success(t *testing.T) {
+	ts := newTestServer(t, func(ctx context.Context) ([]notify.Summary, error) {
+		return []notify.Summary{{ScanID: "scan", Success: true, Added: 2}}, nil
+	})
+
+	resp, body := postSync(t, ts.URL, "secret")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, body.Success)
+	require.Len(t, body.Scans, 1)
+	assert.Equal(t, "scan", body.Scans[0].ScanID)
+	assert.Equal(t, 2, body.Scans[0].Added)
+}
+
+func TestServer_Sync_Failed(t *testing.T) {
+	ts := newTestServer(t, func(ctx context.Context) ([]notify.Summary, error) {
+		return []notify.Summary{{ScanID: "scan", Error: "boom"}}, errors.New("boom")
+	})
+
+	resp, body := postSync(t, ts.URL, "secret")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.False(t, body.Success)
+	assert.Equal(t, "boom", body.Error)
+	assert.Len(t, body.Scans, 1)
+}
+
+func TestServer_Sync_InProgress(t *testing.T) {
+	ts := newTestServer(t, func(ctx context.Context) ([]notify.Summary, error) {
+		return nil, ErrSyncInProgress
+	})
+
+	resp, _ := postSync(t, ts.URL, "secret")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestServer_Sync_Unauthorised(t *testing.T) {
+	ts := newTestServer(t, func(ctx context.Context) ([]notify.Summary, error) {
+		t.Fatal("sync should not run")
+		return nil, nil
+	})
+
+	for _, token := range []string{"", "wrong"} {
+		resp, _ := postSync(t, ts.URL, token)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func TestServer_Sync_MethodNotAllowed(t *testing.T) {
+	ts := newTestServer(t, nil)
+
+	resp, err := http.Get(ts.URL + "/sync")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestServer_Healthz_Stale(t *testing.T) {
+	tracker := health.NewTracker("", time.Minute, time.Now().Add(-time.Hour))
+	ts := httptest.NewServer(New(":0", "secret", nil, tracker).Handler())
+	t.Cleanup(ts.Close)
+
+	resp, err := http.Get(ts.URL + "/healthz")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body healthResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "stale", body.Status)
+	assert.NotEmpty(t, body.Reason)
+}
+
+func TestServer_Healthz_RecentSuccess(t *testing.T) {
+	tracker := health.NewTracker("", time.Minute, time.Now().Add(-time.Hour))
+	tracker.Success(context.Background(), time.Now())
+	ts := httptest.NewServer(New(":0", "secret", nil, tracker).Handler())
+	t.Cleanup(ts.Close)
+
+	resp, err := http.Get(ts.URL + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
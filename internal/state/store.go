@@ -0,0 +1,108 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+// IStore persists small JSON documents between runs, keyed by name
+type IStore interface {
+	// Load decodes the document stored under key into v, returning false if there is none
+	Load(ctx context.Context, key string, v any) (bool, error)
+	Save(ctx context.Context, key string, v any) error
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStore returns a file store in the configured state directory, or a store that
+// keeps nothing if no directory is configured
+func NewStore(cfg *config.Config) (IStore, error) {
+	if cfg.State.Dir == "" {
+		return &nopStore{}, nil
+	}
+
+	if err := os.MkdirAll(cfg.State.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("state: could not create state directory %s, %w", cfg.State.Dir, err)
+	}
+
+	return &fileStore{dir: cfg.State.Dir}, nil
+}
+
+type fileStore struct {
+	dir string
+}
+
+func (s *fileStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+func (s *fileStore) Load(_ context.Context, key string, v any) (bool, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("state: could not read %s, %w", key, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("state: could not decode %s, %w", key, err)
+	}
+
+	return true, nil
+}
+
+func (s *fileStore) Save(_ context.Context, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("state: could not encode %s, %w", key, err)
+	}
+
+	// Write to a temporary file and rename, so a crash mid-write never leaves a truncated document
+	tmp, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("state: could not create temporary file for %s, %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("state: could not write %s, %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("state: could not write %s, %w", key, err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path(key)); err != nil {
+		return fmt.Errorf("state: could not write %s, %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *fileStore) Delete(_ context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("state: could not delete %s, %w", key, err)
+	}
+
+	return nil
+}
+
+type nopStore struct{}
+
+func (s *nopStore) Load(_ context.Context, _ string, _ any) (bool, error) {
+	return false, nil
+}
+
+func (s *nopStore) Save(_ context.Context, _ string, _ any) error {
+	return nil
+}
+
+func (s *nopStore) Delete(_ context.Context, _ string) error {
+	return nil
+}
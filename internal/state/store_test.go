@@ -0,0 +1,86 @@
+package state
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+type testDoc struct {
+	Name  string   `json:"name"`
+	Items []string `json:"items"`
+}
+
+func newTestStore(t *testing.T) (IStore, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "state")
+	cfg := &config.Config{}
+	cfg.State.Dir = dir
+	store, err := NewStore(cfg)
+	require.NoError(t, err)
+	return store, dir
+}
+
+func TestFileStore_SaveLoad_Success(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.Save(ctx, "doc", testDoc{Name: "a", Items: []string{"b"}}))
+
+	var got testDoc
+	ok, err := store.Load(ctx, "doc", &got)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, testDoc{Name: "a", Items: []string{"b"}}, got)
+}
+
+func TestFileStore_Load_Missing_NotFound(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	var got testDoc
+	ok, err := store.Load(context.Background(), "missing", &got)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestFileStore_Load_Corrupt_Err(t *testing.T) {
+	store, dir := newTestStore(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "doc.json"), []byte("{"), 0o600))
+
+	var got testDoc
+	_, err := store.Load(context.Background(), "doc", &got)
+	assert.ErrorContains(t, err, "could not decode doc")
+}
+
+func TestFileStore_Delete_Success(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.Save(ctx, "doc", testDoc{Name: "a"}))
+	require.NoError(t, store.Delete(ctx, "doc"))
+	require.NoError(t, store.Delete(ctx, "doc"))
+
+	var got testDoc
+	ok, err := store.Load(ctx, "doc", &got)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestNewStore_NoDir_Nop(t *testing.T) {
+	store, err := NewStore(&config.Config{})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, store.Save(ctx, "doc", testDoc{Name: "a"}))
+
+	var got testDoc
+	ok, err := store.Load(ctx, "doc", &got)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,97 @@
+// Package systemd implements the sd_notify protocol, so the daemon can run as a Type=notify systemd
+// service with a watchdog, without linking libsystemd
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+// Notify sends state, e.g. "READY=1", to the service manager. It does nothing if the connector
+// isn't run by systemd with NOTIFY_SOCKET set, e.g. outside a Type=notify service.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// An @ prefix is an abstract socket
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("systemd: could not connect to notify socket, %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("systemd: could not notify, %w", err)
+	}
+	return nil
+}
+
+// Ready tells the service manager the daemon has started
+func Ready(ctx context.Context) {
+	notify(ctx, "READY=1")
+}
+
+// Stopping tells the service manager the daemon is stopping
+func Stopping(ctx context.Context) {
+	notify(ctx, "STOPPING=1")
+}
+
+// Status sets the status shown by systemctl status
+func Status(ctx context.Context, status string) {
+	notify(ctx, "STATUS="+status)
+}
+
+// notify sends state, failures are only logged as the daemon runs without systemd too
+func notify(ctx context.Context, state string) {
+	if err := Notify(state); err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Str("state", state).Msg("Could not notify systemd")
+	}
+}
+
+// WatchdogInterval returns the interval of the systemd watchdog, false if it isn't enabled for the
+// connector
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	// WATCHDOG_PID is only set if the watchdog is for a single process, which must be this one
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// Watchdog pings the systemd watchdog at half its interval until ctx is cancelled, while healthy
+// returns true. Once healthy returns false the pings stop, so systemd restarts the daemon. It does
+// nothing if the watchdog isn't enabled.
+func Watchdog(ctx context.Context, healthy func() bool) {
+	interval, ok := WatchdogInterval()
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		if healthy() {
+			notify(ctx, "WATCHDOG=1")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
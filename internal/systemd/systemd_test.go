@@ -0,0 +1,50 @@
+package systemd
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotify_NoSocket_Nop(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	assert.NoError(t, Notify("READY=1"))
+}
+
+func TestNotify_SendsState(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	require.NoError(t, Notify("READY=1"))
+
+	buf := make([]byte, 64)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "READY=1", string(buf[:n]))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(1<<30))
+	_, ok := WatchdogInterval()
+	assert.False(t, ok, "watchdog of another process")
+
+	t.Setenv("WATCHDOG_PID", "")
+	interval, ok := WatchdogInterval()
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, interval)
+
+	t.Setenv("WATCHDOG_USEC", "")
+	_, ok = WatchdogInterval()
+	assert.False(t, ok)
+}
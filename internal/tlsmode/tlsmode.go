@@ -0,0 +1,140 @@
+// Package tlsmode applies network.tls_mode to the outbound clients of the process: the clients of
+// the AWS, Azure and GCP SDKs the connector creates, and http.DefaultTransport, which the GCP SDK,
+// its token requests and the remote config sources build on. The connector's own clients are
+// restricted by internal/http with the mode of their config.
+package tlsmode
+
+import (
+	"crypto/fips140"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// RestrictedCipherSuites are the TLS 1.2 cipher suites of network.tls_mode restricted and fips,
+// with forward secrecy and AES-GCM. The TLS 1.3 cipher suites aren't configurable, and all use AEAD.
+var RestrictedCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// RestrictedCurves are the key exchange curves of network.tls_mode restricted and fips, the NIST
+// curves approved for FIPS
+var RestrictedCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+
+var (
+	// restricted is whether the mode of the process is restricted or fips
+	restricted atomic.Bool
+	// defaultTLS is the TLS config of http.DefaultTransport before a mode was set, restored by
+	// the default mode
+	defaultTLS = sync.OnceValue(func() *tls.Config {
+		if tlsConfig := http.DefaultTransport.(*http.Transport).TLSClientConfig; tlsConfig != nil {
+			return tlsConfig.Clone()
+		}
+		return nil
+	})
+)
+
+// Restrict limits tlsConfig to TLS 1.2 or later, and the cipher suites and curves of mode
+// restricted and fips. fips fails unless Go's FIPS 140-3 mode is on, which restricts all the crypto
+// of the process to FIPS approved algorithms.
+func Restrict(tlsConfig *tls.Config, mode string) error {
+	if mode != "restricted" && mode != "fips" {
+		return nil
+	}
+	if mode == "fips" && !fips140.Enabled() {
+		return fmt.Errorf("tlsmode: network.tls_mode fips needs Go's FIPS 140-3 mode, use the FIPS build or set GODEBUG=fips140=on")
+	}
+
+	tlsConfig.MinVersion = max(tlsConfig.MinVersion, tls.VersionTLS12)
+	tlsConfig.CipherSuites = RestrictedCipherSuites
+	tlsConfig.CurvePreferences = RestrictedCurves
+	return nil
+}
+
+// Set makes mode the TLS mode of the process, restricting http.DefaultTransport and the cloud SDK
+// clients created after it. An empty mode is the default mode.
+func Set(mode string) error {
+	isRestricted := mode == "restricted" || mode == "fips"
+	tlsConfig := defaultTLS()
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+	} else if isRestricted {
+		tlsConfig = &tls.Config{}
+	}
+	if isRestricted {
+		if err := Restrict(tlsConfig, mode); err != nil {
+			return err
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport)
+	restricted.Store(isRestricted)
+	transport.TLSClientConfig = tlsConfig
+	// Connections negotiated under the previous mode aren't reused
+	transport.CloseIdleConnections()
+	return nil
+}
+
+// Restricted returns whether the mode of the process is restricted or fips
+func Restricted() bool {
+	return restricted.Load()
+}
+
+// restrictedTLS returns a clone of tlsConfig, or a new config if nil, limited as by Restrict
+func restrictedTLS(tlsConfig *tls.Config) *tls.Config {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	// Only fips can fail, and its check was made by Set
+	_ = Restrict(tlsConfig, "restricted")
+	return tlsConfig
+}
+
+// AWS is a load option of the AWS SDK config, giving its clients, and those of its credentials, a
+// restricted transport unless the mode of the process is default
+func AWS(o *awsconfig.LoadOptions) error {
+	if !Restricted() {
+		return nil
+	}
+	o.HTTPClient = awshttp.NewBuildableClient().WithTransportOptions(func(transport *http.Transport) {
+		transport.TLSClientConfig = restrictedTLS(transport.TLSClientConfig)
+	})
+	return nil
+}
+
+// Azure returns the client options of Azure SDK clients and credentials, with a restricted
+// transport unless the mode of the process is default
+func Azure() policy.ClientOptions {
+	if !Restricted() {
+		return policy.ClientOptions{}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = restrictedTLS(transport.TLSClientConfig)
+	return policy.ClientOptions{Transport: &http.Client{Transport: transport}}
+}
+
+// GCP returns the client options of GCP SDK clients restricting their gRPC connections unless the
+// mode of the process is default. Their HTTP connections build on http.DefaultTransport, which Set
+// restricts.
+func GCP() []option.ClientOption {
+	if !Restricted() {
+		return nil
+	}
+	return []option.ClientOption{
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(credentials.NewTLS(restrictedTLS(nil)))),
+	}
+}
@@ -0,0 +1,62 @@
+package tlsmode
+
+import (
+	"crypto/fips140"
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet_Restricted(t *testing.T) {
+	t.Cleanup(func() { _ = Set("") })
+
+	require.NoError(t, Set("restricted"))
+
+	assert.True(t, Restricted())
+	tlsConfig := http.DefaultTransport.(*http.Transport).TLSClientConfig
+	require.NotNil(t, tlsConfig)
+	assert.Equal(t, RestrictedCipherSuites, tlsConfig.CipherSuites)
+	assert.Equal(t, RestrictedCurves, tlsConfig.CurvePreferences)
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+
+	// The cloud SDK clients get a restricted transport
+	var opts awsconfig.LoadOptions
+	require.NoError(t, AWS(&opts))
+	assert.NotNil(t, opts.HTTPClient)
+	azure := Azure().Transport.(*http.Client).Transport.(*http.Transport)
+	assert.Equal(t, RestrictedCipherSuites, azure.TLSClientConfig.CipherSuites)
+	assert.Len(t, GCP(), 1)
+}
+
+func TestSet_Default_Restores(t *testing.T) {
+	previous := http.DefaultTransport.(*http.Transport).TLSClientConfig
+	require.NoError(t, Set("restricted"))
+
+	require.NoError(t, Set("default"))
+
+	assert.False(t, Restricted())
+	assert.Equal(t, previous, http.DefaultTransport.(*http.Transport).TLSClientConfig)
+	var opts awsconfig.LoadOptions
+	require.NoError(t, AWS(&opts))
+	assert.Nil(t, opts.HTTPClient)
+	assert.Nil(t, Azure().Transport)
+	assert.Empty(t, GCP())
+}
+
+func TestSet_FIPS(t *testing.T) {
+	t.Cleanup(func() { _ = Set("") })
+
+	err := Set("fips")
+
+	if !fips140.Enabled() {
+		assert.ErrorContains(t, err, "network.tls_mode fips needs Go's FIPS 140-3 mode")
+		assert.False(t, Restricted())
+		return
+	}
+	require.NoError(t, err)
+	assert.True(t, Restricted())
+}
@@ -0,0 +1,64 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// defaultVersion is the version of a build without one injected or stamped by go install
+const defaultVersion = "0.0.0"
+
+var (
+	// Injected at build time with the commit and the time of the build
+	// -ldflags "-X github.com/hexiosec/asm-cloud-connector/internal/version.commit=$$(git rev-parse HEAD)
+	//   -X github.com/hexiosec/asm-cloud-connector/internal/version.date=$$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+	commit string
+	date   string
+)
+
+// Info is the build information of the binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// BuildInfo returns the build information injected with -ldflags. Fields that weren't injected
+// fall back to what Go stamps into the binary, the module version of go install and the VCS
+// commit and time of go build in a checkout.
+var BuildInfo = sync.OnceValue(func() Info {
+	bi, _ := debug.ReadBuildInfo()
+	return newInfo(version, commit, date, bi)
+})
+
+// newInfo returns the build information of the injected values, falling back to bi, which may be nil
+func newInfo(version string, commit string, date string, bi *debug.BuildInfo) Info {
+	info := Info{Version: version, Commit: commit, Date: date, GoVersion: runtime.Version()}
+	if bi == nil {
+		return info
+	}
+
+	info.GoVersion = bi.GoVersion
+	// go build in a checkout stamps (devel) rather than a version
+	if info.Version == defaultVersion && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
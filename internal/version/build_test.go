@@ -0,0 +1,42 @@
+package version
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewInfo_Injected(t *testing.T) {
+	bi := &debug.BuildInfo{
+		GoVersion: "go1.24.4",
+		Main:      debug.Module{Version: "v0.9.0"},
+		Settings:  []debug.BuildSetting{{Key: "vcs.revision", Value: "def456"}, {Key: "vcs.time", Value: "2025-01-01T00:00:00Z"}},
+	}
+
+	info := newInfo("v1.2.3", "abc123", "2025-02-01T00:00:00Z", bi)
+
+	assert.Equal(t, Info{Version: "v1.2.3", Commit: "abc123", Date: "2025-02-01T00:00:00Z", GoVersion: "go1.24.4"}, info)
+}
+
+func TestNewInfo_FallsBackToBuildInfo(t *testing.T) {
+	bi := &debug.BuildInfo{
+		GoVersion: "go1.24.4",
+		Main:      debug.Module{Version: "v0.9.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "def456"},
+			{Key: "vcs.time", Value: "2025-01-01T00:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	info := newInfo(defaultVersion, "", "", bi)
+
+	assert.Equal(t, Info{Version: "v0.9.0", Commit: "def456", Date: "2025-01-01T00:00:00Z", Modified: true, GoVersion: "go1.24.4"}, info)
+}
+
+func TestNewInfo_DevelBuild_KeepsDefaultVersion(t *testing.T) {
+	info := newInfo(defaultVersion, "", "", &debug.BuildInfo{GoVersion: "go1.24.4", Main: debug.Module{Version: "(devel)"}})
+
+	assert.Equal(t, defaultVersion, info.Version)
+}
@@ -0,0 +1,151 @@
+package version
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheTTL is how long the latest version is cached, so many connectors starting together don't
+// exceed GitHub's unauthenticated rate limit of 60 requests an hour
+const cacheTTL = 6 * time.Hour
+
+// cacheFile is the name of the file the latest version is cached in, see DefaultCachePath
+const cacheFile = "latest-version.json"
+
+// cachedRelease is the latest release of a source, as found at CheckedAt
+type cachedRelease struct {
+	CheckedAt time.Time `json:"checked_at"`
+	// Found is false if the source had no release
+	Found   bool    `json:"found"`
+	Release release `json:"release"`
+	// Validators of the response the release was found in, so once the release expires it is
+	// looked up again with a conditional request
+	Validators validators `json:"validators"`
+}
+
+// validators are the ETag and Last-Modified of a response, which make the next request for it
+// conditional, so it isn't downloaded again if it hasn't changed
+type validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// headers returns the headers of a request conditional on v, nil if v is empty
+func (v validators) headers() map[string]string {
+	if v.ETag == "" && v.LastModified == "" {
+		return nil
+	}
+	headers := map[string]string{}
+	if v.ETag != "" {
+		headers["If-None-Match"] = v.ETag
+	}
+	if v.LastModified != "" {
+		headers["If-Modified-Since"] = v.LastModified
+	}
+	return headers
+}
+
+// Cache holds the latest release of each source the version is checked against, GitHub or Docker
+// Hub, with the validators to look it up again conditionally. It is shared by every run of the
+// process, or by every process with a file cache, so a new HTTP service each run doesn't lose it.
+type Cache interface {
+	Get(source string) (cachedRelease, bool)
+	Put(source string, entry cachedRelease)
+}
+
+// NewMemoryCache returns a cache kept for the life of the process, for a daemon or server
+func NewMemoryCache() Cache {
+	return &memoryCache{entries: map[string]cachedRelease{}}
+}
+
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedRelease
+}
+
+func (c *memoryCache) Get(source string) (cachedRelease, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[source]
+	return entry, ok
+}
+
+func (c *memoryCache) Put(source string, entry cachedRelease) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[source] = entry
+}
+
+// NewFileCache returns a cache kept in the file at path, shared by the runs of a one-shot connector
+// and by connectors on the same host. A file that can't be read or written is a cache miss.
+func NewFileCache(path string) Cache {
+	return &fileCache{path: path}
+}
+
+// DefaultCachePath returns the file the latest version is cached in, in the user cache directory,
+// or the temp directory if there's none, e.g. /tmp in a Lambda
+func DefaultCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, userAgentProduct, cacheFile)
+}
+
+type fileCache struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (c *fileCache) Get(source string) (cachedRelease, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.read()[source]
+	return entry, ok
+}
+
+func (c *fileCache) Put(source string, entry cachedRelease) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := c.read()
+	entries[source] = entry
+
+	encoded, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return
+	}
+	// Written to a temp file and renamed, so a connector reading it concurrently never sees it half
+	// written
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), cacheFile+".*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(encoded); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	_ = os.Rename(tmp.Name(), c.path)
+}
+
+// read returns the entries of the file, none if it can't be read
+func (c *fileCache) read() map[string]cachedRelease {
+	entries := map[string]cachedRelease{}
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		return entries
+	}
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return map[string]cachedRelease{}
+	}
+	return entries
+}
@@ -0,0 +1,114 @@
+package version
+
+import (
+	h "net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/http"
+)
+
+func mockLatestRelease(t *testing.T, deps *dependencies, tag string) {
+	t.Helper()
+	resp := http.NewMockHttpResponse(t)
+	resp.On("GetStatusCode").Return(200)
+	resp.On("HasBody").Return(true)
+	resp.On("GetBody").Return(map[string]any{
+		"tag_name": tag,
+	})
+	resp.On("GetHeader").Return(h.Header{"Etag": {`"` + tag + `"`}})
+	deps.http.On("Get", home, mock.Anything).Return(resp, nil).Once()
+}
+
+func TestLogVersion_Cached_LookedUpOnce(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	checker.cache = NewMemoryCache()
+	mockLatestRelease(t, deps, "v1.2.3")
+
+	checker.LogVersion(deps.ctx)
+	// The mock fails if GitHub is called again
+	checker.LogVersion(deps.ctx)
+
+	assert.Contains(t, deps.logBuffer.String(), "Using cached latest version from GitHub")
+	assert.Equal(t, 2, strings.Count(deps.logBuffer.String(), "New version available, v1.2.3"))
+}
+
+func TestLogVersion_CacheExpired_LookedUpAgain(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	checker.cache = NewMemoryCache()
+	checker.cache.Put("GitHub", cachedRelease{CheckedAt: time.Now().Add(-cacheTTL - time.Minute), Found: true, Release: release{TagName: "v1.0.0"}})
+	mockLatestRelease(t, deps, "v1.2.3")
+
+	checker.LogVersion(deps.ctx)
+
+	assert.Contains(t, deps.logBuffer.String(), "New version available, v1.2.3")
+}
+
+func TestLogVersion_CacheExpired_NotModified_CachedReleaseKept(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	checker.cache = NewMemoryCache()
+	checker.cache.Put("GitHub", cachedRelease{
+		CheckedAt:  time.Now().Add(-cacheTTL - time.Minute),
+		Found:      true,
+		Release:    release{TagName: "v1.2.3"},
+		Validators: validators{ETag: `"v1.2.3"`},
+	})
+
+	resp := http.NewMockHttpResponse(t)
+	resp.On("GetStatusCode").Return(h.StatusNotModified)
+	deps.http.On("Get", home, http.HttpOptions{Headers: map[string]string{"If-None-Match": `"v1.2.3"`}, Timeout: checkTimeout}).Return(resp, nil).Once()
+
+	checker.LogVersion(deps.ctx)
+	assert.Contains(t, deps.logBuffer.String(), "not modified, using cached latest version")
+	assert.Contains(t, deps.logBuffer.String(), "New version available, v1.2.3")
+
+	// Revalidated, so cached for cacheTTL again
+	entry, ok := checker.cache.Get("GitHub")
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now(), entry.CheckedAt, time.Minute)
+	assert.Equal(t, `"v1.2.3"`, entry.Validators.ETag)
+}
+
+func TestLogVersion_Cached_ValidatorsKept(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	checker.cache = NewMemoryCache()
+	mockLatestRelease(t, deps, "v1.2.3")
+
+	checker.LogVersion(deps.ctx)
+
+	entry, ok := checker.cache.Get("GitHub")
+	require.True(t, ok)
+	assert.Equal(t, validators{ETag: `"v1.2.3"`}, entry.Validators)
+}
+
+func TestFileCache_SharedBetweenCaches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", cacheFile)
+	checkedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	NewFileCache(path).Put("GitHub", cachedRelease{CheckedAt: checkedAt, Found: true, Release: release{TagName: "v1.2.3", HTMLURL: "https://github.com/hexiosec/asm-cloud-connector/releases/tag/v1.2.3"}})
+	NewFileCache(path).Put("Docker Hub", cachedRelease{CheckedAt: checkedAt, Found: true, Release: release{TagName: "1.2.3"}})
+
+	entry, ok := NewFileCache(path).Get("GitHub")
+	require.True(t, ok)
+	assert.True(t, entry.CheckedAt.Equal(checkedAt))
+	assert.Equal(t, "v1.2.3", entry.Release.TagName)
+	assert.Equal(t, "https://github.com/hexiosec/asm-cloud-connector/releases/tag/v1.2.3", entry.Release.HTMLURL)
+	_, ok = NewFileCache(path).Get("Docker Hub")
+	assert.True(t, ok)
+}
+
+func TestFileCache_Corrupt_Miss(t *testing.T) {
+	path := filepath.Join(t.TempDir(), cacheFile)
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+
+	_, ok := NewFileCache(path).Get("GitHub")
+
+	assert.False(t, ok)
+}
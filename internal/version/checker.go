@@ -2,9 +2,14 @@ package version
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	h "net/http"
+	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/hexiosec/asm-cloud-connector/internal/http"
@@ -15,31 +20,116 @@ import (
 var (
 	// Injected at build time with the git tag
 	// -ldflags "-X github.com/hexiosec/asm-cloud-connector/internal/version.version=$$(git describe --tags --abbrev=0)"
-	version string = "0.0.0"
+	version string = defaultVersion
 )
 
+// userAgentProduct is the product of the User-Agent header, followed by the version
+const userAgentProduct = "hexiosec-cloud-connector"
+
 const (
 	home string = "https://api.github.com/repos/hexiosec/asm-cloud-connector/releases/latest"
+	// releases lists the releases, including pre-releases, most recent first
+	releases string = "https://api.github.com/repos/hexiosec/asm-cloud-connector/releases"
+)
+
+// Release channels the version is checked against
+const (
+	// ChannelStable is compared against the latest stable release
+	ChannelStable = "stable"
+	// ChannelBeta is compared against the latest release, including pre-releases
+	ChannelBeta = "beta"
+)
+
+const (
+	// checkTimeout bounds the version check, including retries, so a network dropping requests to
+	// GitHub or Docker Hub doesn't hold up a run
+	checkTimeout = 10 * time.Second
+	// unreachableBackoff is how long the check is skipped once it's unreachable, e.g. from an
+	// air-gapped network, so a daemon doesn't try and log it every run
+	unreachableBackoff = 24 * time.Hour
+	// maxNotesLength is the longest summary of release notes logged
+	maxNotesLength = 500
+)
+
+// errNotModified is returned by a lookup of the latest release that was answered 304 Not Modified,
+// so the cached release is still the latest
+var errNotModified = errors.New("checker: not modified")
+
+var (
+	unreachableMu sync.Mutex
+	// unreachableUntil is when the version is checked again, once it was unreachable
+	unreachableUntil time.Time
 )
 
 type checker struct {
 	http http.IHttpService
+	// container checks the tags of the published image rather than the GitHub releases, as a
+	// container is upgraded by pulling a new image
+	container bool
+	// cache holds the latest version for cacheTTL, nil to always look it up
+	cache Cache
+	// channel is the release channel, ChannelStable if empty
+	channel string
 }
 
-func NewChecker(http http.IHttpService) (*checker, error) {
+// Version returns the build version, see BuildInfo
+func Version() string {
+	return BuildInfo().Version
+}
+
+// UserAgent returns the User-Agent header of requests, stamped with the build version
+func UserAgent() string {
+	return userAgentProduct + "/" + Version()
+}
+
+// NewChecker returns a version checker of the release channel, ChannelStable or ChannelBeta,
+// caching the latest version in cache, which may be nil to always look it up
+func NewChecker(http http.IHttpService, cache Cache, channel string) (*checker, error) {
 	return &checker{
-		http: http,
+		http:      http,
+		container: InContainer(),
+		cache:     cache,
+		channel:   channel,
 	}, nil
 }
 
 type release struct {
-	TagName string `mapstructure:"tag_name" validate:"required"`
+	TagName    string `mapstructure:"tag_name" validate:"required"`
+	Name       string `mapstructure:"name"`
+	Prerelease bool   `mapstructure:"prerelease"`
+	Draft      bool   `mapstructure:"draft"`
+	// Body is the release notes, in markdown
+	Body    string  `mapstructure:"body"`
+	HTMLURL string  `mapstructure:"html_url"`
+	Assets  []asset `mapstructure:"assets" validate:"dive"`
+}
+
+// asset is a file attached to a release, e.g. the binary of an OS and architecture
+type asset struct {
+	Name string `mapstructure:"name" validate:"required"`
+	URL  string `mapstructure:"browser_download_url" validate:"required,url"`
 }
 
-// LogVersion compares the embedded build version to the latest Git tag and logs version status.
+// LogVersion compares the embedded build version to the latest Git tag, or image tag in a
+// container, and logs version status.
 func (c *checker) LogVersion(ctx context.Context) {
-	iCtx := logger.WithLogger(ctx, logger.GetLogger(ctx).With().Str("current", version).Logger())
-	ok, remoteV, err := c.getLatestVersion(iCtx)
+	current := Version()
+	iCtx := logger.WithLogger(ctx, logger.GetLogger(ctx).With().Str("current", current).Logger())
+	getLatest, source := c.getLatestRelease, "GitHub"
+	if c.container {
+		getLatest, source = c.getLatestImageTag, "Docker Hub"
+	}
+	if until, skip := skipUnreachable(); skip {
+		logger.GetLogger(iCtx).Debug().Time("until", until).Msgf("Skipping version check, %s was unreachable", source)
+		return
+	}
+
+	ok, rel, err := c.cached(iCtx, source, getLatest)
+	if isUnreachable(err) {
+		markUnreachable()
+		logger.GetLogger(iCtx).Info().Err(err).Msgf("Could not reach %s to check for a new version, assuming an air-gapped network. Set version_check: disabled to skip the check.", source)
+		return
+	}
 	if err != nil {
 		logger.GetLogger(iCtx).Warn().Err(err).Msg("Failed to get latest version from remote repository")
 		return
@@ -50,45 +140,227 @@ func (c *checker) LogVersion(ctx context.Context) {
 		return
 	}
 
-	newAvail, err := isGreaterThan(remoteV, version)
+	newAvail, err := isGreaterThan(rel.TagName, current)
 	if err != nil {
-		logger.GetLogger(iCtx).Warn().Err(err).Str("remote", remoteV).Msg("Failed to compare current and remote version")
+		logger.GetLogger(iCtx).Warn().Err(err).Str("remote", rel.TagName).Msg("Failed to compare current and remote version")
+		return
+	}
+
+	if !newAvail {
+		logger.GetLogger(iCtx).Info().Msgf("Running latest version, %s", current)
+		return
+	}
+
+	// The release notes are logged so operators can prioritise the upgrade, a security release first
+	security := isSecurityRelease(rel)
+	event := logger.GetLogger(iCtx).Warn().Str("remote", rel.TagName).Bool("security", security)
+	if c.container {
+		event = event.Str("image", image+":"+rel.TagName)
+	}
+	if rel.HTMLURL != "" {
+		event = event.Str("url", rel.HTMLURL)
+	}
+	if notes := summariseNotes(rel.Body); notes != "" {
+		event = event.Str("notes", notes)
+	}
+	if security {
+		event.Msgf("New version available with security fixes, upgrade as soon as possible, %s", rel.TagName)
 		return
 	}
+	event.Msgf("New version available, %s", rel.TagName)
+}
+
+// latestFunc looks up the latest release of a source, conditional on the validators of the cached
+// release, returning errNotModified if it hasn't changed
+type latestFunc func(ctx context.Context, prev validators) (bool, release, validators, error)
+
+// cached returns the latest release of source from the cache, or looks it up with getLatest and
+// caches it. An expired release is looked up conditionally, and kept if it hasn't changed. The
+// latest release of each channel is cached separately.
+func (c *checker) cached(ctx context.Context, source string, getLatest latestFunc) (bool, release, error) {
+	key := source
+	if c.channel == ChannelBeta {
+		key += " " + ChannelBeta
+	}
+
+	var stale *cachedRelease
+	if c.cache != nil {
+		if entry, ok := c.cache.Get(key); ok {
+			if time.Since(entry.CheckedAt) < cacheTTL {
+				logger.GetLogger(ctx).Debug().Time("checked_at", entry.CheckedAt).Msgf("Using cached latest version from %s", source)
+				return entry.Found, entry.Release, nil
+			}
+			stale = &entry
+		}
+	}
+
+	var prev validators
+	if stale != nil {
+		prev = stale.Validators
+	}
+	ok, rel, v, err := getLatest(ctx, prev)
+	if errors.Is(err, errNotModified) && stale != nil {
+		logger.GetLogger(ctx).Debug().Time("checked_at", stale.CheckedAt).Msgf("Latest version from %s not modified, using cached latest version", source)
+		ok, rel, v, err = stale.Found, stale.Release, stale.Validators, nil
+	}
+	if err == nil && c.cache != nil {
+		c.cache.Put(key, cachedRelease{CheckedAt: time.Now(), Found: ok, Release: rel, Validators: v})
+	}
+	return ok, rel, err
+}
+
+// get performs a GET of url with query, conditional on prev, returning errNotModified if it is
+// answered 304 Not Modified. The validators of a successful response are returned if it is cached.
+func (c *checker) get(ctx context.Context, url string, query map[string]string, prev validators) (http.IHttpResponse, validators, error) {
+	resp, err := c.http.Get(ctx, url, http.HttpOptions{Headers: prev.headers(), QueryParams: query, Timeout: checkTimeout})
+	if err != nil {
+		return nil, validators{}, err
+	}
+	if resp.GetStatusCode() == h.StatusNotModified {
+		return nil, validators{}, errNotModified
+	}
 
-	if newAvail {
-		logger.GetLogger(iCtx).Warn().Str("remote", remoteV).Msgf("New version available, %s", remoteV)
-	} else {
-		logger.GetLogger(iCtx).Info().Msgf("Running latest version, %s", version)
+	var v validators
+	if c.cache != nil && resp.GetStatusCode() == h.StatusOK {
+		v = validators{ETag: resp.GetHeader().Get("ETag"), LastModified: resp.GetHeader().Get("Last-Modified")}
 	}
+	return resp, v, nil
 }
 
-func (c *checker) getLatestVersion(ctx context.Context) (bool, string, error) {
-	resp, err := c.http.Get(ctx, home, http.HttpOptions{})
+// getLatestRelease returns the latest release of the channel, false if there's none
+func (c *checker) getLatestRelease(ctx context.Context, prev validators) (bool, release, validators, error) {
+	if c.channel == ChannelBeta {
+		return c.getLatestPrerelease(ctx, prev)
+	}
+
+	resp, v, err := c.get(ctx, home, nil, prev)
 	if err != nil {
-		return false, "", err
+		return false, release{}, validators{}, err
 	}
 
 	if resp.GetStatusCode() == h.StatusNotFound {
 		// No release found
-		return false, "", nil
+		return false, release{}, validators{}, nil
 	}
 
 	if resp.GetStatusCode() != h.StatusOK {
-		return false, "", fmt.Errorf("checker: received non-200 code %d", resp.GetStatusCode())
+		return false, release{}, validators{}, fmt.Errorf("checker: received non-200 code %d", resp.GetStatusCode())
 	}
 
 	if !resp.HasBody() {
-		return false, "", fmt.Errorf("checker: request successful but no body returned")
+		return false, release{}, validators{}, fmt.Errorf("checker: request successful but no body returned")
 	}
 
 	rel := release{}
 	err = util.MapStructDecodeAndValidate(resp.GetBody(), &rel)
 	if err != nil {
-		return false, "", fmt.Errorf("checker: failed to destruct and validate response %w", err)
+		return false, release{}, validators{}, fmt.Errorf("checker: failed to destruct and validate response %w", err)
 	}
 
-	return true, rel.TagName, nil
+	return true, rel, v, nil
+}
+
+// getLatestPrerelease returns the release with the highest version, including pre-releases, false
+// if there's none. GitHub's latest release is only ever a stable release.
+func (c *checker) getLatestPrerelease(ctx context.Context, prev validators) (bool, release, validators, error) {
+	resp, v, err := c.get(ctx, releases, map[string]string{"per_page": "30"}, prev)
+	if err != nil {
+		return false, release{}, validators{}, err
+	}
+
+	if resp.GetStatusCode() != h.StatusOK {
+		return false, release{}, validators{}, fmt.Errorf("checker: received non-200 code %d", resp.GetStatusCode())
+	}
+
+	if !resp.HasBody() {
+		return false, release{}, validators{}, fmt.Errorf("checker: request successful but no body returned")
+	}
+
+	rels := []release{}
+	err = util.MapStructDecodeAndValidate(resp.GetBody(), &rels)
+	if err != nil {
+		return false, release{}, validators{}, fmt.Errorf("checker: failed to destruct and validate response %w", err)
+	}
+
+	var latest *semver.Version
+	var latestRel release
+	for _, rel := range rels {
+		v, err := semver.NewVersion(strings.TrimPrefix(rel.TagName, "v"))
+		if rel.Draft || err != nil {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest, latestRel = v, rel
+		}
+	}
+	if latest == nil {
+		return false, release{}, v, nil
+	}
+
+	return true, latestRel, v, nil
+}
+
+// isSecurityRelease returns whether a release is flagged security relevant, with a Security
+// section in its notes, as in the changelog, or security in its name
+func isSecurityRelease(rel release) bool {
+	if strings.Contains(strings.ToLower(rel.Name), "security") {
+		return true
+	}
+	for _, line := range strings.Split(rel.Body, "\n") {
+		if heading, ok := strings.CutPrefix(strings.TrimSpace(line), "#"); ok {
+			if strings.EqualFold(strings.Trim(heading, "# "), "security") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// summariseNotes returns the entries of release notes on one line, without headings and links to
+// the full changelog, truncated to maxNotesLength
+func summariseNotes(body string) string {
+	var entries []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "**Full Changelog**") {
+			continue
+		}
+		line = strings.TrimLeft(line, "-*+ ")
+		if line != "" {
+			entries = append(entries, line)
+		}
+	}
+
+	summary := strings.Join(entries, "; ")
+	if runes := []rune(summary); len(runes) > maxNotesLength {
+		summary = string(runes[:maxNotesLength-1]) + "…"
+	}
+	return summary
+}
+
+// isUnreachable returns whether err is GitHub being unreachable, its name not resolving, the
+// connection being refused or dropped, or the check timing out, rather than a failed response
+func isUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	return errors.As(err, &dnsErr) || errors.As(err, &opErr) || errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err)
+}
+
+// markUnreachable skips the check for unreachableBackoff
+func markUnreachable() {
+	unreachableMu.Lock()
+	defer unreachableMu.Unlock()
+	unreachableUntil = time.Now().Add(unreachableBackoff)
+}
+
+// skipUnreachable returns whether the check is skipped as GitHub was unreachable, and until when
+func skipUnreachable() (time.Time, bool) {
+	unreachableMu.Lock()
+	defer unreachableMu.Unlock()
+	return unreachableUntil, time.Now().Before(unreachableUntil)
 }
 
 // compares SemVer strings and returns a > b
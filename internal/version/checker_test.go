@@ -3,11 +3,18 @@ package version
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/hexiosec/asm-cloud-connector/internal/http"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
@@ -83,6 +90,31 @@ func TestLogVersion_RemoteGreater_Warns(t *testing.T) {
 	assert.Contains(t, deps.logBuffer.String(), "New version available")
 }
 
+func TestLogVersion_SecurityRelease_LogsNotes(t *testing.T) {
+	checker, deps := newTestChecker(t)
+
+	resp := http.NewMockHttpResponse(t)
+	resp.On("GetStatusCode").Return(200)
+	resp.On("HasBody").Return(true)
+	resp.On("GetBody").Return(map[string]any{
+		"tag_name": "v1.2.3",
+		"html_url": "https://github.com/hexiosec/asm-cloud-connector/releases/tag/v1.2.3",
+		"body":     "## [1.2.3]\n\n### Security\n\n- Redact SAS tokens from logs\n\n### Fixed\n\n- Retry throttled requests\n",
+	})
+	deps.http.On(
+		"Get",
+		home,
+		mock.Anything,
+	).Return(resp, nil)
+
+	checker.LogVersion(deps.ctx)
+
+	assert.Contains(t, deps.logBuffer.String(), "New version available with security fixes")
+	assert.Contains(t, deps.logBuffer.String(), `"security":true`)
+	assert.Contains(t, deps.logBuffer.String(), `"notes":"Redact SAS tokens from logs; Retry throttled requests"`)
+	assert.Contains(t, deps.logBuffer.String(), `"url":"https://github.com/hexiosec/asm-cloud-connector/releases/tag/v1.2.3"`)
+}
+
 func TestLogVersion_RemoteNotGreater_ReportsLatest(t *testing.T) {
 	checker, deps := newTestChecker(t)
 
@@ -123,7 +155,7 @@ func TestLogVersion_InvalidRemoteVersion_WarnsCompare(t *testing.T) {
 	assert.Contains(t, deps.logBuffer.String(), "Failed to compare")
 }
 
-func TestGetLatestVersion_HTTPError_Err(t *testing.T) {
+func TestGetLatestRelease_HTTPError_Err(t *testing.T) {
 	checker, deps := newTestChecker(t)
 
 	deps.http.On(
@@ -132,14 +164,14 @@ func TestGetLatestVersion_HTTPError_Err(t *testing.T) {
 		mock.Anything,
 	).Return(nil, assert.AnError)
 
-	ok, version, err := checker.getLatestVersion(deps.ctx)
+	ok, rel, _, err := checker.getLatestRelease(deps.ctx, validators{})
 
 	assert.False(t, ok)
-	assert.Empty(t, version)
+	assert.Empty(t, rel.TagName)
 	assert.ErrorIs(t, err, assert.AnError)
 }
 
-func TestGetLatestVersion_Non200_Err(t *testing.T) {
+func TestGetLatestRelease_Non200_Err(t *testing.T) {
 	checker, deps := newTestChecker(t)
 
 	resp := http.NewMockHttpResponse(t)
@@ -150,14 +182,14 @@ func TestGetLatestVersion_Non200_Err(t *testing.T) {
 		mock.Anything,
 	).Return(resp, nil)
 
-	ok, version, err := checker.getLatestVersion(deps.ctx)
+	ok, rel, _, err := checker.getLatestRelease(deps.ctx, validators{})
 
 	assert.False(t, ok)
-	assert.Empty(t, version)
+	assert.Empty(t, rel.TagName)
 	assert.Contains(t, err.Error(), "400")
 }
 
-func TestGetLatestVersion_NoBody_Err(t *testing.T) {
+func TestGetLatestRelease_NoBody_Err(t *testing.T) {
 	checker, deps := newTestChecker(t)
 
 	resp := http.NewMockHttpResponse(t)
@@ -169,14 +201,14 @@ func TestGetLatestVersion_NoBody_Err(t *testing.T) {
 		mock.Anything,
 	).Return(resp, nil)
 
-	ok, version, err := checker.getLatestVersion(deps.ctx)
+	ok, rel, _, err := checker.getLatestRelease(deps.ctx, validators{})
 
 	assert.False(t, ok)
-	assert.Empty(t, version)
+	assert.Empty(t, rel.TagName)
 	assert.Contains(t, err.Error(), "no body")
 }
 
-func TestGetLatestVersion_DecodeError_Err(t *testing.T) {
+func TestGetLatestRelease_DecodeError_Err(t *testing.T) {
 	checker, deps := newTestChecker(t)
 
 	resp := http.NewMockHttpResponse(t)
@@ -191,15 +223,15 @@ func TestGetLatestVersion_DecodeError_Err(t *testing.T) {
 		mock.Anything,
 	).Return(resp, nil)
 
-	ok, version, err := checker.getLatestVersion(deps.ctx)
+	ok, rel, _, err := checker.getLatestRelease(deps.ctx, validators{})
 
 	assert.False(t, ok)
-	assert.Empty(t, version)
+	assert.Empty(t, rel.TagName)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to destruct and validate response")
 }
 
-func TestGetLatestVersion_ReleaseNotFound_False(t *testing.T) {
+func TestGetLatestRelease_ReleaseNotFound_False(t *testing.T) {
 	checker, deps := newTestChecker(t)
 
 	resp := http.NewMockHttpResponse(t)
@@ -210,14 +242,14 @@ func TestGetLatestVersion_ReleaseNotFound_False(t *testing.T) {
 		mock.Anything,
 	).Return(resp, nil)
 
-	ok, version, err := checker.getLatestVersion(deps.ctx)
+	ok, rel, _, err := checker.getLatestRelease(deps.ctx, validators{})
 
 	assert.False(t, ok)
-	assert.Empty(t, version)
+	assert.Empty(t, rel.TagName)
 	assert.NoError(t, err)
 }
 
-func TestGetLatestVersion_Release_True(t *testing.T) {
+func TestGetLatestRelease_Release_True(t *testing.T) {
 	checker, deps := newTestChecker(t)
 
 	resp := http.NewMockHttpResponse(t)
@@ -232,10 +264,10 @@ func TestGetLatestVersion_Release_True(t *testing.T) {
 		mock.Anything,
 	).Return(resp, nil)
 
-	ok, version, err := checker.getLatestVersion(deps.ctx)
+	ok, rel, _, err := checker.getLatestRelease(deps.ctx, validators{})
 
 	assert.True(t, ok)
-	assert.Equal(t, "v1.2.3", version)
+	assert.Equal(t, "v1.2.3", rel.TagName)
 	assert.NoError(t, err)
 }
 
@@ -273,3 +305,91 @@ func TestIsGreaterThan_RemoteNotGreater_False(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, ok)
 }
+
+func TestUserAgent_StampedWithVersion(t *testing.T) {
+	assert.Equal(t, "hexiosec-cloud-connector/"+version, UserAgent())
+}
+
+func TestLogVersion_Unreachable_InfoThenSkipped(t *testing.T) {
+	t.Cleanup(func() { unreachableUntil = time.Time{} })
+	checker, deps := newTestChecker(t)
+
+	dnsErr := &net.DNSError{Err: "no such host", Name: "api.github.com", IsNotFound: true}
+	deps.http.On(
+		"Get",
+		home,
+		mock.Anything,
+	).Return(nil, &url.Error{Op: "Get", URL: home, Err: dnsErr}).Once()
+
+	checker.LogVersion(deps.ctx)
+	assert.Contains(t, deps.logBuffer.String(), `"level":"info"`)
+	assert.Contains(t, deps.logBuffer.String(), "assuming an air-gapped network")
+	assert.NotContains(t, deps.logBuffer.String(), `"level":"warn"`)
+
+	// Not checked again until the backoff passes, the mock fails if it is
+	deps.logBuffer.Reset()
+	checker.LogVersion(deps.ctx)
+	assert.Contains(t, deps.logBuffer.String(), "Skipping version check")
+}
+
+func TestIsUnreachable(t *testing.T) {
+	assert.True(t, isUnreachable(&url.Error{Op: "Get", URL: home, Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}))
+	assert.True(t, isUnreachable(fmt.Errorf("request failed, %w", context.DeadlineExceeded)))
+	assert.False(t, isUnreachable(context.Canceled))
+	assert.False(t, isUnreachable(assert.AnError))
+	assert.False(t, isUnreachable(nil))
+}
+
+func TestIsSecurityRelease(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rel      release
+		security bool
+	}{
+		{"SecuritySection", release{Body: "### Security\n- Redact SAS tokens"}, true},
+		{"SecurityInName", release{Name: "v1.2.3 (security)"}, true},
+		{"SecurityInEntry", release{Body: "### Added\n- Discover security groups"}, false},
+		{"NoNotes", release{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.security, isSecurityRelease(tc.rel))
+		})
+	}
+}
+
+func TestSummariseNotes_Long_Truncated(t *testing.T) {
+	notes := summariseNotes("## What's Changed\n* " + strings.Repeat("a", 600) + "\n\n**Full Changelog**: https://github.com/hexiosec/asm-cloud-connector/compare/v1.2.2...v1.2.3")
+
+	assert.Len(t, []rune(notes), maxNotesLength)
+	assert.True(t, strings.HasPrefix(notes, "aaa"))
+	assert.True(t, strings.HasSuffix(notes, "…"))
+}
+
+func TestGetLatestRelease_Beta_IncludesPrereleases(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	checker.channel = ChannelBeta
+
+	resp := http.NewMockHttpResponse(t)
+	resp.On("GetStatusCode").Return(200)
+	resp.On("HasBody").Return(true)
+	resp.On("GetBody").Return([]any{
+		map[string]any{"tag_name": "v1.5.0", "draft": true},
+		map[string]any{"tag_name": "v1.4.0-beta.2", "prerelease": true},
+		map[string]any{"tag_name": "v1.3.0"},
+		map[string]any{"tag_name": "nightly", "prerelease": true},
+	})
+	deps.http.On(
+		"Get",
+		releases,
+		mock.Anything,
+	).Return(resp, nil)
+
+	ok, rel, _, err := checker.getLatestRelease(deps.ctx, validators{})
+
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "v1.4.0-beta.2", rel.TagName)
+	deps.http.AssertNotCalled(t, "Get", home, mock.Anything)
+}
@@ -0,0 +1,125 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	h "net/http"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/hexiosec/asm-cloud-connector/internal/util"
+)
+
+const (
+	// image is the published container image of the connector
+	image string = "docker.io/hexiosec/asm-cloud-connector"
+	// imageTags lists the tags of image
+	imageTags string = "https://hub.docker.com/v2/repositories/hexiosec/asm-cloud-connector/tags"
+)
+
+// containerEnvVars are set by container runtimes and orchestrators in the containers they run
+var containerEnvVars = []string{
+	"container",                     // podman, systemd-nspawn
+	"KUBERNETES_SERVICE_HOST",       // Kubernetes
+	"ECS_CONTAINER_METADATA_URI_V4", // ECS and Fargate
+	"K_SERVICE",                     // Cloud Run
+	"CONTAINER_APP_NAME",            // Azure Container Apps
+}
+
+// containerFiles are created by container runtimes in the containers they run
+var containerFiles = []string{"/.dockerenv", "/run/.containerenv"}
+
+// containerCgroups are in the cgroup paths of a process in a container, with cgroup v1
+var containerCgroups = []string{"docker", "kubepods", "containerd", "libpod"}
+
+// environment is how InContainer looks at the process, replaced in tests
+type environment struct {
+	lookupEnv func(string) (string, bool)
+	stat      func(string) (os.FileInfo, error)
+	readFile  func(string) ([]byte, error)
+}
+
+var hostEnvironment = environment{lookupEnv: os.LookupEnv, stat: os.Stat, readFile: os.ReadFile}
+
+// InContainer returns whether the connector is running in a container, as the deployments pulling
+// its image do, detected from the env vars and files container runtimes set and the cgroup of the
+// process
+func InContainer() bool {
+	return hostEnvironment.inContainer()
+}
+
+func (e environment) inContainer() bool {
+	for _, name := range containerEnvVars {
+		if _, ok := e.lookupEnv(name); ok {
+			return true
+		}
+	}
+	for _, path := range containerFiles {
+		if _, err := e.stat(path); err == nil {
+			return true
+		}
+	}
+
+	cgroup, err := e.readFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	for _, name := range containerCgroups {
+		if strings.Contains(string(cgroup), name) {
+			return true
+		}
+	}
+	return false
+}
+
+type tagList struct {
+	Results []struct {
+		Name string `mapstructure:"name" validate:"required"`
+	} `mapstructure:"results" validate:"dive"`
+}
+
+// getLatestImageTag returns the highest version tag of the published image as a release, false if
+// there's none. Tags that aren't a version, e.g. latest, are ignored, as are pre-releases unless
+// the channel is ChannelBeta.
+func (c *checker) getLatestImageTag(ctx context.Context, prev validators) (bool, release, validators, error) {
+	resp, v, err := c.get(ctx, imageTags, map[string]string{"page_size": "100", "ordering": "last_updated"}, prev)
+	if err != nil {
+		return false, release{}, validators{}, err
+	}
+
+	if resp.GetStatusCode() == h.StatusNotFound {
+		// No image published
+		return false, release{}, validators{}, nil
+	}
+
+	if resp.GetStatusCode() != h.StatusOK {
+		return false, release{}, validators{}, fmt.Errorf("checker: received non-200 code %d", resp.GetStatusCode())
+	}
+
+	if !resp.HasBody() {
+		return false, release{}, validators{}, fmt.Errorf("checker: request successful but no body returned")
+	}
+
+	tags := tagList{}
+	if err := util.MapStructDecodeAndValidate(resp.GetBody(), &tags); err != nil {
+		return false, release{}, validators{}, fmt.Errorf("checker: failed to destruct and validate response %w", err)
+	}
+
+	var latest *semver.Version
+	var latestTag string
+	for _, tag := range tags.Results {
+		v, err := semver.NewVersion(strings.TrimPrefix(tag.Name, "v"))
+		if err != nil || (v.Prerelease() != "" && c.channel != ChannelBeta) {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest, latestTag = v, tag.Name
+		}
+	}
+	if latest == nil {
+		return false, release{}, v, nil
+	}
+
+	return true, release{TagName: latestTag}, v, nil
+}
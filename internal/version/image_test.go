@@ -0,0 +1,113 @@
+package version
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/http"
+)
+
+func testEnvironment(env map[string]string, files map[string]string) environment {
+	return environment{
+		lookupEnv: func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		},
+		stat: func(path string) (os.FileInfo, error) {
+			if _, ok := files[path]; ok {
+				return nil, nil
+			}
+			return nil, os.ErrNotExist
+		},
+		readFile: func(path string) ([]byte, error) {
+			if content, ok := files[path]; ok {
+				return []byte(content), nil
+			}
+			return nil, os.ErrNotExist
+		},
+	}
+}
+
+func TestInContainer(t *testing.T) {
+	testCases := []struct {
+		name      string
+		env       map[string]string
+		files     map[string]string
+		container bool
+	}{
+		{"Kubernetes", map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1"}, nil, true},
+		{"ECS", map[string]string{"ECS_CONTAINER_METADATA_URI_V4": "http://169.254.170.2/v4/abc"}, nil, true},
+		{"Docker", nil, map[string]string{"/.dockerenv": ""}, true},
+		{"Cgroup", nil, map[string]string{"/proc/1/cgroup": "12:pids:/docker/0123456789abcdef\n"}, true},
+		{"Host", nil, map[string]string{"/proc/1/cgroup": "0::/init.scope\n"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.container, testEnvironment(tc.env, tc.files).inContainer())
+		})
+	}
+}
+
+func mockImageTags(t *testing.T, deps *dependencies, tags ...string) {
+	t.Helper()
+	results := []any{}
+	for _, tag := range tags {
+		results = append(results, map[string]any{"name": tag})
+	}
+
+	resp := http.NewMockHttpResponse(t)
+	resp.On("GetStatusCode").Return(200)
+	resp.On("HasBody").Return(true)
+	resp.On("GetBody").Return(map[string]any{"results": results})
+	deps.http.On("Get", imageTags, mock.Anything).Return(resp, nil)
+}
+
+func TestGetLatestImageTag_HighestVersion(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	mockImageTags(t, deps, "latest", "1.10.0-rc.1", "1.9.0", "v1.2.3", "1.10.0-alpine")
+
+	ok, rel, _, err := checker.getLatestImageTag(deps.ctx, validators{})
+
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "1.9.0", rel.TagName)
+}
+
+func TestGetLatestImageTag_NoVersionTags_False(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	mockImageTags(t, deps, "latest", "main")
+
+	ok, _, _, err := checker.getLatestImageTag(deps.ctx, validators{})
+
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestLogVersion_Container_ChecksImageTags(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	checker.container = true
+	mockImageTags(t, deps, "latest", "1.2.3")
+
+	checker.LogVersion(deps.ctx)
+
+	assert.Contains(t, deps.logBuffer.String(), "New version available, 1.2.3")
+	assert.Contains(t, deps.logBuffer.String(), `"image":"docker.io/hexiosec/asm-cloud-connector:1.2.3"`)
+	deps.http.AssertNotCalled(t, "Get", home, mock.Anything)
+}
+
+func TestGetLatestImageTag_Beta_IncludesPrereleases(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	checker.channel = ChannelBeta
+	mockImageTags(t, deps, "latest", "1.10.0-rc.1", "1.9.0")
+
+	ok, rel, _, err := checker.getLatestImageTag(deps.ctx, validators{})
+
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "1.10.0-rc.1", rel.TagName)
+}
@@ -0,0 +1,243 @@
+package version
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	h "net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/http"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+var (
+	// Injected at build time with the base64 encoded Ed25519 public key release checksums are signed
+	// with, by go run ./cmd/release, which derives it from the signing key of the release
+	releaseKey string
+)
+
+const (
+	// ChecksumsAsset lists the SHA-256 checksum of each binary of a release, as written by sha256sum
+	ChecksumsAsset = "checksums.txt"
+	// SignatureAsset is the base64 encoded Ed25519 signature of ChecksumsAsset and the version of
+	// the release, see SignedPayload
+	SignatureAsset = "checksums.txt.sig"
+	// downloadTimeout bounds the download of each asset, the binary being the largest
+	downloadTimeout = 5 * time.Minute
+)
+
+// ErrNoReleaseKey is returned updating a build without a release key, e.g. one built from source,
+// which can't verify a release came from Hexiosec so never replaces itself
+var ErrNoReleaseKey = errors.New("checker: no release key in this build to verify the signature of the release with")
+
+// UpdateOptions are the options of SelfUpdate
+type UpdateOptions struct {
+	// Executable is the binary replaced by the update
+	Executable string
+}
+
+// Update is the outcome of SelfUpdate
+type Update struct {
+	Current string
+	Latest  string
+	// Updated is whether the binary was replaced, false if it's already the latest version
+	Updated bool
+}
+
+// SelfUpdate replaces the executable with the binary of the latest release for the current OS and
+// architecture, if it's newer than the running version. The binary is only replaced once its
+// checksum, and the signature of the checksums for the tag of the release, are verified, so an
+// older signed release published under a newer tag isn't installed.
+func (c *checker) SelfUpdate(ctx context.Context, opts UpdateOptions) (Update, error) {
+	update := Update{Current: Version()}
+	ok, rel, _, err := c.getLatestRelease(ctx, validators{})
+	if err != nil {
+		return update, err
+	}
+	if !ok {
+		return update, fmt.Errorf("checker: no release found")
+	}
+	update.Latest = rel.TagName
+
+	newer, err := isGreaterThan(rel.TagName, update.Current)
+	if err != nil {
+		return update, err
+	}
+	if !newer {
+		return update, nil
+	}
+
+	key, err := releasePublicKey()
+	if err != nil {
+		return update, err
+	}
+
+	name := BinaryAsset(runtime.GOOS, runtime.GOARCH)
+	iCtx := logger.WithLogger(ctx, logger.GetLogger(ctx).With().Str("current", update.Current).Str("latest", rel.TagName).Str("asset", name).Logger())
+
+	checksums, err := c.download(iCtx, rel, ChecksumsAsset)
+	if err != nil {
+		return update, err
+	}
+	signature, err := c.download(iCtx, rel, SignatureAsset)
+	if err != nil {
+		return update, err
+	}
+	if err := verifySignature(key, rel.TagName, checksums, signature); err != nil {
+		return update, err
+	}
+
+	want, err := findChecksum(checksums, name)
+	if err != nil {
+		return update, err
+	}
+
+	logger.GetLogger(iCtx).Info().Msg("Downloading release")
+	binary, err := c.download(iCtx, rel, name)
+	if err != nil {
+		return update, err
+	}
+	if got := sha256.Sum256(binary); hex.EncodeToString(got[:]) != want {
+		return update, fmt.Errorf("checker: checksum of %s doesn't match %s", name, ChecksumsAsset)
+	}
+
+	if err := replaceExecutable(opts.Executable, binary); err != nil {
+		return update, err
+	}
+	logger.GetLogger(iCtx).Info().Str("path", opts.Executable).Msg("Replaced the binary with the latest release")
+
+	update.Updated = true
+	return update, nil
+}
+
+// releasePublicKey returns the key release checksums are signed with
+func releasePublicKey() (ed25519.PublicKey, error) {
+	if releaseKey == "" {
+		return nil, ErrNoReleaseKey
+	}
+
+	key, err := base64.StdEncoding.DecodeString(releaseKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("checker: invalid release key in this build")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// download returns the content of the asset name of rel
+func (c *checker) download(ctx context.Context, rel release, name string) ([]byte, error) {
+	idx := -1
+	for i, a := range rel.Assets {
+		if a.Name == name {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("checker: release %s has no %s", rel.TagName, name)
+	}
+
+	resp, err := c.http.Get(ctx, rel.Assets[idx].URL, http.HttpOptions{Timeout: downloadTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("checker: failed to download %s, %w", name, err)
+	}
+	if resp.GetStatusCode() != h.StatusOK {
+		return nil, fmt.Errorf("checker: received non-200 code %d downloading %s", resp.GetStatusCode(), name)
+	}
+	if !resp.HasBody() {
+		return nil, fmt.Errorf("checker: %s is empty", name)
+	}
+	return resp.GetRawBody(), nil
+}
+
+// BinaryAsset returns the name of the release binary of an OS and architecture, e.g.
+// connector_linux_amd64, as cmd/release names it and SelfUpdate looks it up
+func BinaryAsset(goos string, goarch string) string {
+	name := fmt.Sprintf("connector_%s_%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// SignedPayload returns what the signature of a release signs, its version and checksums, so the
+// signature of one release doesn't verify under the tag of another. A leading v of the version is
+// ignored, so v1.4.0 and 1.4.0 sign the same.
+func SignedPayload(version string, checksums []byte) []byte {
+	return append(fmt.Appendf(nil, "asm-cloud-connector %s\n", strings.TrimPrefix(version, "v")), checksums...)
+}
+
+// verifySignature checks signature, base64 encoded, is the signature by key of checksums for the
+// release version
+func verifySignature(key ed25519.PublicKey, version string, checksums []byte, signature []byte) error {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("checker: failed to decode %s, %w", SignatureAsset, err)
+	}
+	if !ed25519.Verify(key, SignedPayload(version, checksums), decoded) {
+		return fmt.Errorf("checker: signature of %s is invalid for release %s", ChecksumsAsset, version)
+	}
+	return nil
+}
+
+// findChecksum returns the SHA-256 checksum of name in checksums, lines of "<checksum>  <name>"
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sha256sum marks a file read in binary mode with *
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("checker: %s has no checksum of %s", ChecksumsAsset, name)
+}
+
+// replaceExecutable replaces the binary at path with binary, keeping its permissions. The binary
+// is written next to it and renamed over it, so path is never left half written. Windows can't
+// replace a running binary, but can rename it, so it's moved aside to path.old first.
+func replaceExecutable(path string, binary []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("checker: failed to stat executable, %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".update-*")
+	if err != nil {
+		return fmt.Errorf("checker: failed to create update file, %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return fmt.Errorf("checker: failed to write update file, %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("checker: failed to write update file, %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("checker: failed to set permissions of update file, %w", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		_ = os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return fmt.Errorf("checker: failed to move executable aside, %w", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("checker: failed to replace executable, %w", err)
+	}
+	return nil
+}
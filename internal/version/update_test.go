@@ -0,0 +1,212 @@
+package version
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/http"
+)
+
+const assetURL = "https://github.com/hexiosec/asm-cloud-connector/releases/download/v1.2.3/"
+
+// testRelease is a release of binary, whose checksums are signed with key
+type testRelease struct {
+	binary    []byte
+	checksums []byte
+	signature []byte
+}
+
+func newTestRelease(t *testing.T, key ed25519.PrivateKey) testRelease {
+	t.Helper()
+	binary := []byte("#!/bin/sh\necho v1.2.3\n")
+	sum := sha256.Sum256(binary)
+	checksums := fmt.Appendf(nil, "%s  %s\n%s  connector_plan9_386\n", hex.EncodeToString(sum[:]), BinaryAsset(runtime.GOOS, runtime.GOARCH), hex.EncodeToString(sum[:]))
+	return testRelease{
+		binary:    binary,
+		checksums: checksums,
+		signature: sign(key, "v1.2.3", checksums),
+	}
+}
+
+// sign returns the signature of checksums for version, as cmd/release writes it
+func sign(key ed25519.PrivateKey, version string, checksums []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, SignedPayload(version, checksums))) + "\n")
+}
+
+// setReleaseKey sets the release key of the build for the test
+func setReleaseKey(t *testing.T, key ed25519.PublicKey) {
+	t.Helper()
+	previous := releaseKey
+	t.Cleanup(func() { releaseKey = previous })
+	releaseKey = base64.StdEncoding.EncodeToString(key)
+}
+
+func (r testRelease) mock(t *testing.T, deps *dependencies) {
+	t.Helper()
+	name := BinaryAsset(runtime.GOOS, runtime.GOARCH)
+
+	latest := http.NewMockHttpResponse(t)
+	latest.On("GetStatusCode").Return(200)
+	latest.On("HasBody").Return(true)
+	latest.On("GetBody").Return(map[string]any{
+		"tag_name": "v1.2.3",
+		"assets": []any{
+			map[string]any{"name": name, "browser_download_url": assetURL + name},
+			map[string]any{"name": ChecksumsAsset, "browser_download_url": assetURL + ChecksumsAsset},
+			map[string]any{"name": SignatureAsset, "browser_download_url": assetURL + SignatureAsset},
+		},
+	})
+	deps.http.On("Get", home, mock.Anything).Return(latest, nil)
+
+	for asset, body := range map[string][]byte{name: r.binary, ChecksumsAsset: r.checksums, SignatureAsset: r.signature} {
+		resp := http.NewMockHttpResponse(t)
+		resp.On("GetStatusCode").Return(200).Maybe()
+		resp.On("HasBody").Return(true).Maybe()
+		resp.On("GetRawBody").Return(body).Maybe()
+		deps.http.On("Get", assetURL+asset, mock.Anything).Return(resp, nil).Maybe()
+	}
+}
+
+func newExecutable(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "connector")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0o755))
+	return path
+}
+
+func TestSelfUpdate_Verified_ReplacesExecutable(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	setReleaseKey(t, public)
+	rel := newTestRelease(t, private)
+	rel.mock(t, deps)
+	path := newExecutable(t)
+
+	update, err := checker.SelfUpdate(deps.ctx, UpdateOptions{Executable: path})
+
+	require.NoError(t, err)
+	assert.True(t, update.Updated)
+	assert.Equal(t, "v1.2.3", update.Latest)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, rel.binary, content)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+	}
+}
+
+func TestSelfUpdate_BadSignature_KeepsExecutable(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	setReleaseKey(t, public)
+	newTestRelease(t, other).mock(t, deps)
+	path := newExecutable(t)
+
+	_, err = checker.SelfUpdate(deps.ctx, UpdateOptions{Executable: path})
+
+	assert.ErrorContains(t, err, "signature of checksums.txt is invalid")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(content))
+}
+
+func TestSelfUpdate_SignedForOtherVersion_KeepsExecutable(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	setReleaseKey(t, public)
+	// An older release, signed for its own version, published again under a newer tag
+	rel := newTestRelease(t, private)
+	rel.signature = sign(private, "v1.2.2", rel.checksums)
+	rel.mock(t, deps)
+	path := newExecutable(t)
+
+	_, err = checker.SelfUpdate(deps.ctx, UpdateOptions{Executable: path})
+
+	assert.ErrorContains(t, err, "signature of checksums.txt is invalid for release v1.2.3")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(content))
+}
+
+func TestSelfUpdate_BadChecksum_KeepsExecutable(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	setReleaseKey(t, public)
+	rel := newTestRelease(t, private)
+	rel.binary = []byte("tampered")
+	rel.mock(t, deps)
+	path := newExecutable(t)
+
+	_, err = checker.SelfUpdate(deps.ctx, UpdateOptions{Executable: path})
+
+	assert.ErrorContains(t, err, "doesn't match checksums.txt")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(content))
+}
+
+func TestSelfUpdate_NoReleaseKey_Err(t *testing.T) {
+	checker, deps := newTestChecker(t)
+	previous := releaseKey
+	t.Cleanup(func() { releaseKey = previous })
+	releaseKey = ""
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	newTestRelease(t, private).mock(t, deps)
+
+	_, err = checker.SelfUpdate(deps.ctx, UpdateOptions{Executable: newExecutable(t)})
+
+	assert.ErrorIs(t, err, ErrNoReleaseKey)
+}
+
+func TestSelfUpdate_Latest_DoesNothing(t *testing.T) {
+	checker, deps := newTestChecker(t)
+
+	resp := http.NewMockHttpResponse(t)
+	resp.On("GetStatusCode").Return(200)
+	resp.On("HasBody").Return(true)
+	resp.On("GetBody").Return(map[string]any{
+		"tag_name": "v0.0.0",
+	})
+	deps.http.On("Get", home, mock.Anything).Return(resp, nil)
+
+	update, err := checker.SelfUpdate(deps.ctx, UpdateOptions{Executable: newExecutable(t)})
+
+	require.NoError(t, err)
+	assert.False(t, update.Updated)
+}
+
+func TestFindChecksum(t *testing.T) {
+	checksums := []byte("ABC123  connector_linux_amd64\ndef456 *connector_windows_amd64.exe\n")
+
+	sum, err := findChecksum(checksums, "connector_windows_amd64.exe")
+	require.NoError(t, err)
+	assert.Equal(t, "def456", sum)
+
+	sum, err = findChecksum(checksums, "connector_linux_amd64")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", sum)
+
+	_, err = findChecksum(checksums, "connector_darwin_arm64")
+	assert.ErrorContains(t, err, "no checksum of connector_darwin_arm64")
+}
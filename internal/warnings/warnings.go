@@ -0,0 +1,89 @@
+// Package warnings collects the non-fatal failures of a run, e.g. a service check that failed and
+// was skipped, so they can be reported together rather than only logged where they happen
+package warnings
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Categories of warnings
+const (
+	// ServiceCheck is a service check, e.g. one AWS service in one region, that failed and was skipped
+	ServiceCheck = "service_check"
+	// SkippedAccount is an account whose role couldn't be assumed, so wasn't discovered
+	SkippedAccount = "skipped_account"
+	// SkippedProfile is a cloud provider profile that couldn't be initialised, so wasn't discovered
+	SkippedProfile = "skipped_profile"
+	// SkippedResource is a discovered resource that couldn't be decoded and was skipped
+	SkippedResource = "skipped_resource"
+	// RejectedSeeds are resources ASM or validation rejected as seeds
+	RejectedSeeds = "rejected_seeds"
+	// FailedSeeds are seeds that couldn't be added after retrying
+	FailedSeeds = "failed_seeds"
+	// FailedRemovals are stale seeds that couldn't be removed
+	FailedRemovals = "failed_removals"
+	// Overflow are resources exceeding the seed limit of a scan
+	Overflow = "overflow"
+	// StaleKept is stale seeds kept as the sync couldn't be scoped to the part of the cloud synced
+	StaleKept = "stale_kept"
+	// Audit is a seed change that couldn't be recorded in the audit log
+	Audit = "audit"
+)
+
+// Warning is a non-fatal failure of a run
+type Warning struct {
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+// New returns a warning of category with a formatted message
+func New(category string, format string, args ...any) Warning {
+	return Warning{Category: category, Message: fmt.Sprintf(format, args...)}
+}
+
+// Counts returns the number of warnings of each category
+func Counts(warnings []Warning) map[string]int {
+	if len(warnings) == 0 {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, w := range warnings {
+		counts[w.Category]++
+	}
+	return counts
+}
+
+// Collector collects the warnings recorded in a run. It's safe to use concurrently.
+type Collector struct {
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+// Warnings returns the warnings collected, in the order they were recorded
+func (c *Collector) Warnings() []Warning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Warning(nil), c.warnings...)
+}
+
+func (c *Collector) add(w Warning) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.warnings = append(c.warnings, w)
+}
+
+type collectorKey struct{}
+
+// WithCollector returns a context whose warnings are recorded in c
+func WithCollector(ctx context.Context, c *Collector) context.Context {
+	return context.WithValue(ctx, collectorKey{}, c)
+}
+
+// Record records a warning of category in the collector of ctx, if it has one
+func Record(ctx context.Context, category string, format string, args ...any) {
+	if c, ok := ctx.Value(collectorKey{}).(*Collector); ok {
+		c.add(New(category, format, args...))
+	}
+}
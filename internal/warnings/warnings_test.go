@@ -0,0 +1,31 @@
+package warnings
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord(t *testing.T) {
+	c := &Collector{}
+	ctx := WithCollector(context.Background(), c)
+
+	Record(ctx, ServiceCheck, "EC2 in %s failed", "eu-west-1")
+	Record(ctx, ServiceCheck, "S3 in %s failed", "eu-west-2")
+	Record(ctx, SkippedResource, "bad asset")
+
+	assert.Equal(t, []Warning{
+		{Category: ServiceCheck, Message: "EC2 in eu-west-1 failed"},
+		{Category: ServiceCheck, Message: "S3 in eu-west-2 failed"},
+		{Category: SkippedResource, Message: "bad asset"},
+	}, c.Warnings())
+	assert.Equal(t, map[string]int{ServiceCheck: 2, SkippedResource: 1}, Counts(c.Warnings()))
+}
+
+func TestRecord_WithoutCollector(t *testing.T) {
+	assert.NotPanics(t, func() {
+		Record(context.Background(), ServiceCheck, "ignored")
+	})
+	assert.Nil(t, Counts(nil))
+}
@@ -0,0 +1,29 @@
+// Package api is the client of the Hexiosec ASM API the connector syncs seeds with, for embedding
+// the connector in another service
+package api
+
+import (
+	"io"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/api"
+	"github.com/hexiosec/asm-cloud-connector/pkg/config"
+)
+
+type (
+	// API is the subset of the Hexiosec ASM API the connector calls. Implement it to sync through
+	// another client, or to fake ASM in tests.
+	API = api.API
+	// RefreshKeyFunc fetches the API key again, e.g. from a secret, when ASM rejects it
+	RefreshKeyFunc = api.RefreshKeyFunc
+)
+
+// ErrUnavailable is returned once ASM has failed enough requests in a row that the remaining
+// requests would fail too
+var ErrUnavailable = api.ErrUnavailable
+
+// NewAPI returns a client of the ASM API of cfg, authenticated with apiKey or the OAuth client
+// credentials of cfg. refreshKey may be nil if the key can't be fetched again. Close the returned
+// closer once the client is no longer used.
+func NewAPI(cfg *config.Config, userAgent string, apiKey string, refreshKey RefreshKeyFunc) (API, io.Closer, error) {
+	return api.NewAPI(cfg, userAgent, apiKey, refreshKey)
+}
@@ -0,0 +1,54 @@
+// Package cloudprovider discovers the resources of cloud provider profiles, for embedding the
+// connector in another service
+package cloudprovider
+
+import (
+	"context"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/aws"
+	"github.com/hexiosec/asm-cloud-connector/internal/azure"
+	"github.com/hexiosec/asm-cloud-connector/internal/cloud_provider"
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+	"github.com/hexiosec/asm-cloud-connector/internal/gcp"
+	"github.com/hexiosec/asm-cloud-connector/pkg/config"
+)
+
+type (
+	// CloudProvider discovers the resources of a profile. Call Authenticate before the other
+	// methods. Implement it to sync resources from another source.
+	CloudProvider = cloud_provider_t.CloudProvider
+	// AccountLister is a provider that discovers the resources of several accounts
+	AccountLister = cloud_provider_t.AccountLister
+	// Preflighter is a provider that can check the access its discovery needs
+	Preflighter = cloud_provider_t.Preflighter
+	// Check is the outcome of a preflight check
+	Check = cloud_provider_t.Check
+	// PolicyGenerator is a provider that can generate the policies its service checks need
+	PolicyGenerator = cloud_provider_t.PolicyGenerator
+	// Policy is the least privilege access the discovery of a profile needs
+	Policy = cloud_provider_t.Policy
+)
+
+// ErrNoAPIKey is returned by GetAPIKey if the profile doesn't store the ASM API key
+var ErrNoAPIKey = cloud_provider_t.ErrNoAPIKey
+
+// NewCloudProviders returns a cloud provider for each enabled profile of cfg, skipping and logging
+// any profile that fails to initialise
+func NewCloudProviders(ctx context.Context, cfg *config.Config) ([]CloudProvider, error) {
+	return cloud_provider.NewCloudProviders(ctx, cfg)
+}
+
+// NewAWSProvider returns the cloud provider of an AWS profile
+func NewAWSProvider(cfg *config.AWSCloudProvider) (CloudProvider, error) {
+	return aws.NewAWSProvider(cfg)
+}
+
+// NewAzureProvider returns the cloud provider of an Azure profile
+func NewAzureProvider(cfg *config.AzureCloudProvider) (CloudProvider, error) {
+	return azure.NewAzureProvider(cfg)
+}
+
+// NewGCPProvider returns the cloud provider of a GCP profile
+func NewGCPProvider(cfg *config.GCPCloudProvider) (CloudProvider, error) {
+	return gcp.NewGCPProvider(cfg)
+}
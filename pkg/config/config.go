@@ -0,0 +1,41 @@
+// Package config is the configuration of the connector, for embedding it in another service. The
+// types are those the connector itself uses, so a config loaded here can be passed to the other
+// packages of pkg/. Being aliases, they change as the connector does, and aren't yet a stable API.
+package config
+
+import (
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+type (
+	// Config is the configuration of a run, as loaded from YAML
+	Config = config.Config
+	// CloudProvider is the config shared by the profiles of every cloud provider
+	CloudProvider      = config.CloudProvider
+	AWSCloudProvider   = config.AWSCloudProvider
+	AzureCloudProvider = config.AzureCloudProvider
+	GCPCloudProvider   = config.GCPCloudProvider
+	// Profiles are the profiles of a cloud provider, given as a single profile or a list in YAML
+	Profiles[T any] = config.Profiles[T]
+
+	AWSServices      = config.AWSServices
+	AzureServices    = config.AzureServices
+	GCPServices      = config.GCPServices
+	ServiceSelection = config.ServiceSelection
+	Check            = config.Check
+	S3Check          = config.S3Check
+	RDSCheck         = config.RDSCheck
+
+	NormalisationRule = config.NormalisationRule
+	NewScan           = config.NewScan
+
+	// ValidationErrors are the fields of a config that failed validation, with their YAML path
+	ValidationErrors = config.ValidationErrors
+	FieldError       = config.FieldError
+)
+
+// Load reads, defaults and validates the config from the CONNECTOR_CONFIG env var, or filePath if
+// it's not set. Validation failures are returned as ValidationErrors.
+func Load(filePath string) (*Config, error) {
+	return config.Load(filePath)
+}
@@ -0,0 +1,86 @@
+// Package connector syncs discovered resources with the seeds of a Hexiosec ASM scan, for embedding
+// the connector in another service
+package connector
+
+import (
+	"context"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/connector"
+	"github.com/hexiosec/asm-cloud-connector/internal/state"
+	"github.com/hexiosec/asm-cloud-connector/pkg/api"
+	"github.com/hexiosec/asm-cloud-connector/pkg/config"
+)
+
+// Connector syncs resources with the seeds of the scan and seed tag of its config. Call
+// Authenticate before Plan or SyncResources.
+type Connector interface {
+	// Authenticate checks the credentials are valid and finds the scan, creating it if configured
+	Authenticate(ctx context.Context) error
+	// ScanID returns the ID of the scan synced to, only known after Authenticate if the scan is
+	// found by name or created
+	ScanID() string
+	// SetLabels adds extra tags to, or sets the seed type of, the seeds of resources
+	SetLabels(labels map[string]Label) error
+	// SetScope tags new seeds with tag, and only deletes stale seeds with it, for a sync of part
+	// of the cloud
+	SetScope(tag string) error
+	// Plan returns the changes SyncResources would make, without making them
+	Plan(ctx context.Context, resources []string) (*SyncPlan, error)
+	// SyncResources adds the missing seeds of resources and deletes stale ones. If the sync is
+	// interrupted, the report holds the changes made before it was.
+	SyncResources(ctx context.Context, resources []string) (*SyncReport, error)
+}
+
+var _ Connector = (*connector.Connector)(nil)
+
+type (
+	// Label is the extra tags and seed type of a resource
+	Label = connector.Label
+	// SyncReport summarises the outcome of SyncResources
+	SyncReport   = connector.SyncReport
+	RejectedSeed = connector.RejectedSeed
+	FailedSeed   = connector.FailedSeed
+	DroppedTags  = connector.DroppedTags
+	// SyncPlan is the changes SyncResources would make
+	SyncPlan    = connector.SyncPlan
+	PlannedSeed = connector.PlannedSeed
+	// Store persists the checkpoint of an interrupted sync between runs
+	Store = state.IStore
+)
+
+// ErrQuotaExceeded is returned by SyncResources if the new seeds don't fit in the seed quota of the
+// scan and the quota mode is to fail
+var ErrQuotaExceeded = connector.ErrQuotaExceeded
+
+// NewConnector returns a connector syncing with the scan and seed tag of cfg through sdk. store may
+// be nil if syncs aren't checkpointed.
+func NewConnector(cfg *config.Config, sdk api.API, store Store) (Connector, error) {
+	if store == nil {
+		var err error
+		if store, err = NewStore(&config.Config{}); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := connector.NewConnector(cfg, sdk, store)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// NewStore returns a store in the state directory of cfg, or one that keeps nothing if it has none
+func NewStore(cfg *config.Config) (Store, error) {
+	return state.NewStore(cfg)
+}
+
+// Normalise returns the seed names resources are synced as, without calling the ASM API
+func Normalise(ctx context.Context, cfg *config.Config, resources []string) ([]string, error) {
+	return connector.Normalise(ctx, cfg, resources)
+}
+
+// WithInFlightGrace returns a context whose syncs give a seed being added or removed grace to finish
+// once the sync is stopped, 30s by default
+func WithInFlightGrace(ctx context.Context, grace time.Duration) context.Context {
+	return connector.WithInFlightGrace(ctx, grace)
+}
@@ -0,0 +1,26 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/api"
+	"github.com/hexiosec/asm-cloud-connector/pkg/config"
+	asm "github.com/hexiosec/asm-sdk-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewConnector_NilStore_Syncs(t *testing.T) {
+	cfg := &config.Config{ScanID: "scan-123", SeedTag: "seed-tag"}
+	mockAPI := api.NewMockAPI(t).(*api.MockAPI)
+	mockAPI.On("GetScanSeedsById", cfg.ScanID).Return([]asm.SeedsResponseInner{}, nil, nil)
+	mockAPI.On("AddScanSeedById", cfg.ScanID, mock.Anything).Return(&asm.NodeResponse{}, nil, nil)
+
+	conn, err := NewConnector(cfg, mockAPI, nil)
+	assert.NoError(t, err)
+
+	report, err := conn.SyncResources(context.Background(), []string{"example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com"}, report.Added)
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/metrics"
+)
+
+// emfOutput is where a Lambda invocation writes its metrics, Lambda sends stdout to CloudWatch Logs
+var emfOutput io.Writer = os.Stdout
+
+// emitMetrics writes the metrics of a sync run by a Lambda invocation in the CloudWatch Embedded
+// Metric Format, so the function's metrics need no agent or extra permissions. It does nothing
+// outside Lambda, or if the metrics are disabled.
+func emitMetrics(ctx context.Context, cfg *config.Config, result *RunResult, err error, duration time.Duration) {
+	function := os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	if function == "" || cfg.CloudWatch.DisableMetrics {
+		return
+	}
+
+	resources := 0
+	for _, provider := range result.Providers {
+		resources += provider.Resources
+	}
+	failed := 0
+	if err != nil {
+		failed = 1
+	}
+
+	if err := metrics.WriteEMF(emfOutput, cfg.CloudWatch.Namespace, map[string]string{"FunctionName": function}, []metrics.EMFMetric{
+		{Name: "Resources", Unit: "Count", Value: float64(resources)},
+		{Name: "SeedsAdded", Unit: "Count", Value: float64(result.Added)},
+		{Name: "SeedsRemoved", Unit: "Count", Value: float64(result.Removed)},
+		{Name: "Warnings", Unit: "Count", Value: float64(len(result.Warnings))},
+		{Name: "Errors", Unit: "Count", Value: float64(failed)},
+		{Name: "Duration", Unit: "Seconds", Value: duration.Seconds()},
+	}, time.Now()); err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not emit CloudWatch metrics")
+	}
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withEMFOutput(t *testing.T) *bytes.Buffer {
+	out := &bytes.Buffer{}
+	prev := emfOutput
+	emfOutput = out
+	t.Cleanup(func() { emfOutput = prev })
+	return out
+}
+
+func TestEmitMetrics(t *testing.T) {
+	out := withEMFOutput(t)
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "connector")
+	cfg := &config.Config{}
+	cfg.CloudWatch.Namespace = "Test"
+
+	result := &RunResult{
+		Providers: []ProviderResult{{Resources: 3}, {Resources: 2}},
+		Added:     4,
+		Removed:   1,
+		Warnings:  []Warning{{Category: "rejected_seeds", Message: "rejected"}},
+	}
+	emitMetrics(context.Background(), cfg, result, assert.AnError, 1500*time.Millisecond)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "connector", record["FunctionName"])
+	assert.EqualValues(t, 5, record["Resources"])
+	assert.EqualValues(t, 4, record["SeedsAdded"])
+	assert.EqualValues(t, 1, record["SeedsRemoved"])
+	assert.EqualValues(t, 1, record["Warnings"])
+	assert.EqualValues(t, 1, record["Errors"])
+	assert.EqualValues(t, 1.5, record["Duration"])
+	assert.Contains(t, out.String(), `"Namespace":"Test"`)
+}
+
+func TestEmitMetrics_OutsideLambdaOrDisabled(t *testing.T) {
+	out := withEMFOutput(t)
+	cfg := &config.Config{}
+
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "")
+	emitMetrics(context.Background(), cfg, &RunResult{}, nil, time.Second)
+	assert.Empty(t, out.String())
+
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "connector")
+	cfg.CloudWatch.DisableMetrics = true
+	emitMetrics(context.Background(), cfg, &RunResult{}, nil, time.Second)
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+// ConfigResult is the outcome of running one config of a config directory
+type ConfigResult struct {
+	// Config is the name of the config file or subdirectory
+	Config   string     `json:"config"`
+	Success  bool       `json:"success"`
+	Error    string     `json:"error,omitempty"`
+	ExitCode int        `json:"exit_code"`
+	Result   *RunResult `json:"result,omitempty"`
+}
+
+// configRun is a config of a config directory, loaded and with its own logger
+type configRun struct {
+	name string
+	cfg  *config.Config
+	ctx  context.Context
+	// file is the log file of the config, if it has one
+	file io.Closer
+	err  error
+}
+
+// RunConfigDir runs a sync with each config of dir, each config file and subdirectory in name
+// order, up to parallel at a time. Each config logs to its own log.file, or the log output tagged
+// with the config name, and notifies its own webhook. A failed config doesn't stop the others, the
+// error joins the error of each failed config.
+func RunConfigDir(ctx context.Context, dir string, parallel int) ([]ConfigResult, error) {
+	if _, ok := os.LookupEnv("CONNECTOR_CONFIG"); ok {
+		return nil, classify(ErrConfig, errors.New("core: CONNECTOR_CONFIG can't be used with a config directory"))
+	}
+
+	paths, err := config.DirConfigs(dir)
+	if err != nil {
+		logger.GetLogger(ctx).Error().Err(err).Msg("Could not read config directory")
+		return nil, classify(ErrConfig, err)
+	}
+
+	// Loggers can't be created concurrently, so every config is loaded before any runs
+	runs := make([]*configRun, 0, len(paths))
+	for _, path := range paths {
+		runs = append(runs, loadConfigRun(ctx, path))
+	}
+
+	if parallel < 1 {
+		parallel = 1
+	}
+	sem := make(chan struct{}, parallel)
+	results := make([]*RunResult, len(runs))
+	var wg sync.WaitGroup
+	for idx, r := range runs {
+		if r.err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if r.file != nil {
+				defer r.file.Close()
+			}
+
+			// Don't start another config once the run is stopped, e.g. by SIGTERM
+			if err := ctx.Err(); err != nil {
+				r.err = fmt.Errorf("core: not run, the run was stopped, %w", err)
+				return
+			}
+			results[idx], r.err = runOnce(r.ctx, r.cfg)
+		}()
+	}
+	wg.Wait()
+
+	configResults := make([]ConfigResult, 0, len(runs))
+	errs := []error{}
+	for idx, r := range runs {
+		result := ConfigResult{Config: r.name, Success: r.err == nil, ExitCode: ExitCode(r.err), Result: results[idx]}
+		if r.err != nil {
+			result.Error = r.err.Error()
+			errs = append(errs, fmt.Errorf("core: config %s, %w", r.name, r.err))
+		}
+		configResults = append(configResults, result)
+	}
+	logConfigResults(ctx, configResults)
+
+	return configResults, errors.Join(errs...)
+}
+
+// loadConfigRun loads the config at path with a logger of its own log config. A failure to load is
+// the error of the run.
+func loadConfigRun(ctx context.Context, path string) *configRun {
+	r := &configRun{name: filepath.Base(path)}
+	ctx = logger.WithLogger(ctx, logger.GetLogger(ctx).With().Str("config", r.name).Logger())
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		logger.GetLogger(ctx).Error().Err(err).Msg("Config failed to load")
+		r.err = classify(ErrConfig, err)
+		return r
+	}
+
+	out, file, err := openLogFile(cfg, logOutput)
+	if err != nil {
+		logger.GetLogger(ctx).Error().Err(err).Msg("Could not open log file")
+		r.err = classify(ErrConfig, err)
+		return r
+	}
+	if file != nil {
+		r.file = file
+	}
+
+	l, err := logger.New(logLevel(cfg), debugMode || cfg.Log.Format == "console", privacyLogOutput(cfg, cloudLogOutput(cfg, out)))
+	if err != nil {
+		if r.file != nil {
+			r.file.Close()
+		}
+		r.err = classify(ErrConfig, fmt.Errorf("core: could not setup logging, %w", err))
+		return r
+	}
+
+	r.cfg = cfg
+	r.ctx = logger.WithLogger(ctx, l.With().Str("config", r.name).Logger())
+	return r
+}
+
+// logConfigResults logs the combined summary of the configs of a config directory
+func logConfigResults(ctx context.Context, results []ConfigResult) {
+	failed := 0
+	for _, result := range results {
+		event := logger.Summary(ctx).Info()
+		if !result.Success {
+			failed++
+			event = logger.Summary(ctx).Warn().Str("error", result.Error).Int("exit_code", result.ExitCode)
+		}
+		if result.Result != nil {
+			event = event.
+				Int("added", result.Result.Added).
+				Int("removed", result.Result.Removed).
+				Int("warnings", len(result.Result.Warnings))
+		}
+		event.Str("config", result.Config).Bool("success", result.Success).Msg("Config run complete")
+	}
+	logger.Summary(ctx).Info().
+		Int("configs", len(results)).
+		Int("failed", failed).
+		Msgf("Ran %d configs, %d failed", len(results), failed)
+}
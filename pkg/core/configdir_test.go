@@ -0,0 +1,37 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunConfigDir_InvalidConfigs_ResultEach(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.yml"), []byte("scan_id: [\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.yml"), []byte("version: 99\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o600))
+
+	results, err := RunConfigDir(context.Background(), dir, 2)
+
+	assert.ErrorIs(t, err, ErrConfig)
+	require.Len(t, results, 2)
+	assert.Equal(t, "a.yml", results[0].Config)
+	assert.Equal(t, "b.yml", results[1].Config)
+	for _, result := range results {
+		assert.False(t, result.Success)
+		assert.Equal(t, ExitConfig, result.ExitCode)
+		assert.NotEmpty(t, result.Error)
+	}
+}
+
+func TestRunConfigDir_NoConfigs_Err(t *testing.T) {
+	results, err := RunConfigDir(context.Background(), t.TempDir(), 1)
+
+	assert.ErrorIs(t, err, ErrConfig)
+	assert.Nil(t, results)
+}
@@ -4,25 +4,46 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net/url"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/hexiosec/asm-cloud-connector/internal/api"
+	"github.com/hexiosec/asm-cloud-connector/internal/audit"
 	"github.com/hexiosec/asm-cloud-connector/internal/cloud_provider"
 	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
 	"github.com/hexiosec/asm-cloud-connector/internal/config"
 	"github.com/hexiosec/asm-cloud-connector/internal/connector"
+	"github.com/hexiosec/asm-cloud-connector/internal/heartbeat"
 	"github.com/hexiosec/asm-cloud-connector/internal/http"
 	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/metrics"
+	"github.com/hexiosec/asm-cloud-connector/internal/notify"
+	"github.com/hexiosec/asm-cloud-connector/internal/progress"
+	"github.com/hexiosec/asm-cloud-connector/internal/reporting"
+	"github.com/hexiosec/asm-cloud-connector/internal/state"
 	"github.com/hexiosec/asm-cloud-connector/internal/version"
+	"github.com/hexiosec/asm-cloud-connector/internal/warnings"
 	"github.com/joho/godotenv"
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 )
 
+// auditCloseTimeout bounds writing out the audit records of a run once it's finished
+const auditCloseTimeout = 30 * time.Second
+
 var (
-	cfgFilePath string = "./config.yml"
-	debugMode   bool   = false
+	cfgFilePath string    = "./config.yml"
+	debugMode   bool      = false
+	quietMode   bool      = false
+	logOutput   io.Writer = os.Stdout
+	logFile     *logger.RotatingFile
+	statsD      *metrics.StatsD
+	// versionCache caches the latest version between runs, on disk for one-shot runs and in memory
+	// once a daemon or server starts
+	versionCache = version.NewFileCache(version.DefaultCachePath())
 )
 
 func SetCfgFilePath(v string) {
@@ -33,6 +54,15 @@ func SetDebugMode(v bool) {
 	debugMode = v
 }
 
+// SetQuietMode logs only errors and the summary of each run, whatever the log level of the config
+func SetQuietMode(v bool) {
+	quietMode = v
+}
+
+func SetLogOutput(v io.Writer) {
+	logOutput = v
+}
+
 // Will load the .env file if available and setup
 func Setup() error {
 	if err := godotenv.Load(".env"); err != nil && !os.IsNotExist(err) {
@@ -43,107 +73,905 @@ func Setup() error {
 	if !ok {
 		logEnv = "info"
 	}
+	if quietMode {
+		logEnv = "error"
+	}
 
-	logLevel, err := zerolog.ParseLevel(logEnv)
-	if err != nil {
+	if err := logger.Setup(logEnv, debugMode, logOutput); err != nil {
 		logger.GetGlobalLogger().Warn().Err(err).Msg("Could not parse log level")
-		return fmt.Errorf("core: could not parse log level, %w", err)
+		return classify(ErrConfig, fmt.Errorf("core: could not parse log level, %w", err))
 	}
 
-	zerolog.SetGlobalLevel(logLevel)
+	return nil
+}
+
+// setupLogging reconfigures the logger with the log config, once the config is loaded. --debug
+// takes precedence over the log format.
+func setupLogging(cfg *config.Config) error {
+	out, file, err := openLogFile(cfg, logOutput)
+	if err != nil {
+		return err
+	}
 
-	// Add file and line number to log output
-	log.Logger = log.With().Caller().Logger()
+	if err := logger.Setup(logLevel(cfg), debugMode || cfg.Log.Format == "console", privacyLogOutput(cfg, cloudLogOutput(cfg, out))); err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return fmt.Errorf("core: could not setup logging, %w", err)
+	}
 
-	if debugMode {
-		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
-	} else {
-		log.Logger = log.Output(os.Stdout)
+	// Close the file of a previous run, e.g. a previous Lambda invocation
+	if logFile != nil {
+		logFile.Close()
+	}
+	logFile = file
+
+	return nil
+}
+
+// setupStatsD sends the metrics to the StatsD server in the config, replacing the sink of a previous
+// run, e.g. a previous Lambda invocation
+func setupStatsD(cfg *config.Config) error {
+	if statsD != nil {
+		metrics.Default.SetSink(nil)
+		statsD.Close()
+		statsD = nil
+	}
+	if cfg.StatsD.Address == "" {
+		return nil
+	}
+
+	s, err := metrics.NewStatsD(cfg.StatsD.Address, cfg.StatsD.Format, cfg.StatsD.Tags)
+	if err != nil {
+		return fmt.Errorf("core: could not setup StatsD, %w", err)
 	}
+	metrics.Default.SetSink(s)
+	statsD = s
 
 	return nil
 }
 
+// cloudLogOutput maps the fields of the logs written to out to the conventions of Cloud Logging or
+// Azure Monitor, for log.format gcp or azure, unless --debug logs human readable output
+func cloudLogOutput(cfg *config.Config, out io.Writer) io.Writer {
+	if debugMode {
+		return out
+	}
+	switch cfg.Log.Format {
+	case logger.FormatGCP, logger.FormatAzure:
+		return logger.NewCloudWriter(out, cfg.Log.Format, cfg.Log.GCPProject)
+	}
+	return out
+}
+
+// privacyLogOutput replaces the hostnames and IP addresses in the logs written to out, for
+// log.privacy hash or redact, keeping the host of the ASM API
+func privacyLogOutput(cfg *config.Config, out io.Writer) io.Writer {
+	var keep []string
+	if u, err := url.Parse(cfg.ASM.BaseURL); err == nil {
+		keep = append(keep, u.Hostname())
+	}
+	return logger.NewPrivacyWriter(out, cfg.Log.Privacy, cfg.Log.PrivacyKey, keep...)
+}
+
+// logLevel returns the level to log at, error in quiet mode or log.level otherwise
+func logLevel(cfg *config.Config) string {
+	if quietMode {
+		return "error"
+	}
+	return cfg.Log.Level
+}
+
+// openLogFile opens log.file, if set, rotated as configured. It returns the output to log to, the
+// file, as well as out if log.stdout is set, or out if there's no log file.
+func openLogFile(cfg *config.Config, out io.Writer) (io.Writer, *logger.RotatingFile, error) {
+	if cfg.Log.File == "" {
+		return out, nil, nil
+	}
+
+	file, err := logger.OpenFile(cfg.Log.File, logger.Rotation{
+		MaxSize:    int64(cfg.Log.MaxSizeMB) << 20,
+		MaxAge:     cfg.Log.MaxAge,
+		MaxBackups: cfg.Log.MaxBackups,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("core: could not open log file, %w", err)
+	}
+	if cfg.Log.Stdout {
+		return io.MultiWriter(out, file), file, nil
+	}
+	return file, file, nil
+}
+
 func Run(ctx context.Context) error {
-	// Load config
-	cfg := config.Provider(cfgFilePath)
+	_, err := RunWithResult(ctx)
+	return err
+}
+
+// RunWithResult runs a sync as Run does, returning its result. The result is returned with the
+// error of a failed run, holding what was synced before it failed.
+func RunWithResult(ctx context.Context) (*RunResult, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return runOnce(ctx, cfg)
+}
 
-	// Check for a new version
-	http := http.NewHttpService(cfg, "hexiosec-cloud-connector")
-	checker, err := version.NewChecker(http)
+// runOnce syncs the resources of every target and sends their notifications, returning the
+// result of the run. The result is never nil.
+func runOnce(ctx context.Context, cfg *config.Config) (*RunResult, error) {
+	ctx = runContext(ctx)
+	start := time.Now()
+	result := &RunResult{}
+	collector := &warnings.Collector{}
+	ctx = warnings.WithCollector(ctx, collector)
+	ctx, sampling := logger.WithSampling(ctx, cfg.Log.SampleEvery)
+	defer sampling.Log(ctx)
+
+	reporter, err := reporting.NewReporter(cfg)
+	if err != nil {
+		// Reporting errors is best effort, so the run goes ahead without it
+		logger.GetLogger(ctx).Error().Err(err).Msg("Could not set up error reporting")
+	}
+	defer reporter.Recover(ctx)
+
+	results, err := run(ctx, cfg)
+	if err != nil {
+		summary := newSummary(syncResult{scanID: cfg.ScanID, err: err}, time.Since(start))
+		sendNotification(ctx, cfg, summary)
+		result.Scans = []notify.Summary{summary}
+		result.merge(RunResult{Warnings: collector.Warnings()})
+		logRunSummary(ctx, result, err, time.Since(start))
+		reportFailure(ctx, reporter, result, err)
+		sendHeartbeat(ctx, cfg, result, err, start)
+		return result, err
+	}
+
+	// Warnings of discovery come before those of syncing each scan
+	result.merge(RunResult{Warnings: collector.Warnings()})
+	errs := []error{}
+	for _, r := range results {
+		summary := newSummary(r, time.Since(start))
+		sendNotification(ctx, cfg, summary)
+		result.add(r, summary)
+		errs = append(errs, r.err)
+	}
+
+	err = classify(ErrPartialSync, errors.Join(errs...))
+	logRunSummary(ctx, result, err, time.Since(start))
+	reportFailure(ctx, reporter, result, err)
+	sendHeartbeat(ctx, cfg, result, err, start)
+	return result, err
+}
+
+// reportFailure reports a failed run to Sentry or the error webhook, tagged with the scans that
+// failed and the profiles resources were discovered from
+func reportFailure(ctx context.Context, reporter *reporting.Reporter, result *RunResult, err error) {
+	if err == nil {
+		return
+	}
+
+	var scanIDs, profiles []string
+	for _, scan := range result.Scans {
+		if !scan.Success {
+			scanIDs = append(scanIDs, scan.ScanID)
+		}
+	}
+	for _, p := range result.Providers {
+		profiles = append(profiles, p.Provider+"/"+p.Profile)
+	}
+	slices.Sort(profiles)
+
+	tags := map[string]string{}
+	if len(scanIDs) > 0 {
+		tags["scan_id"] = strings.Join(scanIDs, ",")
+	}
+	if len(profiles) > 0 {
+		tags["profiles"] = strings.Join(slices.Compact(profiles), ",")
+	}
+	reporter.Error(ctx, err, tags)
+}
+
+// logRunSummary logs the outcome of a run as a single event, with the resources found by each
+// service of each profile and the seeds synced to each scan, so the counts of a run don't have to
+// be pieced together from the trace logs
+func logRunSummary(ctx context.Context, result *RunResult, err error, duration time.Duration) {
+	var existing, rejected, failed, failedRemovals, overflow, dropped int
+	for _, scan := range result.Scans {
+		existing += scan.Existing
+		rejected += scan.Rejected
+		failed += scan.Failed
+		failedRemovals += scan.FailedRemovals
+		overflow += scan.Overflow
+		dropped += scan.Dropped
+	}
+
+	// The summary is logged even if the level is higher, so a quiet run still logs its outcome
+	event := logger.Summary(ctx).Info()
+	if err != nil {
+		event = logger.Summary(ctx).Warn().Err(err)
+	}
+	event.
+		Bool("success", err == nil).
+		Dur("duration", duration).
+		Int("added", result.Added).
+		Int("removed", result.Removed).
+		Int("existing", existing).
+		Int("rejected", rejected).
+		Int("failed", failed).
+		Int("failed_removals", failedRemovals).
+		Int("overflow", overflow).
+		Int("dropped", dropped).
+		Interface("warning_counts", result.WarningCounts).
+		Interface("warnings", result.Warnings).
+		Interface("providers", result.Providers).
+		Interface("scans", result.Scans).
+		Msg("Run summary")
+}
+
+// logFieldsKey is the context key of the fields the logs of a run are tagged with
+type logFieldsKey struct{}
+
+// WithLogFields returns a context whose runs tag their logs with fields, e.g. to correlate them with
+// the task that ran them. The logger is only set up once the config is loaded, so fields can't be
+// added to a logger in ctx beforehand.
+func WithLogFields(ctx context.Context, fields map[string]string) context.Context {
+	return context.WithValue(ctx, logFieldsKey{}, fields)
+}
+
+// runContext tags the logs and requests of a run with a new run ID, so support can trace them
+// end to end, and with the fields of WithLogFields
+func runContext(ctx context.Context) context.Context {
+	id := http.NewRunID()
+	ctx = http.WithRunID(ctx, id)
+	logCtx := logger.GetLogger(ctx).With().Str("run_id", id).Str("version", version.Version())
+	fields, _ := ctx.Value(logFieldsKey{}).(map[string]string)
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		logCtx = logCtx.Str(key, fields[key])
+	}
+	return logger.WithLogger(ctx, logCtx.Logger())
+}
+
+// target is a scan and seed tag that the resources of one or more cloud provider profiles are
+// synced to
+type target struct {
+	scanID    string
+	scanName  string
+	seedTag   string
+	conn      *connector.Connector
+	resources []string
+	// providers are the number of resources discovered from each profile
+	providers []ProviderResult
+}
+
+// syncResult is the outcome of syncing a target, report is nil if the sync wasn't attempted
+type syncResult struct {
+	scanID    string
+	providers []ProviderResult
+	report    *connector.SyncReport
+	err       error
+}
+
+// checkVersion logs whether a new version is available, unless version_check is disabled
+func checkVersion(ctx context.Context, cfg *config.Config) error {
+	if cfg.VersionCheck.Mode == "disabled" {
+		logger.GetLogger(ctx).Debug().Msg("Version check disabled")
+		return nil
+	}
+
+	http, err := http.NewHttpService(cfg, version.UserAgent())
+	if err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init HTTP service")
+		return fmt.Errorf("core: could not init HTTP service, %w", err)
+	}
+	checker, err := version.NewChecker(http, versionCache, cfg.VersionCheck.Channel)
 	if err != nil {
 		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init version checker")
 		return fmt.Errorf("core: could not init version checker, %w", err)
 	}
 	checker.LogVersion(ctx)
+	return nil
+}
 
-	logger.GetLogger(ctx).Info().Str("scan_id", cfg.ScanID).Msg("Getting cloud resources")
+// run discovers the cloud resources and syncs them to each target. An error is only returned if
+// discovery failed, errors syncing a target are in its result.
+func run(ctx context.Context, cfg *config.Config) ([]syncResult, error) {
+	// Bound discovery and sync, so a slow run stops between seeds rather than being killed mid-delete
+	if cfg.SyncTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.SyncTimeout)
+		defer cancel()
+	}
 
-	// Setup Cloud Provider
-	cp, err := cloud_provider.NewCloudProvider(cfg)
+	auditor, err := audit.NewAuditor(cfg, http.RunID(ctx))
 	if err != nil {
-		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init cloud provider")
-		return fmt.Errorf("core: could not init cloud provider, %w", err)
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init audit log")
+		return nil, classify(ErrConfig, fmt.Errorf("core: could not init audit log, %w", err))
 	}
-	ctx = logger.WithLogger(ctx, logger.GetLogger(ctx).With().Str("cloud_provider", cp.GetName()).Logger())
+	defer closeAuditor(ctx, auditor)
 
-	if err := cp.Authenticate(ctx); err != nil {
-		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not authenticate with cloud provider")
-		return fmt.Errorf("core: could not authenticate with cloud provider, %w", err)
+	if err := checkVersion(ctx, cfg); err != nil {
+		return nil, err
 	}
-	logger.GetLogger(ctx).Debug().Msg("Cloud provider authentication successful")
 
-	// Try get the API key from the cloud provider first
-	apiKey, err := cp.GetAPIKey(ctx)
-	var ok bool
+	targets, sdkCloser, err := discover(ctx, cfg)
 	if err != nil {
-		if !errors.Is(err, cloud_provider_t.ErrNoAPIKey) {
-			logger.GetLogger(ctx).Warn().Err(err).Msg("Failed to get api key")
-			return fmt.Errorf("core: failed to get api key, %w", err)
+		return nil, err
+	}
+	defer closeAPI(ctx, sdkCloser)
+	for _, t := range targets {
+		t.conn.SetAuditor(auditor)
+	}
 
+	results := make([]syncResult, 0, len(targets))
+	var unavailable error
+	for _, t := range targets {
+		// Don't start syncing another target once the run is stopped, e.g. by SIGTERM
+		if err := ctx.Err(); err != nil {
+			results = append(results, syncResult{scanID: t.scanID, providers: t.providers, err: fmt.Errorf("core: not synced, the run was stopped, %w", err)})
+			continue
 		}
 
-		// Default to getting API key via ENV if cloud provider doesn't have it
-		apiKey, ok = os.LookupEnv("API_KEY")
-		if !ok || strings.TrimSpace(apiKey) == "" {
-			logger.GetLogger(ctx).Warn().Msg("API key not provided by cloud provider or en")
-			return fmt.Errorf("core: API key not provided by cloud provider or env API_KEY")
+		// Don't attempt the remaining targets once ASM is unavailable
+		if unavailable != nil {
+			results = append(results, syncResult{scanID: t.scanID, providers: t.providers, err: unavailable})
+			continue
 		}
+
+		report, err := syncTarget(targetContext(ctx, t), t)
+		results = append(results, syncResult{scanID: t.scanID, providers: t.providers, report: report, err: err})
+		if errors.Is(err, api.ErrUnavailable) {
+			unavailable = err
+		}
+	}
+
+	return results, nil
+}
+
+// closeAPI closes the ASM client, writing out the recording of asm.record
+func closeAPI(ctx context.Context, closer io.Closer) {
+	if err := closer.Close(); err != nil {
+		logger.GetLogger(ctx).Error().Err(err).Msg("Could not close ASM SDK")
+	}
+}
+
+// closeAuditor writes out the audit records of the run. The seeds were changed already, so a
+// failure doesn't fail the run, but is a warning of it.
+func closeAuditor(ctx context.Context, auditor audit.IAuditor) {
+	// Write the records out even if the run was stopped
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditCloseTimeout)
+	defer cancel()
+
+	if err := auditor.Close(ctx); err != nil {
+		logger.GetLogger(ctx).Error().Err(err).Msg("Could not write audit log")
+		warnings.Record(ctx, warnings.Audit, "could not write audit log, %v", err)
 	}
+}
+
+// syncTarget syncs the resources of a target with its scan
+func syncTarget(ctx context.Context, t *target) (*connector.SyncReport, error) {
+	defer reporting.Repanic(ctx)
 
-	// Setup SDK and connector
-	sdk, err := api.NewAPI(cfg, "hexiosec-cloud-connector", apiKey)
+	report, err := t.conn.SyncResources(ctx, t.resources)
+	if len(report.Overflow) > 0 {
+		logger.GetLogger(ctx).Warn().Strs("overflow", report.Overflow).Msgf("%d resources exceed the seed limit of the scan", len(report.Overflow))
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		logger.GetLogger(ctx).Warn().Err(err).
+			Int("added", len(report.Added)).
+			Int("removed", len(report.Removed)).
+			Msg("Timed out syncing resources with Hexiosec ASM, the sync is incomplete")
+		return report, fmt.Errorf("core: timed out syncing resources with Hexiosec ASM, %w", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		logger.GetLogger(ctx).Warn().Err(err).
+			Int("added", len(report.Added)).
+			Int("removed", len(report.Removed)).
+			Msg("Stopped syncing resources with Hexiosec ASM to shut down, the sync is incomplete")
+		return report, fmt.Errorf("core: stopped syncing resources with Hexiosec ASM, %w", err)
+	}
+	if errors.Is(err, api.ErrUnavailable) {
+		logger.GetLogger(ctx).Error().Err(err).
+			Int("added", len(report.Added)).
+			Int("resources", len(t.resources)).
+			Msg("Hexiosec ASM is unavailable, stopped syncing. Progress is checkpointed to state.dir if set, a re-run resumes from it")
+		return report, fmt.Errorf("core: Hexiosec ASM unavailable, %w", err)
+	}
+	if err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not sync resources with Hexiosec ASM connector")
+		return report, fmt.Errorf("core: could not sync resources with Hexiosec ASM connector, %w", err)
+	}
+
+	logger.GetLogger(ctx).Info().
+		Int("added", len(report.Added)).
+		Int("existing", len(report.Existing)).
+		Int("removed", len(report.Removed)).
+		Int("rejected", len(report.Rejected)).
+		Int("failed", len(report.Failed)).
+		Msg("Cloud resource sync successful with Hexiosec ASM")
+	if len(report.Rejected) > 0 {
+		logger.GetLogger(ctx).Warn().Interface("rejected", report.Rejected).Msgf("%d resources were rejected", len(report.Rejected))
+	}
+	if len(report.Failed) > 0 {
+		logger.GetLogger(ctx).Warn().Interface("failed", report.Failed).Msgf("%d resources failed to be added", len(report.Failed))
+	}
+	return report, nil
+}
+
+// newSummary returns the outcome of syncing a target, as notified and returned by the server
+func newSummary(result syncResult, duration time.Duration) notify.Summary {
+	summary := notify.Summary{
+		ScanID:   result.scanID,
+		Success:  result.err == nil,
+		Duration: duration,
+	}
+	if result.err != nil {
+		summary.Error = result.err.Error()
+	}
+	if report := result.report; report != nil {
+		summary.Added = len(report.Added)
+		summary.Existing = len(report.Existing)
+		summary.Removed = len(report.Removed)
+		summary.Rejected = len(report.Rejected)
+		summary.Failed = len(report.Failed)
+		summary.FailedRemovals = len(report.FailedRemovals)
+		summary.Overflow = len(report.Overflow)
+		summary.Dropped = report.Dropped
+		for _, failed := range report.Failed {
+			summary.FailedSeeds = append(summary.FailedSeeds, failed.Name)
+		}
+	}
+	return summary
+}
+
+// sendNotification posts the outcome of syncing a target to the configured webhook, failures are only logged.
+// The outcome of a stopped run is still sent.
+func sendNotification(ctx context.Context, cfg *config.Config, summary notify.Summary) {
+	ctx = context.WithoutCancel(ctx)
+	notifier, err := notify.NewNotifier(cfg)
+	if err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init webhook notifier")
+		return
+	}
+	if err := notifier.Notify(ctx, summary); err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not send webhook notification")
+	}
+}
+
+// sendHeartbeat posts the status of the connector after a run to the heartbeat URL. The next run is
+// when the schedule of the config runs after this run started, as the daemon schedules it.
+func sendHeartbeat(ctx context.Context, cfg *config.Config, result *RunResult, err error, start time.Time) {
+	ctx = context.WithoutCancel(ctx)
+	sender, sendErr := heartbeat.NewSender(cfg)
+	if sendErr != nil {
+		logger.GetLogger(ctx).Warn().Err(sendErr).Msg("Could not init heartbeat")
+		return
+	}
+
+	hash, hashErr := cfg.Hash()
+	if hashErr != nil {
+		logger.GetLogger(ctx).Warn().Err(hashErr).Msg("Could not hash config for heartbeat")
+	}
+	status := heartbeat.Status{
+		Version:    version.Version(),
+		ConfigHash: hash,
+		LastRun: heartbeat.Run{
+			ID:       http.RunID(ctx),
+			Start:    start,
+			Duration: time.Since(start),
+			Success:  err == nil,
+			Added:    result.Added,
+			Removed:  result.Removed,
+		},
+	}
+	if err != nil {
+		status.LastRun.Error = err.Error()
+	}
+	for _, scan := range result.Scans {
+		status.LastRun.ScanIDs = append(status.LastRun.ScanIDs, scan.ScanID)
+	}
+	if schedule, scheduleErr := newSchedule(cfg); scheduleErr == nil {
+		next := schedule.Next(start)
+		status.NextRun = &next
+	}
+
+	if sendErr := sender.Send(ctx, status); sendErr != nil {
+		logger.GetLogger(ctx).Warn().Err(sendErr).Msg("Could not send heartbeat")
+	}
+}
+
+// discover sets up the cloud providers, and gets the cloud resources of every enabled profile.
+// Resources are grouped into a target for each scan and seed tag, with a connector for it.
+func discover(ctx context.Context, cfg *config.Config) ([]*target, io.Closer, error) {
+	logger.GetLogger(ctx).Info().Str("scan_id", cfg.ScanID).Msg("Getting cloud resources")
+
+	providers, err := setupProviders(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	apiKey, refreshKey, err := resolveAPIKey(ctx, cfg, providers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Setup SDK and a connector for each target
+	sdk, sdkCloser, err := api.NewAPI(cfg, version.UserAgent(), apiKey, refreshKey)
 	if err != nil {
 		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init ASM SDK")
-		return fmt.Errorf("core: could not init ASM SDK, %w", err)
+		return nil, nil, classify(ErrASMAuth, fmt.Errorf("core: could not init ASM SDK, %w", err))
 
 	}
+	// Closed by the caller once the targets are synced, or here if discovery fails
+	discovered := false
+	defer func() {
+		if !discovered {
+			closeAPI(ctx, sdkCloser)
+		}
+	}()
 
-	conn, err := connector.NewConnector(cfg, sdk)
+	store, err := state.NewStore(cfg)
 	if err != nil {
-		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init Hexiosec ASM connecto")
-		return fmt.Errorf("core: could not init Hexiosec ASM connector %w", err)
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init state store")
+		return nil, nil, fmt.Errorf("core: could not init state store, %w", err)
 	}
 
-	if err := conn.Authenticate(ctx); err != nil {
-		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not authenticate with Hexiosec ASM connector")
-		return fmt.Errorf("core: could not authenticate with Hexiosec ASM connector, %w", err)
+	targets := []*target{}
+	providerTargets := make([]*target, len(providers))
+	for idx, cp := range providers {
+		profile := cp.GetProfile()
+		i := slices.IndexFunc(targets, func(t *target) bool {
+			return t.scanID == profile.ScanID && t.scanName == profile.ScanName && t.seedTag == profile.SeedTag
+		})
+		if i >= 0 {
+			providerTargets[idx] = targets[i]
+			continue
+		}
+
+		t := &target{scanID: profile.ScanID, scanName: profile.ScanName, seedTag: profile.SeedTag}
+		tCtx := targetContext(ctx, t)
+
+		targetCfg := *cfg
+		targetCfg.ScanID = t.scanID
+		targetCfg.ScanName = t.scanName
+		targetCfg.SeedTag = t.seedTag
+		t.conn, err = connector.NewConnector(&targetCfg, sdk, store)
+		if err != nil {
+			logger.GetLogger(tCtx).Warn().Err(err).Msg("Could not init Hexiosec ASM connecto")
+			return nil, nil, classify(ErrConfig, fmt.Errorf("core: could not init Hexiosec ASM connector %w", err))
+		}
+
+		if err := t.conn.Authenticate(tCtx); err != nil {
+			logger.GetLogger(tCtx).Warn().Err(err).Msg("Could not authenticate with Hexiosec ASM connector")
+			return nil, nil, classify(ErrASMAuth, fmt.Errorf("core: could not authenticate with Hexiosec ASM connector, %w", err))
+		}
+		logger.GetLogger(tCtx).Debug().Msg("Cloud connector authentication successful")
+
+		if tag := scopeOf(ctx); tag != "" {
+			if err := t.conn.SetScope(tag); err != nil {
+				logger.GetLogger(tCtx).Warn().Err(err).Msg("Could not scope Hexiosec ASM connector")
+				return nil, nil, fmt.Errorf("core: could not scope Hexiosec ASM connector, %w", err)
+			}
+		}
+
+		// The scan may have been found by name or created
+		t.scanID = t.conn.ScanID()
+
+		// Profiles naming the same scan by ID and by name are synced together
+		i = slices.IndexFunc(targets, func(other *target) bool {
+			return other.scanID == t.scanID && other.seedTag == t.seedTag
+		})
+		if i >= 0 {
+			providerTargets[idx] = targets[i]
+			continue
+		}
+
+		targets = append(targets, t)
+		providerTargets[idx] = t
+	}
+
+	// Get resources. A profile failing fails the run, as syncing partial resources could remove
+	// the seeds of that profile as stale.
+	for idx, cp := range providers {
+		// Providers skip services that fail, so don't start another once the run is stopped
+		if ctx.Err() != nil {
+			break
+		}
+
+		counter := &serviceCounter{}
+		start := time.Now()
+		resources, err := providerResources(progress.WithReporter(ctx, counter), cp)
+		if err != nil {
+			return nil, nil, err
+		}
+		result := ProviderResult{
+			Provider:        cp.GetName(),
+			Profile:         cp.GetProfile().Name,
+			ScanID:          providerTargets[idx].scanID,
+			Resources:       len(resources),
+			Duration:        time.Since(start),
+			Services:        counter.results(),
+			SlowestServices: counter.slowest(slowestServices),
+		}
+		logger.GetLogger(providerContext(ctx, cp)).Info().
+			Int("resource_count", result.Resources).
+			Dur("duration", result.Duration).
+			Interface("slowest_services", result.SlowestServices).
+			Msg("Got resources of cloud provider")
+
+		providerTargets[idx].resources = append(providerTargets[idx].resources, resources...)
+		providerTargets[idx].providers = append(providerTargets[idx].providers, result)
 	}
-	logger.GetLogger(ctx).Debug().Msg("Cloud connector authentication successful")
 
-	// Get resources and sync
-	resources, err := cp.GetResources(ctx)
+	// Providers skip services that fail, so resources may be incomplete if discovery ran out of time
+	// or was stopped. Nothing is synced, so no seeds are deleted as stale.
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.GetLogger(ctx).Warn().Dur("sync_timeout", cfg.SyncTimeout).Msg("Timed out getting resources of cloud provider")
+		return nil, nil, classify(ErrDiscovery, fmt.Errorf("core: timed out getting resources of cloud provider, %w", ctx.Err()))
+	}
+	if err := ctx.Err(); err != nil {
+		logger.GetLogger(ctx).Warn().Msg("Stopped getting resources of cloud provider to shut down, nothing was synced")
+		return nil, nil, classify(ErrDiscovery, fmt.Errorf("core: stopped getting resources of cloud provider, %w", err))
+	}
+
+	discovered = true
+	return targets, sdkCloser, nil
+}
+
+// resolveAPIKey returns the ASM API key, and a function to fetch it again, from the first cloud
+// provider that has one, otherwise from the config. The key is empty with OAuth client credentials.
+func resolveAPIKey(ctx context.Context, cfg *config.Config, providers []cloud_provider_t.CloudProvider) (string, api.RefreshKeyFunc, error) {
+	apiKey := ""
+	var refreshKey api.RefreshKeyFunc
+	var err error
+	for _, cp := range providers {
+		cpCtx := providerContext(ctx, cp)
+
+		// Try get the API key from the cloud providers first, the first profile to provide one is used
+		// OAuth client credentials replace the API key
+		if apiKey != "" || cfg.ASM.OAuth.ClientID != "" {
+			continue
+		}
+		apiKey, err = cp.GetAPIKey(cpCtx)
+		if err != nil && !errors.Is(err, cloud_provider_t.ErrNoAPIKey) {
+			logger.GetLogger(cpCtx).Warn().Err(err).Msg("Failed to get api key")
+			return "", nil, classify(ErrASMAuth, fmt.Errorf("core: failed to get api key, %w", err))
+		}
+		if apiKey != "" {
+			refreshKey = func(ctx context.Context) (string, error) {
+				return cp.GetAPIKey(providerContext(ctx, cp))
+			}
+		}
+	}
+
+	// Default to the API key from the config or env API_KEY if no cloud provider has it
+	if apiKey == "" && cfg.ASM.OAuth.ClientID == "" {
+		apiKey = cfg.APIKey
+		// Replayed responses don't need a key
+		if strings.TrimSpace(apiKey) == "" && cfg.ASM.Replay == "" {
+			logger.GetLogger(ctx).Warn().Msg("API key not provided by cloud provider, config or env")
+			return "", nil, classify(ErrASMAuth, fmt.Errorf("core: API key not provided by cloud provider, api_key, asm.api_key_file or env API_KEY"))
+		}
+		// Only a secret reference or asm.api_key_file can be read again
+		refreshKey = func(ctx context.Context) (string, error) {
+			key, ok, err := cfg.RefreshSecret(ctx, apiKey)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return apiKey, nil
+			}
+			return key, nil
+		}
+	}
+
+	return apiKey, refreshKey, nil
+}
+
+// setupProviders returns a cloud provider for each enabled profile, authenticated
+func setupProviders(ctx context.Context, cfg *config.Config) ([]cloud_provider_t.CloudProvider, error) {
+	providers, err := cloud_provider.NewCloudProviders(ctx, cfg)
 	if err != nil {
-		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not get resources of cloud provider")
-		return fmt.Errorf("core: could not get resources of cloud provider, %w", err)
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init cloud provider")
+		return nil, classify(ErrConfig, fmt.Errorf("core: could not init cloud provider, %w", err))
 	}
-	logger.GetLogger(ctx).Debug().Interface("resources", resources).Msgf("Got %d resources", len(resources))
 
-	if err := conn.SyncResources(ctx, resources); err != nil {
-		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not sync resources with Hexiosec ASM connector")
-		return fmt.Errorf("core: could not sync resources with Hexiosec ASM connector, %w", err)
+	for _, cp := range providers {
+		cpCtx := providerContext(ctx, cp)
+		if err := cp.Authenticate(cpCtx); err != nil {
+			logAuthFailure(cpCtx, err)
+			return nil, classify(ErrCloudAuth, fmt.Errorf("core: could not authenticate with cloud provider %s, %w", cp.GetProfile().Name, err))
+		}
+		logger.GetLogger(cpCtx).Debug().Msg("Cloud provider authentication successful")
 	}
 
-	logger.GetLogger(ctx).Info().Msg("Cloud resource sync successful with Hexiosec ASM")
-	return nil
+	return providers, nil
+}
+
+// logAuthFailure logs that a cloud provider couldn't authenticate, with the error of the SDK at
+// debug if the cause was diagnosed
+func logAuthFailure(ctx context.Context, err error) {
+	var authErr *cloud_provider_t.AuthError
+	if errors.As(err, &authErr) {
+		logger.GetLogger(ctx).Debug().Err(authErr.Err).Msg("Cloud provider SDK error")
+	}
+	logger.GetLogger(ctx).Warn().Err(err).Msg("Could not authenticate with cloud provider")
+}
+
+// providerResources gets the cloud resources of a provider, bounded by the timeout of its profile.
+// Running out of time fails, as services that ran out of time are skipped.
+func providerResources(ctx context.Context, cp cloud_provider_t.CloudProvider) ([]string, error) {
+	// Warnings of the provider are recorded in the run prefixed with the profile they're of
+	collector := &warnings.Collector{}
+	defer func() {
+		for _, w := range collector.Warnings() {
+			warnings.Record(ctx, w.Category, "%s/%s: %s", cp.GetName(), cp.GetProfile().Name, w.Message)
+		}
+	}()
+	cpCtx := warnings.WithCollector(providerContext(ctx, cp), collector)
+	defer reporting.Repanic(cpCtx)
+
+	timeoutCtx, cancel := cloud_provider_t.WithTimeout(cpCtx, cp.GetProfile().Timeout)
+	resources, err := cp.GetResources(timeoutCtx)
+	timedOut := errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+	cancel()
+	if err != nil {
+		logger.GetLogger(cpCtx).Warn().Err(err).Msg("Could not get resources of cloud provider")
+		return nil, classify(ErrDiscovery, fmt.Errorf("core: could not get resources of cloud provider %s, %w", cp.GetProfile().Name, err))
+	}
+
+	// Services are skipped once the run is stopped, e.g. by SIGTERM, so the resources are incomplete
+	if errors.Is(ctx.Err(), context.Canceled) {
+		logger.GetLogger(cpCtx).Warn().Msg("Stopped getting resources of cloud provider to shut down")
+		return nil, classify(ErrDiscovery, fmt.Errorf("core: stopped getting resources of cloud provider %s, %w", cp.GetProfile().Name, ctx.Err()))
+	}
+
+	// Services that ran out of time are skipped, so don't sync the incomplete resources
+	if timedOut {
+		logger.GetLogger(cpCtx).Warn().Dur("timeout", cp.GetProfile().Timeout).Msg("Timed out getting resources of cloud provider")
+		return nil, classify(ErrDiscovery, fmt.Errorf("core: timed out getting resources of cloud provider %s, %w", cp.GetProfile().Name, context.DeadlineExceeded))
+	}
+	logger.GetLogger(cpCtx).Debug().Interface("resources", resources).Msgf("Got %d resources", len(resources))
+	progress.Discovered(cpCtx, len(resources))
+
+	return resources, nil
+}
+
+// providerContext returns a context with a logger, progress and error reporting tags identifying the
+// cloud provider and profile
+func providerContext(ctx context.Context, cp cloud_provider_t.CloudProvider) context.Context {
+	ctx = progress.WithProfile(ctx, cp.GetName()+"/"+cp.GetProfile().Name)
+	ctx = reporting.WithTags(ctx, "cloud_provider", cp.GetName(), "profile", cp.GetProfile().Name)
+	return logger.WithLogger(ctx, logger.GetLogger(ctx).With().
+		Str("cloud_provider", cp.GetName()).
+		Str("profile", cp.GetProfile().Name).
+		Logger())
+}
+
+// targetContext returns a context with a logger, and error reporting tags, identifying the scan and
+// seed tag of a target
+func targetContext(ctx context.Context, t *target) context.Context {
+	ctx = reporting.WithTags(ctx, "scan_id", t.scanID, "seed_tag", t.seedTag)
+	return logger.WithLogger(ctx, logger.GetLogger(ctx).With().
+		Str("scan_id", t.scanID).
+		Str("seed_tag", t.seedTag).
+		Logger())
+}
+
+// ScanPlan is the plan for the seeds of a scan with a seed tag
+type ScanPlan struct {
+	ScanID  string              `json:"scan_id"`
+	SeedTag string              `json:"seed_tag"`
+	Plan    *connector.SyncPlan `json:"plan"`
+}
+
+// Plan returns the changes a run would make to the seeds of each scan, without making them
+func Plan(ctx context.Context) ([]ScanPlan, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return plan(ctx, cfg)
+}
+
+// plan returns the changes a run with cfg would make to the seeds of each scan
+func plan(ctx context.Context, cfg *config.Config) ([]ScanPlan, error) {
+	// Plans make no changes, so a missing scan is an error rather than created
+	cfg.CreateScanIfMissing = false
+	ctx = runContext(ctx)
+	ctx, sampling := logger.WithSampling(ctx, cfg.Log.SampleEvery)
+	defer sampling.Log(ctx)
+
+	targets, sdkCloser, err := discover(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAPI(ctx, sdkCloser)
+
+	plans := make([]ScanPlan, 0, len(targets))
+	for _, t := range targets {
+		tCtx := targetContext(ctx, t)
+
+		plan, err := t.conn.Plan(tCtx, t.resources)
+		if err != nil {
+			logger.GetLogger(tCtx).Warn().Err(err).Msg("Could not plan sync with Hexiosec ASM connector")
+			return nil, fmt.Errorf("core: could not plan sync with Hexiosec ASM connector, %w", err)
+		}
+
+		plans = append(plans, ScanPlan{ScanID: t.scanID, SeedTag: t.seedTag, Plan: plan})
+	}
+
+	return plans, nil
+}
+
+// ProfileResources are the resources discovered from a cloud provider profile
+type ProfileResources struct {
+	Provider  string   `json:"provider"`
+	Profile   string   `json:"profile"`
+	Resources []string `json:"resources"`
+}
+
+// Discover returns the resources of every enabled profile, normalised as they would be synced,
+// without calling the ASM API
+func Discover(ctx context.Context) ([]ProfileResources, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	ctx = runContext(ctx)
+
+	if cfg.SyncTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.SyncTimeout)
+		defer cancel()
+	}
+
+	providers, err := setupProviders(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	discovered := make([]ProfileResources, 0, len(providers))
+	for _, cp := range providers {
+		resources, err := providerResources(ctx, cp)
+		if err != nil {
+			return nil, err
+		}
+
+		resources, err = connector.Normalise(providerContext(ctx, cp), cfg, resources)
+		if err != nil {
+			return nil, fmt.Errorf("core: could not normalise resources, %w", err)
+		}
+		if resources == nil {
+			resources = []string{}
+		}
+
+		discovered = append(discovered, ProfileResources{
+			Provider:  cp.GetName(),
+			Profile:   cp.GetProfile().Name,
+			Resources: resources,
+		})
+	}
+
+	return discovered, nil
+}
+
+// Validate loads and validates the config, without calling any cloud provider or ASM API.
+// Validation failures are returned as config.ValidationErrors.
+func Validate() error {
+	_, err := config.Load(cfgFilePath)
+	return classify(ErrConfig, err)
+}
+
+// EffectiveConfig loads the config and returns it as YAML, merged and defaulted, with credentials
+// redacted. Validation failures are returned as config.ValidationErrors.
+func EffectiveConfig() ([]byte, error) {
+	cfg, err := config.Load(cfgFilePath)
+	if err != nil {
+		return nil, classify(ErrConfig, err)
+	}
+	return cfg.MarshalRedacted()
 }
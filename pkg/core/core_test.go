@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/notify"
+	"github.com/hexiosec/asm-cloud-connector/internal/warnings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderResources_Stopped_Err(t *testing.T) {
+	cp := &fakeProvider{name: "aws", profile: config.CloudProvider{Name: "prod"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resources, err := providerResources(ctx, cp)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, resources)
+}
+
+// warningProvider is a cloud provider whose service check fails
+type warningProvider struct {
+	fakeProvider
+}
+
+func (p *warningProvider) GetResources(ctx context.Context) ([]string, error) {
+	warnings.Record(ctx, warnings.ServiceCheck, "EC2 in eu-west-1 failed, denied")
+	return []string{"example.com"}, nil
+}
+
+func TestProviderResources_RecordsWarningsOfProfile(t *testing.T) {
+	cp := &warningProvider{fakeProvider{name: "AWS", profile: config.CloudProvider{Name: "prod"}}}
+	collector := &warnings.Collector{}
+
+	resources, err := providerResources(warnings.WithCollector(context.Background(), collector), cp)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com"}, resources)
+	assert.Equal(t, []warnings.Warning{
+		{Category: warnings.ServiceCheck, Message: "AWS/prod: EC2 in eu-west-1 failed, denied"},
+	}, collector.Warnings())
+}
+
+func TestSendHeartbeat_StatusOfRun(t *testing.T) {
+	var status map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&status))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ScanID: "scan-123"}
+	cfg.Heartbeat.URL = server.URL
+	cfg.Schedule.Cron = "0 * * * *"
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	result := &RunResult{Scans: []notify.Summary{{ScanID: "scan-123"}}, Added: 2}
+
+	sendHeartbeat(context.Background(), cfg, result, errors.New("core: could not sync"), start)
+
+	hash, err := cfg.Hash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, status["config_hash"])
+	assert.Equal(t, "2026-01-02T04:00:00Z", status["next_run"])
+	lastRun := status["last_run"].(map[string]any)
+	assert.Equal(t, false, lastRun["success"])
+	assert.Equal(t, "core: could not sync", lastRun["error"])
+	assert.Equal(t, float64(2), lastRun["added"])
+	assert.Equal(t, []any{"scan-123"}, lastRun["scan_ids"])
+}
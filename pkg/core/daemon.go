@@ -0,0 +1,166 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/health"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/notify"
+	"github.com/hexiosec/asm-cloud-connector/internal/server"
+	"github.com/hexiosec/asm-cloud-connector/internal/systemd"
+	"github.com/hexiosec/asm-cloud-connector/internal/version"
+	"github.com/robfig/cron/v3"
+)
+
+// runMu stops syncs overlapping, e.g. a scheduled sync and one triggered through the server
+var runMu sync.Mutex
+
+// Start runs a sync. If daemon is set, or the config has a schedule or server, it keeps running
+// until ctx is cancelled, syncing on the schedule and serving the server.
+func Start(ctx context.Context, daemon bool) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	scheduled := cfg.Schedule.Interval > 0 || cfg.Schedule.Cron != ""
+	if cfg.Server.Listen == "" && !daemon && !scheduled {
+		_, err := runOnce(ctx, cfg)
+		return err
+	}
+
+	versionCache = version.NewMemoryCache()
+
+	// The liveness of a daemon or server is from when it starts until its first sync succeeds
+	tracker := health.NewTracker(cfg.Health.File, cfg.Health.MaxStaleness, time.Now())
+
+	// Run as a systemd Type=notify service, the watchdog stops being pinged once unhealthy
+	systemd.Ready(ctx)
+	defer systemd.Stopping(ctx)
+	go systemd.Watchdog(ctx, func() bool {
+		return tracker.Status(time.Now()).Healthy
+	})
+
+	if cfg.Server.Listen == "" {
+		return runDaemon(ctx, cfg, tracker)
+	}
+
+	srv := server.New(cfg.Server.Listen, cfg.Server.Token, func(ctx context.Context) ([]notify.Summary, error) {
+		result, err := syncExclusive(ctx, cfg, tracker)
+		if result == nil {
+			return nil, err
+		}
+		return result.Scans, err
+	}, tracker)
+	if !scheduled {
+		go tracker.Watch(ctx)
+		return srv.ListenAndServe(ctx)
+	}
+
+	// The daemon stops if the server fails, e.g. to listen
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	serverErr := make(chan error, 1)
+	go func() {
+		err := srv.ListenAndServe(ctx)
+		cancel()
+		serverErr <- err
+	}()
+
+	err = runDaemon(ctx, cfg, tracker)
+	cancel()
+	return errors.Join(err, <-serverErr)
+}
+
+// syncExclusive runs a sync, unless one is already running, recording a successful sync in tracker
+func syncExclusive(ctx context.Context, cfg *config.Config, tracker *health.Tracker) (*RunResult, error) {
+	if !runMu.TryLock() {
+		return nil, server.ErrSyncInProgress
+	}
+	defer runMu.Unlock()
+
+	result, err := runOnce(ctx, cfg)
+	if err != nil {
+		systemd.Status(ctx, fmt.Sprintf("Last sync at %s failed: %v", time.Now().Format(time.RFC3339), err))
+		return result, err
+	}
+	tracker.Success(ctx, time.Now())
+	systemd.Status(ctx, fmt.Sprintf("Last sync at %s added %d and removed %d seeds", time.Now().Format(time.RFC3339), result.Added, result.Removed))
+	return result, nil
+}
+
+// runDaemon syncs on the schedule until ctx is cancelled, e.g. by SIGTERM. Runs never overlap, a
+// run overrunning the schedule skips the runs it missed. A failed run is logged and notified, and
+// the daemon carries on. sync_timeout bounds each run. tracker keeps the liveness file until the
+// daemon stops.
+func runDaemon(ctx context.Context, cfg *config.Config, tracker *health.Tracker) error {
+	schedule, err := newSchedule(cfg)
+	if err != nil {
+		return err
+	}
+
+	go tracker.Watch(ctx)
+
+	// An interval runs straight away, a cron expression waits for its first time
+	next := time.Now()
+	if cfg.Schedule.Cron != "" {
+		next = schedule.Next(next)
+	}
+
+	logger.GetLogger(ctx).Info().
+		Dur("interval", cfg.Schedule.Interval).
+		Str("cron", cfg.Schedule.Cron).
+		Time("next_run", next).
+		Msg("Starting daemon")
+
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.GetLogger(ctx).Info().Msg("Stopping daemon")
+			return nil
+		case <-timer.C:
+		}
+
+		if _, err := syncExclusive(ctx, cfg, tracker); err != nil {
+			switch {
+			case errors.Is(err, server.ErrSyncInProgress):
+				logger.GetLogger(ctx).Warn().Msg("Sync triggered through the server still in progress, skipping run")
+			case ctx.Err() != nil:
+				logger.GetLogger(ctx).Warn().Err(err).Msg("Stopped run to shut down, the sync is incomplete")
+				return nil
+			default:
+				logger.GetLogger(ctx).Error().Err(err).Msg("Run failed")
+			}
+		}
+
+		next = schedule.Next(next)
+		if now := time.Now(); next.Before(now) {
+			logger.GetLogger(ctx).Warn().Time("missed_run", next).Msg("Run overran the schedule, skipping the runs it missed")
+			next = schedule.Next(now)
+		}
+		logger.GetLogger(ctx).Info().Time("next_run", next).Msg("Waiting for next run")
+	}
+}
+
+// newSchedule returns the schedule of the config, or an error if it has none
+func newSchedule(cfg *config.Config) (cron.Schedule, error) {
+	switch {
+	case cfg.Schedule.Cron != "":
+		schedule, err := cron.ParseStandard(cfg.Schedule.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("core: could not parse schedule.cron, %w", err)
+		}
+		return schedule, nil
+	case cfg.Schedule.Interval > 0:
+		return cron.Every(cfg.Schedule.Interval), nil
+	default:
+		return nil, errors.New("core: daemon mode requires schedule.interval or schedule.cron")
+	}
+}
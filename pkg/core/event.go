@@ -0,0 +1,262 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/warnings"
+)
+
+// Event is the payload of a Lambda invocation, overriding the config for that invocation, so one
+// function can serve several scans from different EventBridge rules. An empty event runs with the
+// config as it is.
+type Event struct {
+	// ScanID syncs the resources of every profile to this scan
+	ScanID string `json:"scan_id,omitempty"`
+	// Providers limits discovery to the profiles of these providers, e.g. ["aws"]
+	Providers []string `json:"providers,omitempty"`
+	// Profiles limits discovery to the profiles with these names
+	Profiles []string `json:"profiles,omitempty"`
+	// Accounts limits discovery to these AWS account IDs and GCP projects. Profiles without any of
+	// them are skipped.
+	Accounts []string `json:"accounts,omitempty"`
+	// DryRun plans the sync and logs the plan, without changing any seeds
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// EventResponse is the result of a Lambda invocation, the result of the run, or the plan of each
+// scan for a dry run. A fanned out sync returns the number of worker events queued.
+type EventResponse struct {
+	RunResult
+	Plans  []ScanPlan `json:"plans,omitempty"`
+	Queued int        `json:"queued,omitempty"`
+}
+
+// scopeKey is the context key of the scope tag of a sync
+type scopeKey struct{}
+
+// withScope returns a context syncing only the seeds tagged with tag, see Connector.SetScope
+func withScope(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, scopeKey{}, tag)
+}
+
+// scopeOf returns the scope tag of the sync, empty if it syncs every seed with the seed tag
+func scopeOf(ctx context.Context) string {
+	tag, _ := ctx.Value(scopeKey{}).(string)
+	return tag
+}
+
+// HandleLambda is the Lambda handler. An SQS event is a batch of worker events of a fanned out
+// sync, each is run in turn. Any other payload is an Event.
+func HandleLambda(ctx context.Context, payload json.RawMessage) (*EventResponse, error) {
+	var sqsEvent events.SQSEvent
+	if err := json.Unmarshal(payload, &sqsEvent); err == nil && len(sqsEvent.Records) > 0 {
+		return runMessages(ctx, sqsEvent.Records)
+	}
+
+	var event Event
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &event); err != nil {
+			logger.GetLogger(ctx).Warn().Err(err).Msg("Could not parse event")
+			return nil, fmt.Errorf("core: could not parse event, %w", err)
+		}
+	}
+	return RunEvent(ctx, event)
+}
+
+// runMessages runs the event of each SQS message, failing if any of them failed
+func runMessages(ctx context.Context, messages []events.SQSMessage) (*EventResponse, error) {
+	response := &EventResponse{}
+	errs := []error{}
+	for _, message := range messages {
+		msgCtx := logger.WithLogger(ctx, logger.GetLogger(ctx).With().Str("message_id", message.MessageId).Logger())
+
+		var event Event
+		if err := json.Unmarshal([]byte(message.Body), &event); err != nil {
+			logger.GetLogger(msgCtx).Warn().Err(err).Msg("Could not parse event of SQS message")
+			errs = append(errs, fmt.Errorf("core: could not parse event of SQS message %s, %w", message.MessageId, err))
+			continue
+		}
+
+		resp, err := RunEvent(msgCtx, event)
+		if resp != nil {
+			response.merge(resp.RunResult)
+			response.Plans = append(response.Plans, resp.Plans...)
+		}
+		errs = append(errs, err)
+	}
+	return response, errors.Join(errs...)
+}
+
+// RunEvent runs a sync, or plans one, with the config overridden by event. If fan_out.queue_url
+// is set and the event doesn't select profiles or accounts, an event for each account is queued
+// for the worker invocations instead.
+func RunEvent(ctx context.Context, event Event) (*EventResponse, error) {
+	start := time.Now()
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	before := enabledProfiles(cfg)
+	if err := event.apply(cfg); err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not apply event to config")
+		return nil, err
+	}
+
+	if cfg.FanOut.QueueURL != "" && len(event.Profiles) == 0 && len(event.Accounts) == 0 {
+		queued, err := fanOut(ctx, cfg, event)
+		return &EventResponse{Queued: queued}, err
+	}
+
+	// Seeds of the rest of the cloud would look stale to a sync of part of it
+	tag, partial := event.scope(before, enabledProfiles(cfg))
+	if tag != "" {
+		ctx = withScope(ctx, tag)
+	}
+	keptStale := partial && cfg.DeleteStaleSeeds
+	if keptStale {
+		logger.GetLogger(ctx).Warn().Msg("The event selects several profiles or accounts, which can't be scoped, so stale seeds aren't deleted")
+		cfg.DeleteStaleSeeds = false
+	}
+
+	if event.DryRun {
+		plans, err := plan(ctx, cfg)
+		for _, p := range plans {
+			logger.GetLogger(ctx).Info().
+				Str("scan_id", p.ScanID).
+				Str("seed_tag", p.SeedTag).
+				Interface("plan", p.Plan).
+				Msg("Dry run, the sync would make these changes")
+		}
+		return &EventResponse{Plans: plans}, err
+	}
+
+	result, err := runOnce(ctx, cfg)
+	if keptStale {
+		result.warn(warnings.StaleKept, "stale seeds weren't deleted, the event selects several profiles or accounts, which can't be scoped")
+	}
+	emitMetrics(ctx, cfg, result, err, time.Since(start))
+	return &EventResponse{RunResult: *result}, err
+}
+
+// apply overrides cfg with the fields of the event that are set
+func (e *Event) apply(cfg *config.Config) error {
+	if e.ScanID != "" {
+		cfg.ScanID = e.ScanID
+		cfg.ScanName = ""
+		for _, profile := range profilesOf(cfg) {
+			profile.ScanID = e.ScanID
+			profile.ScanName = ""
+		}
+	}
+
+	if len(e.Providers) > 0 {
+		for _, provider := range e.Providers {
+			if !slices.Contains([]string{"aws", "azure", "gcp"}, provider) {
+				return fmt.Errorf("core: unknown provider %q in event, expected aws, azure or gcp", provider)
+			}
+		}
+		for _, profile := range cfg.AWS {
+			profile.Enabled = profile.Enabled && slices.Contains(e.Providers, "aws")
+		}
+		for _, profile := range cfg.Azure {
+			profile.Enabled = profile.Enabled && slices.Contains(e.Providers, "azure")
+		}
+		for _, profile := range cfg.GCP {
+			profile.Enabled = profile.Enabled && slices.Contains(e.Providers, "gcp")
+		}
+	}
+
+	if len(e.Profiles) > 0 {
+		for _, profile := range profilesOf(cfg) {
+			profile.Enabled = profile.Enabled && slices.Contains(e.Profiles, profile.Name)
+		}
+	}
+
+	if len(e.Accounts) > 0 {
+		for _, profile := range cfg.AWS {
+			// Only the accounts a profile can assume a role in can be selected
+			if profile.AssumeRole == nil {
+				profile.Enabled = false
+				continue
+			}
+			var accounts []string
+			for _, account := range e.Accounts {
+				if profile.ListAllAccounts || slices.Contains(profile.Accounts, account) {
+					accounts = append(accounts, account)
+				}
+			}
+			profile.ListAllAccounts = false
+			profile.Accounts = accounts
+			profile.Enabled = profile.Enabled && len(accounts) > 0
+		}
+		for _, profile := range cfg.GCP {
+			projects := slices.DeleteFunc(slices.Clone(profile.Projects), func(project string) bool {
+				return !slices.Contains(e.Accounts, strings.TrimPrefix(project, "projects/"))
+			})
+			profile.Projects = projects
+			profile.Enabled = profile.Enabled && len(projects) > 0
+		}
+		// Subscriptions can't be selected, so Azure profiles are skipped
+		for _, profile := range cfg.Azure {
+			profile.Enabled = false
+		}
+	}
+
+	for _, profile := range profilesOf(cfg) {
+		if profile.Enabled {
+			return nil
+		}
+	}
+	return fmt.Errorf("core: no enabled profile matches the providers, profiles and accounts of the event")
+}
+
+// scope returns the tag of the part of the cloud the event selects, given the enabled profiles
+// before and after it was applied, e.g. "cloud-connector:aws-1:111111111111" for one account of
+// the aws-1 profile. It's empty if the event doesn't select part of the cloud, and partial is set
+// if it selects a part that can't be tagged, several profiles or several accounts.
+func (e *Event) scope(before []*config.CloudProvider, after []*config.CloudProvider) (tag string, partial bool) {
+	if len(e.Accounts) == 0 && len(after) == len(before) {
+		return "", false
+	}
+	if len(after) != 1 || len(e.Accounts) > 1 {
+		return "", true
+	}
+
+	tag = after[0].SeedTag + ":" + after[0].Name
+	if len(e.Accounts) == 1 {
+		tag += ":" + e.Accounts[0]
+	}
+	return tag, false
+}
+
+// enabledProfiles returns the enabled profiles of every provider in cfg
+func enabledProfiles(cfg *config.Config) []*config.CloudProvider {
+	return slices.DeleteFunc(profilesOf(cfg), func(profile *config.CloudProvider) bool {
+		return !profile.Enabled
+	})
+}
+
+// profilesOf returns the profiles of every provider in cfg
+func profilesOf(cfg *config.Config) []*config.CloudProvider {
+	var profiles []*config.CloudProvider
+	for _, profile := range cfg.AWS {
+		profiles = append(profiles, &profile.CloudProvider)
+	}
+	for _, profile := range cfg.Azure {
+		profiles = append(profiles, &profile.CloudProvider)
+	}
+	for _, profile := range cfg.GCP {
+		profiles = append(profiles, &profile.CloudProvider)
+	}
+	return profiles
+}
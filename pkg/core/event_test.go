@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEventTestConfig() *config.Config {
+	role := "ConnectorRole"
+	cfg := &config.Config{ScanID: "scan-1"}
+	cfg.AWS = config.Profiles[config.AWSCloudProvider]{
+		{CloudProvider: config.CloudProvider{Enabled: true, Name: "org", ScanID: "scan-1"}, Accounts: []string{"111", "222"}, AssumeRole: &role},
+		{CloudProvider: config.CloudProvider{Enabled: true, Name: "all", ScanName: "Cloud"}, ListAllAccounts: true, AssumeRole: &role},
+	}
+	cfg.Azure = config.Profiles[config.AzureCloudProvider]{
+		{CloudProvider: config.CloudProvider{Enabled: true, Name: "tenant", ScanID: "scan-1"}},
+	}
+	cfg.GCP = config.Profiles[config.GCPCloudProvider]{
+		{CloudProvider: config.CloudProvider{Enabled: true, Name: "gcp", ScanID: "scan-1"}, Projects: []string{"projects/p1", "projects/p2"}},
+	}
+	return cfg
+}
+
+func TestEvent_Apply_Empty(t *testing.T) {
+	cfg := newEventTestConfig()
+	require.NoError(t, (&Event{}).apply(cfg))
+	assert.Equal(t, newEventTestConfig(), cfg)
+}
+
+func TestEvent_Apply_ScanID(t *testing.T) {
+	cfg := newEventTestConfig()
+	require.NoError(t, (&Event{ScanID: "scan-2"}).apply(cfg))
+
+	assert.Equal(t, "scan-2", cfg.ScanID)
+	for _, profile := range profilesOf(cfg) {
+		assert.Equal(t, "scan-2", profile.ScanID)
+		assert.Empty(t, profile.ScanName)
+	}
+}
+
+func TestEvent_Apply_Providers(t *testing.T) {
+	cfg := newEventTestConfig()
+	require.NoError(t, (&Event{Providers: []string{"gcp"}}).apply(cfg))
+
+	assert.False(t, cfg.AWS[0].Enabled)
+	assert.False(t, cfg.AWS[1].Enabled)
+	assert.False(t, cfg.Azure[0].Enabled)
+	assert.True(t, cfg.GCP[0].Enabled)
+}
+
+func TestEvent_Apply_UnknownProvider_Fails(t *testing.T) {
+	err := (&Event{Providers: []string{"oracle"}}).apply(newEventTestConfig())
+	assert.ErrorContains(t, err, `unknown provider "oracle"`)
+}
+
+func TestEvent_Apply_Accounts(t *testing.T) {
+	cfg := newEventTestConfig()
+	require.NoError(t, (&Event{Accounts: []string{"222", "333", "p2"}}).apply(cfg))
+
+	// Only configured accounts are selected, unless the profile lists all accounts
+	assert.Equal(t, []string{"222"}, cfg.AWS[0].Accounts)
+	assert.True(t, cfg.AWS[0].Enabled)
+	assert.Equal(t, []string{"222", "333", "p2"}, cfg.AWS[1].Accounts)
+	assert.False(t, cfg.AWS[1].ListAllAccounts)
+	assert.False(t, cfg.Azure[0].Enabled)
+	assert.Equal(t, []string{"projects/p2"}, cfg.GCP[0].Projects)
+	assert.True(t, cfg.GCP[0].Enabled)
+}
+
+func TestEvent_Apply_NoMatchingProfile_Fails(t *testing.T) {
+	err := (&Event{Providers: []string{"azure"}, Accounts: []string{"111"}}).apply(newEventTestConfig())
+	assert.ErrorContains(t, err, "no enabled profile")
+}
+
+func TestEvent_Apply_Profiles(t *testing.T) {
+	cfg := newEventTestConfig()
+	require.NoError(t, (&Event{Profiles: []string{"all", "gcp"}}).apply(cfg))
+
+	assert.False(t, cfg.AWS[0].Enabled)
+	assert.True(t, cfg.AWS[1].Enabled)
+	assert.False(t, cfg.Azure[0].Enabled)
+	assert.True(t, cfg.GCP[0].Enabled)
+}
+
+func TestEvent_Scope(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   Event
+		tag     string
+		partial bool
+	}{
+		{"empty", Event{}, "", false},
+		{"scan only", Event{ScanID: "scan-2"}, "", false},
+		{"profile", Event{Profiles: []string{"org"}}, "seed-tag:org", false},
+		{"account", Event{Profiles: []string{"all"}, Accounts: []string{"333"}}, "seed-tag:all:333", false},
+		{"several profiles", Event{Providers: []string{"aws"}}, "", true},
+		{"several accounts", Event{Profiles: []string{"org"}, Accounts: []string{"111", "222"}}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newEventTestConfig()
+			for _, profile := range profilesOf(cfg) {
+				profile.SeedTag = "seed-tag"
+			}
+
+			before := enabledProfiles(cfg)
+			require.NoError(t, tt.event.apply(cfg))
+			tag, partial := tt.event.scope(before, enabledProfiles(cfg))
+			assert.Equal(t, tt.tag, tag)
+			assert.Equal(t, tt.partial, partial)
+		})
+	}
+}
+
+// fakeProvider is a cloud provider of a single account
+type fakeProvider struct {
+	name    string
+	profile config.CloudProvider
+}
+
+func (p *fakeProvider) Authenticate(_ context.Context) error             { return nil }
+func (p *fakeProvider) GetResources(_ context.Context) ([]string, error) { return nil, nil }
+func (p *fakeProvider) GetAPIKey(_ context.Context) (string, error)      { return "", nil }
+func (p *fakeProvider) GetName() string                                  { return p.name }
+func (p *fakeProvider) GetProfile() *config.CloudProvider                { return &p.profile }
+
+// fakeOrgProvider is a cloud provider listing the accounts it discovers
+type fakeOrgProvider struct {
+	fakeProvider
+	accounts []string
+}
+
+func (p *fakeOrgProvider) ListAccounts(_ context.Context) ([]string, error) { return p.accounts, nil }
+
+func TestFanOutEvents(t *testing.T) {
+	providers := []cloud_provider_t.CloudProvider{
+		&fakeOrgProvider{fakeProvider{name: "AWS", profile: config.CloudProvider{Name: "org"}}, []string{"111", "222"}},
+		&fakeOrgProvider{fakeProvider{name: "AWS", profile: config.CloudProvider{Name: "local"}}, nil},
+		&fakeProvider{name: "Azure", profile: config.CloudProvider{Name: "tenant"}},
+	}
+
+	events, err := fanOutEvents(context.Background(), Event{ScanID: "scan-2", DryRun: true}, providers)
+	require.NoError(t, err)
+	assert.Equal(t, []Event{
+		{ScanID: "scan-2", Providers: []string{"aws"}, Profiles: []string{"org"}, Accounts: []string{"111"}, DryRun: true},
+		{ScanID: "scan-2", Providers: []string{"aws"}, Profiles: []string{"org"}, Accounts: []string{"222"}, DryRun: true},
+		{ScanID: "scan-2", Providers: []string{"aws"}, Profiles: []string{"local"}, DryRun: true},
+		{ScanID: "scan-2", Providers: []string{"azure"}, Profiles: []string{"tenant"}, DryRun: true},
+	}, events)
+}
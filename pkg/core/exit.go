@@ -0,0 +1,94 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+// Exit codes of the connector, so schedulers and pipelines can branch on the class of a failure
+const (
+	ExitOK = 0
+	// ExitFailure is any failure without a class of its own
+	ExitFailure = 1
+	// ExitUsage is invalid flags or arguments
+	ExitUsage       = 2
+	ExitConfig      = 3
+	ExitCloudAuth   = 4
+	ExitDiscovery   = 5
+	ExitASMAuth     = 6
+	ExitPartialSync = 7
+)
+
+// Classes of failure, the errors returned by core wrap one of them if their class is known
+var (
+	// ErrConfig is the config failing to load or validate
+	ErrConfig = errors.New("config error")
+	// ErrCloudAuth is a cloud provider profile failing to authenticate
+	ErrCloudAuth = errors.New("cloud provider authentication failed")
+	// ErrDiscovery is the resources of a profile failing to be discovered, or discovery being stopped
+	ErrDiscovery = errors.New("discovery failed")
+	// ErrASMAuth is the ASM credentials missing or invalid, or the scan not found
+	ErrASMAuth = errors.New("ASM authentication failed")
+	// ErrPartialSync is one or more scans not being fully synced
+	ErrPartialSync = errors.New("sync incomplete")
+)
+
+// classified is an error of a class of failure, its message is the message of the error alone
+type classified struct {
+	class error
+	err   error
+}
+
+func (e *classified) Error() string {
+	return e.err.Error()
+}
+
+func (e *classified) Unwrap() []error {
+	return []error{e.err, e.class}
+}
+
+// classify marks err as of class, nil stays nil
+func classify(class error, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classified{class: class, err: err}
+}
+
+// ExitCode returns the exit code of the class of failure err wraps, ExitFailure if it has none
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrConfig):
+		return ExitConfig
+	case errors.Is(err, ErrCloudAuth):
+		return ExitCloudAuth
+	case errors.Is(err, ErrDiscovery):
+		return ExitDiscovery
+	case errors.Is(err, ErrASMAuth):
+		return ExitASMAuth
+	case errors.Is(err, ErrPartialSync):
+		return ExitPartialSync
+	default:
+		return ExitFailure
+	}
+}
+
+// loadConfig loads the config and sets up logging with it, failures are ErrConfig
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load(cfgFilePath)
+	if err != nil {
+		logger.GetGlobalLogger().Error().Err(err).Msg("Config failed to load")
+		return nil, classify(ErrConfig, err)
+	}
+	if err := setupLogging(cfg); err != nil {
+		return nil, classify(ErrConfig, err)
+	}
+	if err := setupStatsD(cfg); err != nil {
+		return nil, classify(ErrConfig, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"nil", nil, ExitOK},
+		{"unclassified", errors.New("boom"), ExitFailure},
+		{"config", classify(ErrConfig, errors.New("boom")), ExitConfig},
+		{"cloud auth", classify(ErrCloudAuth, errors.New("boom")), ExitCloudAuth},
+		{"discovery", classify(ErrDiscovery, errors.New("boom")), ExitDiscovery},
+		{"ASM auth", classify(ErrASMAuth, errors.New("boom")), ExitASMAuth},
+		{"partial sync", classify(ErrPartialSync, errors.New("boom")), ExitPartialSync},
+		{"wrapped", fmt.Errorf("run: %w", classify(ErrDiscovery, errors.New("boom"))), ExitDiscovery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.code, ExitCode(tt.err))
+		})
+	}
+}
+
+func TestClassify_KeepsMessageAndCause(t *testing.T) {
+	validationErrs := config.ValidationErrors{}
+	err := classify(ErrConfig, validationErrs)
+
+	assert.Equal(t, validationErrs.Error(), err.Error())
+	assert.ErrorAs(t, err, &config.ValidationErrors{})
+	assert.Nil(t, classify(ErrConfig, nil))
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/cloud_provider"
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/fanout"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+)
+
+// fanOut queues an event for each account of each enabled profile, returning the number of events
+// queued. Each event is run by a worker invocation, so no invocation has to discover the whole
+// organisation.
+func fanOut(ctx context.Context, cfg *config.Config, event Event) (int, error) {
+	providers, err := cloud_provider.NewCloudProviders(ctx, cfg)
+	if err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init cloud provider")
+		return 0, fmt.Errorf("core: could not init cloud provider, %w", err)
+	}
+
+	workerEvents, err := fanOutEvents(ctx, event, providers)
+	if err != nil {
+		return 0, err
+	}
+
+	bodies := make([]string, 0, len(workerEvents))
+	for _, workerEvent := range workerEvents {
+		body, err := json.Marshal(workerEvent)
+		if err != nil {
+			return 0, fmt.Errorf("core: could not encode worker event, %w", err)
+		}
+		bodies = append(bodies, string(body))
+	}
+
+	queue, err := fanout.NewQueue(ctx, cfg)
+	if err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init fan-out queue")
+		return 0, fmt.Errorf("core: could not init fan-out queue, %w", err)
+	}
+	if err := queue.Send(ctx, bodies); err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not queue worker events")
+		return 0, fmt.Errorf("core: could not queue worker events, %w", err)
+	}
+
+	logger.GetLogger(ctx).Info().Str("queue_url", cfg.FanOut.QueueURL).Msgf("Queued %d worker events", len(bodies))
+	return len(bodies), nil
+}
+
+// fanOutEvents returns the worker events of a fanned out event, one for each account of providers
+// that list them, and one for the whole profile of those that don't
+func fanOutEvents(ctx context.Context, event Event, providers []cloud_provider_t.CloudProvider) ([]Event, error) {
+	workerEvents := []Event{}
+	for _, cp := range providers {
+		cpCtx := providerContext(ctx, cp)
+		profileEvent := Event{
+			ScanID:    event.ScanID,
+			Providers: []string{strings.ToLower(cp.GetName())},
+			Profiles:  []string{cp.GetProfile().Name},
+			DryRun:    event.DryRun,
+		}
+
+		lister, ok := cp.(cloud_provider_t.AccountLister)
+		if !ok {
+			workerEvents = append(workerEvents, profileEvent)
+			continue
+		}
+
+		if err := cp.Authenticate(cpCtx); err != nil {
+			logAuthFailure(cpCtx, err)
+			return nil, fmt.Errorf("core: could not authenticate with cloud provider %s, %w", cp.GetProfile().Name, err)
+		}
+		accounts, err := lister.ListAccounts(cpCtx)
+		if err != nil {
+			logger.GetLogger(cpCtx).Warn().Err(err).Msg("Could not list accounts of cloud provider")
+			return nil, fmt.Errorf("core: could not list accounts of cloud provider %s, %w", cp.GetProfile().Name, err)
+		}
+		logger.GetLogger(cpCtx).Debug().Strs("accounts", accounts).Msgf("Fanning out %d accounts", len(accounts))
+
+		if len(accounts) == 0 {
+			workerEvents = append(workerEvents, profileEvent)
+			continue
+		}
+		for _, account := range accounts {
+			accountEvent := profileEvent
+			accountEvent.Accounts = []string{account}
+			workerEvents = append(workerEvents, accountEvent)
+		}
+	}
+	return workerEvents, nil
+}
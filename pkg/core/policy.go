@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/cloud_provider"
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+)
+
+// ProfilePolicies are the least privilege policies the enabled service checks of a cloud provider
+// profile need
+type ProfilePolicies struct {
+	Provider string                    `json:"provider"`
+	Profile  string                    `json:"profile"`
+	Policies []cloud_provider_t.Policy `json:"policies"`
+}
+
+// Policies loads the config and returns the policies of every enabled profile, without calling any
+// cloud provider or ASM API. Validation failures are returned as config.ValidationErrors.
+func Policies() ([]ProfilePolicies, error) {
+	cfg, err := config.Load(cfgFilePath)
+	if err != nil {
+		return nil, classify(ErrConfig, err)
+	}
+
+	return policies(context.Background(), cfg)
+}
+
+// policies returns the policies of every enabled profile of cfg
+func policies(ctx context.Context, cfg *config.Config) ([]ProfilePolicies, error) {
+	providers, err := cloud_provider.NewCloudProviders(ctx, cfg)
+	if err != nil {
+		return nil, classify(ErrConfig, fmt.Errorf("core: could not init cloud provider, %w", err))
+	}
+
+	profiles := make([]ProfilePolicies, 0, len(providers))
+	for _, cp := range providers {
+		generator, ok := cp.(cloud_provider_t.PolicyGenerator)
+		if !ok {
+			continue
+		}
+		profiles = append(profiles, ProfilePolicies{
+			Provider: cp.GetName(),
+			Profile:  cp.GetProfile().Name,
+			Policies: generator.Policies(),
+		})
+	}
+	return profiles, nil
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/api"
+	"github.com/hexiosec/asm-cloud-connector/internal/cloud_provider"
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/hexiosec/asm-cloud-connector/internal/connector"
+	"github.com/hexiosec/asm-cloud-connector/internal/logger"
+	"github.com/hexiosec/asm-cloud-connector/internal/version"
+)
+
+// asmProvider is the provider of the preflight checks of the ASM API
+const asmProvider = "asm"
+
+// PreflightCheck is the outcome of a preflight check. ASM checks have the provider "asm" and no
+// profile.
+type PreflightCheck struct {
+	Provider string `json:"provider"`
+	Profile  string `json:"profile"`
+	Check    string `json:"check"`
+	Passed   bool   `json:"passed"`
+	Error    string `json:"error,omitempty"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+func newPreflightCheck(provider string, profile string, check cloud_provider_t.Check) PreflightCheck {
+	result := PreflightCheck{Provider: provider, Profile: profile, Check: check.Name, Passed: check.Err == nil}
+	if check.Err != nil {
+		result.Error = check.Err.Error()
+		result.Hint = check.Hint
+	}
+	// A diagnosed cause has a more specific fix than the hint of the check
+	var authErr *cloud_provider_t.AuthError
+	if errors.As(check.Err, &authErr) {
+		result.Error = authErr.Cause
+		result.Hint = strings.ToUpper(authErr.Remediation[:1]) + authErr.Remediation[1:]
+	}
+	return result
+}
+
+// Preflight checks the access a run needs, with the smallest call of each check rather than
+// discovering or syncing resources. A failed check doesn't stop the checks that don't depend on
+// it, an error is only returned if the config couldn't be loaded or the providers created.
+func Preflight(ctx context.Context) ([]PreflightCheck, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return preflight(ctx, cfg)
+}
+
+// preflight checks the access a run with cfg needs
+func preflight(ctx context.Context, cfg *config.Config) ([]PreflightCheck, error) {
+	// Preflight makes no changes, so a missing scan is an error rather than created
+	cfg.CreateScanIfMissing = false
+	ctx = runContext(ctx)
+
+	providers, err := cloud_provider.NewCloudProviders(ctx, cfg)
+	if err != nil {
+		logger.GetLogger(ctx).Warn().Err(err).Msg("Could not init cloud provider")
+		return nil, classify(ErrConfig, fmt.Errorf("core: could not init cloud provider, %w", err))
+	}
+
+	var checks []PreflightCheck
+	authenticated := make([]cloud_provider_t.CloudProvider, 0, len(providers))
+	for _, cp := range providers {
+		providerChecks, ok := preflightProvider(providerContext(ctx, cp), cp)
+		checks = append(checks, providerChecks...)
+		if ok {
+			authenticated = append(authenticated, cp)
+		}
+	}
+
+	return append(checks, preflightASM(ctx, cfg, providers, authenticated)...), nil
+}
+
+// preflightProvider checks a provider can authenticate and, if it can, the checks of the provider.
+// ok is whether it authenticated.
+func preflightProvider(ctx context.Context, cp cloud_provider_t.CloudProvider) (checks []PreflightCheck, ok bool) {
+	name, profile := cp.GetName(), cp.GetProfile().Name
+
+	err := cp.Authenticate(ctx)
+	checks = append(checks, newPreflightCheck(name, profile, cloud_provider_t.Check{
+		Name: "Authenticate",
+		Err:  err,
+		Hint: "Check the credentials of the profile are available to the connector",
+	}))
+	if err != nil {
+		return checks, false
+	}
+
+	if p, ok := cp.(cloud_provider_t.Preflighter); ok {
+		for _, check := range p.Preflight(ctx) {
+			checks = append(checks, newPreflightCheck(name, profile, check))
+		}
+	}
+	return checks, true
+}
+
+// preflightASM checks the API key can be resolved, authenticates with ASM, and that the seeds of
+// the scan of each profile can be read
+func preflightASM(ctx context.Context, cfg *config.Config, providers []cloud_provider_t.CloudProvider, authenticated []cloud_provider_t.CloudProvider) []PreflightCheck {
+	// The API key of a provider that failed to authenticate can't be fetched
+	if cfg.ASM.OAuth.ClientID == "" && len(authenticated) < len(providers) && cfg.APIKey == "" {
+		return []PreflightCheck{newPreflightCheck(asmProvider, "", cloud_provider_t.Check{
+			Name: "API key",
+			Err:  fmt.Errorf("core: not checked, a cloud provider failed to authenticate"),
+			Hint: "Fix the authentication of the cloud provider that stores the API key",
+		})}
+	}
+
+	apiKey, refreshKey, err := resolveAPIKey(ctx, cfg, authenticated)
+	checks := []PreflightCheck{newPreflightCheck(asmProvider, "", cloud_provider_t.Check{
+		Name: "API key",
+		Err:  err,
+		Hint: "Set api_key, asm.api_key_file or env API_KEY, or store the key in the secret of a cloud provider profile",
+	})}
+	if err != nil {
+		return checks
+	}
+
+	sdk, sdkCloser, err := api.NewAPI(cfg, version.UserAgent(), apiKey, refreshKey)
+	if err == nil {
+		defer closeAPI(ctx, sdkCloser)
+		err = checkASMAuth(ctx, sdk)
+	}
+	checks = append(checks, newPreflightCheck(asmProvider, "", cloud_provider_t.Check{
+		Name: "Authenticate",
+		Err:  err,
+		Hint: "Check the API key or OAuth client credentials are valid and haven't expired",
+	}))
+	if err != nil {
+		return checks
+	}
+
+	type scan struct{ id, name string }
+	var checked []scan
+	for _, cp := range providers {
+		profile := cp.GetProfile()
+		s := scan{id: profile.ScanID, name: profile.ScanName}
+		if slices.Contains(checked, s) {
+			continue
+		}
+		checked = append(checked, s)
+
+		label := s.id
+		if label == "" {
+			label = s.name
+		}
+		checks = append(checks, newPreflightCheck(asmProvider, "", cloud_provider_t.Check{
+			Name: "Scan " + label,
+			Err:  checkScan(ctx, cfg, sdk, profile),
+			Hint: "Check the scan exists and the API key's user can edit it, or enable create_scan_if_missing",
+		}))
+	}
+	return checks
+}
+
+// checkASMAuth checks the credentials are authenticated
+func checkASMAuth(ctx context.Context, sdk api.API) error {
+	state, _, err := sdk.GetState(ctx)
+	if err != nil {
+		return fmt.Errorf("core: failed to get auth state, %w", err)
+	}
+	if !state.Authenticated {
+		return fmt.Errorf("core: credentials not valid")
+	}
+	return nil
+}
+
+// checkScan checks the scan of profile can be found and its seeds read
+func checkScan(ctx context.Context, cfg *config.Config, sdk api.API, profile *config.CloudProvider) error {
+	scanCfg := *cfg
+	scanCfg.ScanID = profile.ScanID
+	scanCfg.ScanName = profile.ScanName
+	scanCfg.SeedTag = profile.SeedTag
+	conn, err := connector.NewConnector(&scanCfg, sdk, nil)
+	if err != nil {
+		return fmt.Errorf("core: could not init Hexiosec ASM connector, %w", err)
+	}
+	if err := conn.Authenticate(ctx); err != nil {
+		return fmt.Errorf("core: %w", err)
+	}
+
+	if _, _, err := sdk.GetScanSeedsById(ctx, conn.ScanID()); err != nil {
+		return fmt.Errorf("core: failed to read seeds of %s scan, %w", conn.ScanID(), err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	cloud_provider_t "github.com/hexiosec/asm-cloud-connector/internal/cloud_provider/types"
+	"github.com/hexiosec/asm-cloud-connector/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePreflightProvider is a cloud provider with preflight checks
+type fakePreflightProvider struct {
+	fakeProvider
+	checks []cloud_provider_t.Check
+}
+
+func (p *fakePreflightProvider) Preflight(_ context.Context) []cloud_provider_t.Check {
+	return p.checks
+}
+
+func TestPreflightProvider(t *testing.T) {
+	cp := &fakePreflightProvider{
+		fakeProvider: fakeProvider{name: "aws", profile: config.CloudProvider{Name: "prod"}},
+		checks: []cloud_provider_t.Check{
+			{Name: "Regions"},
+			{Name: "Service EC2", Err: assert.AnError, Hint: "Allow ec2:DescribeInstances"},
+		},
+	}
+
+	checks, ok := preflightProvider(context.Background(), cp)
+
+	assert.True(t, ok)
+	assert.Equal(t, []PreflightCheck{
+		{Provider: "aws", Profile: "prod", Check: "Authenticate", Passed: true},
+		{Provider: "aws", Profile: "prod", Check: "Regions", Passed: true},
+		{Provider: "aws", Profile: "prod", Check: "Service EC2", Error: assert.AnError.Error(), Hint: "Allow ec2:DescribeInstances"},
+	}, checks)
+}
+
+func TestPreflightProvider_DiagnosedErr_RemediationHint(t *testing.T) {
+	cp := &fakePreflightProvider{
+		fakeProvider: fakeProvider{name: "azure", profile: config.CloudProvider{Name: "prod"}},
+		checks: []cloud_provider_t.Check{
+			{Name: "Resource Graph", Hint: "Assign the Reader role", Err: &cloud_provider_t.AuthError{
+				Cause:       "the Azure client secret has expired",
+				Remediation: "create a new secret for the app registration",
+				Err:         assert.AnError,
+			}},
+		},
+	}
+
+	checks, _ := preflightProvider(context.Background(), cp)
+
+	assert.Equal(t, PreflightCheck{
+		Provider: "azure", Profile: "prod", Check: "Resource Graph",
+		Error: "the Azure client secret has expired",
+		Hint:  "Create a new secret for the app registration",
+	}, checks[1])
+}
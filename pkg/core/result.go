@@ -0,0 +1,160 @@
+package core
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/notify"
+	"github.com/hexiosec/asm-cloud-connector/internal/warnings"
+)
+
+// RunResult is the outcome of a run, the Lambda invocation response and the result embedders
+// consume. A run that failed before syncing has the summary of the failure and no providers.
+type RunResult struct {
+	// Providers are the resources discovered from each profile
+	Providers []ProviderResult `json:"providers,omitempty"`
+	// Scans are the outcome of syncing each scan and seed tag
+	Scans []notify.Summary `json:"scans,omitempty"`
+	// Added and Removed are the seeds added and removed across every scan
+	Added   int `json:"added,omitempty"`
+	Removed int `json:"removed,omitempty"`
+	// Warnings are issues that didn't fail the run, e.g. rejected resources, and WarningCounts the
+	// number of them of each category
+	Warnings      []Warning      `json:"warnings,omitempty"`
+	WarningCounts map[string]int `json:"warning_counts,omitempty"`
+}
+
+// Warning is an issue that didn't fail a run, with its category, e.g. "service_check"
+type Warning = warnings.Warning
+
+// ProviderResult is the number of resources discovered from a cloud provider profile, and the scan
+// they were synced to
+type ProviderResult struct {
+	Provider  string `json:"provider"`
+	Profile   string `json:"profile"`
+	ScanID    string `json:"scan_id"`
+	Resources int    `json:"resources"`
+	// Duration is how long discovering the resources of the profile took
+	Duration time.Duration `json:"-"`
+	// Services are the resources found by each service check that found any
+	Services []ServiceResult `json:"services,omitempty"`
+	// SlowestServices are the service checks that took longest, whether they found any resources or
+	// not, to tell which checks to disable or run with more concurrency when runs are too slow
+	SlowestServices []ServiceResult `json:"slowest_services,omitempty"`
+}
+
+// MarshalJSON sends the duration in seconds, rather than nanoseconds
+func (r ProviderResult) MarshalJSON() ([]byte, error) {
+	type providerResult ProviderResult
+	return json.Marshal(struct {
+		providerResult
+		DurationSeconds float64 `json:"duration_seconds"`
+	}{providerResult(r), r.Duration.Seconds()})
+}
+
+// ServiceResult is the number of resources a service check found in a location, e.g. an AWS region
+// or GCP project, empty if the service isn't checked by location, and how long it took
+type ServiceResult struct {
+	Service   string        `json:"service"`
+	Location  string        `json:"location,omitempty"`
+	Resources int           `json:"resources"`
+	Duration  time.Duration `json:"-"`
+}
+
+// MarshalJSON sends the duration in seconds, rather than nanoseconds
+func (r ServiceResult) MarshalJSON() ([]byte, error) {
+	type serviceResult ServiceResult
+	return json.Marshal(struct {
+		serviceResult
+		DurationSeconds float64 `json:"duration_seconds"`
+	}{serviceResult(r), r.Duration.Seconds()})
+}
+
+// slowestServices is the number of the slowest service checks of a profile in its result
+const slowestServices = 5
+
+// serviceCounter counts the resources found by each service check of a profile, and times them
+type serviceCounter struct {
+	mu     sync.Mutex
+	checks []ServiceResult
+}
+
+func (c *serviceCounter) Service(_ string, service string, location string, found int, elapsed time.Duration, _ error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, ServiceResult{Service: service, Location: location, Resources: found, Duration: elapsed})
+}
+
+func (c *serviceCounter) Discovered(string, int) {}
+
+func (c *serviceCounter) Seeds(string, int, int) {}
+
+// results returns the counts of each service check that found any resources, sorted by service
+// and location as service checks can run concurrently
+func (c *serviceCounter) results() []ServiceResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var results []ServiceResult
+	for _, check := range c.checks {
+		if check.Resources > 0 {
+			results = append(results, check)
+		}
+	}
+	slices.SortFunc(results, func(a, b ServiceResult) int {
+		return cmp.Or(cmp.Compare(a.Service, b.Service), cmp.Compare(a.Location, b.Location))
+	})
+	return results
+}
+
+// slowest returns the n service checks that took longest, slowest first
+func (c *serviceCounter) slowest(n int) []ServiceResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results := slices.Clone(c.checks)
+	slices.SortStableFunc(results, func(a, b ServiceResult) int {
+		return cmp.Or(cmp.Compare(b.Duration, a.Duration), cmp.Compare(a.Service, b.Service), cmp.Compare(a.Location, b.Location))
+	})
+	return results[:min(n, len(results))]
+}
+
+// add adds the outcome of syncing a target to the result
+func (r *RunResult) add(result syncResult, summary notify.Summary) {
+	r.Providers = append(r.Providers, result.providers...)
+	r.Scans = append(r.Scans, summary)
+	r.Added += summary.Added
+	r.Removed += summary.Removed
+
+	if summary.Rejected > 0 {
+		r.warn(warnings.RejectedSeeds, "%s: %d resources were rejected", summary.ScanID, summary.Rejected)
+	}
+	if summary.Failed > 0 {
+		r.warn(warnings.FailedSeeds, "%s: %d resources failed to be added", summary.ScanID, summary.Failed)
+	}
+	if summary.FailedRemovals > 0 {
+		r.warn(warnings.FailedRemovals, "%s: %d stale seeds failed to be removed", summary.ScanID, summary.FailedRemovals)
+	}
+	if summary.Overflow > 0 {
+		r.warn(warnings.Overflow, "%s: %d resources exceed the seed limit of the scan", summary.ScanID, summary.Overflow)
+	}
+}
+
+// merge adds the providers, scans and warnings of other to the result
+func (r *RunResult) merge(other RunResult) {
+	r.Providers = append(r.Providers, other.Providers...)
+	r.Scans = append(r.Scans, other.Scans...)
+	r.Added += other.Added
+	r.Removed += other.Removed
+	r.Warnings = append(r.Warnings, other.Warnings...)
+	r.WarningCounts = warnings.Counts(r.Warnings)
+}
+
+func (r *RunResult) warn(category string, format string, args ...any) {
+	r.Warnings = append(r.Warnings, warnings.New(category, format, args...))
+	r.WarningCounts = warnings.Counts(r.Warnings)
+}
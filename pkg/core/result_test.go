@@ -0,0 +1,106 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hexiosec/asm-cloud-connector/internal/connector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunResult_Add(t *testing.T) {
+	result := &RunResult{}
+	for _, r := range []syncResult{
+		{
+			scanID:    "scan-1",
+			providers: []ProviderResult{{Provider: "AWS", Profile: "prod", ScanID: "scan-1", Resources: 3}},
+			report: &connector.SyncReport{
+				Added:    []string{"a.example.com", "b.example.com"},
+				Removed:  []string{"old.example.com"},
+				Rejected: []connector.RejectedSeed{{Name: "bad", Reason: "invalid"}},
+			},
+		},
+		{
+			scanID:    "scan-2",
+			providers: []ProviderResult{{Provider: "GCP", Profile: "dev", ScanID: "scan-2", Resources: 1}},
+			report:    &connector.SyncReport{Added: []string{"c.example.com"}},
+		},
+	} {
+		result.add(r, newSummary(r, 0))
+	}
+
+	assert.Len(t, result.Providers, 2)
+	assert.Len(t, result.Scans, 2)
+	assert.Equal(t, 3, result.Added)
+	assert.Equal(t, 1, result.Removed)
+	assert.Equal(t, []Warning{{Category: "rejected_seeds", Message: "scan-1: 1 resources were rejected"}}, result.Warnings)
+	assert.Equal(t, map[string]int{"rejected_seeds": 1}, result.WarningCounts)
+}
+
+func TestRunResult_Merge(t *testing.T) {
+	first := Warning{Category: "service_check", Message: "first"}
+	second := Warning{Category: "overflow", Message: "second"}
+	result := RunResult{Added: 1, Warnings: []Warning{first}}
+
+	result.merge(RunResult{Added: 2, Removed: 1, Warnings: []Warning{second}})
+
+	assert.Equal(t, 3, result.Added)
+	assert.Equal(t, 1, result.Removed)
+	assert.Equal(t, []Warning{first, second}, result.Warnings)
+	assert.Equal(t, map[string]int{"service_check": 1, "overflow": 1}, result.WarningCounts)
+}
+
+func TestServiceCounter(t *testing.T) {
+	counter := &serviceCounter{}
+	counter.Service("AWS/prod", "S3", "eu-west-1", 2, 3*time.Second, nil)
+	counter.Service("AWS/prod", "EC2", "eu-west-2", 1, time.Second, nil)
+	counter.Service("AWS/prod", "EC2", "eu-west-1", 3, 2*time.Second, nil)
+	counter.Service("AWS/prod", "ELB", "eu-west-1", 0, 5*time.Second, nil)
+	counter.Service("AWS/prod", "RDS", "eu-west-1", 0, 4*time.Second, assert.AnError)
+
+	assert.Equal(t, []ServiceResult{
+		{Service: "EC2", Location: "eu-west-1", Resources: 3, Duration: 2 * time.Second},
+		{Service: "EC2", Location: "eu-west-2", Resources: 1, Duration: time.Second},
+		{Service: "S3", Location: "eu-west-1", Resources: 2, Duration: 3 * time.Second},
+	}, counter.results())
+
+	// Checks that found nothing are timed too
+	assert.Equal(t, []ServiceResult{
+		{Service: "ELB", Location: "eu-west-1", Duration: 5 * time.Second},
+		{Service: "RDS", Location: "eu-west-1", Duration: 4 * time.Second},
+		{Service: "S3", Location: "eu-west-1", Resources: 2, Duration: 3 * time.Second},
+	}, counter.slowest(3))
+}
+
+func TestProviderResult_MarshalJSON_DurationInSeconds(t *testing.T) {
+	out, err := json.Marshal(ProviderResult{
+		Provider:        "aws",
+		Profile:         "prod",
+		Duration:        90 * time.Second,
+		SlowestServices: []ServiceResult{{Service: "EC2", Location: "eu-west-1", Resources: 3, Duration: 1500 * time.Millisecond}},
+	})
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"provider": "aws",
+		"profile": "prod",
+		"scan_id": "",
+		"resources": 0,
+		"duration_seconds": 90,
+		"slowest_services": [{"service": "EC2", "location": "eu-west-1", "resources": 3, "duration_seconds": 1.5}]
+	}`, string(out))
+}
+
+func TestRunResult_Add_WarnsOfFailedRemovals(t *testing.T) {
+	result := &RunResult{}
+	r := syncResult{
+		scanID: "scan-1",
+		report: &connector.SyncReport{FailedRemovals: []connector.FailedSeed{{Name: "old.example.com", Error: "denied"}}},
+	}
+
+	result.add(r, newSummary(r, 0))
+
+	assert.Equal(t, []Warning{{Category: "failed_removals", Message: "scan-1: 1 stale seeds failed to be removed"}}, result.Warnings)
+}